@@ -0,0 +1,209 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/config"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/service/node"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/logs"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/logs/colors"
+)
+
+// 子命令退出码
+const (
+	exitOK    = 0 // 执行成功
+	exitFail  = 1 // 检查未通过
+	exitUsage = 2 // 命令使用错误
+)
+
+// commands 支持的子命令, 用于部署脚本中校验配置及节点
+var commands = []struct {
+	name  string
+	usage string
+	run   func(cfgPath string, args []string) int
+}{
+	{"check", "check", runCheck},
+	{"config", "config", runConfig},
+	{"map", "map <emby-path>", runMap},
+	{"nodes", "nodes", runNodes},
+}
+
+// usage 输出命令行帮助信息
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "用法: %s [参数] [子命令]\n\n子命令:\n", filepath.Base(os.Args[0]))
+	fmt.Fprintln(out, "  check                 校验配置文件, 输出所有错误及其所在行号")
+	fmt.Fprintln(out, "  config                输出应用环境变量及密钥文件后实际生效的配置, 敏感配置脱敏显示")
+	fmt.Fprintln(out, "  map <emby-path>       查看 emby 路径命中的 emby2nginx 映射及各节点上的 nginx 路径")
+	fmt.Fprintln(out, "  nodes                 探测所有节点一次并输出健康状态")
+	fmt.Fprintln(out, "\n不指定子命令时启动服务, 子命令执行失败时以非 0 状态码退出\n\n参数:")
+	flag.PrintDefaults()
+}
+
+// runCommand 执行子命令, 返回进程退出码
+func runCommand(dataRoot string, args []string) int {
+	cfgPath := filepath.Join(dataRoot, "config.yml")
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			return cmd.run(cfgPath, args[1:])
+		}
+	}
+	fmt.Fprintf(os.Stderr, "未知的子命令: %s\n\n", args[0])
+	usage()
+	return exitUsage
+}
+
+// runCheck 校验配置文件
+func runCheck(cfgPath string, args []string) int {
+	if len(args) != 0 {
+		fmt.Fprintln(os.Stderr, "用法: check")
+		return exitUsage
+	}
+
+	problems, err := config.Check(cfgPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, colors.ToRed(err.Error()))
+		return exitFail
+	}
+
+	errCnt, warnCnt := 0, 0
+	for _, p := range problems {
+		if p.Warn {
+			warnCnt++
+			fmt.Printf("%s:%s\n", cfgPath, colors.ToYellow(" [警告] "+p.String()))
+			continue
+		}
+		errCnt++
+		fmt.Printf("%s:%s\n", cfgPath, colors.ToRed(" [错误] "+p.String()))
+	}
+
+	if errCnt > 0 {
+		fmt.Println(colors.ToRed(fmt.Sprintf("配置校验未通过: %d 个错误, %d 个警告", errCnt, warnCnt)))
+		return exitFail
+	}
+	fmt.Println(colors.ToGreen(fmt.Sprintf("配置校验通过: %d 个警告", warnCnt)))
+	return exitOK
+}
+
+// runConfig 输出实际生效的配置
+func runConfig(cfgPath string, args []string) int {
+	if len(args) != 0 {
+		fmt.Fprintln(os.Stderr, "用法: config")
+		return exitUsage
+	}
+
+	// 输出内容可直接重定向到文件, 加载配置时只输出错误日志
+	logs.SetLevel("", logs.LevelError)
+	if err := config.ReadFromFile(cfgPath); err != nil {
+		fmt.Fprintln(os.Stderr, colors.ToRed(err.Error()))
+		return exitFail
+	}
+
+	data, err := config.EffectiveYaml()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, colors.ToRed(err.Error()))
+		return exitFail
+	}
+	os.Stdout.Write(data)
+	return exitOK
+}
+
+// runMap 查看 emby 路径的映射结果
+func runMap(cfgPath string, args []string) int {
+	if len(args) != 1 || strings.TrimSpace(args[0]) == "" {
+		fmt.Fprintln(os.Stderr, "用法: map <emby-path>")
+		return exitUsage
+	}
+	if err := config.ReadFromFile(cfgPath); err != nil {
+		fmt.Fprintln(os.Stderr, colors.ToRed(err.Error()))
+		return exitFail
+	}
+
+	embyPath := args[0]
+	idx, nginxPath, ok := config.C().Path.MatchEmby2Nginx(embyPath)
+	if !ok {
+		fmt.Println(colors.ToRed("未命中任何 path.emby2nginx 映射: " + embyPath))
+		return exitFail
+	}
+
+	key := fmt.Sprintf("path.emby2nginx[%d]", idx)
+	if file, line := config.LocateKey(key); line > 0 {
+		key = fmt.Sprintf("%s (%s 第 %d 行)", key, file, line)
+	}
+	fmt.Printf("命中映射: %s %s\n", key, config.C().Path.Emby2Nginx[idx])
+	fmt.Printf("nginx 路径: %s\n", colors.ToGreen(nginxPath))
+
+	if len(config.C().Nodes.List) == 0 {
+		fmt.Println(colors.ToYellow("未配置任何节点"))
+		return exitOK
+	}
+	fmt.Println("各节点地址:")
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, n := range config.C().Nodes.List {
+		state := ""
+		if !n.Enabled {
+			state = colors.ToGray("(已禁用)")
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\n", n.Name, strings.TrimSuffix(n.Host, "/")+nginxPath, state)
+	}
+	w.Flush()
+	return exitOK
+}
+
+// runNodes 探测所有节点一次
+func runNodes(cfgPath string, args []string) int {
+	if len(args) != 0 {
+		fmt.Fprintln(os.Stderr, "用法: nodes")
+		return exitUsage
+	}
+	if err := config.ReadFromFile(cfgPath); err != nil {
+		fmt.Fprintln(os.Stderr, colors.ToRed(err.Error()))
+		return exitFail
+	}
+
+	results := node.ProbeAll(config.C().Nodes)
+	if len(results) == 0 {
+		fmt.Println(colors.ToRed("未配置任何节点"))
+		return exitFail
+	}
+
+	enabled, failed := 0, 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "节点\t健康检查地址\t状态\t耗时")
+	for _, r := range results {
+		latency := r.Latency.Round(time.Millisecond).String()
+		switch {
+		case !r.Enabled:
+			state := "已禁用"
+			if !r.Healthy() {
+				state += ", 不可用"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Name, r.URL, colors.ToGray(state), latency)
+		case r.Healthy():
+			enabled++
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Name, r.URL, colors.ToGreen("健康"), latency)
+		default:
+			enabled++
+			failed++
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Name, r.URL, colors.ToRed("不健康: "+r.Err.Error()), latency)
+		}
+	}
+	w.Flush()
+
+	if enabled == 0 {
+		fmt.Println(colors.ToRed("没有已启用的节点"))
+		return exitFail
+	}
+	if failed > 0 {
+		fmt.Println(colors.ToRed(fmt.Sprintf("%d/%d 个已启用节点不健康", failed, enabled)))
+		return exitFail
+	}
+	fmt.Println(colors.ToGreen(fmt.Sprintf("%d 个已启用节点全部健康", enabled)))
+	return exitOK
+}
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// adminTokenMinLen 管理接口令牌的最小长度
+const adminTokenMinLen = 16
+
+// AdminApi 主服务上的管理接口配置
+type AdminApi struct {
+	Enable   bool   `yaml:"enable"`              // 是否启用
+	Token    string `yaml:"token" secret:"true"` // 访问令牌, 通过 Authorization: Bearer <token> 或 X-Admin-Token 请求头传递
+	ClientCa string `yaml:"client-ca"`           // 校验客户端证书 (mTLS) 的 CA 证书名称, 位于 ssl 目录下, 仅 HTTPS 端口生效
+}
+
+// Init 配置初始化
+func (a *AdminApi) Init() error {
+	if !a.Enable {
+		return nil
+	}
+	if a.Token == "" && a.ClientCa == "" {
+		return errors.New("启用管理接口时 admin-api.token 及 admin-api.client-ca 至少需要配置一项")
+	}
+	if a.Token != "" && len(a.Token) < adminTokenMinLen {
+		return fmt.Errorf("admin-api.token 长度不能少于 %d 位", adminTokenMinLen)
+	}
+	if a.ClientCa != "" {
+		if _, err := a.ClientCaPool(); err != nil {
+			return fmt.Errorf("admin-api.client-ca 配置错误: %v", err)
+		}
+	}
+	return nil
+}
+
+// ClientCaPath 获取客户端 CA 证书的绝对路径
+func (a *AdminApi) ClientCaPath() string {
+	return filepath.Join(BasePath, SslDir, a.ClientCa)
+}
+
+// ClientCaPool 读取客户端 CA 证书
+func (a *AdminApi) ClientCaPool() (*x509.CertPool, error) {
+	bytes, err := os.ReadFile(a.ClientCaPath())
+	if err != nil {
+		return nil, fmt.Errorf("读取 CA 证书失败: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(bytes) {
+		return nil, errors.New("CA 证书中没有有效的 PEM 证书")
+	}
+	return pool, nil
+}
@@ -0,0 +1,31 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAdminApiInit(t *testing.T) {
+	tests := []struct {
+		name string
+		api  AdminApi
+		err  string
+	}{
+		{"未启用", AdminApi{}, ""},
+		{"令牌", AdminApi{Enable: true, Token: "0123456789abcdef"}, ""},
+		{"未配置鉴权", AdminApi{Enable: true}, "至少需要配置一项"},
+		{"令牌过短", AdminApi{Enable: true, Token: "short"}, "长度不能少于"},
+		{"CA 证书不存在", AdminApi{Enable: true, ClientCa: "missing-ca.crt"}, "admin-api.client-ca"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.api.Init()
+			if tt.err == "" && err != nil {
+				t.Errorf("不应报错: %v", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Errorf("错误信息应包含 %q, 实际: %v", tt.err, err)
+			}
+		})
+	}
+}
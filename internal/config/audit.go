@@ -0,0 +1,29 @@
+package config
+
+import "fmt"
+
+// Audit 重定向审计日志配置
+type Audit struct {
+	Enable     bool       `yaml:"enable"`      // 是否记录审计日志
+	Path       string     `yaml:"path"`        // 日志文件路径, 默认 ./logs/redirect-audit.log
+	BufferSize int        `yaml:"buffer-size"` // 缓冲区大小, 默认 1000
+	Blocking   bool       `yaml:"blocking"`    // 缓冲区满时阻塞等待, 默认丢弃
+	Rotate     *LogRotate `yaml:"rotate"`      // 日志轮转配置
+}
+
+// Init 配置初始化
+func (a *Audit) Init() error {
+	if a.Path == "" {
+		a.Path = "./logs/redirect-audit.log"
+	}
+	if a.BufferSize <= 0 {
+		a.BufferSize = 1000
+	}
+	if a.Rotate == nil {
+		a.Rotate = new(LogRotate)
+	}
+	if err := a.Rotate.Init(); err != nil {
+		return fmt.Errorf("audit.rotate %v", err)
+	}
+	return nil
+}
@@ -1,15 +1,98 @@
 package config
 
-import "time"
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// VideoAuthSecretFile 未配置 auth.video-auth-secret 时自动生成的签名密钥文件, 位于数据根目录下
+const VideoAuthSecretFile = "video-auth.secret"
 
 // Auth 鉴权配置
 type Auth struct {
 	UserKeyCacheTTL time.Duration `yaml:"user-key-cache-ttl"`
 	NginxAuthEnable bool          `yaml:"nginx-auth-enable"`
 
+	// UserIdentityTTL 令牌解析出的用户身份及权限策略缓存时间, 过期后重新向 Emby 校验
+	UserIdentityTTL time.Duration `yaml:"user-identity-ttl"`
+
+	// VideoAuthSecret 视频鉴权临时 URL 的签名密钥, 未配置时首次启动自动生成并保存到数据根目录下的 video-auth.secret 文件
+	VideoAuthSecret string `yaml:"video-auth-secret" secret:"true"`
+
 	// 鉴权服务器配置
 	EnableAuthServer    bool   `yaml:"enable-auth-server"`     // 是否启用鉴权服务器
 	AuthServerPort      string `yaml:"auth-server-port"`       // 鉴权服务器端口
 	EnableAuthServerLog bool   `yaml:"enable-auth-server-log"` // 是否启用访问日志
 	AuthServerLogPath   string `yaml:"auth-server-log-path"`   // 访问日志路径
+
+	// AuthServerLogRotate 访问日志轮转配置
+	AuthServerLogRotate *LogRotate `yaml:"auth-server-log-rotate"`
+	// AuthServerLogBufferSize 访问日志缓冲区大小, 默认 1000
+	AuthServerLogBufferSize int `yaml:"auth-server-log-buffer-size"`
+	// AuthServerLogBlocking 缓冲区满时阻塞等待写入, 默认丢弃日志以保证鉴权响应速度
+	AuthServerLogBlocking bool `yaml:"auth-server-log-blocking"`
+
+	// generatedSecret VideoAuthSecret 是否为自动生成
+	generatedSecret bool
+}
+
+// Init 配置初始化
+func (a *Auth) Init() error {
+	if a.UserIdentityTTL <= 0 {
+		// 默认 10 分钟重新校验一次用户权限
+		a.UserIdentityTTL = time.Minute * 10
+	}
+	if a.AuthServerLogBufferSize <= 0 {
+		a.AuthServerLogBufferSize = 1000
+	}
+	if a.AuthServerLogRotate == nil {
+		a.AuthServerLogRotate = new(LogRotate)
+	}
+	if err := a.AuthServerLogRotate.Init(); err != nil {
+		return fmt.Errorf("auth.auth-server-log-rotate %v", err)
+	}
+	return nil
+}
+
+// Apply 配置生效
+func (a *Auth) Apply() error {
+	if a.VideoAuthSecret = strings.TrimSpace(a.VideoAuthSecret); a.VideoAuthSecret != "" {
+		return nil
+	}
+	secret, err := loadOrGenerateSecret(filepath.Join(BasePath, VideoAuthSecretFile))
+	if err != nil {
+		return fmt.Errorf("auth.video-auth-secret 未配置, 自动生成签名密钥失败: %v", err)
+	}
+	a.VideoAuthSecret, a.generatedSecret = secret, true
+	return nil
+}
+
+// loadOrGenerateSecret 读取密钥文件, 文件不存在时生成随机密钥并写入,
+// 保证程序重启后之前签发的临时 URL 依然有效
+func loadOrGenerateSecret(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		if secret := strings.TrimSpace(string(data)); secret != "" {
+			return secret, nil
+		}
+		return "", fmt.Errorf("密钥文件 %s 内容为空", path)
+	}
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	secret := hex.EncodeToString(buf)
+	if err := os.WriteFile(path, []byte(secret+"\n"), 0600); err != nil {
+		return "", err
+	}
+	return secret, nil
 }
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVideoAuthSecret(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yml")
+	writeTestConfig(t, path, testReloadConfig)
+	if err := ReadFromFile(path); err != nil {
+		t.Fatalf("读取配置失败: %v", err)
+	}
+
+	// 未配置时自动生成并保存
+	secret := C().Auth.VideoAuthSecret
+	if len(secret) != 64 {
+		t.Fatalf("应自动生成签名密钥, 实际: %q", secret)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, VideoAuthSecretFile))
+	if err != nil || strings.TrimSpace(string(data)) != secret {
+		t.Fatalf("签名密钥未保存: %q %v", data, err)
+	}
+
+	// 重启后沿用已保存的密钥, 保存配置时不写入配置文件
+	if err := ReadFromFile(path); err != nil {
+		t.Fatalf("读取配置失败: %v", err)
+	}
+	if C().Auth.VideoAuthSecret != secret {
+		t.Errorf("重启后签名密钥发生变化: %q => %q", secret, C().Auth.VideoAuthSecret)
+	}
+	if err := SaveToFile(); err != nil {
+		t.Fatalf("保存配置失败: %v", err)
+	}
+	if content, _ := os.ReadFile(path); strings.Contains(string(content), secret) {
+		t.Errorf("自动生成的签名密钥不应写入配置文件:\n%s", content)
+	}
+
+	// 配置后优先使用配置的密钥
+	writeTestConfig(t, path, testReloadConfig+"auth:\n  video-auth-secret: from-config\n")
+	if err := ReadFromFile(path); err != nil {
+		t.Fatalf("读取配置失败: %v", err)
+	}
+	if C().Auth.VideoAuthSecret != "from-config" {
+		t.Errorf("未使用配置的签名密钥, 实际: %q", C().Auth.VideoAuthSecret)
+	}
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"path/filepath"
 	"strconv"
 	"time"
 )
@@ -14,10 +15,102 @@ var durationMap = map[string]time.Duration{
 	"s": time.Second,
 }
 
+const (
+	// DefaultCacheMaxEntries 默认最多缓存的响应个数
+	DefaultCacheMaxEntries = 8092
+
+	// DefaultCacheMaxSize 默认的缓存大小上限 (MB)
+	DefaultCacheMaxSize = 100
+)
+
 type Cache struct {
-	Enable  bool          `yaml:"enable"`  // 是否启用缓存
-	Expired string        `yaml:"expired"` // 缓存过期时间
-	expired time.Duration // 配置初始化转换之后的标准时间对象
+	Enable     bool          `yaml:"enable"`      // 是否启用缓存
+	Expired    string        `yaml:"expired"`     // 缓存过期时间
+	MaxEntries int           `yaml:"max-entries"` // 最多缓存的响应个数, 默认 8092
+	MaxSize    int           `yaml:"max-size"`    // 缓存的响应体总大小上限 (MB), 默认 100
+	Disk       *CacheDisk    `yaml:"disk"`        // 磁盘缓存配置
+	Rules      []*CacheRule  `yaml:"rules"`       // 缓存规则, 未配置时使用默认规则
+	expired    time.Duration // 配置初始化转换之后的标准时间对象
+}
+
+// CacheDisk 磁盘缓存配置
+//
+// 开启后, 剩余有效期足够长的缓存会同步写入磁盘, 程序重启后按需加载
+type CacheDisk struct {
+	Enable  bool          `yaml:"enable"`   // 是否启用磁盘缓存
+	Dir     string        `yaml:"dir"`      // 缓存目录, 相对路径基于数据目录, 默认 cache
+	MaxSize int           `yaml:"max-size"` // 磁盘缓存总大小上限 (MB), 默认 1024
+	MinTTL  time.Duration `yaml:"min-ttl"`  // 剩余有效期超过该值的缓存才写入磁盘, 默认 30m
+}
+
+// Init 配置初始化
+func (d *CacheDisk) Init() error {
+	if d.Dir == "" {
+		d.Dir = "cache"
+	}
+	if d.MaxSize == 0 {
+		d.MaxSize = 1024
+	}
+	if d.MaxSize < 0 {
+		return fmt.Errorf("cache.disk.max-size 配置错误: %d, 值需大于 0", d.MaxSize)
+	}
+	if d.MinTTL == 0 {
+		d.MinTTL = time.Minute * 30
+	}
+	if d.MinTTL < 0 {
+		return fmt.Errorf("cache.disk.min-ttl 配置错误: %v, 值不能小于 0", d.MinTTL)
+	}
+	return nil
+}
+
+// Path 磁盘缓存目录的绝对路径
+func (d *CacheDisk) Path() string {
+	if filepath.IsAbs(d.Dir) {
+		return d.Dir
+	}
+	return filepath.Join(BasePath, d.Dir)
+}
+
+// MaxBytes 磁盘缓存总大小上限 (Byte)
+func (d *CacheDisk) MaxBytes() int64 {
+	return int64(d.MaxSize) * 1024 * 1024
+}
+
+// MaxBytes 缓存的响应体总大小上限 (Byte)
+func (c *Cache) MaxBytes() int64 {
+	return int64(c.MaxSize) * 1024 * 1024
+}
+
+// parseCacheDuration 解析缓存时间配置, 如 30s, 10m, 12h, 30d
+func parseCacheDuration(s string) (time.Duration, error) {
+	timeFlag := s[len(s)-1:]
+	duration, ok := durationMap[timeFlag]
+	if !ok {
+		return 0, fmt.Errorf("%s, 支持的时间单位: s, m, h, d", s)
+	}
+	base, err := strconv.Atoi(s[:len(s)-1])
+	if err != nil {
+		return 0, err
+	}
+	if base < 1 {
+		return 0, fmt.Errorf("%d, 值需大于 0", base)
+	}
+	return time.Duration(base) * duration, nil
+}
+
+// MatchRule 获取请求地址匹配的缓存规则, 按配置顺序取第一个匹配的规则
+//
+// 没有匹配的规则, 或匹配的规则被禁用时返回 nil, 表示请求不缓存
+func (c *Cache) MatchRule(uri string) *CacheRule {
+	for _, rule := range c.Rules {
+		if rule.Match(uri) {
+			if !rule.Enabled() {
+				return nil
+			}
+			return rule
+		}
+	}
+	return nil
 }
 
 func (c *Cache) ExpiredDuration() time.Duration {
@@ -25,23 +118,43 @@ func (c *Cache) ExpiredDuration() time.Duration {
 }
 
 func (c *Cache) Init() error {
+	if c.MaxEntries == 0 {
+		c.MaxEntries = DefaultCacheMaxEntries
+	}
+	if c.MaxEntries < 0 {
+		return fmt.Errorf("cache.max-entries 配置错误: %d, 值需大于 0", c.MaxEntries)
+	}
+	if c.MaxSize == 0 {
+		c.MaxSize = DefaultCacheMaxSize
+	}
+	if c.MaxSize < 0 {
+		return fmt.Errorf("cache.max-size 配置错误: %d, 值需大于 0", c.MaxSize)
+	}
+	if c.Disk == nil {
+		c.Disk = new(CacheDisk)
+	}
+	if err := c.Disk.Init(); err != nil {
+		return err
+	}
+
 	if len(c.Expired) == 0 {
 		// 缓存默认过期时间一天
 		c.expired = time.Hour * 24
 	} else {
-		timeFlag := c.Expired[len(c.Expired)-1:]
-		duration, ok := durationMap[timeFlag]
-		if !ok {
-			return fmt.Errorf("cache.expired 配置错误: %s, 支持的时间单位: s, m, h, d", timeFlag)
-		}
-		base, err := strconv.Atoi(c.Expired[:len(c.Expired)-1])
+		expired, err := parseCacheDuration(c.Expired)
 		if err != nil {
 			return fmt.Errorf("cache.expired 配置错误: %v", err)
 		}
-		if base < 1 {
-			return fmt.Errorf("cache.exipred 配置错误: %d, 值需大于 0", base)
+		c.expired = expired
+	}
+
+	if c.Rules == nil {
+		c.Rules = DefaultCacheRules()
+	}
+	for i, rule := range c.Rules {
+		if err := rule.Init(); err != nil {
+			return fmt.Errorf("cache.rules[%d] 配置错误: %v", i, err)
 		}
-		c.expired = time.Duration(base) * duration
 	}
 
 	return nil
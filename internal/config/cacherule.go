@@ -0,0 +1,147 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/constant"
+)
+
+// 缓存 key 的用户范围
+const (
+	// CacheScopeToken 按客户端令牌区分缓存, 请求中的所有参数及请求头都参与缓存 key 计算
+	CacheScopeToken = "token"
+
+	// CacheScopeUser 按 Emby 用户区分缓存, 同一用户的不同令牌 (设备) 共享缓存
+	CacheScopeUser = "user"
+
+	// CacheScopeShared 所有用户共享缓存, 仅适用于与用户无关的接口
+	CacheScopeShared = "shared"
+)
+
+// CacheRule 路由缓存规则
+type CacheRule struct {
+	Name           string   `yaml:"name"`            // 规则名称, 用于日志及管理接口展示
+	Pattern        string   `yaml:"pattern"`         // 匹配请求地址 (含参数) 的正则表达式
+	TTL            string   `yaml:"ttl"`             // 缓存时间, 如 30s, 10m, 12h, 30d, 为空时使用 cache.expired
+	Enable         *bool    `yaml:"enable"`          // 是否启用, 默认启用, 禁用后匹配的请求不缓存
+	Scope          string   `yaml:"scope"`           // 用户范围: token (默认) / user / shared
+	IncludeParams  []string `yaml:"include-params"`  // 只有这些参数参与缓存 key 计算, 为空表示全部参数
+	ExcludeParams  []string `yaml:"exclude-params"`  // 不参与缓存 key 计算的参数
+	IncludeHeaders []string `yaml:"include-headers"` // 只有这些请求头参与缓存 key 计算, 为空表示全部请求头
+	ExcludeHeaders []string `yaml:"exclude-headers"` // 不参与缓存 key 计算的请求头
+
+	// IgnoreParams 从请求中去除且不参与缓存 key 计算的请求头及参数, 如播放会话 id、客户端 IP 等每次请求都会变化的值,
+	// 未配置时使用 DefaultCacheIgnoreParams, 配置后覆盖默认列表, 配置为 [] 表示不忽略任何请求头及参数
+	IgnoreParams []string `yaml:"ignore-params"`
+
+	reg *regexp.Regexp
+	ttl time.Duration
+}
+
+// DefaultCacheIgnoreParams 默认忽略的请求头及参数, 规则未配置 ignore-params 时使用
+func DefaultCacheIgnoreParams() []string {
+	return []string{
+		// Fileball
+		"StartTimeTicks", "X-Playback-Session-Id",
+
+		// Emby
+		"PlaySessionId",
+
+		// Common
+		"Range", "Host", "Referrer", "Connection",
+		"Accept", "Accept-Encoding", "Accept-Language", "Cache-Control",
+		"Upgrade-Insecure-Requests", "Referer", "Origin",
+
+		// StreamMusic
+		"X-Streammusic-Audioid", "X-Streammusic-Savepath",
+
+		// IP
+		"X-Forwarded-For", "X-Real-IP", "Forwarded", "Client-IP",
+		"True-Client-IP", "CF-Connecting-IP", "X-Cluster-Client-IP",
+		"Fastly-Client-IP", "X-Client-IP", "X-ProxyUser-IP",
+		"Via", "Forwarded-For", "X-From-Cdn",
+	}
+}
+
+// DefaultCacheRules 默认的缓存规则, 与未支持规则配置前的行为一致
+func DefaultCacheRules() []*CacheRule {
+	return []*CacheRule{
+		{Name: "playback-info", Pattern: constant.Reg_PlaybackInfo, TTL: "12h"},
+		{Name: "subtitles", Pattern: constant.Reg_VideoSubtitles, TTL: "30d"},
+		{Name: "stream", Pattern: constant.Reg_ResourceStream, TTL: "10m"},
+		{Name: "download", Pattern: constant.Reg_ItemDownload, TTL: "10m"},
+		{Name: "sync-download", Pattern: constant.Reg_ItemSyncDownload, TTL: "10m"},
+		{Name: "random-items", Pattern: constant.Reg_UserItemsRandomWithLimit, TTL: "3h"},
+	}
+}
+
+// Init 配置初始化
+func (r *CacheRule) Init() error {
+	if r.Pattern == "" {
+		return errors.New("pattern 不能为空")
+	}
+	reg, err := regexp.Compile(r.Pattern)
+	if err != nil {
+		return fmt.Errorf("pattern 正则表达式错误: %v", err)
+	}
+	r.reg = reg
+	if r.Name == "" {
+		r.Name = r.Pattern
+	}
+
+	if r.TTL != "" {
+		if r.ttl, err = parseCacheDuration(r.TTL); err != nil {
+			return fmt.Errorf("ttl 配置错误: %v", err)
+		}
+	}
+
+	r.Scope = strings.ToLower(strings.TrimSpace(r.Scope))
+	switch r.Scope {
+	case "":
+		r.Scope = CacheScopeToken
+	case CacheScopeToken, CacheScopeUser, CacheScopeShared:
+	default:
+		return fmt.Errorf("scope 配置错误: %s, 可选值: token, user, shared", r.Scope)
+	}
+
+	for _, headers := range [][]string{r.IncludeHeaders, r.ExcludeHeaders} {
+		for i, h := range headers {
+			headers[i] = http.CanonicalHeaderKey(strings.TrimSpace(h))
+		}
+	}
+	for i, p := range r.IgnoreParams {
+		r.IgnoreParams[i] = strings.TrimSpace(p)
+	}
+	return nil
+}
+
+// IgnoredParams 实际生效的忽略列表, 规则为空或未配置 ignore-params 时返回默认列表
+func (r *CacheRule) IgnoredParams() []string {
+	if r == nil || r.IgnoreParams == nil {
+		return DefaultCacheIgnoreParams()
+	}
+	return r.IgnoreParams
+}
+
+// Enabled 规则是否启用
+func (r *CacheRule) Enabled() bool {
+	return r.Enable == nil || *r.Enable
+}
+
+// Match 判断请求地址是否匹配规则
+func (r *CacheRule) Match(uri string) bool {
+	return r.reg != nil && r.reg.MatchString(uri)
+}
+
+// TTLDuration 缓存时间, 规则为空或未配置时返回 0
+func (r *CacheRule) TTLDuration() time.Duration {
+	if r == nil {
+		return 0
+	}
+	return r.ttl
+}
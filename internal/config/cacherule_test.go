@@ -0,0 +1,108 @@
+package config
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestCacheMatchRule(t *testing.T) {
+	disable := false
+	c := &Cache{Expired: "1d", Rules: []*CacheRule{
+		{Name: "no-subtitles", Pattern: `(?i)^/.*videos/.*/subtitles`, Enable: &disable},
+		{Name: "next-up", Pattern: `(?i)^/.*shows/nextup`, TTL: "30s", Scope: "User", ExcludeHeaders: []string{"x-emby-client"}},
+		{Pattern: `(?i)^/.*items/.*/playbackinfo\??`},
+	}}
+	if err := c.Init(); err != nil {
+		t.Fatalf("初始化配置失败: %v", err)
+	}
+
+	if rule := c.MatchRule("/emby/videos/1/subtitles/1/stream.srt"); rule != nil {
+		t.Errorf("禁用的规则不应匹配, 实际匹配: %s", rule.Name)
+	}
+	if rule := c.MatchRule("/emby/System/Info"); rule != nil {
+		t.Errorf("未配置的路由不应匹配, 实际匹配: %s", rule.Name)
+	}
+
+	rule := c.MatchRule("/emby/Shows/NextUp?UserId=1")
+	if rule == nil || rule.Name != "next-up" {
+		t.Fatalf("期望匹配 next-up 规则, 实际: %v", rule)
+	}
+	if rule.TTLDuration() != 30*time.Second {
+		t.Errorf("ttl 解析错误, 期望: 30s, 实际: %v", rule.TTLDuration())
+	}
+	if rule.Scope != CacheScopeUser {
+		t.Errorf("scope 应统一为小写, 实际: %s", rule.Scope)
+	}
+	if rule.ExcludeHeaders[0] != "X-Emby-Client" {
+		t.Errorf("请求头应规范化, 实际: %s", rule.ExcludeHeaders[0])
+	}
+
+	rule = c.MatchRule("/emby/Items/1/PlaybackInfo?api_key=x")
+	if rule == nil || rule.Scope != CacheScopeToken || rule.TTLDuration() != 0 {
+		t.Errorf("默认规则属性错误: %+v", rule)
+	}
+	if rule.Name != rule.Pattern {
+		t.Errorf("未配置名称时应使用 pattern 作为名称, 实际: %s", rule.Name)
+	}
+}
+
+func TestCacheRuleInitError(t *testing.T) {
+	tests := []struct {
+		name string
+		rule CacheRule
+	}{
+		{name: "空 pattern", rule: CacheRule{}},
+		{name: "非法正则", rule: CacheRule{Pattern: "("}},
+		{name: "非法 ttl", rule: CacheRule{Pattern: "/", TTL: "10x"}},
+		{name: "非法 scope", rule: CacheRule{Pattern: "/", Scope: "device"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.rule.Init(); err == nil {
+				t.Errorf("期望初始化失败")
+			}
+		})
+	}
+}
+
+func TestCacheDefaultRules(t *testing.T) {
+	c := &Cache{Expired: "1d"}
+	if err := c.Init(); err != nil {
+		t.Fatalf("初始化配置失败: %v", err)
+	}
+	rule := c.MatchRule("/emby/videos/1/stream.mkv?api_key=x")
+	if rule == nil || rule.TTLDuration() != 10*time.Minute {
+		t.Errorf("默认串流规则错误: %+v", rule)
+	}
+}
+
+func TestCacheRuleIgnoreParams(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	writeTestConfig(t, path, testReloadConfig+`cache:
+  rules:
+    - name: default
+      pattern: '^/a'
+    - name: custom
+      pattern: '^/b'
+      ignore-params: [" t ", PlaySessionId]
+    - name: none
+      pattern: '^/c'
+      ignore-params: []
+`)
+	if err := ReadFromFile(path); err != nil {
+		t.Fatalf("读取配置失败: %v", err)
+	}
+
+	rules := C().Cache.Rules
+	if !slices.Equal(rules[0].IgnoredParams(), DefaultCacheIgnoreParams()) {
+		t.Errorf("未配置时应使用默认列表, 实际: %v", rules[0].IgnoredParams())
+	}
+	if !slices.Equal(rules[1].IgnoredParams(), []string{"t", "PlaySessionId"}) {
+		t.Errorf("配置的列表应覆盖默认列表, 实际: %v", rules[1].IgnoredParams())
+	}
+	if got := rules[2].IgnoredParams(); got == nil || len(got) != 0 {
+		t.Errorf("配置为空列表时不应忽略任何参数, 实际: %v", got)
+	}
+}
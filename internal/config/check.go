@@ -0,0 +1,286 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Problem 配置校验发现的问题
+type Problem struct {
+	File string // 所在的引入配置文件, 相对数据根目录, 为空表示主配置文件
+	Line int    // 所在行, 0 表示无法定位
+	Key  string // 相关的配置项, 如 emby.host, 可能为空
+	Msg  string // 问题描述
+	Warn bool   // 是否仅为警告, 警告不影响程序运行
+}
+
+// String 格式化输出
+func (p Problem) String() string {
+	pos := p.File
+	if p.Line > 0 {
+		pos = strings.TrimSpace(fmt.Sprintf("%s 第 %d 行", pos, p.Line))
+	}
+	if p.Key != "" {
+		pos = strings.TrimSpace(pos + " " + p.Key)
+	}
+	if pos == "" {
+		return p.Msg
+	}
+	return pos + ": " + p.Msg
+}
+
+var (
+	// reYamlLine yaml 错误信息中的行号
+	reYamlLine = regexp.MustCompile(`line (\d+)`)
+
+	// reKeyPath 错误信息中的配置项路径, 如 cache.rules[0]
+	reKeyPath = regexp.MustCompile(`[a-z][a-z0-9-]*(\[\d+\])?(\.[a-z0-9][a-z0-9-]*(\[\d+\])?)*`)
+)
+
+// Check 校验配置文件, 返回发现的所有问题, 不影响当前生效的配置
+//
+// 合并引入的配置文件并应用环境变量及密钥文件覆盖后, 依次执行所有配置项的初始化校验, 某一项出错时继续校验其余配置项;
+// 未知的配置项 (通常是拼写错误) 及无法对应到配置项的环境变量作为警告返回
+func Check(path string) ([]Problem, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取配置文件失败: %v", err)
+	}
+	if err := initBasePath(path); err != nil {
+		return nil, fmt.Errorf("初始化 BasePath 失败: %v", err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(raw, &doc); err != nil {
+		return []Problem{{Line: yamlErrorLine(err.Error()), Msg: err.Error()}}, nil
+	}
+
+	// 引入的配置文件、环境变量或密钥文件有误
+	d, err := parseConfig(path, raw, os.ReadFile)
+	if err != nil {
+		return []Problem{{Msg: err.Error()}}, nil
+	}
+
+	problems := d.unknownFields(d.root, configType, nil)
+	if _, unknown := envOverrides(os.Environ()); len(unknown) > 0 {
+		for _, name := range unknown {
+			problems = append(problems, Problem{Key: name, Msg: "环境变量不对应任何配置项", Warn: true})
+		}
+	}
+
+	// 逐个文件校验类型以定位到具体文件, 合并后的配置出错时 (如不同文件中的类型不一致) 无法定位
+	typeErrs := 0
+	for i, f := range d.files {
+		pf, err := parseConfigFile(f.path, f.raw)
+		if err != nil {
+			continue
+		}
+		file := f.name
+		if i == 0 {
+			file = ""
+		}
+		for _, msg := range decodeErrors(pf.root.Decode(new(Config))) {
+			problems = append(problems, Problem{File: file, Line: yamlErrorLine(msg), Msg: msg})
+			typeErrs++
+		}
+	}
+	c := new(Config)
+	if msgs := decodeErrors(d.root.Decode(c)); typeErrs == 0 {
+		for _, msg := range msgs {
+			problems = append(problems, Problem{Msg: msg})
+		}
+	}
+
+	t := reflect.TypeOf(c).Elem()
+	cVal := reflect.ValueOf(c).Elem()
+	for i := 0; i < cVal.NumField(); i++ {
+		field := cVal.Field(i)
+		if field.Kind() == reflect.Ptr && field.IsNil() {
+			field.Set(reflect.New(field.Type().Elem()))
+		}
+		init, ok := field.Interface().(Initializer)
+		if !ok {
+			continue
+		}
+		if err := init.Init(); err != nil {
+			section := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+			key, node := locateError(d.root, section, err.Error())
+			msg := err.Error()
+			for _, o := range d.ovs {
+				if o.key() == key {
+					msg += fmt.Sprintf(" (配置值来自 %s)", o.source)
+				}
+			}
+			p := Problem{Key: key, Msg: msg}
+			if node != nil {
+				p.File, p.Line = d.fileOf(node), node.Line
+			}
+			problems = append(problems, p)
+		}
+	}
+	return problems, nil
+}
+
+// decodeErrors 展开解析配置时的错误信息
+func decodeErrors(err error) []string {
+	if err == nil {
+		return nil
+	}
+	var te *yaml.TypeError
+	if errors.As(err, &te) {
+		return te.Errors
+	}
+	return []string{err.Error()}
+}
+
+// unknownFields 查找配置文档中未知的配置项, 通常是拼写错误
+func (d *configDoc) unknownFields(n *yaml.Node, t reflect.Type, path []string) []Problem {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	res := []Problem{}
+	switch {
+	case t.Kind() == reflect.Struct && n.Kind == yaml.MappingNode:
+		fields := make(map[string]reflect.Type, t.NumField())
+		for i := 0; i < t.NumField(); i++ {
+			if key := yamlKey(t.Field(i)); key != "" {
+				fields[key] = t.Field(i).Type
+			}
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key := n.Content[i]
+			keyPath := append(append([]string{}, path...), key.Value)
+			typ, ok := fields[key.Value]
+			if !ok {
+				res = append(res, Problem{File: d.fileOf(key), Line: key.Line, Key: keyString(keyPath), Msg: "未知的配置项", Warn: true})
+				continue
+			}
+			res = append(res, d.unknownFields(n.Content[i+1], typ, keyPath)...)
+		}
+	case t.Kind() == reflect.Slice && n.Kind == yaml.SequenceNode:
+		for i, el := range n.Content {
+			res = append(res, d.unknownFields(el, t.Elem(), append(append([]string{}, path...), strconv.Itoa(i)))...)
+		}
+	}
+	return res
+}
+
+// LocateKey 获取配置项 (如 path.emby2nginx[0]) 所在的配置文件及行号, 找不到时行号返回 0
+//
+// 配置文件为相对数据根目录的路径
+func LocateKey(key string) (string, int) {
+	path := GetConfigPath()
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return "", 0
+	}
+	d, err := parseConfig(path, raw, os.ReadFile)
+	if err != nil {
+		return "", 0
+	}
+	n := lookupKey(d.root, key)
+	if n == nil {
+		return "", 0
+	}
+	if file := d.fileOf(n); file != "" {
+		return file, n.Line
+	}
+	return configFileName(path), n.Line
+}
+
+// yamlErrorLine 从 yaml 错误信息中提取行号
+func yamlErrorLine(msg string) int {
+	if m := reYamlLine.FindStringSubmatch(msg); m != nil {
+		line, _ := strconv.Atoi(m[1])
+		return line
+	}
+	return 0
+}
+
+// locateError 根据错误信息中出现的配置项路径定位配置项节点
+//
+// 如 "cache.rules[0] 配置错误: ttl 配置错误" 会依次尝试 cache.rules[0] 及 cache.rules[0].ttl,
+// 取能找到的最深的配置项; 错误信息中没有配置项时定位到一级配置项;
+// 定位到的配置项为数组时, 再尝试定位到错误信息中出现的数组元素
+func locateError(doc *yaml.Node, section, msg string) (string, *yaml.Node) {
+	key, node := section, lookupKey(doc, section)
+	for _, token := range reKeyPath.FindAllString(msg, -1) {
+		candidate := token
+		if !strings.HasPrefix(token, section+".") && token != section {
+			if key == section {
+				continue
+			}
+			candidate = key + "." + token
+		}
+		if n := lookupKey(doc, candidate); n != nil {
+			key, node = candidate, n
+		}
+	}
+	if node == nil {
+		return key, nil
+	}
+
+	// 数组类型的配置项进一步定位到错误信息中出现的元素
+	for i := 0; ; i++ {
+		elem := lookupKey(doc, fmt.Sprintf("%s[%d]", key, i))
+		if elem == nil {
+			break
+		}
+		if elem.Kind == yaml.ScalarNode && elem.Value != "" && strings.Contains(msg, elem.Value) {
+			return fmt.Sprintf("%s[%d]", key, i), elem
+		}
+	}
+	return key, node
+}
+
+// lookupKey 按路径查找配置项节点, 路径格式如 cache.rules[0].ttl
+func lookupKey(doc *yaml.Node, key string) *yaml.Node {
+	n := doc
+	if n.Kind == yaml.DocumentNode && len(n.Content) > 0 {
+		n = n.Content[0]
+	}
+	segs := strings.Split(key, ".")
+	for si, seg := range segs {
+		idx := -1
+		if i := strings.Index(seg, "["); i > 0 && strings.HasSuffix(seg, "]") {
+			var err error
+			if idx, err = strconv.Atoi(seg[i+1 : len(seg)-1]); err != nil {
+				return nil
+			}
+			seg = seg[:i]
+		}
+
+		var keyNode *yaml.Node
+		if n.Kind == yaml.MappingNode {
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				if n.Content[i].Value == seg {
+					keyNode, n = n.Content[i], n.Content[i+1]
+					break
+				}
+			}
+		}
+		if keyNode == nil {
+			return nil
+		}
+		if idx < 0 {
+			// 最后一级为对象或数组时返回 key 节点, 其值所在行为下一行
+			if si == len(segs)-1 && n.Kind != yaml.ScalarNode {
+				n = keyNode
+			}
+			continue
+		}
+		if n.Kind != yaml.SequenceNode || idx >= len(n.Content) {
+			return nil
+		}
+		n = n.Content[idx]
+	}
+	return n
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	content := `emby:
+  host: ""
+  proxy-error-strategy: origin
+path:
+  emby2nginx:
+    - /media/data:/video/data
+    - bad-entry
+cache:
+  enable: true
+  rules:
+    - name: test
+      pattern: '^/test'
+      ttl: abc
+nodes:
+  health-check:
+    intervl: 10
+  list:
+    - name: node-1
+      host: http://1.1.1.1
+      weight: heavy
+`
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("写入配置文件失败: %v", err)
+	}
+
+	problems, err := Check(path)
+	if err != nil {
+		t.Fatalf("校验配置失败: %v", err)
+	}
+
+	want := []struct {
+		line int
+		key  string
+		warn bool
+	}{
+		{2, "emby.host", false},
+		{7, "path.emby2nginx[1]", false},
+		{13, "cache.rules[0].ttl", false},
+		{16, "nodes.health-check.intervl", true},
+		{20, "", false},
+	}
+	for _, w := range want {
+		found := false
+		for _, p := range problems {
+			if p.Line == w.line && p.Key == w.key && p.Warn == w.warn {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("未找到第 %d 行的问题 (key: %s, 警告: %v), 实际: %v", w.line, w.key, w.warn, problems)
+		}
+	}
+}
+
+func TestCheckSyntaxError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(path, []byte("emby:\n  host: a\n    bad: [\n"), 0600); err != nil {
+		t.Fatalf("写入配置文件失败: %v", err)
+	}
+
+	problems, err := Check(path)
+	if err != nil {
+		t.Fatalf("校验配置失败: %v", err)
+	}
+	if len(problems) != 1 || problems[0].Line != 3 || !strings.Contains(problems[0].Msg, "yaml") {
+		t.Errorf("期望第 3 行的语法错误, 实际: %v", problems)
+	}
+}
@@ -5,12 +5,18 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"slices"
+	"strings"
+	"sync/atomic"
 
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/logs"
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/web/webport"
-	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
+	// Include 引入的其他配置文件, 支持通配符, 相对路径基于数据根目录,
+	// 未配置时引入 conf.d 目录下的所有配置文件
+	Include []string `yaml:"include"`
 	// Emby emby 相关配置
 	Emby *Emby `yaml:"emby"`
 	// Nodes 节点配置
@@ -27,10 +33,39 @@ type Config struct {
 	Log *Log `yaml:"log"`
 	// Telegram Bot 配置
 	Telegram *Telegram `yaml:"telegram"`
+	// Audit 重定向审计日志配置
+	Audit *Audit `yaml:"audit"`
+	// AdminApi 主服务上的管理接口配置
+	AdminApi *AdminApi `yaml:"admin-api"`
 }
 
-// C 全局唯一配置对象
-var C *Config
+// current 全局唯一配置对象, 重载时整体替换
+var current atomic.Pointer[Config]
+
+// C 获取当前生效的配置对象
+//
+// 重载时整体替换配置对象, 同一个请求中需要多个配置项保持一致时应只获取一次
+func C() *Config {
+	return current.Load()
+}
+
+// Set 替换当前生效的配置对象, 返回之前的配置对象
+func Set(c *Config) *Config {
+	return current.Swap(c)
+}
+
+// clone 复制配置对象, 用于在副本上修改节点列表后整体替换
+//
+// 只复制节点配置及节点列表, 其余配置项仍指向原对象
+func (c *Config) clone() *Config {
+	nc := *c
+	if c.Nodes != nil {
+		nodes := *c.Nodes
+		nodes.List = slices.Clone(c.Nodes.List)
+		nc.Nodes = &nodes
+	}
+	return &nc
+}
 
 // BasePath 配置文件所在的基础路径
 var BasePath string
@@ -40,28 +75,75 @@ type Initializer interface {
 	Init() error
 }
 
+// Applier 配置生效接口
+//
+// 所有配置项都初始化成功后才会调用, 用于设置日志输出等全局状态,
+// 避免配置校验失败时影响当前正在运行的配置
+type Applier interface {
+	// Apply 使配置生效
+	Apply() error
+}
+
 // ReadFromFile 从指定文件中读取配置
 func ReadFromFile(path string) error {
-	bytes, err := os.ReadFile(path)
-	if err != nil {
-		return fmt.Errorf("读取配置文件失败: %v", err)
-	}
-
-	if err = initBasePath(path); err != nil {
+	if err := initBasePath(path); err != nil {
 		return fmt.Errorf("初始化 BasePath 失败: %v", err)
 	}
 
 	// 设置配置文件路径（用于后续保存）
-	if err = SetConfigPath(path); err != nil {
+	if err := SetConfigPath(path); err != nil {
 		return fmt.Errorf("设置配置文件路径失败: %v", err)
 	}
 
-	C = new(Config)
-	if err := yaml.Unmarshal(bytes, C); err != nil {
-		return fmt.Errorf("解析配置文件失败: %v", err)
+	c, sum, err := load(path)
+	if err != nil {
+		return err
+	}
+	if err := apply(c); err != nil {
+		return err
+	}
+	Set(c)
+	markLoaded(sum)
+	if files := configFiles(); len(files) > 1 {
+		names := make([]string, 0, len(files)-1)
+		for _, f := range files[1:] {
+			names = append(names, configFileName(f))
+		}
+		logs.Info("已引入配置文件: %s", strings.Join(names, ", "))
+	}
+	for _, kv := range overrideKeys() {
+		logs.Info("配置项 %s 使用 %s 覆盖", kv[0], kv[1])
+	}
+	if _, unknown := envOverrides(os.Environ()); len(unknown) > 0 {
+		logs.Warn("环境变量 %s 不对应任何配置项, 已忽略", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// load 读取配置文件及其引入的配置文件并初始化所有配置项, 不影响当前生效的配置
+//
+// 返回配置对象及所有配置文件内容的摘要
+func load(path string) (*Config, string, error) {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("读取配置文件失败: %v", err)
 	}
+	return loadBytes(path, bytes, os.ReadFile)
+}
 
-	cVal := reflect.ValueOf(C).Elem()
+// loadBytes 解析主配置文件内容并合并引入的配置文件, 应用环境变量及密钥文件覆盖后初始化所有配置项,
+// 不影响当前生效的配置
+func loadBytes(path string, bytes []byte, read readFunc) (*Config, string, error) {
+	d, err := parseConfig(path, bytes, read)
+	if err != nil {
+		return nil, "", fmt.Errorf("解析配置文件失败: %v", err)
+	}
+	c := new(Config)
+	if err := d.root.Decode(c); err != nil {
+		return nil, "", fmt.Errorf("解析配置文件失败: %v", err)
+	}
+
+	cVal := reflect.ValueOf(c).Elem()
 	for i := 0; i < cVal.NumField(); i++ {
 		field := cVal.Field(i)
 
@@ -74,23 +156,37 @@ func ReadFromFile(path string) error {
 		// 配置项初始化
 		if i, ok := field.Interface().(Initializer); ok {
 			if err := i.Init(); err != nil {
-				return fmt.Errorf("初始化配置文件失败: %v", err)
+				return nil, "", fmt.Errorf("初始化配置文件失败: %v", err)
 			}
 		}
 	}
 
+	return c, d.sum(), nil
+}
+
+// apply 使配置中实现了 Applier 的配置项生效
+func apply(c *Config) error {
+	cVal := reflect.ValueOf(c).Elem()
+	for i := 0; i < cVal.NumField(); i++ {
+		if a, ok := cVal.Field(i).Interface().(Applier); ok {
+			if err := a.Apply(); err != nil {
+				return fmt.Errorf("应用配置失败: %v", err)
+			}
+		}
+	}
 	return nil
 }
 
 // ServerInternalRequestHost 服务内部自请求 host
 func ServerInternalRequestHost() string {
 	p := "http://127.0.0.1:" + webport.HTTP
-	if C == nil {
+	c := C()
+	if c == nil {
 		return p
 	}
 
 	// 只开启了 https 端口
-	if C.Ssl.Enable && C.Ssl.SinglePort {
+	if c.Ssl.Enable && c.Ssl.SinglePort {
 		p = "https://127.0.0.1:" + webport.HTTPS
 	}
 	return p
@@ -43,7 +43,7 @@ type Emby struct {
 	// Emby 源服务器地址
 	Host string `yaml:"host"`
 	// AdminApiKey 管理员 API Key (用于获取用户信息)
-	AdminApiKey string `yaml:"admin-api-key"`
+	AdminApiKey string `yaml:"admin-api-key" secret:"true"`
 	// rclone 或者 cd 的挂载目录
 	MountPath string `yaml:"mount-path"`
 	// EpisodesUnplayPrior 在获取剧集列表时是否将未播资源优先展示
@@ -60,6 +60,9 @@ type Emby struct {
 	DownloadStrategy DlStrategy `yaml:"download-strategy"`
 	// LocalMediaRoot 本地媒体根路径
 	LocalMediaRoot string `yaml:"local-media-root"`
+
+	// randomRoot LocalMediaRoot 是否为随机生成
+	randomRoot bool
 }
 
 func (e *Emby) Init() error {
@@ -103,6 +106,7 @@ func (e *Emby) Init() error {
 	// 如果没有配置, 生成一个随机前缀, 避免将网盘资源误识别为本地
 	if e.LocalMediaRoot = strings.TrimSpace(e.LocalMediaRoot); e.LocalMediaRoot == "" {
 		e.LocalMediaRoot = "/" + randoms.RandomHex(32)
+		e.randomRoot = true
 	}
 
 	return nil
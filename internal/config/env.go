@@ -0,0 +1,497 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix 覆盖配置项的环境变量前缀
+//
+// 配置项路径转为大写, '.' 及 '-' 替换为 '_', 如 emby.host => GE2O_EMBY_HOST,
+// 数组元素使用下标, 如 nodes.list[0].host => GE2O_NODES_LIST_0_HOST
+const EnvPrefix = "GE2O_"
+
+const (
+	// envFileSuffix 从文件读取敏感配置的环境变量后缀, 如 GE2O_EMBY_ADMIN_API_KEY_FILE
+	envFileSuffix = "_FILE"
+
+	// fileKeySuffix 从文件读取敏感配置的配置项后缀, 如 admin-api-key-file
+	fileKeySuffix = "-file"
+)
+
+// configType 配置对象类型, 用于将环境变量名解析为配置项路径
+var configType = reflect.TypeOf(Config{})
+
+// override 配置覆盖项, 来自环境变量或敏感配置的 xxx-file 配置项
+type override struct {
+	path   []string     // 配置项路径, 数组元素为下标
+	typ    reflect.Type // 配置项类型
+	secret bool         // 是否为敏感配置
+	source string       // 来源, 环境变量名或 xxx-file 配置项
+	value  string       // 配置值
+	file   string       // 非空时从该文件读取配置值
+}
+
+// key 配置项名称, 如 nodes.list[0].host
+func (o override) key() string {
+	return keyString(o.path)
+}
+
+// read 读取覆盖的配置值, 文件内容去除末尾换行
+func (o override) read() (string, error) {
+	if o.file == "" {
+		return o.value, nil
+	}
+	path := o.file
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(BasePath, path)
+	}
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("读取文件失败: %v", err)
+	}
+	return strings.TrimRight(string(bytes), "\r\n"), nil
+}
+
+// apply 将覆盖值写入配置文档
+func (o override) apply(root *yaml.Node) error {
+	value, err := o.read()
+	if err != nil {
+		return fmt.Errorf("%s 配置错误: %v", o.source, err)
+	}
+	n, err := valueNode(value, o.typ)
+	if err != nil {
+		return fmt.Errorf("%s 配置错误: %v", o.source, err)
+	}
+	if err := setNode(root, o.path, n); err != nil {
+		return fmt.Errorf("%s 配置错误: %v", o.source, err)
+	}
+	return nil
+}
+
+// parseConfig 解析主配置文件并合并引入的配置文件, 依次应用 xxx-file 配置项及环境变量覆盖,
+// 未覆盖的节点保留在原文件中的行号
+func parseConfig(path string, raw []byte, read readFunc) (*configDoc, error) {
+	d, err := mergeConfig(path, raw, read)
+	if err != nil {
+		return nil, err
+	}
+
+	ovs := fileKeyOverrides(d.root, configType, nil)
+	envs, _ := envOverrides(os.Environ())
+	ovs = append(ovs, envs...)
+	for _, o := range ovs {
+		if err := o.apply(d.root); err != nil {
+			return nil, err
+		}
+	}
+	d.ovs = ovs
+	return d, nil
+}
+
+// envOverrides 解析环境变量中的配置覆盖项, 同时返回无法对应到配置项的环境变量
+//
+// 层级较浅的配置项先应用, 以便同时覆盖数组及其元素时元素配置生效
+func envOverrides(environ []string) ([]override, []string) {
+	res, unknown := []override{}, []string{}
+	for _, kv := range environ {
+		name, value, _ := strings.Cut(kv, "=")
+		rest, ok := strings.CutPrefix(name, EnvPrefix)
+		if !ok || rest == "" {
+			continue
+		}
+		if path, typ, secret, ok := resolveEnv(rest, configType); ok {
+			res = append(res, override{path: path, typ: typ, secret: secret, source: name, value: value})
+			continue
+		}
+		if base, ok := strings.CutSuffix(rest, envFileSuffix); ok {
+			if path, typ, secret, ok := resolveEnv(base, configType); ok && secret {
+				res = append(res, override{path: path, typ: typ, secret: secret, source: name, file: value})
+				continue
+			}
+		}
+		unknown = append(unknown, name)
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		if len(res[i].path) != len(res[j].path) {
+			return len(res[i].path) < len(res[j].path)
+		}
+		// 同一配置项同时设置时, 从文件读取的优先
+		if (res[i].file == "") != (res[j].file == "") {
+			return res[i].file == ""
+		}
+		return res[i].source < res[j].source
+	})
+	sort.Strings(unknown)
+	return res, unknown
+}
+
+// resolveEnv 根据结构体的 yaml 标签将环境变量名 (不含前缀) 解析为配置项路径,
+// 返回配置项路径、类型及是否为敏感配置
+func resolveEnv(name string, t reflect.Type) ([]string, reflect.Type, bool, bool) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			key := yamlKey(f)
+			if key == "" {
+				continue
+			}
+			envKey := strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+			if name == envKey {
+				return []string{key}, f.Type, isSecret(f), true
+			}
+			// 前缀相同的配置项 (如 enable-auth-server 及 enable-auth-server-log) 继续尝试下一个
+			if rest, ok := strings.CutPrefix(name, envKey+"_"); ok {
+				if sub, typ, secret, ok := resolveEnv(rest, f.Type); ok {
+					return append([]string{key}, sub...), typ, secret, true
+				}
+			}
+		}
+	case reflect.Slice:
+		idx, rest, _ := strings.Cut(name, "_")
+		if _, err := strconv.ParseUint(idx, 10, 32); err != nil {
+			break
+		}
+		if rest == "" {
+			return []string{idx}, t.Elem(), false, true
+		}
+		if sub, typ, secret, ok := resolveEnv(rest, t.Elem()); ok {
+			return append([]string{idx}, sub...), typ, secret, true
+		}
+	case reflect.Map:
+		if name != "" && t.Key().Kind() == reflect.String {
+			return []string{strings.ToLower(name)}, t.Elem(), false, true
+		}
+	}
+	return nil, nil, false, false
+}
+
+// fileKeyOverrides 查找配置文档中敏感配置对应的 xxx-file 配置项, 并从文档中移除
+func fileKeyOverrides(n *yaml.Node, t reflect.Type, path []string) []override {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	res := []override{}
+	switch {
+	case t.Kind() == reflect.Struct && n.Kind == yaml.MappingNode:
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			key := yamlKey(f)
+			if key == "" {
+				continue
+			}
+			fieldPath := append(append([]string{}, path...), key)
+			if isSecret(f) {
+				if file := removeKey(n, key+fileKeySuffix); file != nil && file.Value != "" {
+					res = append(res, override{path: fieldPath, typ: f.Type, secret: true, source: keyString(fieldPath) + fileKeySuffix, file: file.Value})
+				}
+				continue
+			}
+			if child := mappingValue(n, key); child != nil {
+				res = append(res, fileKeyOverrides(child, f.Type, fieldPath)...)
+			}
+		}
+	case t.Kind() == reflect.Slice && n.Kind == yaml.SequenceNode:
+		for i, el := range n.Content {
+			res = append(res, fileKeyOverrides(el, t.Elem(), append(append([]string{}, path...), strconv.Itoa(i)))...)
+		}
+	}
+	return res
+}
+
+// valueNode 将覆盖值转换为 yaml 节点并校验类型
+//
+// 字符串类型直接使用原值; 元素为基础类型的数组可使用逗号分隔, 如 1,2,3;
+// 其余类型按 yaml 解析, 如 [1, 2, 3] 或 {name: a, host: b}
+func valueNode(value string, typ reflect.Type) (*yaml.Node, error) {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() == reflect.String {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}, nil
+	}
+
+	if typ.Kind() == reflect.Slice && !strings.HasPrefix(strings.TrimSpace(value), "[") && isScalarKind(typ.Elem().Kind()) {
+		seq := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, s := range strings.Split(value, ",") {
+			if s = strings.TrimSpace(s); s == "" {
+				continue
+			}
+			el, err := valueNode(s, typ.Elem())
+			if err != nil {
+				return nil, err
+			}
+			seq.Content = append(seq.Content, el)
+		}
+		return seq, nil
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(value), &doc); err != nil {
+		return nil, err
+	}
+	n := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null"}
+	if len(doc.Content) > 0 {
+		n = doc.Content[0]
+	}
+	if err := n.Decode(reflect.New(typ).Interface()); err != nil {
+		var te *yaml.TypeError
+		if errors.As(err, &te) && len(te.Errors) > 0 {
+			// 环境变量中的值没有行号, 去除错误信息中的 line 1: 前缀
+			msgs := make([]string, len(te.Errors))
+			for i, msg := range te.Errors {
+				_, msgs[i], _ = strings.Cut(msg, ": ")
+			}
+			return nil, errors.New(strings.Join(msgs, "; "))
+		}
+		return nil, err
+	}
+	return n, nil
+}
+
+// setNode 将节点写入配置文档的指定路径, 路径不存在时自动创建
+func setNode(root *yaml.Node, path []string, val *yaml.Node) error {
+	n := root
+	for i, seg := range path {
+		last := i == len(path)-1
+		_, err := strconv.Atoi(seg)
+		isIdx := err == nil
+
+		// 空配置项 (如只写了 nodes:) 转为对象或数组
+		if n.Kind == yaml.ScalarNode && n.Tag == "!!null" {
+			n.Kind, n.Tag, n.Value = yaml.MappingNode, "!!map", ""
+			if isIdx {
+				n.Kind, n.Tag = yaml.SequenceNode, "!!seq"
+			}
+		}
+
+		var child *yaml.Node
+		switch n.Kind {
+		case yaml.MappingNode:
+			if child = mappingValue(n, seg); child == nil {
+				child = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null"}
+				n.Content = append(n.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: seg}, child)
+			}
+		case yaml.SequenceNode:
+			idx, _ := strconv.Atoi(seg)
+			if !isIdx || idx > len(n.Content) {
+				return fmt.Errorf("数组下标 %s 超出范围, 当前共 %d 个元素", seg, len(n.Content))
+			}
+			if idx == len(n.Content) {
+				n.Content = append(n.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null"})
+			}
+			child = n.Content[idx]
+		default:
+			return fmt.Errorf("%s 不是对象或数组", keyString(path[:i]))
+		}
+
+		if last {
+			line, column := child.Line, child.Column
+			*child = *val
+			child.Line, child.Column = line, column
+			return nil
+		}
+		n = child
+	}
+	return nil
+}
+
+// detachOverrides 从序列化后的新旧配置中移除被覆盖的配置项,
+// 保存配置时这些配置项保持配置文件中的原样, 避免将环境变量及密钥文件中的值写入配置文件
+//
+// orig 为配置文件原本的内容, 原文件中没有、由环境变量追加的数组元素同时从新旧配置中移除
+func detachOverrides(orig, base, want *yaml.Node, ovs []override) {
+	// 延迟移除数组元素, 避免下标变化
+	removes := map[[2]*yaml.Node][]int{}
+	for _, o := range ovs {
+		od, b, w := orig, base, want
+		for i, seg := range o.path {
+			last := i == len(o.path)-1
+			if b == nil || w == nil || b.Kind != w.Kind {
+				break
+			}
+			if w.Kind == yaml.MappingNode {
+				if last {
+					removeKey(b, seg)
+					removeKey(w, seg)
+					break
+				}
+				od, b, w = mappingValue(od, seg), mappingValue(b, seg), mappingValue(w, seg)
+				continue
+			}
+			idx, err := strconv.Atoi(seg)
+			if w.Kind != yaml.SequenceNode || err != nil || idx >= len(b.Content) || idx >= len(w.Content) {
+				break
+			}
+			inOrig := od != nil && od.Kind == yaml.SequenceNode && idx < len(od.Content)
+			if last {
+				if inOrig {
+					w.Content[idx] = b.Content[idx]
+				} else {
+					removes[[2]*yaml.Node{b, w}] = append(removes[[2]*yaml.Node{b, w}], idx)
+				}
+				break
+			}
+			if inOrig {
+				od = od.Content[idx]
+			} else {
+				od = nil
+			}
+			b, w = b.Content[idx], w.Content[idx]
+		}
+	}
+
+	for seqs, idxs := range removes {
+		sort.Sort(sort.Reverse(sort.IntSlice(idxs)))
+		for _, idx := range slices.Compact(idxs) {
+			for _, n := range seqs {
+				n.Content = append(n.Content[:idx], n.Content[idx+1:]...)
+			}
+		}
+	}
+}
+
+// maskSecrets 将序列化后配置中的敏感配置脱敏
+func maskSecrets(n *yaml.Node, t reflect.Type) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t.Kind() == reflect.Struct && n.Kind == yaml.MappingNode:
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			child := mappingValue(n, yamlKey(f))
+			if child == nil {
+				continue
+			}
+			if isSecret(f) {
+				if child.Kind == yaml.ScalarNode && child.Value != "" {
+					child.Value, child.Style = MaskSecret(child.Value), 0
+				}
+				continue
+			}
+			maskSecrets(child, f.Type)
+		}
+	case t.Kind() == reflect.Slice && n.Kind == yaml.SequenceNode:
+		for _, el := range n.Content {
+			maskSecrets(el, t.Elem())
+		}
+	}
+}
+
+// EffectiveYaml 输出当前生效的配置, 敏感配置脱敏显示,
+// 被环境变量或密钥文件覆盖的配置项以注释标明来源
+func EffectiveYaml() ([]byte, error) {
+	if C() == nil {
+		return nil, errors.New("配置未加载")
+	}
+	var n yaml.Node
+	if err := n.Encode(C()); err != nil {
+		return nil, fmt.Errorf("序列化配置失败: %v", err)
+	}
+	maskSecrets(&n, configType)
+
+	if raw, err := os.ReadFile(GetConfigPath()); err == nil {
+		if d, err := parseConfig(GetConfigPath(), raw, os.ReadFile); err == nil {
+			for _, o := range d.ovs {
+				if kn := lookupKey(&n, o.key()); kn != nil {
+					kn.LineComment = "来自 " + o.source
+				}
+			}
+		}
+	}
+	return marshalYaml(&n)
+}
+
+// overrideKeys 返回当前配置文件中被环境变量或密钥文件覆盖的配置项及其来源
+func overrideKeys() [][2]string {
+	raw, err := os.ReadFile(GetConfigPath())
+	if err != nil {
+		return nil
+	}
+	d, err := parseConfig(GetConfigPath(), raw, os.ReadFile)
+	if err != nil {
+		return nil
+	}
+	res := make([][2]string, 0, len(d.ovs))
+	for _, o := range d.ovs {
+		res = append(res, [2]string{o.key(), o.source})
+	}
+	return res
+}
+
+// yamlKey 获取结构体字段对应的配置项名称, 非导出及忽略的字段返回空
+func yamlKey(f reflect.StructField) string {
+	if !f.IsExported() {
+		return ""
+	}
+	name := strings.Split(f.Tag.Get("yaml"), ",")[0]
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return strings.ToLower(f.Name)
+	}
+	return name
+}
+
+// isSecret 字段是否为敏感配置, 敏感配置使用 secret:"true" 标签标记
+func isSecret(f reflect.StructField) bool {
+	return f.Tag.Get("secret") == "true"
+}
+
+// isScalarKind 是否为基础类型
+func isScalarKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.Struct, reflect.Ptr, reflect.Interface:
+		return false
+	}
+	return true
+}
+
+// removeKey 从对象节点中移除指定 key, 返回被移除的值节点
+func removeKey(n *yaml.Node, key string) *yaml.Node {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			val := n.Content[i+1]
+			n.Content = append(n.Content[:i], n.Content[i+2:]...)
+			return val
+		}
+	}
+	return nil
+}
+
+// keyString 将配置项路径转为字符串, 如 nodes.list[0].host
+func keyString(path []string) string {
+	sb := strings.Builder{}
+	for _, seg := range path {
+		if _, err := strconv.Atoi(seg); err == nil {
+			sb.WriteString("[" + seg + "]")
+			continue
+		}
+		if sb.Len() > 0 {
+			sb.WriteString(".")
+		}
+		sb.WriteString(seg)
+	}
+	return sb.String()
+}
@@ -0,0 +1,125 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestResolveEnv(t *testing.T) {
+	cases := map[string]string{
+		"EMBY_HOST":                   "emby.host",
+		"EMBY_ADMIN_API_KEY":          "emby.admin-api-key",
+		"EMBY_STRM_PATH_MAP":          "emby.strm.path-map",
+		"AUTH_ENABLE_AUTH_SERVER":     "auth.enable-auth-server",
+		"AUTH_ENABLE_AUTH_SERVER_LOG": "auth.enable-auth-server-log",
+		"NODES_LIST_1_WEIGHT":         "nodes.list[1].weight",
+		"CACHE_RULES_0_TTL":           "cache.rules[0].ttl",
+		"LOG_MODULES_NODE":            "log.modules.node",
+	}
+	for name, want := range cases {
+		path, _, _, ok := resolveEnv(name, configType)
+		if !ok || keyString(path) != want {
+			t.Errorf("%s 期望解析为 %s, 实际: %s %v", name, want, keyString(path), ok)
+		}
+	}
+
+	for _, name := range []string{"EMBY_HOTS", "NODES_LIST_X_HOST", "EMBY_HOST_X", "NODES"} {
+		if _, _, _, ok := resolveEnv(name, configType); name != "NODES" && ok {
+			t.Errorf("%s 不应解析成功", name)
+		}
+	}
+	if _, _, secret, _ := resolveEnv("TELEGRAM_BOT_TOKEN", configType); !secret {
+		t.Error("telegram.bot-token 应为敏感配置")
+	}
+}
+
+func TestEnvOverrides(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yml")
+	writeTestConfig(t, path, testReloadConfig+"telegram:\n  bot-token-file: bot-token\n")
+	writeTestConfig(t, filepath.Join(dir, "bot-token"), "123:abc\n")
+	writeTestConfig(t, filepath.Join(dir, "api-key"), "key-from-file")
+	writeTestConfig(t, filepath.Join(dir, "video-secret"), "secret-from-file\n")
+
+	t.Setenv("GE2O_EMBY_HOST", "http://emby:8096")
+	t.Setenv("GE2O_EMBY_ADMIN_API_KEY_FILE", filepath.Join(dir, "api-key"))
+	t.Setenv("GE2O_NODES_LIST_0_WEIGHT", "30")
+	t.Setenv("GE2O_NODES_LIST_1", "{name: node-2, host: http://2.2.2.2, weight: 50, enabled: true}")
+	t.Setenv("GE2O_TELEGRAM_ADMIN_USERS", "1, 2")
+	t.Setenv("GE2O_AUTH_USER_IDENTITY_TTL", "5m")
+	t.Setenv("GE2O_AUTH_VIDEO_AUTH_SECRET_FILE", filepath.Join(dir, "video-secret"))
+	if err := ReadFromFile(path); err != nil {
+		t.Fatalf("读取配置失败: %v", err)
+	}
+
+	if C().Emby.Host != "http://emby:8096" || C().Emby.AdminApiKey != "key-from-file" {
+		t.Errorf("emby 配置未被覆盖: %s %s", C().Emby.Host, C().Emby.AdminApiKey)
+	}
+	if C().Telegram.BotToken != "123:abc" {
+		t.Errorf("bot-token-file 读取错误, 实际: %q", C().Telegram.BotToken)
+	}
+	if len(C().Nodes.List) != 2 || C().Nodes.List[0].Weight != 30 || C().Nodes.List[1].Host != "http://2.2.2.2" {
+		t.Errorf("节点配置未被覆盖: %+v", C().Nodes.List)
+	}
+	if !slices.Equal(C().Telegram.AdminUserID, []int64{1, 2}) {
+		t.Errorf("admin-users 解析错误, 实际: %v", C().Telegram.AdminUserID)
+	}
+	if C().Auth.UserIdentityTTL.String() != "5m0s" {
+		t.Errorf("user-identity-ttl 解析错误, 实际: %v", C().Auth.UserIdentityTTL)
+	}
+	if C().Auth.VideoAuthSecret != "secret-from-file" {
+		t.Errorf("GE2O_AUTH_VIDEO_AUTH_SECRET_FILE 读取错误, 实际: %q", C().Auth.VideoAuthSecret)
+	}
+	if _, err := os.Stat(filepath.Join(dir, VideoAuthSecretFile)); !os.IsNotExist(err) {
+		t.Errorf("已配置签名密钥时不应生成密钥文件: %v", err)
+	}
+
+	// 覆盖的配置不写回配置文件
+	C().Nodes.List[0].Enabled = false
+	if err := SaveToFile(); err != nil {
+		t.Fatalf("保存配置失败: %v", err)
+	}
+	bytes, _ := os.ReadFile(path)
+	content := string(bytes)
+	for _, s := range []string{"emby:8096", "key-from-file", "secret-from-file", "123:abc", "node-2", "weight: 30", "admin-users"} {
+		if strings.Contains(content, s) {
+			t.Errorf("覆盖的配置 %s 不应写入配置文件:\n%s", s, content)
+		}
+	}
+	if !strings.Contains(content, "enabled: false") || !strings.Contains(content, "bot-token-file: bot-token") {
+		t.Errorf("配置文件保存错误:\n%s", content)
+	}
+
+	data, err := EffectiveYaml()
+	if err != nil {
+		t.Fatalf("输出生效配置失败: %v", err)
+	}
+	effective := string(data)
+	if strings.Contains(effective, "key-from-file") || strings.Contains(effective, "secret-from-file") || strings.Contains(effective, "123:abc") {
+		t.Errorf("敏感配置未脱敏:\n%s", effective)
+	}
+	for _, s := range []string{"admin-api-key: key-***", "123:***", "host: http://emby:8096 # 来自 GE2O_EMBY_HOST"} {
+		if !strings.Contains(effective, s) {
+			t.Errorf("生效配置中缺少 %s:\n%s", s, effective)
+		}
+	}
+}
+
+func TestEnvOverrideError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	writeTestConfig(t, path, testReloadConfig)
+
+	t.Setenv("GE2O_NODES_LIST_0_WEIGHT", "heavy")
+	if _, _, err := load(path); err == nil || !strings.Contains(err.Error(), "GE2O_NODES_LIST_0_WEIGHT") {
+		t.Errorf("类型错误应提示环境变量名, 实际: %v", err)
+	}
+
+	t.Setenv("GE2O_NODES_LIST_0_WEIGHT", "")
+	t.Setenv("GE2O_NODES_LIST_3_HOST", "http://3.3.3.3")
+	if _, _, err := load(path); err == nil || !strings.Contains(err.Error(), "超出范围") {
+		t.Errorf("数组下标越界应返回错误, 实际: %v", err)
+	}
+}
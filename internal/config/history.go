@@ -0,0 +1,352 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// HistoryDir 配置历史目录, 位于数据根目录下
+	HistoryDir = "config-history"
+
+	// HistoryKeep 最多保留的配置历史数, 超出时删除最早的记录
+	HistoryKeep = 50
+
+	// ActorSystem 程序自身修改配置时记录的修改人
+	ActorSystem = "system"
+
+	// historyDiffLines 单条历史记录最多保留的差异行数
+	historyDiffLines = 200
+
+	// diffMaxCells 逐行比较时最长公共子序列表格的最大单元格数, 超出时只记录变化的行数
+	diffMaxCells = 1 << 18
+)
+
+// ErrHistoryNotFound 配置历史不存在
+var ErrHistoryNotFound = errors.New("配置历史不存在")
+
+// Change 配置修改来源
+type Change struct {
+	Actor  string // 修改人, 如 telegram:123456、api:abcd***
+	Action string // 修改操作, 如 删除节点 node-1
+}
+
+// HistoryEntry 一次配置修改记录
+//
+// 快照保存的是修改前的配置文件, 回滚到某条记录即撤销该次及之后的所有修改
+type HistoryEntry struct {
+	Id     int       `json:"id"`
+	Time   time.Time `json:"time"`
+	Actor  string    `json:"actor"`
+	Action string    `json:"action"`
+	Diff   string    `json:"diff"`           // 修改前后配置文件的差异, - 开头为删除的行, + 开头为新增的行
+	File   string    `json:"file,omitempty"` // 修改的配置文件, 相对数据根目录, 主配置文件为空
+
+	base string // 快照及元数据文件的路径前缀
+}
+
+// historyPath 获取配置历史目录的绝对路径
+func historyPath() string {
+	return filepath.Join(BasePath, HistoryDir)
+}
+
+// recordHistory 保存修改前的配置文件快照, 内容未变化时不记录, 调用方需持有 saveMutex
+func recordHistory(file string, old, new []byte, ch Change) error {
+	if string(old) == string(new) {
+		return nil
+	}
+	dir := historyPath()
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return fmt.Errorf("创建配置历史目录失败: %v", err)
+	}
+
+	list, err := ListHistory()
+	if err != nil {
+		return err
+	}
+	id := 1
+	if len(list) > 0 {
+		id = list[0].Id + 1
+	}
+	if ch.Actor == "" {
+		ch.Actor = ActorSystem
+	}
+
+	now := time.Now()
+	e := &HistoryEntry{
+		Id:     id,
+		Time:   now,
+		Actor:  ch.Actor,
+		Action: ch.Action,
+		Diff:   maskSecretLines(lineDiff(string(old), string(new), historyDiffLines)),
+		File:   file,
+		base:   filepath.Join(dir, fmt.Sprintf("%06d-%s", id, now.Format("20060102-150405"))),
+	}
+	if err := os.WriteFile(e.base+".yml", old, 0600); err != nil {
+		return fmt.Errorf("保存配置快照失败: %v", err)
+	}
+	meta, _ := json.MarshalIndent(e, "", "  ")
+	if err := os.WriteFile(e.base+".json", meta, 0600); err != nil {
+		os.Remove(e.base + ".yml")
+		return fmt.Errorf("保存配置历史失败: %v", err)
+	}
+
+	// 删除超出保留数量的历史
+	list = append([]*HistoryEntry{e}, list...)
+	for _, old := range list[min(len(list), HistoryKeep):] {
+		os.Remove(old.base + ".yml")
+		os.Remove(old.base + ".json")
+	}
+	return nil
+}
+
+// ListHistory 获取所有配置历史, 按修改时间倒序
+func ListHistory() ([]*HistoryEntry, error) {
+	files, err := filepath.Glob(filepath.Join(historyPath(), "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("读取配置历史失败: %v", err)
+	}
+
+	list := make([]*HistoryEntry, 0, len(files))
+	for _, file := range files {
+		bytes, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		var e HistoryEntry
+		if json.Unmarshal(bytes, &e) != nil {
+			continue
+		}
+		e.base = strings.TrimSuffix(file, ".json")
+		e.Diff = maskSecretLines(e.Diff)
+		list = append(list, &e)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Id > list[j].Id })
+	return list, nil
+}
+
+// GetHistory 获取指定的配置历史及其快照内容
+//
+// 快照为修改前配置文件的原始内容, 对外展示前需使用 MaskSnapshot 脱敏
+func GetHistory(id int) (*HistoryEntry, []byte, error) {
+	list, err := ListHistory()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, e := range list {
+		if e.Id != id {
+			continue
+		}
+		bytes, err := os.ReadFile(e.base + ".yml")
+		if err != nil {
+			return nil, nil, fmt.Errorf("读取配置快照失败: %v", err)
+		}
+		return e, bytes, nil
+	}
+	return nil, nil, ErrHistoryNotFound
+}
+
+// Rollback 将配置文件恢复为指定历史记录修改前的内容并立即重载
+//
+// 该次及之后修改过的每个配置文件都会恢复到第一次修改前的内容;
+// 快照校验不通过时不做任何修改; 回滚本身也会记录为一次修改, 可以再次回滚
+func Rollback(id int, ch Change) (*ReloadEvent, error) {
+	list, err := ListHistory()
+	if err != nil {
+		return nil, err
+	}
+
+	// 文件路径 -> 需要恢复的内容
+	restore := map[string][]byte{}
+	found := false
+	for i := len(list) - 1; i >= 0; i-- {
+		e := list[i]
+		if e.Id < id {
+			continue
+		}
+		found = found || e.Id == id
+		path := historyFilePath(e.File)
+		if _, ok := restore[path]; ok {
+			continue
+		}
+		bytes, err := os.ReadFile(e.base + ".yml")
+		if err != nil {
+			return nil, fmt.Errorf("读取配置快照失败: %v", err)
+		}
+		restore[path] = bytes
+	}
+	if !found {
+		return nil, ErrHistoryNotFound
+	}
+
+	read := func(path string) ([]byte, error) {
+		if bytes, ok := restore[path]; ok {
+			return bytes, nil
+		}
+		return os.ReadFile(path)
+	}
+	raw, err := read(configFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("读取配置文件失败: %v", err)
+	}
+	if _, _, err := loadBytes(configFilePath, raw, read); err != nil {
+		return nil, fmt.Errorf("配置快照校验失败: %v", err)
+	}
+	if ch.Action == "" {
+		ch.Action = "回滚到 #" + strconv.Itoa(id) + " 修改前的配置"
+	}
+
+	paths := make([]string, 0, len(restore))
+	for path := range restore {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	saveMutex.Lock()
+	for _, path := range paths {
+		if err = writeConfigFile(path, restore[path], ch); err != nil {
+			break
+		}
+	}
+	saveMutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return Reload()
+}
+
+// historyFilePath 获取配置历史中记录的文件的绝对路径
+func historyFilePath(file string) string {
+	if file == "" {
+		return configFilePath
+	}
+	if filepath.IsAbs(file) {
+		return file
+	}
+	return filepath.Join(BasePath, filepath.FromSlash(file))
+}
+
+// lineDiff 逐行比较两段文本, 返回变化的行, 最多返回 limit 行
+func lineDiff(a, b string, limit int) string {
+	al := strings.Split(strings.ReplaceAll(a, "\r\n", "\n"), "\n")
+	bl := strings.Split(strings.ReplaceAll(b, "\r\n", "\n"), "\n")
+
+	// 去掉首尾相同的行, 减少计算量
+	pre := 0
+	for pre < len(al) && pre < len(bl) && al[pre] == bl[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(al)-pre && suf < len(bl)-pre && al[len(al)-1-suf] == bl[len(bl)-1-suf] {
+		suf++
+	}
+	al, bl = al[pre:len(al)-suf], bl[pre:len(bl)-suf]
+
+	// 变化范围过大时不逐行比较, 避免占用过多内存及 CPU
+	if (len(al)+1)*(len(bl)+1) > diffMaxCells {
+		return fmt.Sprintf("... 变化较大, 未逐行比较: 第 %d 行起 %d 行变为 %d 行", pre+1, len(al), len(bl))
+	}
+
+	// 最长公共子序列
+	lcs := make([][]int, len(al)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(bl)+1)
+	}
+	for i := len(al) - 1; i >= 0; i-- {
+		for j := len(bl) - 1; j >= 0; j-- {
+			if al[i] == bl[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var lines []string
+	i, j := 0, 0
+	for i < len(al) || j < len(bl) {
+		switch {
+		case i < len(al) && j < len(bl) && al[i] == bl[j]:
+			i, j = i+1, j+1
+		case i < len(al) && (j == len(bl) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, "- "+al[i])
+			i++
+		default:
+			lines = append(lines, "+ "+bl[j])
+			j++
+		}
+	}
+
+	if len(lines) > limit {
+		lines = append(lines[:limit], fmt.Sprintf("... 省略 %d 行", len(lines)-limit))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// secretKeys 敏感配置的名称, 如 bot-token, 用于脱敏配置文件文本
+var secretKeys = collectSecretKeys(configType, map[string]bool{})
+
+// secretLineReg 匹配配置文件中 key: value 形式的行, 可带差异前缀 (- / +) 及序列项前缀
+var secretLineReg = regexp.MustCompile(`^(\s*(?:[-+] )?\s*(?:- )?)([\w-]+)(\s*:\s+)(.*?)(\s+#.*)?$`)
+
+// collectSecretKeys 收集使用 secret:"true" 标签标记的配置项名称
+func collectSecretKeys(t reflect.Type, keys map[string]bool) map[string]bool {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return keys
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		key := yamlKey(f)
+		if key == "" {
+			continue
+		}
+		if isSecret(f) {
+			keys[key] = true
+			continue
+		}
+		collectSecretKeys(f.Type, keys)
+	}
+	return keys
+}
+
+// MaskSecret 脱敏显示令牌等敏感信息, 只保留前 4 个字符
+func MaskSecret(s string) string {
+	if len(s) <= 4 {
+		return "***"
+	}
+	return s[:4] + "***"
+}
+
+// maskSecretLines 将配置文件文本或差异中敏感配置的值脱敏, 只保留前 4 个字符
+func maskSecretLines(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		m := secretLineReg.FindStringSubmatch(strings.TrimSuffix(line, "\r"))
+		if m == nil || !secretKeys[m[2]] {
+			continue
+		}
+		val := strings.Trim(m[4], `"'`)
+		if val == "" {
+			continue
+		}
+		lines[i] = m[1] + m[2] + m[3] + MaskSecret(val) + m[5]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// MaskSnapshot 将配置快照中的敏感配置脱敏, 用于对外展示
+func MaskSnapshot(snapshot []byte) string {
+	return maskSecretLines(string(snapshot))
+}
@@ -0,0 +1,134 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHistoryRollback(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	writeTestConfig(t, path, testPersistConfig)
+	if err := ReadFromFile(path); err != nil {
+		t.Fatalf("读取配置失败: %v", err)
+	}
+
+	C().Nodes.List = C().Nodes.List[1:]
+	if err := SaveToFileBy(Change{Actor: "telegram:123", Action: "删除节点 node-1"}); err != nil {
+		t.Fatalf("保存配置失败: %v", err)
+	}
+
+	list, err := ListHistory()
+	if err != nil || len(list) != 1 {
+		t.Fatalf("应记录 1 条配置历史, 实际: %d, err: %v", len(list), err)
+	}
+	e := list[0]
+	if e.Id != 1 || e.Actor != "telegram:123" || e.Action != "删除节点 node-1" {
+		t.Errorf("配置历史信息错误: %+v", e)
+	}
+	if !strings.Contains(e.Diff, `-     - name: "node-1"`) || strings.Contains(e.Diff, "+ ") {
+		t.Errorf("配置差异错误:\n%s", e.Diff)
+	}
+
+	ev, err := Rollback(1, Change{Actor: "api:abcd***"})
+	if err != nil {
+		t.Fatalf("回滚失败: %v", err)
+	}
+	if !ev.Has("nodes") || len(C().Nodes.List) != 2 {
+		t.Errorf("回滚后配置未生效, 节点数: %d", len(C().Nodes.List))
+	}
+	if bytes, _ := os.ReadFile(path); string(bytes) != testPersistConfig {
+		t.Errorf("回滚后配置文件应与修改前一致:\n%s", bytes)
+	}
+
+	// 回滚本身也记录为一次修改
+	list, _ = ListHistory()
+	if len(list) != 2 || list[0].Id != 2 || list[0].Actor != "api:abcd***" || !strings.Contains(list[0].Action, "#1") {
+		t.Errorf("回滚应记录为新的配置历史: %+v", list[0])
+	}
+
+	if _, err := Rollback(99, Change{}); err != ErrHistoryNotFound {
+		t.Errorf("回滚不存在的历史应返回 ErrHistoryNotFound, 实际: %v", err)
+	}
+}
+
+func TestHistoryRetention(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	writeTestConfig(t, path, testPersistConfig)
+	if err := ReadFromFile(path); err != nil {
+		t.Fatalf("读取配置失败: %v", err)
+	}
+
+	for i := 1; i <= HistoryKeep+5; i++ {
+		C().Nodes.List[0].Weight = i
+		if err := SaveToFile(); err != nil {
+			t.Fatalf("保存配置失败: %v", err)
+		}
+	}
+
+	list, _ := ListHistory()
+	if len(list) != HistoryKeep || list[0].Id != HistoryKeep+5 || list[len(list)-1].Id != 6 {
+		t.Errorf("应只保留最近 %d 条历史, 实际: %d", HistoryKeep, len(list))
+	}
+	files, _ := filepath.Glob(filepath.Join(BasePath, HistoryDir, "*.yml"))
+	if len(files) != HistoryKeep {
+		t.Errorf("快照文件数量错误: %d", len(files))
+	}
+	if list[0].Actor != ActorSystem {
+		t.Errorf("未指定修改人时应记录为 %s, 实际: %s", ActorSystem, list[0].Actor)
+	}
+}
+
+func TestLineDiff(t *testing.T) {
+	a := "a\nb\nc\nd\n"
+	b := "a\nc\nx\nd\n"
+	if got, want := lineDiff(a, b, 10), "- b\n+ x"; got != want {
+		t.Errorf("期望:\n%s\n实际:\n%s", want, got)
+	}
+	if got := lineDiff("1\n2\n3", "4\n5\n6", 2); !strings.HasSuffix(got, "... 省略 4 行") {
+		t.Errorf("超出行数应省略, 实际:\n%s", got)
+	}
+
+	// 变化范围过大时不逐行比较
+	var big1, big2 strings.Builder
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&big1, "a%d\n", i)
+		fmt.Fprintf(&big2, "b%d\n", i)
+	}
+	if got, want := lineDiff("head\n"+big1.String(), "head\n"+big2.String(), 10), "... 变化较大, 未逐行比较: 第 2 行起 1000 行变为 1000 行"; got != want {
+		t.Errorf("期望:\n%s\n实际:\n%s", want, got)
+	}
+}
+
+func TestMaskSecretLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	writeTestConfig(t, path, testPersistConfig+"telegram:\n  bot-token: \"123456:ABCDEFG\" # 机器人令牌\n"+
+		"admin-api:\n  enable: true\n  token: 0123456789abcdef\n")
+	if err := ReadFromFile(path); err != nil {
+		t.Fatalf("读取配置失败: %v", err)
+	}
+	C().Telegram.BotToken = "654321:HIJKLMN"
+	if err := SaveToFile(); err != nil {
+		t.Fatalf("保存配置失败: %v", err)
+	}
+
+	e, snapshot, err := GetHistory(1)
+	if err != nil {
+		t.Fatalf("获取配置历史失败: %v", err)
+	}
+	masked := MaskSnapshot(snapshot)
+	for _, s := range []string{"0123456789abcdef", "ABCDEFG", "HIJKLMN"} {
+		if strings.Contains(masked, s) || strings.Contains(e.Diff, s) {
+			t.Errorf("快照及差异中的敏感配置应脱敏:\n%s\n%s", masked, e.Diff)
+		}
+	}
+	if !strings.Contains(masked, `bot-token: 1234*** # 机器人令牌`) || !strings.Contains(masked, "token: 0123***") ||
+		!strings.Contains(masked, "host: http://127.0.0.1:8096 # Emby 地址") {
+		t.Errorf("脱敏后应保留前 4 个字符、注释及其他配置:\n%s", masked)
+	}
+	if !strings.Contains(e.Diff, "-   bot-token: 1234***") || !strings.Contains(e.Diff, "+   bot-token: 6543***") {
+		t.Errorf("差异中的敏感配置应脱敏后保留:\n%s", e.Diff)
+	}
+}
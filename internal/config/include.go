@@ -0,0 +1,442 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultIncludeDir 主配置文件未配置 include 时默认引入的配置目录, 位于数据根目录下
+const DefaultIncludeDir = "conf.d"
+
+// includeKey 引入其他配置文件的配置项
+const includeKey = "include"
+
+// readFunc 读取配置文件内容, 回滚时用于在写入前校验快照
+type readFunc func(path string) ([]byte, error)
+
+// configFile 参与合并的单个配置文件
+type configFile struct {
+	path string     // 绝对路径
+	name string     // 相对数据根目录的路径, 用于展示及配置历史
+	raw  []byte     // 文件内容
+	doc  *yaml.Node // 文档节点, 保留文件开头的注释
+	root *yaml.Node // 单独解析出的根节点, 合并时会被修改
+}
+
+// configDoc 合并后的配置文档
+type configDoc struct {
+	root  *yaml.Node    // 合并并应用覆盖后的根节点
+	files []*configFile // 主配置文件及引入的配置文件, 按合并顺序
+	ovs   []override    // 环境变量及密钥文件覆盖项
+
+	// nodeFile 节点 -> 所在配置文件, 用于定位配置错误
+	nodeFile map[*yaml.Node]*configFile
+}
+
+// sum 所有配置文件内容的摘要
+func (d *configDoc) sum() string {
+	return filesChecksum(d.files)
+}
+
+// fileOf 获取节点所在的引入配置文件名称, 位于主配置文件或由环境变量创建的节点返回空
+func (d *configDoc) fileOf(n *yaml.Node) string {
+	if f, ok := d.nodeFile[n]; ok && f != d.files[0] {
+		return f.name
+	}
+	return ""
+}
+
+// mergeConfig 解析主配置文件并按顺序合并 include 引入的配置文件
+//
+// 合并规则:
+//   - 对象逐个 key 合并
+//   - 数组按文件顺序拼接, 元素为带 name 字段的对象时 (如节点列表) name 不能重复
+//   - 其余配置项后引入的文件覆盖先引入的
+func mergeConfig(path string, raw []byte, read readFunc) (*configDoc, error) {
+	main, err := parseConfigFile(path, raw)
+	if err != nil {
+		return nil, err
+	}
+	d := &configDoc{root: main.root, files: []*configFile{main}, nodeFile: map[*yaml.Node]*configFile{}}
+	markNodes(main.root, main, d.nodeFile)
+
+	paths, err := includeFiles(includePatterns(main.root))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		if path == main.path {
+			continue
+		}
+		raw, err := read(path)
+		if err != nil {
+			return nil, fmt.Errorf("读取引入的配置文件失败: %v", err)
+		}
+		f, err := parseConfigFile(path, raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", f.name, err)
+		}
+		if mappingValue(f.root, includeKey) != nil {
+			return nil, fmt.Errorf("%s: 引入的配置文件不能再配置 include", f.name)
+		}
+		markNodes(f.root, f, d.nodeFile)
+		if err := mergeNode(d.root, f.root, nil); err != nil {
+			return nil, fmt.Errorf("%s: %v", f.name, err)
+		}
+		d.files = append(d.files, f)
+	}
+	return d, nil
+}
+
+// parseConfigFile 解析单个配置文件, 空文件视为空对象
+func parseConfigFile(path string, raw []byte) (*configFile, error) {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	f := &configFile{path: path, name: configFileName(path), raw: raw}
+	f.doc = new(yaml.Node)
+	if err := yaml.Unmarshal(raw, f.doc); err != nil {
+		return f, err
+	}
+	if len(f.doc.Content) == 0 {
+		f.root = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		f.doc = &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{f.root}}
+	}
+	f.root = f.doc.Content[0]
+	if f.root.Kind != yaml.MappingNode {
+		return f, errors.New("配置文件不是有效的 yaml 对象")
+	}
+	return f, nil
+}
+
+// configFileName 获取配置文件相对数据根目录的路径
+func configFileName(path string) string {
+	if rel, err := filepath.Rel(BasePath, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
+
+// includePatterns 获取需要引入的配置文件匹配规则
+//
+// 优先使用环境变量 GE2O_INCLUDE, 其次为主配置文件中的 include;
+// 都没有配置时引入 conf.d 目录下的所有 .yml 及 .yaml 文件
+func includePatterns(root *yaml.Node) []string {
+	n := mappingValue(root, includeKey)
+	if value, ok := os.LookupEnv(EnvPrefix + "INCLUDE"); ok {
+		n, _ = valueNode(value, includeType)
+	}
+	if n == nil {
+		return nil
+	}
+	var patterns []string
+	if n.Decode(&patterns) != nil {
+		return []string{}
+	}
+	return patterns
+}
+
+// includeType include 配置项的类型
+var includeType = reflect.TypeOf([]string{})
+
+// includeFiles 根据匹配规则查找需要引入的配置文件, 返回绝对路径
+//
+// 按规则顺序合并, 同一规则匹配到的文件按文件名排序, 重复匹配的文件只引入一次;
+// 规则为 nil 时使用默认的 conf.d 目录
+func includeFiles(patterns []string) ([]string, error) {
+	if patterns == nil {
+		dir := filepath.Join(BasePath, DefaultIncludeDir)
+		yml, _ := filepath.Glob(filepath.Join(dir, "*.yml"))
+		yamls, _ := filepath.Glob(filepath.Join(dir, "*.yaml"))
+		res := append(yml, yamls...)
+		slices.Sort(res)
+		return res, nil
+	}
+
+	res := []string{}
+	for _, p := range patterns {
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(BasePath, p)
+		}
+		matches, err := filepath.Glob(p)
+		if err != nil {
+			return nil, fmt.Errorf("include 配置错误: %s: %v", p, err)
+		}
+		// 不含通配符的规则视为必须存在的文件
+		if len(matches) == 0 && !strings.ContainsAny(p, "*?[") {
+			return nil, fmt.Errorf("include 配置错误: 引入的配置文件不存在: %s", p)
+		}
+		for _, m := range matches {
+			if stat, err := os.Stat(m); err == nil && !stat.IsDir() && !slices.Contains(res, m) {
+				res = append(res, m)
+			}
+		}
+	}
+	return res, nil
+}
+
+// mergeNode 将引入的配置合并到目标对象中, 复用引入文件中的节点以保留行号
+func mergeNode(dst, src *yaml.Node, path []string) error {
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		key, val := src.Content[i], src.Content[i+1]
+		keyPath := append(append([]string{}, path...), key.Value)
+
+		idx := -1
+		for j := 0; j+1 < len(dst.Content); j += 2 {
+			if dst.Content[j].Value == key.Value {
+				idx = j + 1
+				break
+			}
+		}
+		if idx < 0 {
+			dst.Content = append(dst.Content, key, val)
+			continue
+		}
+
+		cur := dst.Content[idx]
+		switch {
+		case val.Kind == yaml.ScalarNode && val.Tag == "!!null":
+			// 引入的文件中只写了 key 没有配置值, 不覆盖已有配置
+		case cur.Kind == yaml.MappingNode && val.Kind == yaml.MappingNode:
+			if err := mergeNode(cur, val, keyPath); err != nil {
+				return err
+			}
+		case cur.Kind == yaml.SequenceNode && val.Kind == yaml.SequenceNode:
+			names, _ := indexByName(cur)
+			for _, el := range val.Content {
+				if name := mappingValue(el, "name"); name != nil && names[name.Value] != nil {
+					return fmt.Errorf("第 %d 行 %s 中的 %s 与已引入的配置重复", el.Line, keyString(keyPath), name.Value)
+				}
+			}
+			// 拼接到新节点中, 避免修改主配置文件的节点
+			merged := *cur
+			merged.Content = append(slices.Clone(cur.Content), val.Content...)
+			dst.Content[idx] = &merged
+		default:
+			dst.Content[idx] = val
+		}
+	}
+	return nil
+}
+
+// markNodes 记录节点所在的配置文件
+func markNodes(n *yaml.Node, f *configFile, m map[*yaml.Node]*configFile) {
+	if n == nil {
+		return
+	}
+	m[n] = f
+	for _, c := range n.Content {
+		markNodes(c, f, m)
+	}
+}
+
+// filesChecksum 计算所有配置文件内容的摘要, 只有主配置文件时与文件内容摘要相同
+func filesChecksum(files []*configFile) string {
+	if len(files) == 1 {
+		return checksum(files[0].raw)
+	}
+	h := sha256.New()
+	for _, f := range files {
+		h.Write([]byte(f.path))
+		h.Write([]byte{0})
+		h.Write(f.raw)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// configFiles 获取当前主配置文件及其引入的配置文件路径, 引入规则有误时只返回主配置文件
+func configFiles() []string {
+	main := GetConfigPath()
+	files := []string{main}
+	raw, err := os.ReadFile(main)
+	if err != nil {
+		return files
+	}
+	f, err := parseConfigFile(main, raw)
+	if err != nil {
+		return files
+	}
+	paths, _ := includeFiles(includePatterns(f.root))
+	for _, p := range paths {
+		if p != main {
+			files = append(files, p)
+		}
+	}
+	return files
+}
+
+// currentChecksum 读取主配置文件及其引入的配置文件, 计算内容摘要
+func currentChecksum() (string, error) {
+	paths := configFiles()
+	files := make([]*configFile, 0, len(paths))
+	for _, p := range paths {
+		raw, err := os.ReadFile(p)
+		if err != nil {
+			return "", err
+		}
+		files = append(files, &configFile{path: p, raw: raw})
+	}
+	return filesChecksum(files), nil
+}
+
+// historyFileName 配置历史中记录的文件名, 主配置文件为空
+func historyFileName(path string) string {
+	if path == configFilePath {
+		return ""
+	}
+	return configFileName(path)
+}
+
+// splitOwned 从完整的新旧配置中裁剪出第 i 个配置文件负责的部分, 保存配置时只修改这部分
+//
+//   - 配置项写回定义它的文件, 多个文件都定义时写回最后一个 (即实际生效的)
+//   - 所有文件都没有定义的配置项写回上级对象所在的第一个文件, 顶层配置项写回主配置文件
+//   - 多个文件都定义的数组, 带 name 的元素 (如节点) 写回其所在的文件, 新增元素写入最后一个文件
+func splitOwned(roots []*yaml.Node, i int, base, want *yaml.Node, path []string, fallback int) (*yaml.Node, *yaml.Node, error) {
+	nb, nw := emptyLike(base), emptyLike(want)
+	for _, key := range mappingKeys(base, want) {
+		keyPath := append(append([]string{}, path...), key)
+		bv, wv := mappingValue(base, key), mappingValue(want, key)
+		defs := definers(roots, keyPath)
+
+		var ob, ow *yaml.Node
+		var err error
+		switch {
+		case sameKind(bv, wv, yaml.MappingNode):
+			childFallback := fallback
+			if len(defs) > 0 {
+				childFallback = defs[0]
+			}
+			if (len(defs) > 0 && !slices.Contains(defs, i)) || (len(defs) == 0 && fallback != i) {
+				continue
+			}
+			ob, ow, err = splitOwned(roots, i, bv, wv, keyPath, childFallback)
+		case sameKind(bv, wv, yaml.SequenceNode) && len(defs) > 1:
+			ob, ow, err = splitSequence(roots, i, bv, wv, keyPath, defs)
+		default:
+			owner := fallback
+			if len(defs) > 0 {
+				owner = defs[len(defs)-1]
+			}
+			if owner != i {
+				continue
+			}
+			ob, ow = bv, wv
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		appendKeyValue(nb, key, ob)
+		appendKeyValue(nw, key, ow)
+	}
+	return nb, nw, nil
+}
+
+// splitSequence 裁剪多个文件都定义的数组
+func splitSequence(roots []*yaml.Node, i int, base, want *yaml.Node, path []string, defs []int) (*yaml.Node, *yaml.Node, error) {
+	_, baseNamed := indexByName(base)
+	_, wantNamed := indexByName(want)
+	if (base != nil && !baseNamed) || (want != nil && !wantNamed) {
+		if base != nil && want != nil && nodeEqual(base, want) {
+			return nil, nil, nil
+		}
+		return nil, nil, fmt.Errorf("%s 由多个配置文件定义, 无法确定修改应写入的文件", keyString(path))
+	}
+	if !slices.Contains(defs, i) {
+		return nil, nil, nil
+	}
+
+	// 元素所在的文件, 新增的元素写入最后一个文件
+	origin := func(name string) int {
+		for _, j := range defs {
+			if names, _ := indexByName(lookupPath(roots[j], path)); names[name] != nil {
+				return j
+			}
+		}
+		return defs[len(defs)-1]
+	}
+	filter := func(seq *yaml.Node) *yaml.Node {
+		if seq == nil {
+			return nil
+		}
+		res := emptyLike(seq)
+		for _, el := range seq.Content {
+			if origin(mappingValue(el, "name").Value) == i {
+				res.Content = append(res.Content, el)
+			}
+		}
+		return res
+	}
+	return filter(base), filter(want), nil
+}
+
+// definers 获取定义了指定配置项的文件下标
+func definers(roots []*yaml.Node, path []string) []int {
+	res := []int{}
+	for i, root := range roots {
+		if lookupPath(root, path) != nil {
+			res = append(res, i)
+		}
+	}
+	return res
+}
+
+// lookupPath 按对象的 key 逐级查找节点
+func lookupPath(n *yaml.Node, path []string) *yaml.Node {
+	for _, seg := range path {
+		if n = mappingValue(n, seg); n == nil {
+			return nil
+		}
+	}
+	return n
+}
+
+// mappingKeys 两个对象节点中所有的 key, 按出现顺序
+func mappingKeys(a, b *yaml.Node) []string {
+	res := []string{}
+	for _, n := range []*yaml.Node{a, b} {
+		if n == nil || n.Kind != yaml.MappingNode {
+			continue
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if !slices.Contains(res, n.Content[i].Value) {
+				res = append(res, n.Content[i].Value)
+			}
+		}
+	}
+	return res
+}
+
+// sameKind 两个节点 (可以有一个为空) 是否都是指定类型
+func sameKind(a, b *yaml.Node, kind yaml.Kind) bool {
+	if a == nil && b == nil {
+		return false
+	}
+	return (a == nil || a.Kind == kind) && (b == nil || b.Kind == kind)
+}
+
+// emptyLike 创建与指定节点类型相同的空节点
+func emptyLike(n *yaml.Node) *yaml.Node {
+	if n == nil {
+		return nil
+	}
+	return &yaml.Node{Kind: n.Kind, Tag: n.Tag, Style: n.Style}
+}
+
+// appendKeyValue 向对象节点追加键值, 对象或值为空时忽略
+func appendKeyValue(m *yaml.Node, key string, val *yaml.Node) {
+	if m == nil || val == nil {
+		return
+	}
+	m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, val)
+}
@@ -0,0 +1,208 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testIncludeMain = `# 主配置
+emby:
+  host: http://127.0.0.1:8096
+nodes:
+  list:
+    - name: "node-1"
+      host: "http://1.1.1.1"
+      weight: 100
+      enabled: true
+`
+
+const testIncludeNodes = `# 额外的节点
+nodes:
+  list:
+    - name: "node-2"
+      host: "http://2.2.2.2"   # 节点二
+      weight: 50
+      enabled: true
+`
+
+const testIncludePaths = `path:
+  emby2nginx:
+    - /media/data:/video/data
+emby:
+  host: http://emby:8096
+`
+
+// writeIncludeConfig 在临时目录中写入主配置文件及 conf.d 下的配置文件, 返回主配置文件路径
+func writeIncludeConfig(t *testing.T, main string, files map[string]string) string {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yml")
+	writeTestConfig(t, path, main)
+	if err := os.MkdirAll(filepath.Join(dir, DefaultIncludeDir), os.ModePerm); err != nil {
+		t.Fatalf("创建目录失败: %v", err)
+	}
+	for name, content := range files {
+		writeTestConfig(t, filepath.Join(dir, DefaultIncludeDir, name), content)
+	}
+	return path
+}
+
+func TestIncludeMerge(t *testing.T) {
+	path := writeIncludeConfig(t, testIncludeMain, map[string]string{
+		"20-paths.yml": testIncludePaths,
+		"10-nodes.yml": testIncludeNodes,
+	})
+	if err := ReadFromFile(path); err != nil {
+		t.Fatalf("读取配置失败: %v", err)
+	}
+
+	if len(C().Nodes.List) != 2 || C().Nodes.List[0].Name != "node-1" || C().Nodes.List[1].Name != "node-2" {
+		t.Errorf("节点列表应按文件顺序合并: %+v", C().Nodes.List)
+	}
+	if len(C().Path.Emby2Nginx) != 1 || C().Path.Emby2Nginx[0] != "/media/data:/video/data" {
+		t.Errorf("路径映射未合并: %v", C().Path.Emby2Nginx)
+	}
+	if C().Emby.Host != "http://emby:8096" {
+		t.Errorf("后引入的文件应覆盖先前的配置: %s", C().Emby.Host)
+	}
+
+	if file, line := LocateKey("nodes.list[1]"); file != "conf.d/10-nodes.yml" || line != 4 {
+		t.Errorf("配置项定位错误: %s 第 %d 行", file, line)
+	}
+	if file, _ := LocateKey("nodes.list[0]"); file != "config.yml" {
+		t.Errorf("配置项定位错误: %s", file)
+	}
+}
+
+func TestIncludeExplicit(t *testing.T) {
+	main := "include:\n  - extra/*.yml\n  - conf.d/10-nodes.yml\n" + testIncludeMain
+	path := writeIncludeConfig(t, main, map[string]string{"10-nodes.yml": testIncludeNodes})
+	extra := filepath.Join(filepath.Dir(path), "extra")
+	os.MkdirAll(extra, os.ModePerm)
+	writeTestConfig(t, filepath.Join(extra, "nodes.yml"), strings.ReplaceAll(testIncludeNodes, "node-2", "node-3"))
+
+	if err := ReadFromFile(path); err != nil {
+		t.Fatalf("读取配置失败: %v", err)
+	}
+	names := []string{}
+	for _, n := range C().Nodes.List {
+		names = append(names, n.Name)
+	}
+	if strings.Join(names, ",") != "node-1,node-3,node-2" {
+		t.Errorf("应按 include 顺序合并: %v", names)
+	}
+
+	// 不含通配符的文件必须存在
+	writeTestConfig(t, path, "include:\n  - missing.yml\n"+testIncludeMain)
+	if err := ReadFromFile(path); err == nil || !strings.Contains(err.Error(), "missing.yml") {
+		t.Errorf("引入不存在的文件应报错: %v", err)
+	}
+}
+
+func TestIncludeDuplicateNode(t *testing.T) {
+	path := writeIncludeConfig(t, testIncludeMain, map[string]string{
+		"10-nodes.yml": strings.ReplaceAll(testIncludeNodes, "node-2", "node-1"),
+	})
+	err := ReadFromFile(path)
+	if err == nil || !strings.Contains(err.Error(), "conf.d/10-nodes.yml") || !strings.Contains(err.Error(), "node-1") {
+		t.Errorf("节点重名应报错并指出文件: %v", err)
+	}
+}
+
+func TestIncludeSaveToOriginFile(t *testing.T) {
+	path := writeIncludeConfig(t, testIncludeMain, map[string]string{
+		"10-nodes.yml": testIncludeNodes,
+		"20-paths.yml": testIncludePaths,
+	})
+	if err := ReadFromFile(path); err != nil {
+		t.Fatalf("读取配置失败: %v", err)
+	}
+	nodesPath := filepath.Join(filepath.Dir(path), DefaultIncludeDir, "10-nodes.yml")
+
+	C().Nodes.List[1].Enabled = false
+	C().Nodes.List = append(C().Nodes.List, Node{Name: "node-3", Host: "http://3.3.3.3", Weight: 80, Enabled: true})
+	if err := SaveToFileBy(Change{Action: "修改节点"}); err != nil {
+		t.Fatalf("保存配置失败: %v", err)
+	}
+
+	if bytes, _ := os.ReadFile(path); string(bytes) != testIncludeMain {
+		t.Errorf("主配置文件不应被修改:\n%s", bytes)
+	}
+	bytes, _ := os.ReadFile(nodesPath)
+	content := string(bytes)
+	if !strings.Contains(content, "enabled: false") || !strings.Contains(content, `"node-3"`) ||
+		!strings.Contains(content, "# 节点二") || strings.Contains(content, "node-1") {
+		t.Errorf("节点修改应只写入所在的文件:\n%s", content)
+	}
+
+	list, _ := ListHistory()
+	if len(list) != 1 || list[0].File != "conf.d/10-nodes.yml" {
+		t.Errorf("配置历史应记录修改的文件: %+v", list)
+	}
+
+	// 删除主配置文件中的节点
+	C().Nodes.List = C().Nodes.List[1:]
+	if err := SaveToFile(); err != nil {
+		t.Fatalf("保存配置失败: %v", err)
+	}
+	if bytes, _ := os.ReadFile(path); strings.Contains(string(bytes), "node-1") {
+		t.Errorf("node-1 应从主配置文件中删除:\n%s", bytes)
+	}
+	if err := ReadFromFile(path); err != nil || len(C().Nodes.List) != 2 {
+		t.Errorf("保存后重新读取配置失败: %v", err)
+	}
+}
+
+func TestIncludeRollback(t *testing.T) {
+	path := writeIncludeConfig(t, testIncludeMain, map[string]string{"10-nodes.yml": testIncludeNodes})
+	if err := ReadFromFile(path); err != nil {
+		t.Fatalf("读取配置失败: %v", err)
+	}
+	nodesPath := filepath.Join(filepath.Dir(path), DefaultIncludeDir, "10-nodes.yml")
+
+	// 同一次保存修改两个文件
+	C().Nodes.List[0].Weight = 1
+	C().Nodes.List[1].Weight = 2
+	if err := SaveToFile(); err != nil {
+		t.Fatalf("保存配置失败: %v", err)
+	}
+	list, _ := ListHistory()
+	if len(list) != 2 {
+		t.Fatalf("应记录 2 条配置历史, 实际: %d", len(list))
+	}
+
+	if _, err := Rollback(list[1].Id, Change{}); err != nil {
+		t.Fatalf("回滚失败: %v", err)
+	}
+	if bytes, _ := os.ReadFile(path); string(bytes) != testIncludeMain {
+		t.Errorf("主配置文件未恢复:\n%s", bytes)
+	}
+	if bytes, _ := os.ReadFile(nodesPath); string(bytes) != testIncludeNodes {
+		t.Errorf("引入的配置文件未恢复:\n%s", bytes)
+	}
+	if C().Nodes.List[0].Weight != 100 || C().Nodes.List[1].Weight != 50 {
+		t.Errorf("回滚后配置未生效: %+v", C().Nodes.List)
+	}
+}
+
+func TestCheckInclude(t *testing.T) {
+	path := writeIncludeConfig(t, testIncludeMain, map[string]string{
+		"10-nodes.yml": strings.ReplaceAll(testIncludeNodes, "weight: 50", "weight: heavy\n      wieght: 1"),
+	})
+	problems, err := Check(path)
+	if err != nil {
+		t.Fatalf("校验配置失败: %v", err)
+	}
+	var typeErr, unknown bool
+	for _, p := range problems {
+		if p.File != "conf.d/10-nodes.yml" {
+			continue
+		}
+		typeErr = typeErr || (p.Line == 6 && !p.Warn)
+		unknown = unknown || (p.Line == 7 && p.Key == "nodes.list[1].wieght" && p.Warn)
+	}
+	if !typeErr || !unknown {
+		t.Errorf("问题应定位到引入的配置文件: %v", problems)
+	}
+}
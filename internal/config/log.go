@@ -1,15 +1,104 @@
 package config
 
-import "github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/logs/colors"
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/logs"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/logs/colors"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/rotates"
+)
 
 // Log 日志配置
 type Log struct {
-	DisableColor bool `yaml:"disable-color"` // 是否禁用彩色日志输出
+	DisableColor bool              `yaml:"disable-color"` // 是否禁用彩色日志输出
+	Level        string            `yaml:"level"`         // 默认日志级别: debug/info/warn/error, 默认 info
+	Modules      map[string]string `yaml:"modules"`       // 按模块单独设置日志级别, 如 node: debug
+	Format       string            `yaml:"format"`        // 输出格式: text/json, 默认 text
+	File         string            `yaml:"file"`          // 日志文件路径, 配置后日志会同时写入文件
+	FileRotate   *LogRotate        `yaml:"file-rotate"`   // 日志文件轮转配置
+
+	// level 解析后的默认日志级别
+	level logs.Level
+	// levels 解析后的模块日志级别
+	levels map[string]logs.Level
 }
 
-// Init 配置初始化
+// Init 配置初始化, 只做校验, 日志输出在 Apply 中生效
 func (lc *Log) Init() error {
+	if lc.Level == "" {
+		lc.Level = "info"
+	}
+	level, err := logs.ParseLevel(lc.Level)
+	if err != nil {
+		return fmt.Errorf("log.level 配置错误: %v", err)
+	}
+	levels := make(map[string]logs.Level, len(lc.Modules))
+	for module, name := range lc.Modules {
+		l, err := logs.ParseLevel(name)
+		if err != nil {
+			return fmt.Errorf("log.modules.%s 配置错误: %v", module, err)
+		}
+		levels[strings.TrimSpace(module)] = l
+	}
+
+	lc.Format = strings.ToLower(strings.TrimSpace(lc.Format))
+	if lc.Format == "" {
+		lc.Format = logs.FormatText
+	}
+	if lc.Format != logs.FormatText && lc.Format != logs.FormatJSON {
+		return fmt.Errorf("log.format 配置错误: %s, 可选值: %s, %s", lc.Format, logs.FormatText, logs.FormatJSON)
+	}
+
+	if lc.FileRotate == nil {
+		lc.FileRotate = new(LogRotate)
+	}
+	if err := lc.FileRotate.Init(); err != nil {
+		return fmt.Errorf("log.file-rotate %v", err)
+	}
+	lc.File = strings.TrimSpace(lc.File)
+
+	lc.level, lc.levels = level, levels
+	return nil
+}
+
+// Apply 使日志配置生效
+//
+// 先打开日志文件, 失败时不修改当前的日志设置
+func (lc *Log) Apply() error {
+	var out io.Writer
+	if lc.File != "" {
+		opts := lc.FileRotate.Options()
+		// 日志文件本身就是 logs 的输出, 轮转结果不能再通过 logs 写回, 直接输出到标准错误
+		opts.OnRotate = func(backup string, err error) {
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "日志文件轮转失败: %v\n", err)
+				return
+			}
+			fmt.Fprintf(os.Stderr, "日志文件已轮转: %s\n", backup)
+		}
+		w, err := rotates.NewWriter(lc.File, opts)
+		if err != nil {
+			return fmt.Errorf("打开日志文件失败: %v", err)
+		}
+		out = w
+	}
+
 	colors.SetEnabler(lc)
+	logs.SetFormat(lc.Format)
+	if old := logs.SetFileOutput(out); old != nil {
+		if c, ok := old.(io.Closer); ok {
+			c.Close()
+		}
+	}
+
+	logs.SetLevel("", lc.level)
+	logs.ResetModuleLevels()
+	for module, l := range lc.levels {
+		logs.SetLevel(module, l)
+	}
 	return nil
 }
 
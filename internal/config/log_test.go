@@ -0,0 +1,43 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/logs"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/rotates"
+)
+
+func TestLogApply_RotateFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	lc := &Log{File: path, FileRotate: &LogRotate{MaxSize: 1}}
+	if err := lc.Init(); err != nil {
+		t.Fatalf("初始化日志配置失败: %v", err)
+	}
+	if err := lc.Apply(); err != nil {
+		t.Fatalf("应用日志配置失败: %v", err)
+	}
+	defer logs.CloseFileOutput()
+
+	// 写入超过 1MB 的日志, 触发按大小轮转
+	line := strings.Repeat("x", 1024)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1100; i++ {
+			logs.Info("%s", line)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("日志文件轮转时卡死")
+	}
+
+	backups, err := rotates.ListBackups(path)
+	if err != nil || len(backups) == 0 {
+		t.Errorf("期望生成轮转文件, 实际: %v, err: %v", backups, err)
+	}
+}
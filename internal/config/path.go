@@ -29,13 +29,35 @@ func (p *Path) Init() error {
 
 // MapEmby2Nginx 将 emby 路径映射成 nginx 路径
 func (p *Path) MapEmby2Nginx(embyPath string) (string, bool) {
-	for _, cfg := range p.emby2NginxArr {
+	idx, nginxPath, ok := p.MatchEmby2Nginx(embyPath)
+	if !ok {
+		return "", false
+	}
+	ep, np := p.emby2NginxArr[idx][0], p.emby2NginxArr[idx][1]
+	logs.Tip("命中 emby2nginx 路径映射: %s => %s (如命中错误, 请将正确的映射配置前移)", ep, np)
+	return nginxPath, true
+}
+
+// MatchEmby2Nginx 查找 emby 路径命中的第一条 emby2nginx 映射,
+// 返回映射在配置中的下标及映射后的 nginx 路径
+func (p *Path) MatchEmby2Nginx(embyPath string) (int, string, bool) {
+	for i, cfg := range p.emby2NginxArr {
 		ep, np := cfg[0], cfg[1]
 		// 完全匹配或者是路径分隔符后的前缀
 		if embyPath == ep || strings.HasPrefix(embyPath, ep+"/") {
-			logs.Tip("命中 emby2nginx 路径映射: %s => %s (如命中错误, 请将正确的映射配置前移)", ep, np)
-			return strings.Replace(embyPath, ep, np, 1), true
+			return i, strings.Replace(embyPath, ep, np, 1), true
+		}
+	}
+	return -1, "", false
+}
+
+// IsNginxPath 判断路径是否位于 emby2nginx 配置的某个 nginx 路径前缀下
+func (p *Path) IsNginxPath(nginxPath string) bool {
+	for _, cfg := range p.emby2NginxArr {
+		np := cfg[1]
+		if nginxPath == np || strings.HasPrefix(nginxPath, strings.TrimSuffix(np, "/")+"/") {
+			return true
 		}
 	}
-	return "", false
+	return false
 }
@@ -126,3 +126,51 @@ func TestMapEmby2Nginx_EmptyConfig(t *testing.T) {
 		t.Error("空配置应该返回 false")
 	}
 }
+
+func TestIsNginxPath(t *testing.T) {
+	testPath := &Path{
+		Emby2Nginx: []string{
+			"/media/data:/video/data",
+			"/media/data1:/video/data1",
+		},
+	}
+
+	if err := testPath.Init(); err != nil {
+		t.Fatalf("初始化配置失败: %v", err)
+	}
+
+	tests := map[string]bool{
+		"/video/data/movie.mp4":  true,
+		"/video/data1/show.mkv":  true,
+		"/video/data":            true,
+		"/video/data2/movie.mp4": false,
+		"/etc/passwd":            false,
+		"":                       false,
+		"/video/datax/movie.mp4": false,
+	}
+	for p, want := range tests {
+		if got := testPath.IsNginxPath(p); got != want {
+			t.Errorf("IsNginxPath(%q) = %v, 期望 %v", p, got, want)
+		}
+	}
+}
+
+func TestMatchEmby2Nginx(t *testing.T) {
+	testPath := &Path{
+		Emby2Nginx: []string{
+			"/media/data:/video/data",
+			"/media/data1:/video/data1",
+		},
+	}
+	if err := testPath.Init(); err != nil {
+		t.Fatalf("初始化配置失败: %v", err)
+	}
+
+	idx, got, ok := testPath.MatchEmby2Nginx("/media/data1/movie.mp4")
+	if !ok || idx != 1 || got != "/video/data1/movie.mp4" {
+		t.Errorf("期望命中第 2 条映射 /video/data1/movie.mp4, 实际: %d %s %v", idx, got, ok)
+	}
+	if idx, _, ok := testPath.MatchEmby2Nginx("/other/movie.mp4"); ok || idx != -1 {
+		t.Errorf("不应命中任何映射, 实际: %d", idx)
+	}
+}
@@ -1,14 +1,19 @@
 package config
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"path/filepath"
 	"sync"
 
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/logs"
 	"gopkg.in/yaml.v3"
 )
 
+// BackupSuffix 保存配置前备份原配置文件使用的后缀
+const BackupSuffix = ".bak"
+
 var (
 	configFilePath string
 	saveMutex      sync.Mutex
@@ -29,30 +34,234 @@ func GetConfigPath() string {
 	return configFilePath
 }
 
-// SaveToFile 保存配置到文件
+// SaveToFile 保存配置到文件, 修改人记录为系统
 func SaveToFile() error {
+	return SaveToFileBy(Change{Actor: ActorSystem})
+}
+
+// SaveToFileBy 保存配置到文件, 并将修改前的配置文件记录到配置历史中
+//
+// 只修改运行期间发生变化的配置项, 原配置文件中的注释、顺序及未配置的项保持不变;
+// 引入了其他配置文件时, 修改只写回配置项所在的文件; 写入前将原文件备份为 xxx.bak
+func SaveToFileBy(ch Change) error {
 	saveMutex.Lock()
 	defer saveMutex.Unlock()
+	return save(C(), ch)
+}
 
+// Update 复制当前配置并通过 fn 修改副本, 副本保存到配置文件成功后才替换当前配置
+//
+// fn 返回本次修改的记录, 返回错误时放弃修改; 修改、保存与替换都持有 saveMutex, 与配置重载串行执行,
+// 读取方要么读到修改前的配置, 要么读到保存成功后的配置.
+// 副本只复制了节点列表, 其余配置项与当前配置共享, fn 中只能修改节点列表
+func Update(fn func(c *Config) (Change, error)) (*Config, error) {
+	saveMutex.Lock()
+	defer saveMutex.Unlock()
+
+	old := C()
+	if old == nil {
+		return nil, fmt.Errorf("配置对象为空")
+	}
+	c := old.clone()
+	ch, err := fn(c)
+	if err != nil {
+		return nil, err
+	}
+	if err := save(c, ch); err != nil {
+		return nil, err
+	}
+	Set(c)
+	return c, nil
+}
+
+// save 将配置写回配置文件, 调用方需持有 saveMutex
+func save(c *Config, ch Change) error {
 	if configFilePath == "" {
 		return fmt.Errorf("配置文件路径未设置")
 	}
 
-	if C == nil {
+	if c == nil {
 		return fmt.Errorf("配置对象为空")
 	}
 
-	// 序列化配置
-	data, err := yaml.Marshal(C)
+	// 无法在原配置文件上修改时不完整写入当前配置,
+	// 否则环境变量及密钥文件覆盖的值会写入配置文件, 引入的配置文件也会被展开
+	files, err := patchConfigFiles(configFilePath, c)
 	if err != nil {
-		return fmt.Errorf("序列化配置失败: %v", err)
+		return fmt.Errorf("保存配置失败: %v", err)
+	}
+
+	for _, f := range files {
+		if err := writeConfigFile(f.path, f.data, ch); err != nil {
+			return err
+		}
+	}
+
+	// 程序自身写入的配置与当前配置一致, 无需触发重载
+	if sum, err := currentChecksum(); err == nil {
+		markLoaded(sum)
 	}
+	return nil
+}
 
-	// Docker 挂载文件不能使用 rename（会报 device or resource busy）
-	// 直接覆盖写入（虽然不是原子操作，但在单进程环境下安全）
-	if err := os.WriteFile(configFilePath, data, 0644); err != nil {
+// fileContent 需要写入的配置文件内容
+type fileContent struct {
+	path string
+	data []byte
+}
+
+// writeConfigFile 记录配置历史并写入配置文件, 调用方需持有 saveMutex
+func writeConfigFile(path string, data []byte, ch Change) error {
+	if old, err := os.ReadFile(path); err == nil {
+		if err := recordHistory(historyFileName(path), old, data, ch); err != nil {
+			logs.Warn("记录配置历史失败: %v", err)
+		}
+	}
+
+	if err := writeFileAtomic(path, data); err != nil {
 		return fmt.Errorf("写入配置文件失败: %v", err)
 	}
+	return nil
+}
+
+// patchConfigFiles 将配置与配置文件的差异写回各配置文件的节点树中, 返回内容发生变化的文件
+//
+// 以配置文件本身解析出的配置作为基准, 只有与基准不同的配置项才会修改,
+// 避免将初始化时填充的默认值写入文件
+func patchConfigFiles(path string, c *Config) ([]fileContent, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取配置文件失败: %v", err)
+	}
+
+	// 合并后未应用覆盖的配置, 用于确定被覆盖的配置项是否来自配置文件
+	merged, err := mergeConfig(path, raw, os.ReadFile)
+	if err != nil {
+		return nil, fmt.Errorf("解析配置文件失败: %v", err)
+	}
+
+	base, _, err := load(path)
+	if err != nil {
+		return nil, err
+	}
+	inheritGenerated(c, base)
 
+	var baseNode, wantNode yaml.Node
+	if err := baseNode.Encode(base); err != nil {
+		return nil, fmt.Errorf("序列化配置失败: %v", err)
+	}
+	if err := wantNode.Encode(c); err != nil {
+		return nil, fmt.Errorf("序列化配置失败: %v", err)
+	}
+	if d, err := parseConfig(path, raw, os.ReadFile); err == nil {
+		detachOverrides(merged.root, &baseNode, &wantNode, d.ovs)
+	}
+
+	// 合并时会修改节点, 重新解析各个文件
+	files := make([]*configFile, len(merged.files))
+	roots := make([]*yaml.Node, len(merged.files))
+	for i, f := range merged.files {
+		if files[i], err = parseConfigFile(f.path, f.raw); err != nil {
+			return nil, fmt.Errorf("%s: %v", f.name, err)
+		}
+		roots[i] = files[i].root
+	}
+
+	// 先拆分出每个文件负责的配置, 再逐个修改, 修改会影响拆分时对配置所在文件的判断
+	bases, wants := make([]*yaml.Node, len(files)), make([]*yaml.Node, len(files))
+	for i := range files {
+		if bases[i], wants[i], err = splitOwned(roots, i, &baseNode, &wantNode, nil, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	res := []fileContent{}
+	for i, f := range files {
+		if nodeEqual(bases[i], wants[i]) {
+			continue
+		}
+		data, err := patchFile(f, bases[i], wants[i])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", f.name, err)
+		}
+		if !bytes.Equal(data, f.raw) {
+			res = append(res, fileContent{path: f.path, data: data})
+		}
+	}
+	return res, nil
+}
+
+// patchFile 修改单个配置文件
+func patchFile(f *configFile, base, want *yaml.Node) ([]byte, error) {
+	// 优先直接修改原文件中发生变化的文本, 格式完全不变;
+	// 遇到无法直接修改的结构时, 修改节点树后重新序列化, 只保留注释及顺序
+	tp := newTextPatcher(f.raw)
+	if err := tp.patch(f.root, base, want); err == nil {
+		return tp.result(), nil
+	}
+	patchNode(f.root, base, want)
+	return marshalYaml(f.doc)
+}
+
+// marshalYaml 以 2 空格缩进序列化, 与配置示例文件保持一致
+func marshalYaml(v any) ([]byte, error) {
+	buf := bytes.Buffer{}
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeFileAtomic 先写入同目录下的临时文件再替换原文件, 保留原文件权限, 并备份原文件
+func writeFileAtomic(path string, data []byte) error {
+	perm := os.FileMode(0644)
+	if stat, err := os.Stat(path); err == nil {
+		perm = stat.Mode().Perm()
+		if err := copyFile(path, path+BackupSuffix, perm); err != nil {
+			return fmt.Errorf("备份原配置文件失败: %v", err)
+		}
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("创建临时文件失败: %v", err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("写入临时文件失败: %v", err)
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		return fmt.Errorf("设置文件权限失败: %v", err)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		// Docker 单独挂载的配置文件不能被替换 (会报 device or resource busy),
+		// 退化为直接覆盖写入, 写入失败时可从备份文件恢复
+		logs.Warn("替换配置文件失败, 改为直接覆盖写入: %v", err)
+		return os.WriteFile(path, data, perm)
+	}
 	return nil
 }
+
+// copyFile 复制文件内容
+func copyFile(src, dst string, perm os.FileMode) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, perm)
+}
@@ -0,0 +1,155 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+const testPersistConfig = `# 测试配置
+emby:
+  host: http://127.0.0.1:8096 # Emby 地址
+
+# 节点配置
+nodes:
+  # 节点列表
+  list:
+    - name: "node-1"
+      host: "http://1.1.1.1"   # 节点一
+      weight: 100
+      enabled: true
+    - name: "node-2"
+      host: "http://2.2.2.2"   # 节点二
+      weight: 50
+      enabled: true
+`
+
+func TestSaveToFilePreservesComments(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(path, []byte(testPersistConfig), 0600); err != nil {
+		t.Fatalf("写入配置文件失败: %v", err)
+	}
+	if err := ReadFromFile(path); err != nil {
+		t.Fatalf("读取配置失败: %v", err)
+	}
+
+	C().Nodes.List[1].Enabled = false
+	C().Nodes.List = append(C().Nodes.List, Node{Name: "node-3", Host: "http://3.3.3.3", Weight: 80, Enabled: true})
+	C().Nodes.List = C().Nodes.List[1:]
+	if err := SaveToFile(); err != nil {
+		t.Fatalf("保存配置失败: %v", err)
+	}
+
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("读取配置文件失败: %v", err)
+	}
+	content := string(bytes)
+	want := `# 测试配置
+emby:
+  host: http://127.0.0.1:8096 # Emby 地址
+
+# 节点配置
+nodes:
+  # 节点列表
+  list:
+    - name: "node-2"
+      host: "http://2.2.2.2"   # 节点二
+      weight: 50
+      enabled: false
+    - name: "node-3"
+      host: "http://3.3.3.3"
+      weight: 80
+      enabled: true
+`
+	if content != want {
+		t.Errorf("只应修改发生变化的内容, 实际:\n%s", content)
+	}
+	for _, want := range []string{"# 测试配置", "# Emby 地址", "# 节点列表", "# 节点二", `name: "node-3"`, "enabled: false"} {
+		if !strings.Contains(content, want) {
+			t.Errorf("保存后的配置文件缺少: %s\n%s", want, content)
+		}
+	}
+	for _, unwanted := range []string{"node-1", "# 节点一", "telegram", "local-media-root", "health-check"} {
+		if strings.Contains(content, unwanted) {
+			t.Errorf("保存后的配置文件不应包含: %s\n%s", unwanted, content)
+		}
+	}
+
+	if stat, err := os.Stat(path); err != nil || stat.Mode().Perm() != 0600 {
+		t.Errorf("保存后应保留文件权限, 实际: %v, err: %v", stat.Mode().Perm(), err)
+	}
+	if backup, err := os.ReadFile(path + BackupSuffix); err != nil || string(backup) != testPersistConfig {
+		t.Errorf("应备份原配置文件, err: %v", err)
+	}
+
+	// 保存后的文件能够重新读取
+	if err := ReadFromFile(path); err != nil {
+		t.Fatalf("重新读取配置失败: %v", err)
+	}
+	if len(C().Nodes.List) != 2 || C().Nodes.List[0].Name != "node-2" || C().Nodes.List[0].Enabled || C().Nodes.List[1].Weight != 80 {
+		t.Errorf("重新读取的节点配置错误: %+v", C().Nodes.List)
+	}
+}
+
+func TestSaveToFileAddSection(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(path, []byte("emby:\n  host: http://127.0.0.1:8096\n"), 0644); err != nil {
+		t.Fatalf("写入配置文件失败: %v", err)
+	}
+	if err := ReadFromFile(path); err != nil {
+		t.Fatalf("读取配置失败: %v", err)
+	}
+
+	C().Nodes.List = append(C().Nodes.List, Node{Name: "node-1", Host: "http://1.1.1.1", Weight: 100, Enabled: true})
+	if err := SaveToFile(); err != nil {
+		t.Fatalf("保存配置失败: %v", err)
+	}
+
+	bytes, _ := os.ReadFile(path)
+	want := "emby:\n  host: http://127.0.0.1:8096\nnodes:\n  list:\n    - name: node-1\n      host: http://1.1.1.1\n      weight: 100\n      enabled: true\n"
+	if string(bytes) != want {
+		t.Errorf("原文件中没有的配置项只应追加发生变化的部分, 实际:\n%s", bytes)
+	}
+}
+
+func TestSaveToFilePatchError(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yml")
+	writeTestConfig(t, path, testPersistConfig)
+	t.Setenv("GE2O_EMBY_ADMIN_API_KEY", "key-from-env")
+	if err := ReadFromFile(path); err != nil {
+		t.Fatalf("读取配置失败: %v", err)
+	}
+
+	// 配置文件在运行期间被改坏, 无法在原文件上修改
+	broken := "emby:\n  host: [\n"
+	writeTestConfig(t, path, broken)
+	C().Nodes.List[0].Enabled = false
+	if err := SaveToFile(); err == nil {
+		t.Fatal("无法在原配置文件上修改时应返回错误")
+	}
+	if bytes, _ := os.ReadFile(path); string(bytes) != broken {
+		t.Errorf("保存失败时不应写入配置文件, 实际:\n%s", bytes)
+	}
+}
+
+func TestTextPatcherKeepsCommentColumn(t *testing.T) {
+	src := "a:\r\n  weight: 100       # 权重\r\n  enabled: true     # 是否启用\r\n"
+	var orig, base, want yaml.Node
+	yaml.Unmarshal([]byte(src), &orig)
+	yaml.Unmarshal([]byte("a:\n  weight: 100\n  enabled: true\n"), &base)
+	yaml.Unmarshal([]byte("a:\n  weight: 5\n  enabled: false\n"), &want)
+
+	tp := newTextPatcher([]byte(src))
+	if err := tp.patch(orig.Content[0], base.Content[0], want.Content[0]); err != nil {
+		t.Fatalf("修改失败: %v", err)
+	}
+	expect := "a:\r\n  weight: 5         # 权重\r\n  enabled: false    # 是否启用\r\n"
+	if got := string(tp.result()); got != expect {
+		t.Errorf("行尾注释位置错误, 期望:\n%q\n实际:\n%q", expect, got)
+	}
+}
@@ -0,0 +1,265 @@
+package config
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/logs"
+	"gopkg.in/yaml.v3"
+)
+
+// WatchInterval 配置文件变更检测间隔
+const WatchInterval = time.Second * 5
+
+// ReloadEvent 配置重载事件
+type ReloadEvent struct {
+	Old, New *Config
+	// Changed 发生变更的配置项, 如 nodes、cache
+	Changed []string
+	// RestartRequired 发生变更但需要重启程序才能生效的配置
+	RestartRequired []string
+}
+
+// Has 判断指定配置项是否发生变更
+func (e ReloadEvent) Has(section string) bool {
+	return slices.Contains(e.Changed, section)
+}
+
+// ReloadListener 配置重载成功后的回调
+type ReloadListener func(e ReloadEvent)
+
+var (
+	// reloadMu 保证同一时间只有一次重载
+	reloadMu sync.Mutex
+
+	reloadListeners  []ReloadListener
+	reloadListenerMu sync.Mutex
+
+	// loadedSum 当前生效的配置文件内容摘要, 用于忽略内容未变化的文件变更
+	loadedSum   string
+	loadedSumMu sync.Mutex
+)
+
+// restartFields 修改后需要重启才能生效的配置
+var restartFields = []struct {
+	name string
+	get  func(c *Config) any
+}{
+	{"emby.host", func(c *Config) any { return c.Emby.Host }},
+	{"emby.admin-api-key", func(c *Config) any { return c.Emby.AdminApiKey }},
+	{"nodes.health-check", func(c *Config) any { return c.Nodes.HealthCheck }},
+	{"auth.enable-auth-server", func(c *Config) any { return c.Auth.EnableAuthServer }},
+	{"auth.auth-server-port", func(c *Config) any { return c.Auth.AuthServerPort }},
+	{"auth.video-auth-secret", func(c *Config) any { return c.Auth.VideoAuthSecret }},
+	{"auth.auth-server-log", func(c *Config) any {
+		return []any{c.Auth.EnableAuthServerLog, c.Auth.AuthServerLogPath, c.Auth.AuthServerLogRotate, c.Auth.AuthServerLogBufferSize, c.Auth.AuthServerLogBlocking}
+	}},
+	{"cache.enable", func(c *Config) any { return c.Cache.Enable }},
+	{"cache.disk", func(c *Config) any { return c.Cache.Disk }},
+	{"ssl", func(c *Config) any { return c.Ssl }},
+	{"telegram.enable", func(c *Config) any { return c.Telegram.Enable }},
+	{"telegram.bot-token", func(c *Config) any { return c.Telegram.BotToken }},
+	{"telegram.webhook", func(c *Config) any { return []any{c.Telegram.WebhookMode, c.Telegram.WebhookURL} }},
+	{"audit", func(c *Config) any { return c.Audit }},
+	{"admin-api.client-ca", func(c *Config) any { return c.AdminApi.ClientCa }},
+}
+
+// OnReload 注册配置重载回调, 回调在新配置生效后按注册顺序同步执行
+func OnReload(fn ReloadListener) {
+	reloadListenerMu.Lock()
+	defer reloadListenerMu.Unlock()
+	reloadListeners = append(reloadListeners, fn)
+}
+
+// Reload 重新读取配置文件并应用
+//
+// 新配置所有配置项都初始化并生效成功后才会替换当前配置, 否则保持当前配置不变并返回错误;
+// 替换是整体替换全局配置对象, 读取方要么读到旧配置, 要么读到完整的新配置
+func Reload() (*ReloadEvent, error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	saveMutex.Lock()
+	if configFilePath == "" {
+		saveMutex.Unlock()
+		return nil, fmt.Errorf("配置文件路径未设置")
+	}
+	c, sum, err := load(configFilePath)
+	if err != nil {
+		saveMutex.Unlock()
+		return nil, err
+	}
+	old := C()
+	inheritGenerated(old, c)
+	if err := apply(c); err != nil {
+		saveMutex.Unlock()
+		return nil, err
+	}
+	Set(c)
+	markLoaded(sum)
+	saveMutex.Unlock()
+
+	e := ReloadEvent{Old: old, New: c, Changed: changedSections(old, c), RestartRequired: restartRequired(old, c)}
+
+	reloadListenerMu.Lock()
+	listeners := slices.Clone(reloadListeners)
+	reloadListenerMu.Unlock()
+	for _, fn := range listeners {
+		fn(e)
+	}
+
+	if len(e.Changed) == 0 {
+		logs.Info("配置已重载, 没有发生变更的配置项")
+	} else {
+		logs.Success("配置已重载, 变更项: %s", strings.Join(e.Changed, ", "))
+	}
+	if len(e.RestartRequired) > 0 {
+		logs.Warn("以下配置需要重启程序才能生效: %s", strings.Join(e.RestartRequired, ", "))
+	}
+	return &e, nil
+}
+
+// WatchFile 定时检测配置文件及引入的配置文件变更, 发生变更时自动重载, ctx 结束后停止检测
+//
+// 检测到变更后, 等待文件连续两次检测都没有变化再重载, 避免读取到写入一半的文件;
+// 内容与当前生效的配置一致时 (如程序自身保存配置) 不重载
+func WatchFile(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last, _ := filesStamp()
+	pending := ""
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		stamp, err := filesStamp()
+		if err != nil || stamp == last {
+			pending = ""
+			continue
+		}
+		if stamp != pending {
+			pending = stamp
+			continue
+		}
+		last, pending = stamp, ""
+
+		sum, err := currentChecksum()
+		if err != nil || sum == getLoaded() {
+			continue
+		}
+		logs.Info("检测到配置文件变更, 正在重载配置...")
+		if _, err := Reload(); err != nil {
+			logs.Error("配置重载失败, 继续使用当前配置: %v", err)
+		}
+	}
+}
+
+// inheritGenerated 新配置沿用旧配置中随机生成的默认值, 避免未修改的配置被识别为变更
+func inheritGenerated(old, new *Config) {
+	if old == nil || old.Emby == nil {
+		return
+	}
+	if old.Emby.randomRoot && new.Emby.randomRoot {
+		new.Emby.LocalMediaRoot = old.Emby.LocalMediaRoot
+	}
+	if old.Auth != nil && new.Auth != nil && old.Auth.generatedSecret && new.Auth.VideoAuthSecret == "" {
+		new.Auth.VideoAuthSecret, new.Auth.generatedSecret = old.Auth.VideoAuthSecret, true
+	}
+}
+
+// fileStamp 文件修改时间及大小
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// statFile 获取文件修改时间及大小
+func statFile(path string) (fileStamp, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}, err
+	}
+	return fileStamp{modTime: stat.ModTime(), size: stat.Size()}, nil
+}
+
+// filesStamp 获取主配置文件及引入的配置文件的修改时间及大小, 引入的文件增减时也会变化
+func filesStamp() (string, error) {
+	sb := strings.Builder{}
+	for _, path := range configFiles() {
+		stamp, err := statFile(path)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&sb, "%s|%d|%d\n", path, stamp.modTime.UnixNano(), stamp.size)
+	}
+	return sb.String(), nil
+}
+
+// checksum 计算配置文件内容摘要
+func checksum(bytes []byte) string {
+	sum := sha256.Sum256(bytes)
+	return hex.EncodeToString(sum[:])
+}
+
+// markLoaded 记录当前生效的配置文件内容摘要
+func markLoaded(sum string) {
+	loadedSumMu.Lock()
+	defer loadedSumMu.Unlock()
+	loadedSum = sum
+}
+
+// getLoaded 获取当前生效的配置文件内容摘要
+func getLoaded() string {
+	loadedSumMu.Lock()
+	defer loadedSumMu.Unlock()
+	return loadedSum
+}
+
+// changedSections 比较新旧配置, 返回发生变更的一级配置项名称
+func changedSections(old, new *Config) []string {
+	res := []string{}
+	if old == nil || new == nil {
+		return res
+	}
+	t := reflect.TypeOf(old).Elem()
+	oVal, nVal := reflect.ValueOf(old).Elem(), reflect.ValueOf(new).Elem()
+	for i := 0; i < t.NumField(); i++ {
+		if !sameYaml(oVal.Field(i).Interface(), nVal.Field(i).Interface()) {
+			res = append(res, strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0])
+		}
+	}
+	return res
+}
+
+// restartRequired 比较新旧配置, 返回发生变更但需要重启才能生效的配置
+func restartRequired(old, new *Config) []string {
+	res := []string{}
+	if old == nil || new == nil {
+		return res
+	}
+	for _, f := range restartFields {
+		if !sameYaml(f.get(old), f.get(new)) {
+			res = append(res, f.name)
+		}
+	}
+	return res
+}
+
+// sameYaml 按序列化结果比较两个配置值, 忽略初始化时生成的内部字段
+func sameYaml(a, b any) bool {
+	ab, err1 := yaml.Marshal(a)
+	bb, err2 := yaml.Marshal(b)
+	return err1 == nil && err2 == nil && string(ab) == string(bb)
+}
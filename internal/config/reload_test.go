@@ -0,0 +1,120 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// writeTestConfig 写入测试配置文件
+func writeTestConfig(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("写入配置文件失败: %v", err)
+	}
+}
+
+const testReloadConfig = `
+emby:
+  host: http://127.0.0.1:8096
+nodes:
+  list:
+    - name: node-1
+      host: http://1.1.1.1
+      weight: 100
+      enabled: true
+`
+
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	writeTestConfig(t, path, testReloadConfig)
+	if err := ReadFromFile(path); err != nil {
+		t.Fatalf("读取配置失败: %v", err)
+	}
+	old := C()
+
+	events := make(chan ReloadEvent, 1)
+	OnReload(func(e ReloadEvent) { events <- e })
+
+	// 配置有误时拒绝重载
+	writeTestConfig(t, path, "emby:\n  host: \"\"\n")
+	if _, err := Reload(); err == nil {
+		t.Fatal("emby.host 为空时应重载失败")
+	}
+	if C() != old {
+		t.Fatal("重载失败时不应替换当前配置")
+	}
+
+	// 配置无法生效 (日志文件无法打开) 时同样拒绝重载
+	writeTestConfig(t, path, testReloadConfig+"log:\n  file: "+filepath.Join(path, "app.log")+"\n")
+	if _, err := Reload(); err == nil {
+		t.Fatal("日志文件无法打开时应重载失败")
+	}
+	if C() != old {
+		t.Fatal("配置无法生效时不应替换当前配置")
+	}
+
+	writeTestConfig(t, path, testReloadConfig+`    - name: node-2
+      host: http://2.2.2.2
+      weight: 50
+      enabled: true
+ssl:
+  enable: false
+  key: a.key
+`)
+	e, err := Reload()
+	if err != nil {
+		t.Fatalf("重载失败: %v", err)
+	}
+	if C() == old || len(C().Nodes.List) != 2 {
+		t.Fatalf("重载后配置未替换, 节点数: %d", len(C().Nodes.List))
+	}
+	if !slices.Equal(e.Changed, []string{"nodes", "ssl"}) {
+		t.Errorf("变更项错误: %v", e.Changed)
+	}
+	if !slices.Equal(e.RestartRequired, []string{"ssl"}) {
+		t.Errorf("需要重启的配置错误: %v", e.RestartRequired)
+	}
+	select {
+	case got := <-events:
+		if got.Old != old || got.New != C() || !got.Has("nodes") {
+			t.Errorf("重载回调参数错误: %+v", got)
+		}
+	default:
+		t.Error("重载成功后应执行回调")
+	}
+}
+
+func TestWatchFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	writeTestConfig(t, path, testReloadConfig)
+	if err := ReadFromFile(path); err != nil {
+		t.Fatalf("读取配置失败: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go WatchFile(ctx, time.Millisecond*20)
+
+	// 程序自身保存的配置不触发重载
+	old := C()
+	if err := SaveToFile(); err != nil {
+		t.Fatalf("保存配置失败: %v", err)
+	}
+	time.Sleep(time.Millisecond * 200)
+	if C() != old {
+		t.Fatal("程序自身保存配置后不应重载")
+	}
+
+	writeTestConfig(t, path, testReloadConfig+"cache:\n  enable: true\n  expired: 2h\n")
+	deadline := time.Now().Add(time.Second * 3)
+	for C().Cache.ExpiredDuration() != time.Hour*2 {
+		if time.Now().After(deadline) {
+			t.Fatal("配置文件变更后未自动重载")
+		}
+		time.Sleep(time.Millisecond * 20)
+	}
+}
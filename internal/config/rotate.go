@@ -0,0 +1,45 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/rotates"
+)
+
+// LogRotate 日志文件轮转配置
+type LogRotate struct {
+	MaxSize         int           `yaml:"max-size"`         // 单个文件最大大小 (MB), 默认 100, 设置为 -1 表示不按大小轮转
+	Interval        time.Duration `yaml:"interval"`         // 按时间轮转的周期, 如 24h, 默认不按时间轮转
+	MaxBackups      int           `yaml:"max-backups"`      // 最多保留的轮转文件数, 默认 7, 设置为 -1 表示不限制
+	MaxAge          time.Duration `yaml:"max-age"`          // 轮转文件的最长保留时间, 如 720h, 默认不限制
+	DisableCompress bool          `yaml:"disable-compress"` // 是否禁用 gzip 压缩轮转文件
+}
+
+// Init 配置初始化
+func (r *LogRotate) Init() error {
+	if r.MaxSize == 0 {
+		r.MaxSize = 100
+	}
+	if r.MaxBackups == 0 {
+		r.MaxBackups = 7
+	}
+	if r.Interval < 0 {
+		return fmt.Errorf("interval 配置错误: %v, 值不能小于 0", r.Interval)
+	}
+	if r.MaxAge < 0 {
+		return fmt.Errorf("max-age 配置错误: %v, 值不能小于 0", r.MaxAge)
+	}
+	return nil
+}
+
+// Options 转换为轮转写入器参数
+func (r *LogRotate) Options() rotates.Options {
+	return rotates.Options{
+		MaxSize:    int64(r.MaxSize) * 1024 * 1024,
+		Interval:   r.Interval,
+		MaxBackups: r.MaxBackups,
+		MaxAge:     r.MaxAge,
+		Compress:   !r.DisableCompress,
+	}
+}
@@ -0,0 +1,24 @@
+//go:build !windows
+
+package config
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/logs"
+)
+
+// NotifyReload 收到 SIGHUP 信号时重载配置文件
+func NotifyReload() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	go func() {
+		for range ch {
+			if _, err := Reload(); err != nil {
+				logs.Error("配置重载失败, 继续使用当前配置: %v", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,6 @@
+//go:build windows
+
+package config
+
+// NotifyReload Windows 不支持 SIGHUP 信号, 忽略, 仍可通过修改配置文件触发重载
+func NotifyReload() {}
@@ -0,0 +1,420 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// errPatchUnsupported 配置结构无法直接修改原文件文本
+var errPatchUnsupported = errors.New("无法直接修改原配置文件")
+
+// textEdit 一处文本修改, 将 [start, end) 替换为 text
+type textEdit struct {
+	start, end int
+	text       string
+}
+
+// textPatcher 按节点在原文件中的位置直接修改文本
+//
+// 只改动发生变化的值及数组元素, 其余内容 (注释、空行、对齐) 原样保留
+type textPatcher struct {
+	src   []byte
+	lines []int // 每行起始位置
+	crlf  bool
+	edits []textEdit
+}
+
+// newTextPatcher 创建文本修改器
+func newTextPatcher(src []byte) *textPatcher {
+	tp := &textPatcher{src: src, lines: []int{0}, crlf: bytes.Contains(src, []byte("\r\n"))}
+	for i, b := range src {
+		if b == '\n' {
+			tp.lines = append(tp.lines, i+1)
+		}
+	}
+	return tp
+}
+
+// result 应用所有修改, 返回修改后的文件内容
+func (tp *textPatcher) result() []byte {
+	edits := append([]textEdit(nil), tp.edits...)
+	sort.SliceStable(edits, func(i, j int) bool { return edits[i].start < edits[j].start })
+
+	buf := bytes.Buffer{}
+	last := 0
+	for _, e := range edits {
+		buf.Write(tp.src[last:e.start])
+		buf.WriteString(e.text)
+		last = e.end
+	}
+	buf.Write(tp.src[last:])
+	return buf.Bytes()
+}
+
+// lineStart 获取指定行 (从 1 开始) 的起始位置, 超出文件末尾时返回文件长度
+func (tp *textPatcher) lineStart(line int) int {
+	if line-1 < len(tp.lines) {
+		return tp.lines[line-1]
+	}
+	return len(tp.src)
+}
+
+// offset 获取节点在文件中的起始位置
+func (tp *textPatcher) offset(n *yaml.Node) int {
+	return tp.lineStart(n.Line) + n.Column - 1
+}
+
+// patch 将 base 到 want 的变化记录为对 orig 所在文本的修改
+func (tp *textPatcher) patch(orig, base, want *yaml.Node) error {
+	if base != nil && nodeEqual(base, want) {
+		return nil
+	}
+	if orig.Style&yaml.FlowStyle != 0 {
+		return errPatchUnsupported
+	}
+	switch {
+	case orig.Kind == yaml.MappingNode && want.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(want.Content); i += 2 {
+			key, wantVal := want.Content[i].Value, want.Content[i+1]
+			baseVal := mappingValue(base, key)
+			if baseVal != nil && nodeEqual(baseVal, wantVal) {
+				continue
+			}
+			origVal := mappingValue(orig, key)
+			if origVal == nil {
+				return errPatchUnsupported
+			}
+			if err := tp.patch(origVal, baseVal, wantVal); err != nil {
+				return err
+			}
+		}
+		return nil
+	case orig.Kind == yaml.SequenceNode && want.Kind == yaml.SequenceNode:
+		return tp.patchSequence(orig, base, want)
+	case orig.Kind == yaml.ScalarNode && want.Kind == yaml.ScalarNode:
+		return tp.patchScalar(orig, want)
+	}
+	return errPatchUnsupported
+}
+
+// patchScalar 替换标量值, 保留原有的引号风格及行尾注释的对齐位置
+func (tp *textPatcher) patchScalar(orig, want *yaml.Node) error {
+	if strings.Contains(orig.Value, "\n") || orig.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+		return errPatchUnsupported
+	}
+	start := tp.offset(orig)
+	end, ok := tp.scalarEnd(start, orig.Style)
+	if !ok {
+		return errPatchUnsupported
+	}
+
+	n := &yaml.Node{Kind: yaml.ScalarNode, Tag: want.Tag, Value: want.Value, Style: want.Style}
+	if orig.ShortTag() == want.ShortTag() {
+		n.Style = orig.Style
+	}
+	out, err := yaml.Marshal(n)
+	if err != nil {
+		return err
+	}
+	text := strings.TrimSuffix(string(out), "\n")
+	if strings.Contains(text, "\n") {
+		return errPatchUnsupported
+	}
+
+	// 值后面紧跟行尾注释时, 调整空格数量使注释保持原来的位置
+	pad := end
+	for pad < len(tp.src) && tp.src[pad] == ' ' {
+		pad++
+	}
+	if pad > end && pad < len(tp.src) && tp.src[pad] == '#' {
+		spaces := max(1, pad-end+(end-start)-len(text))
+		text += strings.Repeat(" ", spaces)
+		end = pad
+	}
+	tp.edits = append(tp.edits, textEdit{start: start, end: end, text: text})
+	return nil
+}
+
+// scalarEnd 获取从 start 开始的单行标量在文件中的结束位置
+func (tp *textPatcher) scalarEnd(start int, style yaml.Style) (int, bool) {
+	src := tp.src
+	switch {
+	case style&yaml.DoubleQuotedStyle != 0:
+		for i := start + 1; i < len(src) && src[i] != '\n'; i++ {
+			if src[i] == '\\' {
+				i++
+			} else if src[i] == '"' {
+				return i + 1, true
+			}
+		}
+		return 0, false
+	case style&yaml.SingleQuotedStyle != 0:
+		for i := start + 1; i < len(src) && src[i] != '\n'; i++ {
+			if src[i] != '\'' {
+				continue
+			}
+			if i+1 < len(src) && src[i+1] == '\'' {
+				i++
+				continue
+			}
+			return i + 1, true
+		}
+		return 0, false
+	}
+
+	end := start
+	for end < len(src) && src[end] != '\n' && src[end] != '\r' {
+		if src[end] == '#' && end > start && src[end-1] == ' ' {
+			break
+		}
+		end++
+	}
+	for end > start && (src[end-1] == ' ' || src[end-1] == '\t') {
+		end--
+	}
+	return end, true
+}
+
+// patchSequence 修改按 name 对应的对象数组 (如节点列表), 删除、修改及在末尾追加元素
+func (tp *textPatcher) patchSequence(orig, base, want *yaml.Node) error {
+	origByName, ok := indexByName(orig)
+	wantByName, wantOk := indexByName(want)
+	if !ok || !wantOk || len(orig.Content) == 0 || len(wantByName) != len(want.Content) || len(origByName) != len(orig.Content) {
+		return errPatchUnsupported
+	}
+	baseByName, _ := indexByName(base)
+
+	// 保留的元素顺序不变时才能直接修改
+	kept := make([]string, 0, len(orig.Content))
+	for _, el := range orig.Content {
+		if name := mappingValue(el, "name").Value; wantByName[name] != nil {
+			kept = append(kept, name)
+		}
+	}
+	var added []*yaml.Node
+	idx := 0
+	for _, el := range want.Content {
+		name := mappingValue(el, "name").Value
+		if origByName[name] == nil {
+			added = append(added, el)
+			continue
+		}
+		if len(added) > 0 || idx >= len(kept) || kept[idx] != name {
+			return errPatchUnsupported
+		}
+		idx++
+	}
+
+	first := orig.Content[0]
+	dash := tp.offset(first) - 2
+	lineStart := tp.lineStart(first.Line)
+	if dash < lineStart || tp.src[dash] != '-' || strings.TrimSpace(string(tp.src[lineStart:dash])) != "" {
+		return errPatchUnsupported
+	}
+	indent := string(tp.src[lineStart:dash])
+
+	for _, el := range orig.Content {
+		name := mappingValue(el, "name").Value
+		if wantEl := wantByName[name]; wantEl != nil {
+			if err := tp.patch(el, baseByName[name], wantEl); err != nil {
+				return err
+			}
+			continue
+		}
+		tp.edits = append(tp.edits, textEdit{start: tp.elementStart(el), end: tp.lineStart(lastLine(el) + 1)})
+	}
+
+	if len(added) == 0 {
+		return nil
+	}
+	for _, el := range added {
+		copyStyle(el, orig.Content[len(orig.Content)-1])
+	}
+	out, err := marshalYaml(&yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Content: added})
+	if err != nil {
+		return err
+	}
+	lines := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+	newline := "\n"
+	if tp.crlf {
+		newline = "\r\n"
+	}
+	text := ""
+	for _, line := range lines {
+		text += indent + line + newline
+	}
+
+	at := tp.lineStart(lastLine(orig.Content[len(orig.Content)-1]) + 1)
+	if at == len(tp.src) && len(tp.src) > 0 && tp.src[len(tp.src)-1] != '\n' {
+		text = newline + text
+	}
+	tp.edits = append(tp.edits, textEdit{start: at, end: at, text: text})
+	return nil
+}
+
+// elementStart 获取数组元素所在行的起始位置, 包含元素上方紧邻的注释行
+func (tp *textPatcher) elementStart(el *yaml.Node) int {
+	line := el.Line
+	head := el.HeadComment
+	if head == "" && len(el.Content) > 0 {
+		head = el.Content[0].HeadComment
+	}
+	if head != "" {
+		line -= strings.Count(head, "\n") + 1
+	}
+	return tp.lineStart(max(line, 1))
+}
+
+// lastLine 获取节点及其子节点所在的最后一行
+func lastLine(n *yaml.Node) int {
+	line := n.Line
+	for _, c := range n.Content {
+		line = max(line, lastLine(c))
+	}
+	return line
+}
+
+// patchNode 将 base 到 want 的变化应用到 orig 上, 用于无法直接修改原文件文本时
+//
+// orig 为原配置文件中的节点, 保留其注释及顺序; 未发生变化的节点不做修改, 保留其注释及顺序; 未发生变化的节点不做修改
+func patchNode(orig, base, want *yaml.Node) {
+	if base != nil && nodeEqual(base, want) {
+		return
+	}
+	switch {
+	case orig.Kind == yaml.MappingNode && want.Kind == yaml.MappingNode:
+		patchMapping(orig, base, want)
+	case orig.Kind == yaml.SequenceNode && want.Kind == yaml.SequenceNode:
+		patchSequence(orig, base, want)
+	case orig.Kind == yaml.ScalarNode && want.Kind == yaml.ScalarNode:
+		// 类型不变时保留原有的引号风格
+		if orig.Tag != want.Tag {
+			orig.Style = want.Style
+		}
+		orig.Tag, orig.Value = want.Tag, want.Value
+	default:
+		orig.Kind, orig.Style, orig.Tag, orig.Value, orig.Content = want.Kind, want.Style, want.Tag, want.Value, want.Content
+	}
+}
+
+// patchMapping 按 key 逐个修改对象节点, 原文件中没有的 key 追加到末尾
+func patchMapping(orig, base, want *yaml.Node) {
+	for i := 0; i+1 < len(want.Content); i += 2 {
+		key, wantVal := want.Content[i].Value, want.Content[i+1]
+		baseVal := mappingValue(base, key)
+		if baseVal != nil && nodeEqual(baseVal, wantVal) {
+			continue
+		}
+
+		if origVal := mappingValue(orig, key); origVal != nil {
+			patchNode(origVal, baseVal, wantVal)
+			continue
+		}
+
+		// 原文件中没有配置该项, 对象只追加发生变化的子项
+		if wantVal.Kind == yaml.MappingNode && baseVal != nil && baseVal.Kind == yaml.MappingNode {
+			sub := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			patchMapping(sub, baseVal, wantVal)
+			if len(sub.Content) == 0 {
+				continue
+			}
+			wantVal = sub
+		}
+		orig.Content = append(orig.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, wantVal)
+	}
+}
+
+// patchSequence 修改数组节点
+//
+// 元素均为带 name 字段的对象时 (如节点列表) 按 name 对应, 保留未删除元素的注释;
+// 否则整体替换
+func patchSequence(orig, base, want *yaml.Node) {
+	origByName, ok := indexByName(orig)
+	wantByName, wantOk := indexByName(want)
+	if !ok || !wantOk || len(wantByName) != len(want.Content) {
+		orig.Content = want.Content
+		return
+	}
+	baseByName, _ := indexByName(base)
+	if len(orig.Content) == 0 {
+		// 原配置为 [] 时改为块风格输出
+		orig.Style = 0
+	}
+
+	content := make([]*yaml.Node, 0, len(want.Content))
+	for _, wantEl := range want.Content {
+		name := mappingValue(wantEl, "name").Value
+		if origEl, ok := origByName[name]; ok {
+			patchNode(origEl, baseByName[name], wantEl)
+			content = append(content, origEl)
+			continue
+		}
+		if len(orig.Content) > 0 {
+			copyStyle(wantEl, orig.Content[len(orig.Content)-1])
+		}
+		content = append(content, wantEl)
+	}
+	orig.Content = content
+}
+
+// indexByName 将元素均为带 name 字段对象的数组按 name 建立索引, 不满足条件时返回 false
+func indexByName(n *yaml.Node) (map[string]*yaml.Node, bool) {
+	if n == nil || n.Kind != yaml.SequenceNode {
+		return nil, false
+	}
+	res := make(map[string]*yaml.Node, len(n.Content))
+	for _, el := range n.Content {
+		name := mappingValue(el, "name")
+		if el.Kind != yaml.MappingNode || name == nil || name.Kind != yaml.ScalarNode {
+			return nil, false
+		}
+		res[name.Value] = el
+	}
+	return res, true
+}
+
+// copyStyle 新增的数组元素沿用相邻元素的引号风格
+func copyStyle(dst, sibling *yaml.Node) {
+	if dst.Kind != yaml.MappingNode || sibling.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(dst.Content); i += 2 {
+		ref := mappingValue(sibling, dst.Content[i].Value)
+		if val := dst.Content[i+1]; ref != nil && ref.Kind == yaml.ScalarNode && val.Kind == yaml.ScalarNode && ref.Tag == val.Tag {
+			val.Style = ref.Style
+		}
+	}
+}
+
+// mappingValue 获取对象节点中指定 key 的值节点, 不存在时返回 nil
+func mappingValue(n *yaml.Node, key string) *yaml.Node {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// nodeEqual 比较两个节点的内容是否相同, 忽略注释及风格
+func nodeEqual(a, b *yaml.Node) bool {
+	if a.Kind != b.Kind || len(a.Content) != len(b.Content) {
+		return false
+	}
+	if a.Kind == yaml.ScalarNode && (a.Value != b.Value || a.ShortTag() != b.ShortTag()) {
+		return false
+	}
+	for i := range a.Content {
+		if !nodeEqual(a.Content[i], b.Content[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,240 @@
+package audit
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/config"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/logs"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/metrics"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/rotates"
+)
+
+// droppedTotal 缓冲区已满被丢弃的日志数, 与鉴权访问日志共用同一指标
+var droppedTotal = metrics.NewCounterVec(
+	"ge2o_access_log_dropped_total",
+	"缓冲区已满被丢弃的日志条数",
+	"log",
+)
+
+// Event 一次串流/下载请求的审计事件
+type Event struct {
+	Timestamp     time.Time     `json:"timestamp"`
+	RequestId     string        `json:"request_id"`
+	RemoteIP      string        `json:"remote_ip"`
+	Method        string        `json:"method"`
+	URI           string        `json:"uri"`      // 已脱敏
+	Route         string        `json:"route"`    // stream/download/original
+	Decision      string        `json:"decision"` // redirect/local/forbidden/no_node/error/origin/rejected
+	User          string        `json:"user"`
+	UserId        string        `json:"user_id"`
+	ItemId        string        `json:"item_id"`
+	MediaSourceId string        `json:"media_source_id"`
+	EmbyPath      string        `json:"emby_path"`
+	NginxPath     string        `json:"nginx_path"`
+	Node          string        `json:"node"`
+	RedirectURL   string        `json:"redirect_url"` // 已脱敏
+	CacheHit      bool          `json:"cache_hit"`
+	Status        int           `json:"status"`
+	Latency       time.Duration `json:"latency"`
+	Error         string        `json:"error,omitempty"`
+}
+
+// recentSize 内存中保留的最近审计事件个数
+const recentSize = 200
+
+// Logger 审计日志记录器
+type Logger struct {
+	writer   *rotates.Writer
+	bufferCh chan Event
+	closeCh  chan struct{}
+	doneCh   chan struct{}
+	blocking bool
+
+	recent   []Event // 最近写入的事件, 环形缓冲区
+	recentAt int     // 下一个事件在 recent 中的位置
+	recentMu sync.RWMutex
+}
+
+// logger 全局审计日志记录器, 未启用时为 nil
+var logger *Logger
+
+// Init 根据配置初始化审计日志
+func Init(cfg *config.Audit) error {
+	if !cfg.Enable {
+		return nil
+	}
+
+	opts := cfg.Rotate.Options()
+	opts.OnRotate = rotates.LogRotated
+	writer, err := rotates.NewWriter(cfg.Path, opts)
+	if err != nil {
+		return err
+	}
+	logger = &Logger{
+		writer:   writer,
+		bufferCh: make(chan Event, cfg.BufferSize),
+		closeCh:  make(chan struct{}),
+		doneCh:   make(chan struct{}),
+		blocking: cfg.Blocking,
+		recent:   make([]Event, 0, recentSize),
+	}
+	go logger.writeLoop()
+
+	rotates.NotifyReopen(func() {
+		if err := writer.Reopen(); err != nil {
+			logs.Error("重新打开审计日志失败: %v", err)
+		}
+	})
+
+	logs.Success("重定向审计日志已启动，日志文件: %s", cfg.Path)
+	return nil
+}
+
+// Enabled 是否启用了审计日志
+func Enabled() bool {
+	return logger != nil
+}
+
+// LogPath 审计日志文件路径
+func LogPath() string {
+	if logger == nil {
+		return ""
+	}
+	return logger.writer.Path()
+}
+
+// Log 记录审计事件（异步）
+func Log(e Event) {
+	if logger == nil {
+		return
+	}
+	e.URI = MaskURL(e.URI)
+	e.RedirectURL = MaskURL(e.RedirectURL)
+
+	if logger.blocking {
+		select {
+		case logger.bufferCh <- e:
+		case <-logger.closeCh:
+			droppedTotal.With("audit").Inc()
+		}
+		return
+	}
+
+	select {
+	case logger.bufferCh <- e:
+	default:
+		droppedTotal.With("audit").Inc()
+		logs.Warn("审计日志缓冲区已满，丢弃日志")
+	}
+}
+
+// Close 写入缓冲区中剩余的事件并关闭日志文件
+func Close() error {
+	if logger == nil {
+		return nil
+	}
+	close(logger.closeCh)
+	<-logger.doneCh
+	return logger.writer.Close()
+}
+
+// Recent 获取最近写入的审计事件, 最新的在前, 最多 limit 条
+//
+// 只保留最近 200 条, 更早的事件需要查看日志文件; 未启用审计日志时返回空
+func Recent(limit int) []Event {
+	if logger == nil {
+		return []Event{}
+	}
+	l := logger
+	l.recentMu.RLock()
+	defer l.recentMu.RUnlock()
+
+	n := len(l.recent)
+	if limit <= 0 || limit > n {
+		limit = n
+	}
+	res := make([]Event, 0, limit)
+	for i := 1; i <= limit; i++ {
+		res = append(res, l.recent[(l.recentAt-i+n)%n])
+	}
+	return res
+}
+
+// remember 将事件保存到最近事件的环形缓冲区中
+func (l *Logger) remember(e Event) {
+	l.recentMu.Lock()
+	defer l.recentMu.Unlock()
+	if len(l.recent) < recentSize {
+		l.recent = append(l.recent, e)
+	} else {
+		l.recent[l.recentAt] = e
+	}
+	l.recentAt = (l.recentAt + 1) % recentSize
+}
+
+// writeLoop 日志写入循环
+func (l *Logger) writeLoop() {
+	defer close(l.doneCh)
+	for {
+		select {
+		case e := <-l.bufferCh:
+			l.write(e)
+		case <-l.closeCh:
+			for len(l.bufferCh) > 0 {
+				l.write(<-l.bufferCh)
+			}
+			return
+		}
+	}
+}
+
+// write 写入单条事件
+func (l *Logger) write(e Event) {
+	l.remember(e)
+	data, err := json.Marshal(e)
+	if err != nil {
+		logs.Error("序列化审计日志失败: %v", err)
+		return
+	}
+	if _, err := l.writer.Write(append(data, '\n')); err != nil {
+		logs.Error("写入审计日志失败: %v", err)
+	}
+}
+
+// secretParams 需要脱敏的地址参数
+var secretParams = []string{"api_key", "X-Emby-Token", "x-emby-token"}
+
+// MaskURL 隐藏地址中携带的密钥参数
+func MaskURL(rawUrl string) string {
+	idx := strings.Index(rawUrl, "?")
+	if idx == -1 {
+		return rawUrl
+	}
+	q, err := url.ParseQuery(rawUrl[idx+1:])
+	if err != nil {
+		return rawUrl
+	}
+	masked := false
+	for _, key := range secretParams {
+		if v := q.Get(key); v != "" {
+			q.Set(key, maskSecret(v))
+			masked = true
+		}
+	}
+	if !masked {
+		return rawUrl
+	}
+	return rawUrl[:idx+1] + q.Encode()
+}
+
+// maskSecret 只保留密钥首尾 4 位
+func maskSecret(s string) string {
+	if len(s) <= 8 {
+		return "****"
+	}
+	return s[:4] + "****" + s[len(s)-4:]
+}
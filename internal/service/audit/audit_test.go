@@ -0,0 +1,101 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/config"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/web/cache"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMiddleware(t *testing.T) {
+	config.Set(&config.Config{Nodes: &config.Nodes{List: []config.Node{{Name: "node1", Host: "http://1.2.3.4:80", Enabled: true}}}})
+	cfg := &config.Audit{Enable: true, Path: filepath.Join(t.TempDir(), "audit.log")}
+	if err := cfg.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if err := Init(cfg); err != nil {
+		t.Fatalf("初始化审计日志失败: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Middleware(Route{Name: "stream", Pattern: regexp.MustCompile(`/stream`)}))
+	r.GET("/videos/:id/stream", func(c *gin.Context) {
+		if c.Query("cached") != "" {
+			// 模拟缓存直接响应
+			c.Set(cache.HitGinKey, true)
+			c.Redirect(http.StatusTemporaryRedirect, "http://1.2.3.4:80/video/a.mp4?api_key=abcdefghijkl")
+			return
+		}
+		e := From(c)
+		e.Decision, e.EmbyPath, e.NginxPath = "local", "/media/a.mp4", ""
+		c.String(http.StatusOK, "ok")
+	})
+	r.GET("/other", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+
+	for _, uri := range []string{"/videos/100/stream?api_key=abcdefghijkl", "/videos/200/stream?cached=1", "/other"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, uri, nil))
+	}
+	// 缓存后台刷新请求不记录
+	refresh := httptest.NewRequest(http.MethodGet, "/videos/300/stream?api_key=abcdefghijkl", nil)
+	r.ServeHTTP(httptest.NewRecorder(), refresh.WithContext(cache.WithRefresh(refresh.Context())))
+	if err := Close(); err != nil {
+		t.Fatalf("关闭审计日志失败: %v", err)
+	}
+	if recent := Recent(10); len(recent) != 2 || recent[0].ItemId != "200" || recent[1].ItemId != "100" {
+		t.Errorf("最近的审计事件应按时间倒序: %+v", recent)
+	}
+	logger = nil
+
+	file, err := os.Open(cfg.Path)
+	if err != nil {
+		t.Fatalf("打开审计日志失败: %v", err)
+	}
+	defer file.Close()
+	events := make([]Event, 0)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("解析审计日志失败: %v", err)
+		}
+		events = append(events, e)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("期望记录 2 条审计事件, 实际: %d", len(events))
+	}
+	if e := events[0]; e.ItemId != "100" || e.Decision != "local" || e.EmbyPath != "/media/a.mp4" || e.CacheHit || e.URI != "/videos/100/stream?api_key=abcd%2A%2A%2A%2Aijkl" {
+		t.Errorf("处理器补充的审计事件不正确: %+v", e)
+	}
+	if e := events[1]; !e.CacheHit || e.Decision != DecisionRedirect || e.Node != "node1" || e.ItemId != "200" || e.Status != http.StatusTemporaryRedirect {
+		t.Errorf("缓存命中的审计事件不正确: %+v", e)
+	}
+}
+
+func TestRecent(t *testing.T) {
+	defer func() { logger = nil }()
+	logger = &Logger{}
+	if len(Recent(10)) != 0 {
+		t.Error("未写入事件时应返回空")
+	}
+	for i := range recentSize + 5 {
+		logger.remember(Event{Status: i})
+	}
+	recent := Recent(0)
+	if len(recent) != recentSize || recent[0].Status != recentSize+4 || recent[recentSize-1].Status != 5 {
+		t.Errorf("超出上限时应丢弃最早的事件: %d %d %d", len(recent), recent[0].Status, recent[len(recent)-1].Status)
+	}
+	if recent = Recent(3); len(recent) != 3 || recent[2].Status != recentSize+2 {
+		t.Errorf("limit 不生效: %+v", recent)
+	}
+}
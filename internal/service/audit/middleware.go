@@ -0,0 +1,125 @@
+package audit
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/config"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/service/userkey"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/https"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/reqids"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/web/cache"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EventGinKey 审计事件存放在 gin 上下文中的 key
+const EventGinKey = "auditEvent"
+
+// 处理器未补充决策时, 根据响应推断的决策类型
+const (
+	DecisionRedirect = "redirect" // 重定向 (缓存命中)
+	DecisionOrigin   = "origin"   // 代理回源
+	DecisionRejected = "rejected" // 请求被拒绝
+)
+
+// From 获取当前请求的审计事件, 供处理器补充处理细节
+//
+// 请求不在审计范围内时返回一个不会被记录的事件, 调用方无需判空
+func From(c *gin.Context) *Event {
+	if v, ok := c.Get(EventGinKey); ok {
+		if e, ok := v.(*Event); ok {
+			return e
+		}
+	}
+	return new(Event)
+}
+
+// Route 审计的路由
+type Route struct {
+	Name    string         // 路由名称, 如 stream/download
+	Pattern *regexp.Regexp // 匹配的请求地址
+}
+
+// Middleware 为匹配的串流/下载请求记录审计事件
+//
+// 需要注册在缓存中间件之前, 才能记录到由缓存直接响应的请求; 缓存后台刷新请求不记录
+func Middleware(routes ...Route) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !Enabled() || cache.IsRefresh(c.Request.Context()) {
+			return
+		}
+
+		route := ""
+		for _, r := range routes {
+			if r.Pattern.MatchString(c.Request.RequestURI) {
+				route = r.Name
+				break
+			}
+		}
+		if route == "" {
+			return
+		}
+
+		start := time.Now()
+		e := &Event{
+			Timestamp: start,
+			RequestId: reqids.Get(c),
+			RemoteIP:  c.ClientIP(),
+			Method:    c.Request.Method,
+			URI:       c.Request.RequestURI,
+			Route:     route,
+			ItemId:    path.Base(path.Dir(c.Request.URL.Path)),
+		}
+		c.Set(EventGinKey, e)
+
+		c.Next()
+
+		e.Status = c.Writer.Status()
+		e.Latency = time.Since(start)
+		e.CacheHit = c.GetBool(cache.HitGinKey)
+		if user, ok := userkey.GetUser(c); ok {
+			e.User, e.UserId = user.Name, user.Id
+		}
+		if https.IsRedirectCode(e.Status) && e.RedirectURL == "" {
+			e.RedirectURL = c.Writer.Header().Get("Location")
+		}
+		if e.Node == "" && e.RedirectURL != "" {
+			e.Node = nodeNameByUrl(e.RedirectURL)
+		}
+		if e.Decision == "" {
+			e.Decision = inferDecision(e.Status)
+		}
+		Log(*e)
+	}
+}
+
+// inferDecision 处理器没有记录决策时 (如缓存命中、被前置中间件拦截), 根据响应码推断
+func inferDecision(status int) string {
+	switch {
+	case https.IsRedirectCode(status):
+		return DecisionRedirect
+	case status >= http.StatusBadRequest:
+		return DecisionRejected
+	}
+	return DecisionOrigin
+}
+
+// nodeNameByUrl 根据重定向地址查找节点名称
+func nodeNameByUrl(rawUrl string) string {
+	u, err := url.Parse(rawUrl)
+	if err != nil || u.Host == "" || config.C() == nil || config.C().Nodes == nil {
+		return ""
+	}
+	for _, n := range config.C().Nodes.List {
+		nu, err := url.Parse(n.Host)
+		if err == nil && strings.EqualFold(nu.Host, u.Host) {
+			return n.Name
+		}
+	}
+	return ""
+}
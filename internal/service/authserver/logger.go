@@ -2,86 +2,118 @@ package authserver
 
 import (
 	"encoding/json"
-	"fmt"
-	"os"
-	"path/filepath"
-	"sync"
 	"time"
 
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/config"
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/logs"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/metrics"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/rotates"
+)
+
+// droppedLogTotal 缓冲区已满被丢弃的日志数
+var droppedLogTotal = metrics.NewCounterVec(
+	"ge2o_access_log_dropped_total",
+	"缓冲区已满被丢弃的日志条数",
+	"log",
 )
 
 // AccessLogger 访问日志记录器
 type AccessLogger struct {
-	logFile   *os.File
+	writer    *rotates.Writer
 	logPath   string
-	stats     *Stats
-	mu        sync.Mutex
+	stats     *StatsStore
 	bufferCh  chan AccessLog
 	closeCh   chan struct{}
+	doneCh    chan struct{} // 写入协程处理完剩余日志后关闭
 	enableLog bool
+	blocking  bool // 缓冲区满时阻塞等待而不是丢弃
 }
 
 // NewAccessLogger 创建访问日志记录器
-func NewAccessLogger(logPath string, enableLog bool) (*AccessLogger, error) {
-	logger := &AccessLogger{
-		logPath:   logPath,
-		enableLog: enableLog,
-		stats: &Stats{
-			FailReasons: make(map[string]int64),
-			TopUsers:    make([]UserStats, 0),
-		},
-		bufferCh: make(chan AccessLog, 1000),
-		closeCh:  make(chan struct{}),
+//
+// statsPath 为鉴权统计的持久化文件路径, 为空时统计仅保存在内存中
+func NewAccessLogger(cfg *config.Auth, statsPath string) (*AccessLogger, error) {
+	stats, err := NewStatsStore(statsPath)
+	if err != nil {
+		// 统计文件损坏不影响服务启动, 重新开始累计
+		logs.Warn("加载鉴权统计失败, 将重新统计: %v", err)
 	}
 
-	if enableLog {
-		// 确保日志目录存在
-		dir := filepath.Dir(logPath)
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			return nil, fmt.Errorf("创建日志目录失败: %v", err)
-		}
-
-		// 打开日志文件（追加模式）
-		file, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	logger := &AccessLogger{
+		logPath:   cfg.AuthServerLogPath,
+		enableLog: cfg.EnableAuthServerLog,
+		blocking:  cfg.AuthServerLogBlocking,
+		stats:     stats,
+		bufferCh:  make(chan AccessLog, cfg.AuthServerLogBufferSize),
+		closeCh:   make(chan struct{}),
+		doneCh:    make(chan struct{}),
+	}
+
+	if logger.enableLog {
+		// 打开日志文件（追加模式）, 按配置自动轮转
+		opts := cfg.AuthServerLogRotate.Options()
+		opts.OnRotate = rotates.LogRotated
+		writer, err := rotates.NewWriter(logger.logPath, opts)
 		if err != nil {
-			return nil, fmt.Errorf("打开日志文件失败: %v", err)
+			return nil, err
 		}
-		logger.logFile = file
+		logger.writer = writer
+
+		// 外部 logrotate 移走日志文件后, 通过 SIGUSR1 通知重新打开
+		rotates.NotifyReopen(func() {
+			if err := logger.Reopen(); err != nil {
+				logs.Error("重新打开访问日志失败: %v", err)
+				return
+			}
+			logs.Success("访问日志已重新打开: %s", logger.logPath)
+		})
 	}
 
 	// 启动日志写入协程
 	go logger.writeLoop()
 
-	// 启动统计清理协程
-	go logger.cleanupLoop()
+	// 启动统计持久化协程
+	go logger.persistLoop()
 
-	logs.Success("访问日志记录器已启动，日志文件: %s", logPath)
+	logs.Success("访问日志记录器已启动，日志文件: %s", logger.logPath)
 	return logger, nil
 }
 
 // Log 记录访问日志（异步）
+//
+// 缓冲区已满时, 阻塞模式下等待写入协程消费, 否则丢弃日志
 func (l *AccessLogger) Log(log AccessLog) {
+	if l.blocking {
+		select {
+		case l.bufferCh <- log:
+		case <-l.closeCh:
+			droppedLogTotal.With("auth").Inc()
+		}
+		return
+	}
+
 	select {
 	case l.bufferCh <- log:
 	default:
+		droppedLogTotal.With("auth").Inc()
 		logs.Warn("日志缓冲区已满，丢弃日志")
 	}
 }
 
 // writeLoop 日志写入循环
 func (l *AccessLogger) writeLoop() {
+	defer close(l.doneCh)
 	for {
 		select {
 		case log := <-l.bufferCh:
 			l.writeLog(log)
-			l.updateStats(log)
+			l.stats.Record(log)
 		case <-l.closeCh:
 			// 处理剩余日志
 			for len(l.bufferCh) > 0 {
 				log := <-l.bufferCh
 				l.writeLog(log)
-				l.updateStats(log)
+				l.stats.Record(log)
 			}
 			return
 		}
@@ -90,13 +122,10 @@ func (l *AccessLogger) writeLoop() {
 
 // writeLog 写入单条日志
 func (l *AccessLogger) writeLog(log AccessLog) {
-	if !l.enableLog || l.logFile == nil {
+	if !l.enableLog || l.writer == nil {
 		return
 	}
 
-	l.mu.Lock()
-	defer l.mu.Unlock()
-
 	// JSON 格式
 	data, err := json.Marshal(log)
 	if err != nil {
@@ -105,116 +134,53 @@ func (l *AccessLogger) writeLog(log AccessLog) {
 	}
 
 	// 写入文件
-	if _, err := l.logFile.Write(append(data, '\n')); err != nil {
+	if _, err := l.writer.Write(append(data, '\n')); err != nil {
 		logs.Error("写入日志失败: %v", err)
 	}
 }
 
-// updateStats 更新统计信息
-func (l *AccessLogger) updateStats(log AccessLog) {
-	l.stats.mu.Lock()
-	defer l.stats.mu.Unlock()
-
-	l.stats.TotalRequests++
-
-	if log.AuthResult == "success" {
-		l.stats.SuccessRequests++
-	} else {
-		l.stats.FailedRequests++
-		if log.ErrorReason != "" {
-			l.stats.FailReasons[log.ErrorReason]++
-		}
-	}
-
-	// 更新每小时统计
-	l.stats.LastHourStats.Requests++
-	if log.AuthResult == "success" {
-		l.stats.LastHourStats.Success++
-	} else {
-		l.stats.LastHourStats.Failed++
-	}
-
-	// 更新用户统计（简化版，只记录最近的用户）
-	if log.ApiKey != "" {
-		found := false
-		for i, user := range l.stats.TopUsers {
-			if user.ApiKey == log.ApiKey {
-				l.stats.TopUsers[i].Requests++
-				l.stats.TopUsers[i].LastSeen = log.Timestamp
-				found = true
-				break
-			}
-		}
-		if !found && len(l.stats.TopUsers) < 100 {
-			l.stats.TopUsers = append(l.stats.TopUsers, UserStats{
-				ApiKey:   log.ApiKey,
-				Requests: 1,
-				LastSeen: log.Timestamp,
-			})
-		}
-	}
-}
-
-// cleanupLoop 定期清理统计数据
-func (l *AccessLogger) cleanupLoop() {
-	ticker := time.NewTicker(1 * time.Hour)
+// persistLoop 定期清理过期的统计窗口并持久化
+func (l *AccessLogger) persistLoop() {
+	ticker := time.NewTicker(1 * time.Minute)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ticker.C:
-			l.resetHourlyStats()
+			l.stats.prune(time.Now())
+			if err := l.stats.Save(); err != nil {
+				logs.Error("保存鉴权统计失败: %v", err)
+			}
 		case <-l.closeCh:
 			return
 		}
 	}
 }
 
-// resetHourlyStats 重置每小时统计
-func (l *AccessLogger) resetHourlyStats() {
-	l.stats.mu.Lock()
-	defer l.stats.mu.Unlock()
-
-	l.stats.LastHourStats = HourlyStats{}
-}
-
 // GetStats 获取统计信息
 func (l *AccessLogger) GetStats() *Stats {
-	l.stats.mu.RLock()
-	defer l.stats.mu.RUnlock()
-
-	// 返回副本
-	stats := &Stats{
-		TotalRequests:   l.stats.TotalRequests,
-		SuccessRequests: l.stats.SuccessRequests,
-		FailedRequests:  l.stats.FailedRequests,
-		FailReasons:     make(map[string]int64),
-		LastHourStats:   l.stats.LastHourStats,
-		TopUsers:        make([]UserStats, len(l.stats.TopUsers)),
-	}
-
-	for k, v := range l.stats.FailReasons {
-		stats.FailReasons[k] = v
-	}
-
-	copy(stats.TopUsers, l.stats.TopUsers)
+	return l.stats.Overview()
+}
 
-	return stats
+// QueryStats 按时间范围和维度查询统计信息
+func (l *AccessLogger) QueryStats(q StatsQuery) StatsResult {
+	return l.stats.Query(q)
 }
 
-// Close 关闭日志记录器
+// Close 关闭日志记录器, 等待缓冲区中的日志全部写入后保存统计并关闭日志文件
+//
+// 调用前应先停止接收请求, 之后记录的日志会被丢弃
 func (l *AccessLogger) Close() error {
 	close(l.closeCh)
+	<-l.doneCh
 
-	// 等待日志写入完成
-	time.Sleep(100 * time.Millisecond)
-
-	l.mu.Lock()
-	defer l.mu.Unlock()
+	if err := l.stats.Save(); err != nil {
+		logs.Error("保存鉴权统计失败: %v", err)
+	}
 
-	if l.logFile != nil {
-		if err := l.logFile.Close(); err != nil {
-			return fmt.Errorf("关闭日志文件失败: %v", err)
+	if l.writer != nil {
+		if err := l.writer.Close(); err != nil {
+			return err
 		}
 	}
 
@@ -224,34 +190,21 @@ func (l *AccessLogger) Close() error {
 
 // Rotate 日志轮转
 func (l *AccessLogger) Rotate() error {
-	if !l.enableLog || l.logFile == nil {
+	if !l.enableLog || l.writer == nil {
 		return nil
 	}
+	return l.writer.Rotate()
+}
 
-	l.mu.Lock()
-	defer l.mu.Unlock()
-
-	// 关闭当前文件
-	if err := l.logFile.Close(); err != nil {
-		return fmt.Errorf("关闭日志文件失败: %v", err)
-	}
-
-	// 重命名旧文件
-	timestamp := time.Now().Format("20060102-150405")
-	oldPath := l.logPath
-	newPath := fmt.Sprintf("%s.%s", l.logPath, timestamp)
-
-	if err := os.Rename(oldPath, newPath); err != nil {
-		return fmt.Errorf("重命名日志文件失败: %v", err)
-	}
-
-	// 打开新文件
-	file, err := os.OpenFile(oldPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-	if err != nil {
-		return fmt.Errorf("打开新日志文件失败: %v", err)
+// Reopen 重新打开日志文件, 用于配合外部 logrotate
+func (l *AccessLogger) Reopen() error {
+	if !l.enableLog || l.writer == nil {
+		return nil
 	}
+	return l.writer.Reopen()
+}
 
-	l.logFile = file
-	logs.Success("日志文件已轮转: %s -> %s", oldPath, newPath)
-	return nil
+// LogPath 访问日志文件路径
+func (l *AccessLogger) LogPath() string {
+	return l.logPath
 }
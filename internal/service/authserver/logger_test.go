@@ -0,0 +1,48 @@
+package authserver
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/config"
+)
+
+func TestAccessLogger_CloseFlush(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Auth{
+		EnableAuthServerLog:     true,
+		AuthServerLogPath:       filepath.Join(dir, "auth-access.log"),
+		AuthServerLogRotate:     &config.LogRotate{},
+		AuthServerLogBufferSize: 5000,
+	}
+	statsPath := filepath.Join(dir, "auth-stats.json")
+	l, err := NewAccessLogger(cfg, statsPath)
+	if err != nil {
+		t.Fatalf("创建访问日志记录器失败: %v", err)
+	}
+
+	const total = 3000
+	for i := 0; i < total; i++ {
+		l.Log(AccessLog{Timestamp: time.Now(), RemoteIP: "1.1.1.1", Method: "GET", URI: "/api/auth", Status: 200, AuthResult: "success"})
+	}
+	if err := l.Close(); err != nil {
+		t.Fatalf("关闭访问日志记录器失败: %v", err)
+	}
+
+	data, err := os.ReadFile(cfg.AuthServerLogPath)
+	if err != nil {
+		t.Fatalf("读取日志文件失败: %v", err)
+	}
+	if lines := bytes.Count(data, []byte("\n")); lines != total {
+		t.Errorf("关闭前缓冲区中的日志应全部写入, 期望 %d 条, 实际: %d", total, lines)
+	}
+	if stats := l.GetStats(); stats.TotalRequests != total {
+		t.Errorf("关闭前缓冲区中的日志应全部统计, 实际: %+v", stats)
+	}
+	if _, err := os.Stat(statsPath); err != nil {
+		t.Errorf("关闭时应保存统计: %v", err)
+	}
+}
@@ -0,0 +1,364 @@
+package authserver
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/rotates"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 支持的导出格式
+const (
+	ExportJSON = "json" // 每行一条 JSON, 与日志文件格式一致
+	ExportCSV  = "csv"
+	ExportCLF  = "clf" // Common Log Format
+)
+
+// LogFilter 访问日志过滤条件, 零值字段表示不过滤
+type LogFilter struct {
+	From       time.Time
+	To         time.Time
+	ApiKey     string // 脱敏后的 api_key
+	User       string
+	RemoteIP   string
+	Path       string // 路径通配符, * 匹配单级目录, ** 匹配任意层级
+	AuthResult string
+	Status     int
+	Limit      int // 最多返回条数, <= 0 时不限制
+
+	pathReg *regexp.Regexp
+}
+
+// compile 预编译路径通配符
+func (f *LogFilter) compile() error {
+	if f.Path == "" {
+		return nil
+	}
+	reg, err := globToRegexp(f.Path)
+	if err != nil {
+		return fmt.Errorf("路径通配符错误: %v", err)
+	}
+	f.pathReg = reg
+	return nil
+}
+
+// Match 判断日志是否满足过滤条件
+func (f *LogFilter) Match(log *AccessLog) bool {
+	if !f.From.IsZero() && log.Timestamp.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && log.Timestamp.After(f.To) {
+		return false
+	}
+	if f.ApiKey != "" && log.ApiKey != f.ApiKey {
+		return false
+	}
+	if f.User != "" && log.User != f.User {
+		return false
+	}
+	if f.RemoteIP != "" && log.RemoteIP != f.RemoteIP {
+		return false
+	}
+	if f.AuthResult != "" && log.AuthResult != f.AuthResult {
+		return false
+	}
+	if f.Status != 0 && log.Status != f.Status {
+		return false
+	}
+	if f.pathReg != nil && !f.pathReg.MatchString(logPath(log)) {
+		return false
+	}
+	return true
+}
+
+// logPath 日志对应的资源路径, 优先使用原始路径
+func logPath(log *AccessLog) string {
+	if log.OriginalPath != "" {
+		return log.OriginalPath
+	}
+	p := log.URI
+	if idx := strings.IndexAny(p, "?#"); idx != -1 {
+		p = p[:idx]
+	}
+	return p
+}
+
+// globToRegexp 将路径通配符转换为正则表达式
+func globToRegexp(glob string) (*regexp.Regexp, error) {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch ch := glob[i]; ch {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				sb.WriteString(".*")
+				i++
+				continue
+			}
+			sb.WriteString("[^/]*")
+		case '?':
+			sb.WriteString("[^/]")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(ch)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.Compile(sb.String())
+}
+
+// QueryLogs 按时间顺序扫描当前及已轮转的访问日志, 满足条件的日志交由 fn 处理
+func (l *AccessLogger) QueryLogs(f LogFilter, fn func(log *AccessLog) error) error {
+	if !l.enableLog {
+		return errors.New("访问日志未启用")
+	}
+	if err := f.compile(); err != nil {
+		return err
+	}
+
+	count := 0
+	return rotates.ScanLines(l.logPath, f.From, func(line []byte) error {
+		var log AccessLog
+		if err := json.Unmarshal(line, &log); err != nil {
+			// 跳过写入中途或损坏的行
+			return nil
+		}
+		if !f.Match(&log) {
+			return nil
+		}
+		if err := fn(&log); err != nil {
+			return err
+		}
+		count++
+		if f.Limit > 0 && count >= f.Limit {
+			return rotates.ErrStopScan
+		}
+		return nil
+	})
+}
+
+// LogExporter 访问日志导出器
+type LogExporter interface {
+	// ContentType 响应类型
+	ContentType() string
+	// Write 写入一条日志
+	Write(log *AccessLog) error
+	// Flush 结束导出
+	Flush() error
+}
+
+// NewLogExporter 创建指定格式的导出器
+func NewLogExporter(format string, w io.Writer) (LogExporter, error) {
+	switch format {
+	case "", ExportJSON:
+		return &jsonExporter{enc: json.NewEncoder(w)}, nil
+	case ExportCSV:
+		e := &csvExporter{w: csv.NewWriter(w)}
+		return e, e.w.Write(csvHeader)
+	case ExportCLF:
+		return &clfExporter{w: w}, nil
+	}
+	return nil, fmt.Errorf("不支持的导出格式: %s", format)
+}
+
+// jsonExporter 每行输出一条 JSON
+type jsonExporter struct {
+	enc *json.Encoder
+}
+
+func (e *jsonExporter) ContentType() string { return "application/x-ndjson; charset=utf-8" }
+
+func (e *jsonExporter) Write(log *AccessLog) error {
+	exported := *log
+	exported.URI = maskUriSecrets(log.URI)
+	exported.RedirectURL = maskUriSecrets(log.RedirectURL)
+	return e.enc.Encode(exported)
+}
+
+func (e *jsonExporter) Flush() error { return nil }
+
+// csvHeader CSV 表头
+var csvHeader = []string{
+	"timestamp", "remote_ip", "method", "uri", "status", "api_key", "user", "node",
+	"user_agent", "referer", "duration_ms", "auth_result", "error_reason", "redirect_url", "original_path",
+}
+
+// csvExporter 输出 CSV
+type csvExporter struct {
+	w *csv.Writer
+}
+
+func (e *csvExporter) ContentType() string { return "text/csv; charset=utf-8" }
+
+func (e *csvExporter) Write(log *AccessLog) error {
+	return e.w.Write([]string{
+		log.Timestamp.Format(time.RFC3339Nano),
+		log.RemoteIP,
+		log.Method,
+		maskUriSecrets(log.URI),
+		strconv.Itoa(log.Status),
+		log.ApiKey,
+		log.User,
+		log.Node,
+		log.UserAgent,
+		log.Referer,
+		strconv.FormatFloat(float64(log.Duration)/float64(time.Millisecond), 'f', 3, 64),
+		log.AuthResult,
+		log.ErrorReason,
+		maskUriSecrets(log.RedirectURL),
+		log.OriginalPath,
+	})
+}
+
+func (e *csvExporter) Flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// clfExporter 输出 Common Log Format
+//
+// 格式: host ident authuser [date] "request" status bytes, 响应大小未记录, 固定为 -
+type clfExporter struct {
+	w io.Writer
+}
+
+func (e *clfExporter) ContentType() string { return "text/plain; charset=utf-8" }
+
+func (e *clfExporter) Write(log *AccessLog) error {
+	authUser := log.User
+	if authUser == "" {
+		authUser = log.ApiKey
+	}
+	_, err := fmt.Fprintf(e.w, "%s - %s [%s] \"%s %s HTTP/1.1\" %d -\n",
+		clfField(log.RemoteIP),
+		clfField(strings.ReplaceAll(authUser, " ", "_")),
+		log.Timestamp.Format("02/Jan/2006:15:04:05 -0700"),
+		log.Method,
+		maskUriSecrets(log.URI),
+		log.Status,
+	)
+	return err
+}
+
+func (e *clfExporter) Flush() error { return nil }
+
+// clfField 空字段使用 - 占位
+func clfField(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// secretQueryParams 导出时需要脱敏的地址参数, 包括 api_key 及重定向地址中的签名参数
+var secretQueryParams = []string{"api_key", "X-Emby-Token", "token", "uid"}
+
+// maskUriSecrets 隐藏 URI 或重定向地址中的 api_key 及签名参数, 避免导出时泄露
+func maskUriSecrets(uri string) string {
+	idx := strings.Index(uri, "?")
+	if idx == -1 {
+		return uri
+	}
+	q, err := url.ParseQuery(uri[idx+1:])
+	if err != nil {
+		return uri
+	}
+	masked := false
+	for _, key := range secretQueryParams {
+		if q.Has(key) {
+			q.Set(key, maskApiKey(q.Get(key)))
+			masked = true
+		}
+	}
+	if !masked {
+		return uri
+	}
+	return uri[:idx+1] + q.Encode()
+}
+
+// HandleLogs 查询及导出访问日志, 需挂载在管理令牌鉴权之后
+//
+// 支持按时间范围、用户、IP、路径通配符、鉴权结果及状态码过滤,
+// 结果以流式输出, 格式由 format 参数指定: json/csv/clf
+func (s *Server) HandleLogs(c *gin.Context) {
+	if s.logger == nil {
+		c.JSON(http.StatusOK, gin.H{"error": "Logger not initialized"})
+		return
+	}
+	if !s.logger.enableLog {
+		c.JSON(http.StatusNotFound, gin.H{"error": "访问日志未启用"})
+		return
+	}
+
+	now := time.Now()
+	f := LogFilter{
+		ApiKey:     c.Query("api_key"),
+		User:       c.Query("user"),
+		RemoteIP:   c.Query("ip"),
+		Path:       c.Query("path"),
+		AuthResult: c.Query("result"),
+	}
+	var err error
+	if raw := c.Query("from"); raw != "" {
+		if f.From, err = ParseStatsTime(raw, now); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if raw := c.Query("to"); raw != "" {
+		if f.To, err = ParseStatsTime(raw, now); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if raw := c.Query("status"); raw != "" {
+		if f.Status, err = strconv.Atoi(raw); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "status 参数错误"})
+			return
+		}
+	}
+	if raw := c.Query("limit"); raw != "" {
+		if f.Limit, err = strconv.Atoi(raw); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit 参数错误"})
+			return
+		}
+	}
+	if err = f.compile(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	format := c.DefaultQuery("format", ExportJSON)
+	exporter, err := NewLogExporter(format, c.Writer)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Content-Type", exporter.ContentType())
+	if format != ExportJSON {
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=auth-access-%s.%s", now.Format(rotates.BackupTimeFormat), format))
+	}
+	c.Status(http.StatusOK)
+
+	err = s.logger.QueryLogs(f, func(log *AccessLog) error {
+		return exporter.Write(log)
+	})
+	if err == nil {
+		err = exporter.Flush()
+	}
+	if err != nil {
+		// 响应已经开始输出, 只能将错误追加在末尾
+		fmt.Fprintf(c.Writer, "\n# error: %v\n", err)
+	}
+	c.Writer.Flush()
+}
@@ -0,0 +1,116 @@
+package authserver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeLogFile 将日志写入文件, gz 为 true 时使用 gzip 压缩
+func writeLogFile(t *testing.T, path string, gz bool, logs ...AccessLog) {
+	var buf bytes.Buffer
+	for _, log := range logs {
+		data, _ := json.Marshal(log)
+		buf.Write(append(data, '\n'))
+	}
+	data := buf.Bytes()
+	if gz {
+		var zipped bytes.Buffer
+		w := gzip.NewWriter(&zipped)
+		w.Write(data)
+		w.Close()
+		data = zipped.Bytes()
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("写入日志文件失败: %v", err)
+	}
+}
+
+func TestAccessLogger_QueryLogs(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "auth-access.log")
+	base := time.Date(2025, 12, 6, 10, 0, 0, 0, time.Local)
+
+	writeLogFile(t, logPath+".20251206-100500.gz", true,
+		AccessLog{Timestamp: base, RemoteIP: "1.1.1.1", Method: "GET", URI: "/api/auth?api_key=abcdefghijkl", Status: 200, ApiKey: "abcd****ijkl", AuthResult: "success", OriginalPath: "/video/data/a.mp4"},
+		AccessLog{Timestamp: base.Add(time.Minute), RemoteIP: "2.2.2.2", Method: "GET", URI: "/api/auth", Status: 403, AuthResult: "failed", ErrorReason: "missing_api_key"},
+	)
+	writeLogFile(t, logPath, false,
+		AccessLog{Timestamp: base.Add(10 * time.Minute), RemoteIP: "1.1.1.1", Method: "GET", URI: "/api/auth", Status: 200, ApiKey: "abcd****ijkl", User: "alice", AuthResult: "success", OriginalPath: "/video/data/sub/b.mp4"},
+	)
+
+	l := &AccessLogger{logPath: logPath, enableLog: true}
+	query := func(f LogFilter) []AccessLog {
+		res := make([]AccessLog, 0)
+		if err := l.QueryLogs(f, func(log *AccessLog) error {
+			res = append(res, *log)
+			return nil
+		}); err != nil {
+			t.Fatalf("查询日志失败: %v", err)
+		}
+		return res
+	}
+
+	if res := query(LogFilter{}); len(res) != 3 || !res[0].Timestamp.Equal(base) {
+		t.Errorf("期望按时间顺序返回全部 3 条日志, 实际: %+v", res)
+	}
+	if res := query(LogFilter{RemoteIP: "1.1.1.1", AuthResult: "success", Path: "/video/data/*"}); len(res) != 1 || res[0].OriginalPath != "/video/data/a.mp4" {
+		t.Errorf("单级通配符过滤结果不正确: %+v", res)
+	}
+	if res := query(LogFilter{Path: "/video/**"}); len(res) != 2 {
+		t.Errorf("多级通配符过滤结果不正确: %+v", res)
+	}
+	if res := query(LogFilter{From: base.Add(5 * time.Minute), ApiKey: "abcd****ijkl"}); len(res) != 1 || res[0].User != "alice" {
+		t.Errorf("时间范围过滤结果不正确: %+v", res)
+	}
+	if res := query(LogFilter{Status: 403, Limit: 1}); len(res) != 1 || res[0].ErrorReason != "missing_api_key" {
+		t.Errorf("状态码过滤结果不正确: %+v", res)
+	}
+
+	var buf bytes.Buffer
+	exporter, err := NewLogExporter(ExportCLF, &buf)
+	if err != nil {
+		t.Fatalf("创建导出器失败: %v", err)
+	}
+	logs := query(LogFilter{Limit: 1})
+	exporter.Write(&logs[0])
+	exporter.Flush()
+	want := `1.1.1.1 - abcd****ijkl [06/Dec/2025:10:00:00 ` + base.Format("-0700") + `] "GET /api/auth?api_key=abcd%2A%2A%2A%2Aijkl HTTP/1.1" 200 -`
+	if strings.TrimSpace(buf.String()) != want {
+		t.Errorf("CLF 导出结果不正确\n期望: %s\n实际: %s", want, buf.String())
+	}
+
+	buf.Reset()
+	exporter, _ = NewLogExporter(ExportCSV, &buf)
+	exporter.Write(&logs[0])
+	exporter.Flush()
+	if lines := strings.Split(strings.TrimSpace(buf.String()), "\n"); len(lines) != 2 || !strings.HasPrefix(lines[0], "timestamp,remote_ip") {
+		t.Errorf("CSV 导出结果不正确: %s", buf.String())
+	}
+
+	// 重定向地址中的签名参数在所有格式中脱敏
+	redirect := AccessLog{
+		Timestamp:   base,
+		URI:         "/api/verify-token?token=0123456789abcdef&uid=user-0123456789&expires=1700000000",
+		RedirectURL: "http://node-1/video/data/a.mp4?api_key=abcdefghijkl&token=0123456789abcdef&uid=user-0123456789&expires=1700000000",
+	}
+	for _, format := range []string{ExportJSON, ExportCSV, ExportCLF} {
+		buf.Reset()
+		exporter, _ = NewLogExporter(format, &buf)
+		exporter.Write(&redirect)
+		exporter.Flush()
+		out := buf.String()
+		for _, secret := range []string{"0123456789abcdef", "user-0123456789", "abcdefghijkl"} {
+			if strings.Contains(out, secret) {
+				t.Errorf("%s 导出结果中的 %s 未脱敏: %s", format, secret, out)
+			}
+		}
+		if format != ExportCLF && !strings.Contains(out, "expires=1700000000") {
+			t.Errorf("%s 导出结果应保留其他参数: %s", format, out)
+		}
+	}
+}
@@ -1,36 +1,50 @@
 package authserver
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"path"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/config"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/service/node"
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/service/userkey"
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/https"
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/logs"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/reqids"
 
 	"github.com/gin-gonic/gin"
 )
 
+// URLSigner 重定向地址签名器
+type URLSigner interface {
+	// SignURL 为指定节点上的路径生成带签名的访问地址
+	SignURL(ctx context.Context, nodeHost, path, apiKey string) string
+}
+
 // Server 鉴权服务器
 type Server struct {
-	cache       *userkey.Cache
-	embyHost    string
-	adminApiKey string
-	logger      *AccessLogger
+	cache         *userkey.Cache
+	embyHost      string
+	adminApiKey   string
+	logger        *AccessLogger
+	healthChecker *node.HealthChecker // 用于校验重定向目标节点
+	signer        URLSigner           // 重定向地址签名器
 }
 
 // NewServer 创建鉴权服务器
-func NewServer(cache *userkey.Cache, cfg *config.Emby, logger *AccessLogger) *Server {
+func NewServer(cache *userkey.Cache, cfg *config.Emby, logger *AccessLogger, healthChecker *node.HealthChecker, signer URLSigner) *Server {
 	return &Server{
-		cache:       cache,
-		embyHost:    cfg.Host,
-		adminApiKey: cfg.AdminApiKey,
-		logger:      logger,
+		cache:         cache,
+		embyHost:      cfg.Host,
+		adminApiKey:   cfg.AdminApiKey,
+		logger:        logger,
+		healthChecker: healthChecker,
+		signer:        signer,
 	}
 }
 
@@ -40,14 +54,7 @@ func (s *Server) HandleAuth(c *gin.Context) {
 	startTime := time.Now()
 
 	// 1. 提取 api_key 参数
-	apiKey := c.Query("api_key")
-	if apiKey == "" {
-		apiKey = c.GetHeader("X-Emby-Token")
-	}
-	if apiKey == "" {
-		apiKey = extractTokenFromAuth(c.GetHeader("Authorization"))
-	}
-
+	apiKey := ExtractApiKey(c)
 	if apiKey == "" {
 		s.logAuthFailed(c, "missing_api_key", startTime)
 		c.JSON(http.StatusForbidden, gin.H{"error": "Missing api_key"})
@@ -57,7 +64,7 @@ func (s *Server) HandleAuth(c *gin.Context) {
 	// 2. 验证 api_key
 	valid, err := s.validateApiKey(apiKey)
 	if err != nil {
-		logs.Error("验证 API Key 失败: %v", err)
+		logs.Ctx(c).Error("验证 API Key 失败: %v", err)
 		s.logAuthFailed(c, "validation_error", startTime)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Validation error"})
 		return
@@ -76,6 +83,9 @@ func (s *Server) HandleAuth(c *gin.Context) {
 
 // HandleAuthAndRedirect 处理鉴权并返回 302 重定向
 // 这是一个更高级的接口，Nginx 可以直接使用
+//
+// 只校验 api_key 有效, 不校验用户的媒体库权限: target_path 是节点上的文件路径, 无法对应到 Emby item,
+// 任何有效的 api_key 都能获取 nginx 路径前缀下任意文件的签名地址
 func (s *Server) HandleAuthAndRedirect(c *gin.Context) {
 	startTime := time.Now()
 
@@ -83,8 +93,9 @@ func (s *Server) HandleAuthAndRedirect(c *gin.Context) {
 	apiKey := c.Query("api_key")
 	targetPath := c.Query("target_path") // 例如：/video/data/movie.mp4
 	nodeHost := c.Query("node_host")     // 例如：http://nginx-node
+	nodeName := c.Query("node")          // 也可以直接传递节点名称
 
-	if apiKey == "" || targetPath == "" || nodeHost == "" {
+	if apiKey == "" || targetPath == "" || (nodeHost == "" && nodeName == "") {
 		s.logAuthFailed(c, "missing_parameters", startTime)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing required parameters"})
 		return
@@ -98,16 +109,114 @@ func (s *Server) HandleAuthAndRedirect(c *gin.Context) {
 		return
 	}
 
-	// 3. 构建重定向 URL
-	redirectUrl := fmt.Sprintf("%s%s", nodeHost, targetPath)
+	// 3. 校验重定向目标, 防止被利用为开放重定向
+	nodeHost, ok := s.resolveNodeHost(nodeHost, nodeName)
+	if !ok {
+		s.logAuthFailed(c, "invalid_node_host", startTime)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid node_host"})
+		return
+	}
+	targetPath, ok = cleanTargetPath(targetPath)
+	if !ok {
+		s.logAuthFailed(c, "invalid_target_path", startTime)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid target_path"})
+		return
+	}
+
+	// 4. 构建带签名的重定向 URL
+	redirectUrl := s.buildRedirectUrl(c, nodeHost, targetPath, apiKey)
+	if redirectUrl == "" {
+		s.logAuthFailed(c, "invalid_node_host", startTime)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid node_host"})
+		return
+	}
 
-	// 4. 记录访问日志
+	// 5. 记录访问日志
 	s.logAccess(c, apiKey, redirectUrl, startTime)
 
-	// 5. 返回 302 重定向
+	// 6. 返回 302 重定向
 	c.Redirect(http.StatusTemporaryRedirect, redirectUrl)
 }
 
+// resolveNodeHost 校验重定向目标节点
+//
+// 传递了节点名称时, 使用配置中已启用节点的地址;
+// 否则 node_host 必须是配置中已启用的节点或健康检查器中的节点
+func (s *Server) resolveNodeHost(nodeHost, nodeName string) (string, bool) {
+	if nodeName != "" {
+		for _, n := range config.C().Nodes.List {
+			if n.Name == nodeName && n.Enabled {
+				return n.Host, true
+			}
+		}
+		return "", false
+	}
+
+	want, ok := normalizeHost(nodeHost)
+	if !ok {
+		return "", false
+	}
+	for _, n := range config.C().Nodes.List {
+		if host, ok := normalizeHost(n.Host); ok && n.Enabled && host == want {
+			return n.Host, true
+		}
+	}
+	if s.healthChecker != nil {
+		for _, n := range s.healthChecker.GetHealthyNodes() {
+			if host, ok := normalizeHost(n.GetHost()); ok && host == want {
+				return n.GetHost(), true
+			}
+		}
+	}
+	return "", false
+}
+
+// normalizeHost 将节点地址规范化为 scheme://host 形式用于比较
+func normalizeHost(rawHost string) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(rawHost))
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", false
+	}
+	if u.User != nil {
+		return "", false
+	}
+	return strings.ToLower(u.Scheme + "://" + u.Host), true
+}
+
+// cleanTargetPath 校验重定向路径
+//
+// 路径必须位于 path.emby2nginx 配置的 nginx 路径前缀下, 且不能包含路径穿越
+func cleanTargetPath(targetPath string) (string, bool) {
+	if !strings.HasPrefix(targetPath, "/") || strings.HasPrefix(targetPath, "//") {
+		return "", false
+	}
+	if strings.ContainsAny(targetPath, "?#\\") {
+		return "", false
+	}
+	cleaned := path.Clean(targetPath)
+	if cleaned != targetPath && cleaned+"/" != targetPath {
+		return "", false
+	}
+	if !config.C().Path.IsNginxPath(cleaned) {
+		return "", false
+	}
+	return targetPath, true
+}
+
+// buildRedirectUrl 构建重定向地址, 配置了签名器时附加签名参数
+func (s *Server) buildRedirectUrl(ctx context.Context, nodeHost, targetPath, apiKey string) string {
+	if s.signer != nil {
+		return s.signer.SignURL(ctx, nodeHost, targetPath, apiKey)
+	}
+	u, err := url.Parse(nodeHost)
+	if err != nil {
+		logs.Ctx(ctx).Error("解析节点地址失败: %v", err)
+		return ""
+	}
+	u.Path = targetPath
+	return u.String()
+}
+
 // validateApiKey 验证 API Key 是否有效
 func (s *Server) validateApiKey(apiKey string) (bool, error) {
 	// 1. 先检查缓存
@@ -161,6 +270,7 @@ func (s *Server) logAuthSuccess(c *gin.Context, apiKey string, startTime time.Ti
 		ErrorReason:  "",
 		OriginalPath: c.Query("target_path"),
 	}
+	s.fillIdentity(c, &log, "")
 
 	s.logger.Log(log)
 }
@@ -186,6 +296,7 @@ func (s *Server) logAuthFailed(c *gin.Context, reason string, startTime time.Tim
 		ErrorReason:  reason,
 		OriginalPath: c.Query("target_path"),
 	}
+	s.fillIdentity(c, &log, "")
 
 	s.logger.Log(log)
 }
@@ -212,19 +323,112 @@ func (s *Server) logAccess(c *gin.Context, apiKey, redirectUrl string, startTime
 		RedirectURL:  redirectUrl,
 		OriginalPath: c.Query("target_path"),
 	}
+	s.fillIdentity(c, &log, redirectUrl)
 
 	s.logger.Log(log)
 }
 
 // HandleStats 处理统计信息查询
+//
+// 不带参数时返回汇总统计; 传递 from/to/group_by 或过滤参数时,
+// 按时间窗口查询并按维度分组
 func (s *Server) HandleStats(c *gin.Context) {
 	if s.logger == nil {
 		c.JSON(http.StatusOK, gin.H{"error": "Logger not initialized"})
 		return
 	}
 
-	stats := s.logger.GetStats()
-	c.JSON(http.StatusOK, stats)
+	windowed := false
+	for _, key := range []string{"from", "to", "group_by", "user", "node", "path", "reason"} {
+		if c.Query(key) != "" {
+			windowed = true
+			break
+		}
+	}
+	if !windowed {
+		c.JSON(http.StatusOK, s.logger.GetStats())
+		return
+	}
+
+	now := time.Now()
+	q := StatsQuery{
+		User:   c.Query("user"),
+		Node:   c.Query("node"),
+		Path:   c.Query("path"),
+		Reason: c.Query("reason"),
+	}
+	var err error
+	if raw := c.Query("from"); raw != "" {
+		if q.From, err = ParseStatsTime(raw, now); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if raw := c.Query("to"); raw != "" {
+		if q.To, err = ParseStatsTime(raw, now); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if !q.To.IsZero() && !q.From.IsZero() && q.To.Before(q.From) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to 不能早于 from"})
+		return
+	}
+	if q.GroupBy, err = ParseGroupBy(c.Query("group_by")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, s.logger.QueryStats(q))
+}
+
+// fillIdentity 补充日志中的用户与节点信息, 用于按维度统计
+//
+// 节点优先取重定向地址所属节点, 其次取请求参数中的 node/node_host
+func (s *Server) fillIdentity(c *gin.Context, log *AccessLog, redirectUrl string) {
+	log.RequestId = reqids.Get(c)
+	if user, ok := userkey.GetUser(c); ok {
+		log.User = user.Name
+	}
+
+	if name := c.Query("node"); name != "" {
+		log.Node = name
+		return
+	}
+	host := c.Query("node_host")
+	if redirectUrl != "" {
+		host = redirectUrl
+	}
+	if host == "" {
+		return
+	}
+	log.Node = nodeNameByHost(host)
+}
+
+// nodeNameByHost 根据地址查找配置中的节点名称
+func nodeNameByHost(rawHost string) string {
+	want, ok := normalizeHost(rawHost)
+	if !ok {
+		return ""
+	}
+	for _, n := range config.C().Nodes.List {
+		if host, ok := normalizeHost(n.Host); ok && host == want {
+			return n.Name
+		}
+	}
+	return ""
+}
+
+// ExtractApiKey 从请求参数或请求头中提取 api_key
+func ExtractApiKey(c *gin.Context) string {
+	apiKey := c.Query("api_key")
+	if apiKey == "" {
+		apiKey = c.GetHeader("X-Emby-Token")
+	}
+	if apiKey == "" {
+		apiKey = extractTokenFromAuth(c.GetHeader("Authorization"))
+	}
+	return apiKey
 }
 
 // extractTokenFromAuth 从 Authorization 头提取 token
@@ -267,7 +471,6 @@ type Stats struct {
 	LastHourStats   HourlyStats      `json:"last_hour_stats"`
 	TopUsers        []UserStats      `json:"top_users"`
 	AverageDuration time.Duration    `json:"average_duration"`
-	mu              sync.RWMutex     `json:"-"`
 }
 
 // HourlyStats 每小时统计
@@ -279,7 +482,8 @@ type HourlyStats struct {
 
 // UserStats 用户统计
 type UserStats struct {
-	ApiKey   string    `json:"api_key"`
+	User     string    `json:"user"`    // 用户名, 未识别用户时为脱敏后的 api_key
+	ApiKey   string    `json:"api_key"` // 已脱敏
 	Requests int64     `json:"requests"`
 	LastSeen time.Time `json:"last_seen"`
 }
@@ -287,11 +491,14 @@ type UserStats struct {
 // AccessLog 访问日志
 type AccessLog struct {
 	Timestamp    time.Time     `json:"timestamp"`
+	RequestId    string        `json:"request_id"`
 	RemoteIP     string        `json:"remote_ip"`
 	Method       string        `json:"method"`
 	URI          string        `json:"uri"`
 	Status       int           `json:"status"`
 	ApiKey       string        `json:"api_key"` // 已脱敏
+	User         string        `json:"user"`    // 解析到的 Emby 用户名
+	Node         string        `json:"node"`    // 目标节点名称
 	UserAgent    string        `json:"user_agent"`
 	Referer      string        `json:"referer"`
 	Duration     time.Duration `json:"duration"`
@@ -0,0 +1,599 @@
+package authserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// minuteRetention 分钟粒度统计的保留时长
+	minuteRetention = 24 * time.Hour
+
+	// hourRetention 小时粒度统计的保留时长
+	hourRetention = 30 * 24 * time.Hour
+
+	// statsFileVersion 统计持久化文件的格式版本
+	statsFileVersion = 1
+)
+
+// 支持的分组维度
+const (
+	GroupByUser   = "user"
+	GroupByNode   = "node"
+	GroupByPath   = "path"
+	GroupByReason = "reason"
+	GroupByResult = "result"
+	GroupByTime   = "time"
+)
+
+// statsDims 统计维度
+type statsDims struct {
+	User   string `json:"user,omitempty"`   // 用户名, 未识别用户时为脱敏后的 api_key
+	Node   string `json:"node,omitempty"`   // 节点名称
+	Path   string `json:"path,omitempty"`   // 路径前缀
+	Reason string `json:"reason,omitempty"` // 失败原因, 成功时为空
+}
+
+// statsCounter 统计计数
+type statsCounter struct {
+	Requests    int64         `json:"requests"`
+	Success     int64         `json:"success"`
+	Failed      int64         `json:"failed"`
+	DurationSum time.Duration `json:"duration_sum"`
+}
+
+// add 累加一条访问记录
+func (sc *statsCounter) add(success bool, duration time.Duration) {
+	sc.Requests++
+	if success {
+		sc.Success++
+	} else {
+		sc.Failed++
+	}
+	sc.DurationSum += duration
+}
+
+// merge 合并另一个计数
+func (sc *statsCounter) merge(o *statsCounter) {
+	sc.Requests += o.Requests
+	sc.Success += o.Success
+	sc.Failed += o.Failed
+	sc.DurationSum += o.DurationSum
+}
+
+// summary 转换为对外输出的统计摘要
+func (sc *statsCounter) summary() StatsSummary {
+	s := StatsSummary{Requests: sc.Requests, Success: sc.Success, Failed: sc.Failed}
+	if sc.Requests > 0 {
+		s.AverageDuration = sc.DurationSum / time.Duration(sc.Requests)
+	}
+	return s
+}
+
+// statsBucket 单个时间窗口内的统计
+type statsBucket map[statsDims]*statsCounter
+
+// userSeen 用户最近一次访问
+type userSeen struct {
+	ApiKey   string    `json:"api_key"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// StatsStore 按时间窗口滚动的鉴权统计
+//
+// 每条访问记录同时计入分钟桶 (保留 24 小时) 和小时桶 (保留 30 天),
+// 查询时根据时间范围选择粒度; 数据定期持久化到文件, 重启后继续累计
+type StatsStore struct {
+	path        string
+	mu          sync.RWMutex
+	minutes     map[int64]statsBucket // key: 桶起始时间 (unix 秒)
+	hours       map[int64]statsBucket
+	totals      statsCounter
+	failReasons map[string]int64
+	users       map[string]userSeen
+	dirty       bool
+}
+
+// NewStatsStore 创建统计存储, path 为空时不做持久化
+func NewStatsStore(path string) (*StatsStore, error) {
+	s := &StatsStore{
+		path:        path,
+		minutes:     make(map[int64]statsBucket),
+		hours:       make(map[int64]statsBucket),
+		failReasons: make(map[string]int64),
+		users:       make(map[string]userSeen),
+	}
+	if err := s.load(); err != nil {
+		return s, err
+	}
+	return s, nil
+}
+
+// Record 记录一条访问日志
+func (s *StatsStore) Record(log AccessLog) {
+	ts := log.Timestamp
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	success := log.AuthResult == "success"
+	dims := statsDims{
+		User: statsUser(log),
+		Node: log.Node,
+		Path: pathPrefix(log),
+	}
+	if !success {
+		dims.Reason = log.ErrorReason
+		if dims.Reason == "" {
+			dims.Reason = "unknown"
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.totals.add(success, log.Duration)
+	if !success {
+		s.failReasons[dims.Reason]++
+	}
+	if dims.User != "" {
+		s.users[dims.User] = userSeen{ApiKey: log.ApiKey, LastSeen: ts}
+	}
+	addToBucket(s.minutes, ts.Truncate(time.Minute).Unix(), dims, success, log.Duration)
+	addToBucket(s.hours, ts.Truncate(time.Hour).Unix(), dims, success, log.Duration)
+	s.dirty = true
+}
+
+// addToBucket 将一条记录计入指定时间桶
+func addToBucket(buckets map[int64]statsBucket, start int64, dims statsDims, success bool, duration time.Duration) {
+	bucket, ok := buckets[start]
+	if !ok {
+		bucket = make(statsBucket)
+		buckets[start] = bucket
+	}
+	counter, ok := bucket[dims]
+	if !ok {
+		counter = new(statsCounter)
+		bucket[dims] = counter
+	}
+	counter.add(success, duration)
+}
+
+// statsUser 统计使用的用户标识
+func statsUser(log AccessLog) string {
+	if log.User != "" {
+		return log.User
+	}
+	return log.ApiKey
+}
+
+// pathPrefix 取请求路径的前两级目录作为统计维度, 避免维度过多
+func pathPrefix(log AccessLog) string {
+	p := log.OriginalPath
+	if p == "" {
+		p = log.URI
+		if idx := strings.IndexAny(p, "?#"); idx != -1 {
+			p = p[:idx]
+		}
+	}
+	if p == "" {
+		return ""
+	}
+	segs := strings.Split(strings.Trim(p, "/"), "/")
+	if len(segs) > 2 {
+		segs = segs[:2]
+	}
+	return "/" + strings.Join(segs, "/")
+}
+
+// StatsSummary 统计摘要
+type StatsSummary struct {
+	Requests        int64         `json:"requests"`
+	Success         int64         `json:"success"`
+	Failed          int64         `json:"failed"`
+	AverageDuration time.Duration `json:"average_duration"`
+}
+
+// StatsQuery 统计查询条件
+type StatsQuery struct {
+	From    time.Time
+	To      time.Time
+	GroupBy []string
+
+	// 过滤条件, 为空表示不过滤
+	User   string
+	Node   string
+	Path   string // 路径前缀
+	Reason string
+}
+
+// StatsGroup 分组统计结果
+type StatsGroup struct {
+	Key map[string]string `json:"key"`
+	StatsSummary
+}
+
+// StatsResult 统计查询结果
+type StatsResult struct {
+	From       time.Time    `json:"from"`
+	To         time.Time    `json:"to"`
+	Resolution string       `json:"resolution"` // minute/hour
+	GroupBy    []string     `json:"group_by"`
+	Total      StatsSummary `json:"total"`
+	Groups     []StatsGroup `json:"groups"`
+}
+
+// ParseGroupBy 解析并校验分组维度, 多个维度使用逗号分隔
+func ParseGroupBy(raw string) ([]string, error) {
+	res := make([]string, 0)
+	if strings.TrimSpace(raw) == "" {
+		return res, nil
+	}
+	seen := make(map[string]bool)
+	for _, g := range strings.Split(raw, ",") {
+		g = strings.TrimSpace(g)
+		switch g {
+		case GroupByUser, GroupByNode, GroupByPath, GroupByReason, GroupByResult, GroupByTime:
+		default:
+			return nil, fmt.Errorf("不支持的分组维度: %s", g)
+		}
+		if !seen[g] {
+			seen[g] = true
+			res = append(res, g)
+		}
+	}
+	return res, nil
+}
+
+// ParseStatsTime 解析查询时间
+//
+// 支持 unix 秒级时间戳、RFC3339 格式以及相对当前时间的时长 (如 -24h)
+func ParseStatsTime(raw string, now time.Time) (time.Time, error) {
+	raw = strings.TrimSpace(raw)
+	if sec, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	if strings.HasPrefix(raw, "-") {
+		if d, err := time.ParseDuration(raw[1:]); err == nil {
+			return now.Add(-d), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("无法解析时间: %s", raw)
+}
+
+// Query 查询时间范围内的统计
+//
+// 起始时间在 24 小时以内时使用分钟粒度, 否则使用小时粒度,
+// 时间范围按所选粒度对齐
+func (s *StatsStore) Query(q StatsQuery) StatsResult {
+	now := time.Now()
+	if q.To.IsZero() || q.To.After(now) {
+		q.To = now
+	}
+	if q.From.IsZero() {
+		q.From = q.To.Add(-time.Hour)
+	}
+
+	resolution, step, buckets := "minute", time.Minute, s.minutes
+	if q.From.Before(now.Add(-minuteRetention)) {
+		resolution, step, buckets = "hour", time.Hour, s.hours
+	}
+	from := q.From.Truncate(step).Unix()
+	to := q.To.Unix()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	total := new(statsCounter)
+	groups := make(map[string]*statsCounter)
+	keys := make(map[string]map[string]string)
+	for start, bucket := range buckets {
+		if start < from || start > to {
+			continue
+		}
+		for dims, counter := range bucket {
+			if !q.match(dims) {
+				continue
+			}
+			total.merge(counter)
+			if len(q.GroupBy) == 0 {
+				continue
+			}
+			key := groupKey(q.GroupBy, dims, start)
+			id := fmt.Sprint(key)
+			g, ok := groups[id]
+			if !ok {
+				g = new(statsCounter)
+				groups[id] = g
+				keys[id] = key
+			}
+			g.merge(counter)
+		}
+	}
+
+	res := StatsResult{
+		From:       time.Unix(from, 0),
+		To:         q.To,
+		Resolution: resolution,
+		GroupBy:    q.GroupBy,
+		Total:      total.summary(),
+		Groups:     make([]StatsGroup, 0, len(groups)),
+	}
+	for id, g := range groups {
+		res.Groups = append(res.Groups, StatsGroup{Key: keys[id], StatsSummary: g.summary()})
+	}
+	sortGroups(res.Groups, q.GroupBy)
+	return res
+}
+
+// match 判断维度是否满足过滤条件
+func (q *StatsQuery) match(dims statsDims) bool {
+	if q.User != "" && dims.User != q.User {
+		return false
+	}
+	if q.Node != "" && dims.Node != q.Node {
+		return false
+	}
+	if q.Path != "" && !strings.HasPrefix(dims.Path, q.Path) {
+		return false
+	}
+	if q.Reason != "" && dims.Reason != q.Reason {
+		return false
+	}
+	return true
+}
+
+// groupKey 计算一条统计所属的分组
+//
+// 失败记录的 reason 一定非空, 因此 result 维度可以由 reason 推断
+func groupKey(groupBy []string, dims statsDims, start int64) map[string]string {
+	key := make(map[string]string, len(groupBy))
+	for _, g := range groupBy {
+		switch g {
+		case GroupByUser:
+			key[g] = dims.User
+		case GroupByNode:
+			key[g] = dims.Node
+		case GroupByPath:
+			key[g] = dims.Path
+		case GroupByReason:
+			key[g] = dims.Reason
+		case GroupByResult:
+			key[g] = "success"
+			if dims.Reason != "" {
+				key[g] = "failed"
+			}
+		case GroupByTime:
+			key[g] = time.Unix(start, 0).Format(time.RFC3339)
+		}
+	}
+	return key
+}
+
+// sortGroups 按时间分组时按时间升序, 否则按请求数降序
+func sortGroups(groups []StatsGroup, groupBy []string) {
+	byTime := false
+	for _, g := range groupBy {
+		if g == GroupByTime {
+			byTime = true
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if byTime && groups[i].Key[GroupByTime] != groups[j].Key[GroupByTime] {
+			return groups[i].Key[GroupByTime] < groups[j].Key[GroupByTime]
+		}
+		if groups[i].Requests != groups[j].Requests {
+			return groups[i].Requests > groups[j].Requests
+		}
+		return fmt.Sprint(groups[i].Key) < fmt.Sprint(groups[j].Key)
+	})
+}
+
+// Overview 汇总统计, 兼容原有的 /api/stats 返回结构
+//
+// 累计数据跨重启保留, 最近一小时为滚动窗口,
+// 活跃用户取最近 24 小时请求数最多的前 10 个
+func (s *StatsStore) Overview() *Stats {
+	now := time.Now()
+	lastHour := s.Query(StatsQuery{From: now.Add(-time.Hour), To: now}).Total
+	users := s.Query(StatsQuery{From: now.Add(-minuteRetention + time.Minute), To: now, GroupBy: []string{GroupByUser}})
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stats := &Stats{
+		TotalRequests:   s.totals.Requests,
+		SuccessRequests: s.totals.Success,
+		FailedRequests:  s.totals.Failed,
+		FailReasons:     make(map[string]int64, len(s.failReasons)),
+		LastHourStats: HourlyStats{
+			Requests: lastHour.Requests,
+			Success:  lastHour.Success,
+			Failed:   lastHour.Failed,
+		},
+		TopUsers:        make([]UserStats, 0),
+		AverageDuration: s.totals.summary().AverageDuration,
+	}
+	for k, v := range s.failReasons {
+		stats.FailReasons[k] = v
+	}
+	for _, g := range users.Groups {
+		user := g.Key[GroupByUser]
+		if user == "" {
+			continue
+		}
+		seen := s.users[user]
+		stats.TopUsers = append(stats.TopUsers, UserStats{
+			User:     user,
+			ApiKey:   seen.ApiKey,
+			Requests: g.Requests,
+			LastSeen: seen.LastSeen,
+		})
+		if len(stats.TopUsers) >= 10 {
+			break
+		}
+	}
+	return stats
+}
+
+// prune 清理超出保留时长的时间桶
+func (s *StatsStore) prune(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	minuteLimit := now.Add(-minuteRetention).Unix()
+	for start := range s.minutes {
+		if start < minuteLimit {
+			delete(s.minutes, start)
+			s.dirty = true
+		}
+	}
+	hourLimit := now.Add(-hourRetention)
+	for start := range s.hours {
+		if start < hourLimit.Unix() {
+			delete(s.hours, start)
+			s.dirty = true
+		}
+	}
+	for user, seen := range s.users {
+		if seen.LastSeen.Before(hourLimit) {
+			delete(s.users, user)
+			s.dirty = true
+		}
+	}
+}
+
+// statsFile 统计持久化文件结构
+type statsFile struct {
+	Version     int                 `json:"version"`
+	SavedAt     time.Time           `json:"saved_at"`
+	Totals      statsCounter        `json:"totals"`
+	FailReasons map[string]int64    `json:"fail_reasons"`
+	Users       map[string]userSeen `json:"users"`
+	Minutes     []statsFileBucket   `json:"minutes"`
+	Hours       []statsFileBucket   `json:"hours"`
+}
+
+// statsFileBucket 持久化的时间桶
+type statsFileBucket struct {
+	Start   int64            `json:"start"`
+	Entries []statsFileEntry `json:"entries"`
+}
+
+// statsFileEntry 持久化的单个维度计数
+type statsFileEntry struct {
+	statsDims
+	statsCounter
+}
+
+// Save 将统计数据写入文件, 数据未变化时跳过
+func (s *StatsStore) Save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	s.mu.Lock()
+	if !s.dirty {
+		s.mu.Unlock()
+		return nil
+	}
+	file := statsFile{
+		Version:     statsFileVersion,
+		SavedAt:     time.Now(),
+		Totals:      s.totals,
+		FailReasons: s.failReasons,
+		Users:       s.users,
+		Minutes:     encodeBuckets(s.minutes),
+		Hours:       encodeBuckets(s.hours),
+	}
+	data, err := json.Marshal(file)
+	s.dirty = false
+	s.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("序列化统计数据失败: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("创建统计目录失败: %v", err)
+	}
+	// 统计中包含各用户及 api_key 的访问情况, 只允许当前用户读写
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("写入统计文件失败: %v", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("替换统计文件失败: %v", err)
+	}
+	return nil
+}
+
+// load 从文件中恢复统计数据
+func (s *StatsStore) load() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("读取统计文件失败: %v", err)
+	}
+
+	var file statsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("解析统计文件失败: %v", err)
+	}
+	if file.Version != statsFileVersion {
+		return fmt.Errorf("不支持的统计文件版本: %d", file.Version)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.totals = file.Totals
+	if file.FailReasons != nil {
+		s.failReasons = file.FailReasons
+	}
+	if file.Users != nil {
+		s.users = file.Users
+	}
+	decodeBuckets(s.minutes, file.Minutes)
+	decodeBuckets(s.hours, file.Hours)
+	return nil
+}
+
+// encodeBuckets 将时间桶转换为可序列化的结构
+func encodeBuckets(buckets map[int64]statsBucket) []statsFileBucket {
+	res := make([]statsFileBucket, 0, len(buckets))
+	for start, bucket := range buckets {
+		fb := statsFileBucket{Start: start, Entries: make([]statsFileEntry, 0, len(bucket))}
+		for dims, counter := range bucket {
+			fb.Entries = append(fb.Entries, statsFileEntry{statsDims: dims, statsCounter: *counter})
+		}
+		res = append(res, fb)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Start < res[j].Start })
+	return res
+}
+
+// decodeBuckets 从持久化结构中恢复时间桶
+func decodeBuckets(buckets map[int64]statsBucket, fbs []statsFileBucket) {
+	for _, fb := range fbs {
+		bucket := make(statsBucket, len(fb.Entries))
+		for _, e := range fb.Entries {
+			counter := e.statsCounter
+			bucket[e.statsDims] = &counter
+		}
+		buckets[fb.Start] = bucket
+	}
+}
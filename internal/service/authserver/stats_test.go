@@ -0,0 +1,80 @@
+package authserver
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStatsStore_QueryAndPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "auth-stats.json")
+	s, err := NewStatsStore(path)
+	if err != nil {
+		t.Fatalf("创建统计存储失败: %v", err)
+	}
+
+	now := time.Now()
+	s.Record(AccessLog{Timestamp: now, User: "alice", Node: "node1", OriginalPath: "/video/data/a.mp4", AuthResult: "success", Duration: time.Millisecond})
+	s.Record(AccessLog{Timestamp: now, User: "alice", Node: "node2", OriginalPath: "/video/data/b.mp4", AuthResult: "success", Duration: 3 * time.Millisecond})
+	s.Record(AccessLog{Timestamp: now, ApiKey: "abcd****wxyz", URI: "/api/auth?api_key=x", AuthResult: "failed", ErrorReason: "invalid_api_key"})
+	s.Record(AccessLog{Timestamp: now.Add(-48 * time.Hour), User: "bob", Node: "node1", OriginalPath: "/video/old/c.mp4", AuthResult: "success"})
+
+	res := s.Query(StatsQuery{From: now.Add(-time.Hour), GroupBy: []string{GroupByUser}})
+	if res.Resolution != "minute" || res.Total.Requests != 3 || res.Total.Failed != 1 {
+		t.Fatalf("最近一小时统计不正确: %+v", res)
+	}
+	if len(res.Groups) != 2 || res.Groups[0].Key[GroupByUser] != "alice" || res.Groups[0].AverageDuration != 2*time.Millisecond {
+		t.Errorf("按用户分组结果不正确: %+v", res.Groups)
+	}
+
+	res = s.Query(StatsQuery{From: now.Add(-72 * time.Hour), Node: "node1", Path: "/video"})
+	if res.Resolution != "hour" || res.Total.Requests != 2 {
+		t.Errorf("按节点和路径过滤结果不正确: %+v", res)
+	}
+
+	res = s.Query(StatsQuery{From: now.Add(-time.Hour), GroupBy: []string{GroupByReason, GroupByResult}, Reason: "invalid_api_key"})
+	if len(res.Groups) != 1 || res.Groups[0].Key[GroupByResult] != "failed" || res.Groups[0].Key[GroupByReason] != "invalid_api_key" {
+		t.Errorf("按失败原因分组结果不正确: %+v", res.Groups)
+	}
+
+	if err := s.Save(); err != nil {
+		t.Fatalf("保存统计失败: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("获取统计文件信息失败: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("统计文件权限应为 0600, 实际: %v", info.Mode().Perm())
+	}
+	loaded, err := NewStatsStore(path)
+	if err != nil {
+		t.Fatalf("加载统计失败: %v", err)
+	}
+	overview := loaded.Overview()
+	if overview.TotalRequests != 4 || overview.FailReasons["invalid_api_key"] != 1 || overview.LastHourStats.Requests != 3 {
+		t.Errorf("重启后汇总统计不正确: %+v", overview)
+	}
+	if len(overview.TopUsers) == 0 || overview.TopUsers[0].User != "alice" || overview.TopUsers[0].Requests != 2 {
+		t.Errorf("活跃用户统计不正确: %+v", overview.TopUsers)
+	}
+}
+
+func TestParseStatsTime(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	cases := map[string]time.Time{
+		"1704164645":           time.Unix(1704164645, 0),
+		"2024-01-01T00:00:00Z": time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		"-24h":                 now.Add(-24 * time.Hour),
+	}
+	for raw, want := range cases {
+		got, err := ParseStatsTime(raw, now)
+		if err != nil || !got.Equal(want) {
+			t.Errorf("解析 %s 失败, 期望: %v, 实际: %v, err: %v", raw, want, got, err)
+		}
+	}
+	if _, err := ParseStatsTime("yesterday", now); err == nil {
+		t.Errorf("期望解析失败")
+	}
+}
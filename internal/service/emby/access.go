@@ -0,0 +1,193 @@
+package emby
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/config"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/service/userkey"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/https"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/logs"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrLibraryForbidden 用户无权访问资源所在的媒体库
+var ErrLibraryForbidden = errors.New("用户无权访问该媒体库")
+
+var (
+	// itemFolderCache item 所属媒体库缓存 (itemId -> 媒体库的 Id 及 Guid)
+	itemFolderCache = newTtlMap[[]string](time.Minute * 30)
+
+	// accessDecisionCache 用户访问 item 的鉴权结果缓存 (userId:itemId -> 是否允许访问)
+	accessDecisionCache = newTtlMap[bool](time.Minute * 5)
+)
+
+// checkLibraryAccess 校验当前用户是否有权访问 item 所在的媒体库
+//
+// 返回 ErrLibraryForbidden 表示明确无权访问,
+// 其他错误表示无法完成校验 (如 Emby 不可达)
+func checkLibraryAccess(c *gin.Context, itemInfo ItemInfo) error {
+	user, ok := userkey.GetUser(c)
+	if !ok {
+		if identityResolver == nil {
+			return nil
+		}
+		var err error
+		if user, err = identityResolver.Resolve(c, itemInfo.ApiKey); err != nil {
+			return fmt.Errorf("无法解析用户身份: %v", err)
+		}
+	}
+
+	if user.IsDisabled {
+		return ErrLibraryForbidden
+	}
+	if user.IsAdministrator || user.EnableAllFolders {
+		return nil
+	}
+
+	decisionKey := user.Id + ":" + itemInfo.Id
+	if allowed, ok := accessDecisionCache.Get(decisionKey); ok {
+		if allowed {
+			return nil
+		}
+		return ErrLibraryForbidden
+	}
+
+	folders, err := getItemFolders(itemInfo)
+	if err != nil {
+		return err
+	}
+
+	allowed := false
+	for _, folder := range folders {
+		if user.CanAccessFolder(folder) {
+			allowed = true
+			break
+		}
+	}
+
+	accessDecisionCache.Set(decisionKey, allowed)
+	if !allowed {
+		logs.Ctx(c).Warn("用户 %s 无权访问 item: %s, 所属媒体库: %v", user.Name, itemInfo.Id, folders)
+		return ErrLibraryForbidden
+	}
+	return nil
+}
+
+// getItemFolders 获取 item 所属的媒体库 id 列表
+//
+// 优先使用管理员 Key 查询, 未配置时使用用户自身的 api_key
+func getItemFolders(itemInfo ItemInfo) ([]string, error) {
+	if folders, ok := itemFolderCache.Get(itemInfo.Id); ok {
+		return folders, nil
+	}
+
+	token := config.C().Emby.AdminApiKey
+	if token == "" {
+		token = itemInfo.ApiKey
+	}
+	header := http.Header{"X-Emby-Token": []string{token}}
+	u := fmt.Sprintf("%s/emby/Items/%s/Ancestors", config.C().Emby.Host, itemInfo.Id)
+	resp, err := https.Get(u).Header(header).Do()
+	if err != nil {
+		return nil, fmt.Errorf("查询 item 所属媒体库失败: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("查询 item 所属媒体库失败, status: %s", resp.Status)
+	}
+
+	var ancestors []struct {
+		Id   string
+		Guid string
+		Type string
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&ancestors); err != nil {
+		return nil, fmt.Errorf("解析 item 所属媒体库失败: %v", err)
+	}
+
+	// 用户策略中的 EnabledFolders 在不同版本的 Emby 中可能是 Id 或 Guid, 两者都记录
+	folders := make([]string, 0, 2)
+	for _, a := range ancestors {
+		if a.Type != "CollectionFolder" {
+			continue
+		}
+		if a.Id != "" {
+			folders = append(folders, a.Id)
+		}
+		if a.Guid != "" {
+			folders = append(folders, a.Guid)
+		}
+	}
+	if len(folders) == 0 {
+		return nil, fmt.Errorf("item %s 找不到所属媒体库", itemInfo.Id)
+	}
+
+	itemFolderCache.Set(itemInfo.Id, folders)
+	return folders, nil
+}
+
+// ttlMap 带过期时间的内存缓存
+//
+// 不启动后台清理协程, 写入时距离上次清理超过 ttl 才遍历清理过期项
+type ttlMap[V any] struct {
+	ttl       time.Duration
+	data      map[string]ttlEntry[V]
+	lastSweep time.Time
+	mu        sync.Mutex
+}
+
+// ttlEntry 缓存项
+type ttlEntry[V any] struct {
+	value     V
+	expiredAt time.Time
+}
+
+// newTtlMap 创建带过期时间的内存缓存
+func newTtlMap[V any](ttl time.Duration) *ttlMap[V] {
+	return &ttlMap[V]{
+		ttl:       ttl,
+		data:      make(map[string]ttlEntry[V]),
+		lastSweep: time.Now(),
+	}
+}
+
+// Get 获取未过期的缓存值
+func (m *ttlMap[V]) Get(key string) (V, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.data[key]
+	if !ok || !time.Now().Before(e.expiredAt) {
+		var zero V
+		return zero, false
+	}
+	return e.value, true
+}
+
+// Set 写入缓存值
+func (m *ttlMap[V]) Set(key string, value V) {
+	m.SetWithTTL(key, value, m.ttl)
+}
+
+// SetWithTTL 写入缓存值并指定过期时间
+func (m *ttlMap[V]) SetWithTTL(key string, value V, ttl time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(m.lastSweep) > m.ttl {
+		for k, e := range m.data {
+			if !now.Before(e.expiredAt) {
+				delete(m.data, k)
+			}
+		}
+		m.lastSweep = now
+	}
+	m.data[key] = ttlEntry[V]{value: value, expiredAt: now.Add(ttl)}
+}
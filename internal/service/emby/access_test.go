@@ -0,0 +1,132 @@
+package emby
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/config"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/service/userkey"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newAccessServer 模拟 Emby 的 /Items/{id}/Ancestors 接口, 返回请求次数计数器
+func newAccessServer(t *testing.T) *atomic.Int32 {
+	t.Helper()
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.Header.Get("X-Emby-Token") != "admin-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/emby/Items/broken/Ancestors":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "/emby/Items/orphan/Ancestors":
+			w.Write([]byte(`[{"Id": "1", "Type": "Folder"}]`))
+		default:
+			w.Write([]byte(`[
+				{"Id": "10", "Guid": "series-guid", "Type": "Series"},
+				{"Id": "lib-movie", "Guid": "guid-movie", "Type": "CollectionFolder"},
+				{"Id": "1", "Type": "UserRootFolder"}
+			]`))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	oldC := config.C()
+	t.Cleanup(func() { config.Set(oldC) })
+	config.Set(&config.Config{Emby: &config.Emby{Host: server.URL, AdminApiKey: "admin-key"}})
+	return &calls
+}
+
+// accessContext 创建携带已解析用户身份的 gin 上下文
+func accessContext(user *userkey.User) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	c.Set(userkey.UserGinKey, user)
+	return c
+}
+
+func TestCheckLibraryAccess(t *testing.T) {
+	calls := newAccessServer(t)
+
+	cases := []struct {
+		name   string
+		user   *userkey.User
+		itemId string
+		want   error
+		fetch  bool // 是否需要查询 item 所属媒体库
+	}{
+		{"按媒体库 Id 允许", &userkey.User{Id: "u-id", EnabledFolders: []string{"lib-movie"}}, "100", nil, true},
+		{"按媒体库 Guid 允许", &userkey.User{Id: "u-guid", EnabledFolders: []string{"guid-movie"}}, "101", nil, true},
+		{"不属于已授权的媒体库", &userkey.User{Id: "u-other", EnabledFolders: []string{"lib-tv", "series-guid"}}, "102", ErrLibraryForbidden, true},
+		{"管理员", &userkey.User{Id: "u-admin", IsAdministrator: true}, "103", nil, false},
+		{"允许访问所有媒体库", &userkey.User{Id: "u-all", EnableAllFolders: true}, "104", nil, false},
+		{"已禁用的用户", &userkey.User{Id: "u-disabled", IsDisabled: true, IsAdministrator: true}, "105", ErrLibraryForbidden, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			before := calls.Load()
+			err := checkLibraryAccess(accessContext(tc.user), ItemInfo{Id: tc.itemId, ApiKey: "user-key"})
+			if err != tc.want {
+				t.Errorf("鉴权结果不正确, 期望: %v, 实际: %v", tc.want, err)
+			}
+			if fetched := calls.Load() > before; fetched != tc.fetch {
+				t.Errorf("是否查询所属媒体库不正确, 期望: %v, 实际: %v", tc.fetch, fetched)
+			}
+		})
+	}
+
+	// 鉴权结果按用户及 item 缓存
+	before := calls.Load()
+	if err := checkLibraryAccess(accessContext(&userkey.User{Id: "u-other", EnabledFolders: []string{"lib-tv"}}), ItemInfo{Id: "102"}); err != ErrLibraryForbidden {
+		t.Errorf("缓存的鉴权结果不正确: %v", err)
+	}
+	if calls.Load() != before {
+		t.Error("命中缓存时不应再次查询所属媒体库")
+	}
+}
+
+func TestCheckLibraryAccess_FailClosed(t *testing.T) {
+	calls := newAccessServer(t)
+	user := &userkey.User{Id: "u-fail", EnabledFolders: []string{"lib-movie"}}
+
+	for _, itemId := range []string{"broken", "orphan"} {
+		err := checkLibraryAccess(accessContext(user), ItemInfo{Id: itemId})
+		if err == nil || errors.Is(err, ErrLibraryForbidden) {
+			t.Errorf("%s: 无法查询所属媒体库时应返回校验错误, 实际: %v", itemId, err)
+		}
+	}
+
+	// 查询失败的结果不能被缓存为允许访问
+	before := calls.Load()
+	if err := checkLibraryAccess(accessContext(user), ItemInfo{Id: "broken"}); err == nil {
+		t.Error("再次校验时仍应返回错误")
+	}
+	if calls.Load() == before {
+		t.Error("查询失败的结果不应被缓存")
+	}
+}
+
+func TestTtlMap(t *testing.T) {
+	m := newTtlMap[bool](time.Millisecond * 20)
+	m.Set("a", true)
+	if v, ok := m.Get("a"); !ok || !v {
+		t.Errorf("读取缓存失败: %v %v", v, ok)
+	}
+
+	time.Sleep(time.Millisecond * 30)
+	if _, ok := m.Get("a"); ok {
+		t.Error("缓存过期后不应再读取到")
+	}
+	m.Set("b", false)
+	if _, ok := m.data["a"]; ok {
+		t.Error("写入时应清理过期的缓存项")
+	}
+}
@@ -1,14 +1,18 @@
 package emby
 
 import (
+	"context"
 	"errors"
 	"io"
 	"net/http"
+	"time"
 
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/config"
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/model"
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/https"
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/jsons"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/logs"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/reqids"
 
 	"github.com/gin-gonic/gin"
 )
@@ -19,7 +23,7 @@ import (
 // 如果请求是失败的响应, 会直接返回客户端, 并在第二个参数中返回 false
 func proxyAndSetRespHeader(c *gin.Context) (model.HttpRes[*jsons.Item], bool) {
 	c.Request.Header.Del("Accept-Encoding")
-	res, respHeader := RawFetch(c.Request.URL.String(), c.Request.Method, c.Request.Header, c.Request.Body)
+	res, respHeader := RawFetch(c, c.Request.URL.String(), c.Request.Method, c.Request.Header, c.Request.Body)
 	if res.Code != http.StatusOK {
 		checkErr(c, errors.New(res.Msg))
 		return res, false
@@ -29,13 +33,15 @@ func proxyAndSetRespHeader(c *gin.Context) (model.HttpRes[*jsons.Item], bool) {
 }
 
 // Fetch 请求 emby api 接口, 使用 map 请求体
-func Fetch(uri, method string, header http.Header, body map[string]any) (model.HttpRes[*jsons.Item], http.Header) {
-	return RawFetch(uri, method, header, https.MapBody(body))
+func Fetch(ctx context.Context, uri, method string, header http.Header, body map[string]any) (model.HttpRes[*jsons.Item], http.Header) {
+	return RawFetch(ctx, uri, method, header, https.MapBody(body))
 }
 
 // RawFetch 请求 emby api 接口, 使用流式请求体
-func RawFetch(uri, method string, header http.Header, body io.ReadCloser) (model.HttpRes[*jsons.Item], http.Header) {
-	u := config.C.Emby.Host + uri
+//
+// ctx 中携带请求 id 时通过 X-Request-Id 请求头传递给 Emby
+func RawFetch(ctx context.Context, uri, method string, header http.Header, body io.ReadCloser) (model.HttpRes[*jsons.Item], http.Header) {
+	u := config.C().Emby.Host + uri
 
 	// 构造请求头, 发出请求
 	if header == nil {
@@ -44,12 +50,18 @@ func RawFetch(uri, method string, header http.Header, body io.ReadCloser) (model
 	if header.Get("Content-Type") == "" {
 		header.Set("Content-Type", "application/json;charset=utf-8")
 	}
+	if id := logs.RequestId(ctx); id != "" && header.Get(reqids.Header) == "" {
+		header.Set(reqids.Header, id)
+	}
 
+	start := time.Now()
 	resp, err := https.Request(method, u).Header(header).Body(body).Do()
 	if err != nil {
+		observeEmbyRequest(uri, 0, start)
 		return model.HttpRes[*jsons.Item]{Code: http.StatusBadRequest, Msg: "请求发送失败: " + err.Error()}, nil
 	}
 	defer resp.Body.Close()
+	observeEmbyRequest(uri, resp.StatusCode, start)
 
 	// 读取响应
 	result, err := jsons.Read(resp.Body)
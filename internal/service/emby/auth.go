@@ -5,10 +5,11 @@ import (
 	"net/http"
 	"regexp"
 	"strings"
-	"sync"
+	"time"
 
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/config"
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/constant"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/service/userkey"
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/https"
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/logs"
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/strs"
@@ -22,11 +23,19 @@ import (
 // 通过此 uri, 可以判断出客户端传递的 api_key 是否是被 emby 服务器认可的
 const AuthUri = "/emby/Auth/Keys"
 
-// validApiKeys 已经校验通过的 api_key, 下次就不再校验
+// validApiKeys 已经校验通过的 api_key, 有效期内不再校验
 //
-// 这个 map 不会进行大小限制, 考虑到 emby 原服务器中合法的 api_key 个数不是无限个
-// 所以这里也不用限制太多
-var validApiKeys = sync.Map{}
+// 与用户身份一样按 auth.user-identity-ttl 过期, 过期后重新向 Emby 校验,
+// 避免在 Emby 中删除或吊销的 key 一直被信任
+var validApiKeys = newTtlMap[struct{}](time.Minute * 10)
+
+// identityResolver 用户身份解析器, 将 api_key 解析为 Emby 用户
+var identityResolver *userkey.Resolver
+
+// InitIdentity 初始化用户身份解析模块
+func InitIdentity(resolver *userkey.Resolver) {
+	identityResolver = resolver
+}
 
 // ApiKeyType 标记 emby 支持的不同种 api_key 传递方式
 type ApiKeyType string
@@ -70,8 +79,17 @@ func ApiKeyChecker() gin.HandlerFunc {
 		// 1 取出 api_key
 		kType, kName, apiKey := getApiKey(c)
 
-		// 2 如果该 key 已经是被信任的, 跳过校验
-		if _, ok := validApiKeys.Load(apiKey); ok {
+		// 2 已解析出用户身份的 key 由身份解析器按 TTL 重新校验, 这里只拦截被禁用的用户
+		if user, ok := userkey.GetUser(c); ok {
+			if user.IsDisabled {
+				c.String(http.StatusUnauthorized, "鉴权失败")
+				c.Abort()
+			}
+			return
+		}
+
+		// 如果该 key 已经是被信任的, 跳过校验
+		if _, ok := validApiKeys.Get(apiKey); ok {
 			return
 		}
 
@@ -88,7 +106,7 @@ func ApiKeyChecker() gin.HandlerFunc {
 		}
 
 		// 4 发出请求, 验证 api_key
-		u := config.C.Emby.Host + AuthUri
+		u := config.C().Emby.Host + AuthUri
 		var header http.Header
 		if kType == Query {
 			u = urls.AppendArgs(u, kName, apiKey)
@@ -98,14 +116,14 @@ func ApiKeyChecker() gin.HandlerFunc {
 		}
 		resp, err := https.Get(u).Header(header).Do()
 		if err != nil {
-			logs.Error("鉴权失败: %v", err)
+			logs.Ctx(c).Error("鉴权失败: %v", err)
 			c.Abort()
 			return
 		}
 		defer resp.Body.Close()
 		bodyBytes, err := io.ReadAll(resp.Body)
 		if err != nil {
-			logs.Error("鉴权中间件读取源服务器响应失败: %v", err)
+			logs.Ctx(c).Error("鉴权中间件读取源服务器响应失败: %v", err)
 			bodyBytes = []byte(UnauthorizedResp)
 		}
 		respBody := strings.TrimSpace(string(bodyBytes))
@@ -118,8 +136,21 @@ func ApiKeyChecker() gin.HandlerFunc {
 		}
 
 		// 6 校验通过, 加入信任集合
-		validApiKeys.Store(apiKey, struct{}{})
+		validApiKeys.SetWithTTL(apiKey, struct{}{}, config.C().Auth.UserIdentityTTL)
+	}
+}
+
+// IdentityResolver 解析请求 api_key 对应的 Emby 用户, 写入 gin 上下文
+//
+// 后续处理器可通过 userkey.GetUser 获取当前用户
+func IdentityResolver() gin.HandlerFunc {
+	if identityResolver == nil {
+		return func(c *gin.Context) {}
 	}
+	return identityResolver.Middleware(func(c *gin.Context) string {
+		_, _, apiKey := getApiKey(c)
+		return apiKey
+	})
 }
 
 // getApiKey 获取请求中的 api_key 信息
@@ -9,12 +9,14 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/config"
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/bytess"
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/https"
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/jsons"
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/logs"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/reqids"
 
 	"github.com/gin-gonic/gin"
 )
@@ -25,7 +27,7 @@ func ProxySocket() func(*gin.Context) {
 	var once = sync.Once{}
 
 	initFunc := func() {
-		origin := config.C.Emby.Host
+		origin := config.C().Emby.Host
 		u, err := url.Parse(origin)
 		if err != nil {
 			panic("转换 emby host 异常: " + err.Error())
@@ -52,7 +54,7 @@ func HandleImages(c *gin.Context) {
 	q := c.Request.URL.Query()
 	q.Del("quality")
 	q.Del("Quality")
-	q.Set("Quality", strconv.Itoa(config.C.Emby.ImagesQuality))
+	q.Set("Quality", strconv.Itoa(config.C().Emby.ImagesQuality))
 	c.Request.RequestURI = c.Request.URL.Path + "?" + q.Encode()
 	ProxyOrigin(c)
 }
@@ -62,15 +64,22 @@ func ProxyOrigin(c *gin.Context) {
 	if c == nil {
 		return
 	}
-	origin := config.C.Emby.Host
+	origin := config.C().Emby.Host
 
 	// 传递客户端 IP 到 emby
 	c.Request.Header.Set("X-Forwarded-For", c.ClientIP())
 	c.Request.Header.Set("X-Real-IP", c.ClientIP())
 
+	// 传递请求 id, 便于关联 Emby 日志
+	if id := reqids.Get(c); id != "" {
+		c.Request.Header.Set(reqids.Header, id)
+	}
+
+	start := time.Now()
 	if err := https.ProxyPass(c.Request, c.Writer, origin); err != nil {
-		logs.Error("代理异常: %v", err)
+		logs.Ctx(c).Error("代理异常: %v", err)
 	}
+	observeEmbyRequest(c.Request.URL.Path, c.Writer.Status(), start)
 }
 
 // TestProxyUri 用于测试的代理,
@@ -112,18 +121,18 @@ func TestProxyUri(c *gin.Context) bool {
 
 	bodyBytes, err := io.ReadAll(c.Request.Body)
 	if err != nil {
-		logs.Error("测试 uri 执行异常: %v", err)
+		logs.Ctx(c).Error("测试 uri 执行异常: %v", err)
 		return false
 	}
 	infos.Body = string(bodyBytes)
 
-	origin := config.C.Emby.Host
+	origin := config.C().Emby.Host
 	resp, err := https.Request(infos.Method, origin+infos.Uri).
 		Header(c.Request.Header).
 		Body(io.NopCloser(bytes.NewBuffer(bodyBytes))).
 		Do()
 	if err != nil {
-		logs.Error("测试 uri 执行异常: %v", err)
+		logs.Ctx(c).Error("测试 uri 执行异常: %v", err)
 		return false
 	}
 	defer resp.Body.Close()
@@ -137,12 +146,12 @@ func TestProxyUri(c *gin.Context) bool {
 
 	bodyBytes, err = io.ReadAll(resp.Body)
 	if err != nil {
-		logs.Error("测试 uri 执行异常: %v", err)
+		logs.Ctx(c).Error("测试 uri 执行异常: %v", err)
 		return false
 	}
 	infos.RespBody = string(bodyBytes)
 	infos.RespStatus = resp.StatusCode
-	logs.Warn("测试 uri 代理信息: %s", jsons.FromValue(infos))
+	logs.Ctx(c).Warn("测试 uri 代理信息: %s", jsons.FromValue(infos))
 
 	c.Status(infos.RespStatus)
 	c.Writer.Write(bodyBytes)
@@ -152,7 +161,7 @@ func TestProxyUri(c *gin.Context) bool {
 
 // ProxyRoot web 首页代理
 func ProxyRoot(c *gin.Context) {
-	resp, err := https.Request(c.Request.Method, config.C.Emby.Host+c.Request.URL.String()).
+	resp, err := https.Request(c.Request.Method, config.C().Emby.Host+c.Request.URL.String()).
 		Header(c.Request.Header).
 		Body(c.Request.Body).
 		DoSingle()
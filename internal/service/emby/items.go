@@ -9,7 +9,6 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/config"
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/bytess"
@@ -34,7 +33,7 @@ const (
 // ResortRandomItems 对随机的 items 列表进行重排序
 func ResortRandomItems(c *gin.Context) {
 	// 如果没有开启配置, 代理原请求并返回
-	if !config.C.Emby.ResortRandomItems {
+	if !config.C().Emby.ResortRandomItems {
 		ProxyOrigin(c)
 		return
 	}
@@ -67,7 +66,7 @@ func ResortRandomItems(c *gin.Context) {
 	defer func() {
 		respBody, _ := json.Marshal(ih)
 		if err != nil {
-			logs.Error("随机排序接口非预期响应, err: %v, 返回原始响应", err)
+			logs.Ctx(c).Error("随机排序接口非预期响应, err: %v, 返回原始响应", err)
 			respBody = bodyBytes
 		}
 
@@ -99,7 +98,7 @@ func RandomItemsWithLimit(c *gin.Context) {
 	q.Set("Limit", "500")
 	q.Del("SortOrder")
 	u.RawQuery = q.Encode()
-	embyHost := config.C.Emby.Host
+	embyHost := config.C().Emby.Host
 	c.Request.Header.Del("Accept-Encoding")
 	resp, err := https.Request(c.Request.Method, embyHost+u.String()).
 		Header(c.Request.Header).
@@ -116,7 +115,6 @@ func RandomItemsWithLimit(c *gin.Context) {
 
 	c.Status(resp.StatusCode)
 	https.CloneHeader(c.Writer, resp.Header)
-	c.Header(cache.HeaderKeyExpired, cache.Duration(time.Hour*3))
 	c.Header(cache.HeaderKeySpace, ItemsCacheSpace)
 	c.Header(cache.HeaderKeySpaceKey, calcRandomItemsCacheKey(c))
 
@@ -144,7 +142,7 @@ func calcRandomItemsCacheKey(c *gin.Context) string {
 func ProxyAddItemsPreviewInfo(c *gin.Context) {
 	// 代理请求
 	c.Request.Header.Del("Accept-Encoding")
-	resp, err := https.ProxyRequest(c.Request, config.C.Emby.Host)
+	resp, err := https.ProxyRequest(c.Request, config.C().Emby.Host)
 	if checkErr(c, err) {
 		return
 	}
@@ -233,7 +231,7 @@ func ProxyAddItemsPreviewInfo(c *gin.Context) {
 func ProxyLatestItems(c *gin.Context) {
 	// 代理请求
 	c.Request.Header.Del("Accept-Encoding")
-	resp, err := https.ProxyRequest(c.Request, config.C.Emby.Host)
+	resp, err := https.ProxyRequest(c.Request, config.C().Emby.Host)
 	if checkErr(c, err) {
 		return
 	}
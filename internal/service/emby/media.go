@@ -11,6 +11,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/config"
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/https"
@@ -39,10 +40,13 @@ func getEmbyFileLocalPath(itemInfo ItemInfo) (string, error) {
 	}
 
 	innerRequest := func(method string) (*http.Response, error) {
-		resp, err := https.Request(method, config.C.Emby.Host+itemInfo.PlaybackInfoUri).Header(header).Do()
+		start := time.Now()
+		resp, err := https.Request(method, config.C().Emby.Host+itemInfo.PlaybackInfoUri).Header(header).Do()
 		if err != nil {
+			observeEmbyRequest(itemInfo.PlaybackInfoUri, 0, start)
 			return nil, fmt.Errorf("请求 Emby 接口异常, error: %v", err)
 		}
+		observeEmbyRequest(itemInfo.PlaybackInfoUri, resp.StatusCode, start)
 		if resp.StatusCode != http.StatusOK {
 			resp.Body.Close()
 			return nil, fmt.Errorf("请求 Emby 接口异常, status: %s", resp.Status)
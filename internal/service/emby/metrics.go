@@ -0,0 +1,106 @@
+package emby
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/constant"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/service/audit"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/metrics"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/web/cache"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	// redirectTotal 直链重定向决策次数
+	redirectTotal = metrics.NewCounterVec(
+		"ge2o_redirect_total",
+		"串流及下载请求的重定向决策次数",
+		"node", "route", "decision",
+	)
+
+	// embyRequestDuration 请求 Emby 源服务器耗时
+	embyRequestDuration = metrics.NewHistogramVec(
+		"ge2o_emby_request_duration_seconds",
+		"请求 Emby 源服务器的耗时 (秒)",
+		nil, "endpoint", "status",
+	)
+)
+
+// 重定向决策类型
+const (
+	DecisionRedirect  = "redirect"  // 重定向到节点
+	DecisionLocal     = "local"     // 本地媒体回源
+	DecisionForbidden = "forbidden" // 无权访问
+	DecisionNoNode    = "no_node"   // 没有可用节点
+	DecisionError     = "error"     // 处理异常, 按错误策略处理
+)
+
+var (
+	// downloadRouteReg 下载接口
+	downloadRouteReg = regexp.MustCompile(constant.Reg_ItemDownload)
+
+	// originalRouteReg original 接口
+	originalRouteReg = regexp.MustCompile(constant.Reg_ResourceOriginal)
+
+	// streamRouteReg 串流接口
+	streamRouteReg = regexp.MustCompile(constant.Reg_ResourceStream)
+)
+
+// endpointIdReg 匹配 uri 中的资源 id 片段
+var endpointIdReg = regexp.MustCompile(`^([0-9]+|[0-9a-fA-F]{32}|[0-9a-fA-F-]{36})$`)
+
+// AuditRecorder 为串流、下载请求记录重定向审计事件
+func AuditRecorder() gin.HandlerFunc {
+	return audit.Middleware(
+		audit.Route{Name: "download", Pattern: downloadRouteReg},
+		audit.Route{Name: "original", Pattern: originalRouteReg},
+		audit.Route{Name: "stream", Pattern: streamRouteReg},
+	)
+}
+
+// countRedirect 记录一次重定向决策, 缓存后台刷新请求不计入
+func countRedirect(c *gin.Context, node, route, decision string) {
+	if cache.IsRefresh(c.Request.Context()) {
+		return
+	}
+	redirectTotal.With(node, route, decision).Inc()
+}
+
+// observeEmbyRequest 记录一次 Emby 请求的耗时
+func observeEmbyRequest(uri string, status int, start time.Time) {
+	embyRequestDuration.With(metricEndpoint(uri), strconv.Itoa(status)).ObserveSince(start)
+}
+
+// redirectRoute 根据请求地址判断重定向的路由类型, 用于指标标签
+func redirectRoute(c *gin.Context) string {
+	uri := c.Request.RequestURI
+	switch {
+	case downloadRouteReg.MatchString(uri):
+		return "download"
+	case originalRouteReg.MatchString(uri):
+		return "original"
+	}
+	return "stream"
+}
+
+// metricEndpoint 将 uri 规范化为指标标签, 去掉参数并把资源 id 替换为占位符
+func metricEndpoint(uri string) string {
+	if u, err := url.Parse(uri); err == nil {
+		uri = u.Path
+	}
+	uri = strings.ToLower(uri)
+	uri = strings.TrimPrefix(uri, "/emby")
+
+	segments := strings.Split(uri, "/")
+	for i, seg := range segments {
+		if endpointIdReg.MatchString(seg) {
+			segments[i] = "{id}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
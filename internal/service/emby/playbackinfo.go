@@ -10,7 +10,6 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/config"
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/https"
@@ -47,7 +46,7 @@ var (
 func TransferPlaybackInfo(c *gin.Context) {
 	// 1 解析资源信息
 	itemInfo, err := resolveItemInfo(c, RoutePlaybackInfo)
-	logs.Info("ItemInfo 解析结果: %s", itemInfo)
+	logs.Ctx(c).Info("ItemInfo 解析结果: %s", itemInfo)
 	if checkErr(c, err) {
 		return
 	}
@@ -69,7 +68,7 @@ func TransferPlaybackInfo(c *gin.Context) {
 	c.Request.Header.Del("Accept-Encoding")
 	originRequestBody := c.Request.Body
 	c.Request.Body = io.NopCloser(bytes.NewBufferString(PlaybackCommonPayload))
-	res, respHeader := RawFetch(itemInfo.PlaybackInfoUri, c.Request.Method, c.Request.Header, c.Request.Body)
+	res, respHeader := RawFetch(c, itemInfo.PlaybackInfoUri, c.Request.Method, c.Request.Header, c.Request.Body)
 	if res.Code != http.StatusOK {
 		checkErr(c, errors.New(res.Msg))
 		return
@@ -113,7 +112,7 @@ func TransferPlaybackInfo(c *gin.Context) {
 
 		// 如果是本地媒体, 不处理
 		embyPath, _ := source.Attr("Path").String()
-		if strings.HasPrefix(embyPath, config.C.Emby.LocalMediaRoot) {
+		if strings.HasPrefix(embyPath, config.C().Emby.LocalMediaRoot) {
 			return nil
 		}
 
@@ -158,8 +157,6 @@ func TransferPlaybackInfo(c *gin.Context) {
 	}
 
 	defer func() {
-		// 缓存 12h
-		c.Header(cache.HeaderKeyExpired, cache.Duration(time.Hour*12))
 		// 将请求结果缓存到指定缓存空间下
 		c.Header(cache.HeaderKeySpace, PlaybackCacheSpace)
 		c.Header(cache.HeaderKeySpaceKey, calcPlaybackInfoSpaceCacheKey(itemInfo))
@@ -187,7 +184,7 @@ func handleSpecialPlayback(c *gin.Context, itemInfo ItemInfo) bool {
 	c.Request.Header.Del("Accept-Encoding")
 	originRequestBody := c.Request.Body
 	c.Request.Body = io.NopCloser(bytes.NewBufferString(PlaybackCommonPayload))
-	res, _ := RawFetch(itemInfo.PlaybackInfoUri, c.Request.Method, c.Request.Header, c.Request.Body)
+	res, _ := RawFetch(c, itemInfo.PlaybackInfoUri, c.Request.Method, c.Request.Header, c.Request.Body)
 	if res.Code != http.StatusOK {
 		return false
 	}
@@ -205,8 +202,8 @@ func handleSpecialPlayback(c *gin.Context, itemInfo ItemInfo) bool {
 
 		// 本地媒体
 		path, _ := value.Attr("Path").String()
-		if strings.HasPrefix(path, config.C.Emby.LocalMediaRoot) {
-			logs.Info("本地媒体: %s, 回源处理", path)
+		if strings.HasPrefix(path, config.C().Emby.LocalMediaRoot) {
+			logs.Ctx(c).Info("本地媒体: %s, 回源处理", path)
 			flag = true
 		}
 
@@ -239,7 +236,7 @@ func useCacheSpacePlaybackInfo(c *gin.Context, itemInfo ItemInfo) bool {
 	}
 	reqId := itemInfo.MsInfo.RawId
 
-	if !config.C.Cache.Enable {
+	if !config.C().Cache.Enable {
 		// 未开启缓存功能
 		return false
 	}
@@ -309,7 +306,7 @@ func useCacheSpacePlaybackInfo(c *gin.Context, itemInfo ItemInfo) bool {
 	findMediaSourceAndReturn := func(spaceCache cache.RespCache) bool {
 		jsonBody, err := spaceCache.JsonBody()
 		if err != nil {
-			logs.Error("解析缓存响应体失败: %v", err)
+			logs.Ctx(c).Error("解析缓存响应体失败: %v", err)
 			return false
 		}
 
@@ -364,7 +361,7 @@ func useCacheSpacePlaybackInfo(c *gin.Context, itemInfo ItemInfo) bool {
 
 	// 如果是单个查询, 则手动请求一次全量
 	if _, err := fetchFullPlaybackInfo(itemInfo); err != nil {
-		logs.Error("更新缓存空间 PlaybackInfo 信息异常: %v", err)
+		logs.Ctx(c).Error("更新缓存空间 PlaybackInfo 信息异常: %v", err)
 		c.String(http.StatusInternalServerError, "查无缓存, 请稍后尝试重新播放")
 		return true
 	}
@@ -415,7 +412,7 @@ func LoadCacheItems(c *gin.Context) {
 	if err != nil {
 		return
 	}
-	logs.Info("itemInfo 解析结果: %s", itemInfo)
+	logs.Ctx(c).Info("itemInfo 解析结果: %s", itemInfo)
 
 	// coverMediaSources 解析 PlaybackInfo 中的 MediaSources 属性
 	// 并覆盖到当前请求的响应中
@@ -442,7 +439,7 @@ func LoadCacheItems(c *gin.Context) {
 	// 缓存空间中没有当前 Item 的 PlaybackInfo 数据, 手动请求
 	bodyJson, err := fetchFullPlaybackInfo(itemInfo)
 	if err != nil {
-		logs.Warn("更新 Items 缓存异常: %v", err)
+		logs.Ctx(c).Warn("更新 Items 缓存异常: %v", err)
 		return
 	}
 	coverMediaSources(bodyJson)
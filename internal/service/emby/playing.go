@@ -2,6 +2,7 @@ package emby
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -54,7 +55,7 @@ func PlayingStoppedHelper(c *gin.Context) {
 	body.Put("ItemId", jsons.FromValue(itemId))
 	body.Put("PlaySessionId", jsons.FromValue(randoms.RandomHex(32)))
 	body.Put("PositionTicks", jsons.FromValue(bodyJson.Attr("PositionTicks").Val()))
-	go sendPlayingProgress(kType, kName, apiKey, body)
+	go sendPlayingProgress(c.Request.Context(), kType, kName, apiKey, body)
 }
 
 // PlayingProgressHelper 拦截 Progress 请求, 如果进度报告为 0, 认为是无效请求
@@ -77,8 +78,8 @@ func PlayingProgressHelper(c *gin.Context) {
 	ProxyOrigin(c)
 }
 
-// sendPlayingProgress 发送辅助播放进度请求
-func sendPlayingProgress(kType ApiKeyType, kName, apiKey string, body *jsons.Item) {
+// sendPlayingProgress 发送辅助播放进度请求, ctx 用于在日志中关联原始请求
+func sendPlayingProgress(ctx context.Context, kType ApiKeyType, kName, apiKey string, body *jsons.Item) {
 	if body == nil {
 		return
 	}
@@ -102,14 +103,14 @@ func sendPlayingProgress(kType ApiKeyType, kName, apiKey string, body *jsons.Ite
 		return nil
 	}
 
-	logs.Tip("开始发送辅助 Progress 进度记录, 内容: %v", body)
-	if err := inner(config.C.Emby.Host + "/emby/Sessions/Playing/Progress"); err != nil {
-		logs.Warn("辅助发送 Progress 进度记录失败: %v", err)
+	logs.Ctx(ctx).Tip("开始发送辅助 Progress 进度记录, 内容: %v", body)
+	if err := inner(config.C().Emby.Host + "/emby/Sessions/Playing/Progress"); err != nil {
+		logs.Ctx(ctx).Warn("辅助发送 Progress 进度记录失败: %v", err)
 		return
 	}
-	if err := inner(config.C.Emby.Host + "/emby/Sessions/Playing/Stopped"); err != nil {
-		logs.Warn("辅助发送 Progress 进度记录失败: %v", err)
+	if err := inner(config.C().Emby.Host + "/emby/Sessions/Playing/Stopped"); err != nil {
+		logs.Ctx(ctx).Warn("辅助发送 Progress 进度记录失败: %v", err)
 		return
 	}
-	logs.Success("辅助发送 Progress 进度记录成功")
+	logs.Ctx(ctx).Success("辅助发送 Progress 进度记录成功")
 }
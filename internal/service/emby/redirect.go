@@ -1,96 +1,144 @@
 package emby
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
 	"strings"
-	"time"
 
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/config"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/service/audit"
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/service/node"
-	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/service/userkey"
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/logs"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/reqids"
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/web/cache"
 
 	"github.com/gin-gonic/gin"
 )
 
-var (
-	nodeSelector *node.Selector
-	userKeyCache *userkey.Cache
-)
+var nodeSelector *node.Selector
 
 // InitRedirect 初始化重定向模块
-func InitRedirect(selector *node.Selector, keyCache *userkey.Cache) {
+func InitRedirect(selector *node.Selector) {
 	nodeSelector = selector
-	userKeyCache = keyCache
 }
 
 // Redirect2NginxLink 重定向到 Nginx 节点直链
 func Redirect2NginxLink(c *gin.Context) {
 	// 1. 解析请求的资源信息
+	route := redirectRoute(c)
+	event := audit.From(c)
 	itemInfo, err := resolveItemInfo(c, RouteStream)
-	if checkErr(c, err) {
+	if err != nil {
+		countRedirect(c, "", route, DecisionError)
+		event.Decision, event.Error = DecisionError, err.Error()
+		checkErr(c, err)
+		return
+	}
+	logs.Ctx(c).Debug("解析到的 itemInfo: %v", itemInfo)
+	event.ItemId = itemInfo.Id
+	event.MediaSourceId = itemInfo.MsInfo.OriginId
+
+	// 校验用户对资源所在媒体库的访问权限
+	if err := checkLibraryAccess(c, itemInfo); err != nil {
+		if errors.Is(err, ErrLibraryForbidden) {
+			countRedirect(c, "", route, DecisionForbidden)
+			event.Decision = DecisionForbidden
+			c.Header(cache.HeaderKeyExpired, "-1")
+			c.String(http.StatusForbidden, "无权访问该资源")
+			return
+		}
+		// 无法完成校验, 交由源服务器处理, 由 Emby 自身判断权限
+		countRedirect(c, "", route, DecisionError)
+		event.Decision, event.Error = DecisionError, err.Error()
+		checkErr(c, err)
 		return
 	}
-	logs.Info("解析到的 itemInfo: %v", itemInfo)
 
 	// 2. 获取 Emby 中的媒体路径
 	embyPath, err := getEmbyFileLocalPath(itemInfo)
-	if checkErr(c, err) {
+	if err != nil {
+		countRedirect(c, "", route, DecisionError)
+		event.Decision, event.Error = DecisionError, err.Error()
+		checkErr(c, err)
 		return
 	}
-	logs.Info("Emby 媒体路径: %s", embyPath)
+	logs.Ctx(c).Debug("Emby 媒体路径: %s", embyPath)
+	event.EmbyPath = embyPath
 
 	// 3. 如果是本地媒体，回源处理
-	if strings.HasPrefix(embyPath, config.C.Emby.LocalMediaRoot) {
-		logs.Info("本地媒体: %s, 回源处理", embyPath)
+	if strings.HasPrefix(embyPath, config.C().Emby.LocalMediaRoot) {
+		logs.Ctx(c).Info("本地媒体: %s, 回源处理", embyPath)
+		countRedirect(c, "", route, DecisionLocal)
+		event.Decision = DecisionLocal
 		ProxyOrigin(c)
 		return
 	}
 
 	// 4. 转换为 Nginx 路径
-	nginxPath, ok := config.C.Path.MapEmby2Nginx(embyPath)
+	nginxPath, ok := config.C().Path.MapEmby2Nginx(embyPath)
 	if !ok {
-		checkErr(c, fmt.Errorf("无法映射 Emby 路径到 Nginx: %s", embyPath))
+		err = fmt.Errorf("无法映射 Emby 路径到 Nginx: %s", embyPath)
+		countRedirect(c, "", route, DecisionError)
+		event.Decision, event.Error = DecisionError, err.Error()
+		checkErr(c, err)
 		return
 	}
-	logs.Info("Nginx 路径: %s", nginxPath)
+	logs.Ctx(c).Debug("Nginx 路径: %s", nginxPath)
+	event.NginxPath = nginxPath
 
 	// 5. 选择健康节点
 	selectedNode := nodeSelector.SelectNode()
 	if selectedNode == nil {
-		checkErr(c, fmt.Errorf("没有可用的健康节点"))
+		err = errors.New("没有可用的健康节点")
+		countRedirect(c, "", route, DecisionNoNode)
+		event.Decision, event.Error = DecisionNoNode, err.Error()
+		checkErr(c, err)
 		return
 	}
-	logs.Info("选择节点: %s (%s)", selectedNode.Name, selectedNode.Host)
+	logs.Ctx(c).Debug("选择节点: %s (%s)", selectedNode.Name, selectedNode.Host)
 
 	// 6. 获取用户 API Key (用于 Nginx 鉴权)
-	userApiKey := userKeyCache.GetOrFetch(itemInfo.Id, itemInfo.ApiKey)
+	// 当前请求的 api_key 刚通过校验, 直接使用
+	userApiKey := itemInfo.ApiKey
 
 	// 7. 构建重定向 URL
-	redirectUrl := buildRedirectUrl(selectedNode.Host, nginxPath, userApiKey)
-	logs.Success("重定向到: %s", redirectUrl)
+	redirectUrl := buildRedirectUrl(c, selectedNode.Host, nginxPath, userApiKey)
+	logs.Ctx(c).Success("重定向到: %s", redirectUrl)
 
-	// 8. 设置缓存时间
-	c.Header(cache.HeaderKeyExpired, cache.Duration(time.Minute*10))
+	// 8. 标记指向的节点, 节点下线时清除缓存 (缓存时间由 cache.rules 决定)
+	c.Header(cache.HeaderKeyNode, selectedNode.Name)
 
 	// 9. 返回 302 重定向
+	countRedirect(c, selectedNode.Name, route, DecisionRedirect)
+	event.Decision, event.Node, event.RedirectURL = DecisionRedirect, selectedNode.Name, redirectUrl
 	c.Redirect(http.StatusTemporaryRedirect, redirectUrl)
 }
 
-// convertToNginxPath 将 Emby 路径转换为 Nginx 路径（已废弃，使用 config.C.Path.MapEmby2Nginx）
+// PurgeNodeRedirects 节点下线时清除指向该节点的重定向缓存
+//
+// 可注册为 node.HealthChecker 的节点下线回调, 后续请求会重新选择健康节点
+func PurgeNodeRedirects(ctx context.Context, name, host, reason string) {
+	if n := cache.Purge(cache.Filter{Node: name}); n > 0 {
+		logs.Ctx(ctx).Warn("节点 %s 已下线 (%s), 清除 %d 个指向该节点的重定向缓存", name, reason, n)
+	}
+}
+
+// convertToNginxPath 将 Emby 路径转换为 Nginx 路径（已废弃，使用 config.C().Path.MapEmby2Nginx）
 func convertToNginxPath(embyPath string) string {
-	nginxPath, _ := config.C.Path.MapEmby2Nginx(embyPath)
+	nginxPath, _ := config.C().Path.MapEmby2Nginx(embyPath)
 	return nginxPath
 }
 
 // buildRedirectUrl 构建重定向 URL
-func buildRedirectUrl(nodeHost, nginxPath, apiKey string) string {
+//
+// ctx 中携带请求 id 时以短格式附加到地址中, 便于关联节点侧的日志
+func buildRedirectUrl(ctx context.Context, nodeHost, nginxPath, apiKey string) string {
 	u, err := url.Parse(nodeHost)
 	if err != nil {
-		logs.Error("解析节点地址失败: %v", err)
+		logs.Ctx(ctx).Error("解析节点地址失败: %v", err)
 		return ""
 	}
 
@@ -98,11 +146,14 @@ func buildRedirectUrl(nodeHost, nginxPath, apiKey string) string {
 	u.Path = nginxPath
 
 	// 添加鉴权参数
-	if config.C.Auth.NginxAuthEnable && apiKey != "" {
-		q := u.Query()
+	q := u.Query()
+	if config.C().Auth.NginxAuthEnable && apiKey != "" {
 		q.Set("api_key", apiKey)
-		u.RawQuery = q.Encode()
 	}
+	if id := logs.RequestId(ctx); id != "" {
+		q.Set(reqids.QueryKey, reqids.Short(id))
+	}
+	u.RawQuery = q.Encode()
 
 	return u.String()
 }
@@ -126,7 +177,9 @@ func ProxyOriginalResource(c *gin.Context) {
 	}
 
 	// 如果是本地媒体, 代理回源
-	if strings.HasPrefix(embyPath, config.C.Emby.LocalMediaRoot) {
+	if strings.HasPrefix(embyPath, config.C().Emby.LocalMediaRoot) {
+		event := audit.From(c)
+		event.Decision, event.ItemId, event.EmbyPath = DecisionLocal, itemInfo.Id, embyPath
 		ProxyOrigin(c)
 		return
 	}
@@ -148,13 +201,13 @@ func checkErr(c *gin.Context, err error) bool {
 	c.Header(cache.HeaderKeyExpired, "-1")
 
 	// 采用拒绝策略, 直接返回错误
-	if config.C.Emby.ProxyErrorStrategy == config.PeStrategyReject {
-		logs.Error("代理接口失败: %v", err)
+	if config.C().Emby.ProxyErrorStrategy == config.PeStrategyReject {
+		logs.Ctx(c).Error("代理接口失败: %v", err)
 		c.String(http.StatusInternalServerError, "代理接口失败, 请检查日志")
 		return true
 	}
 
-	logs.Error("代理接口失败: %v, 回源处理", err)
+	logs.Ctx(c).Error("代理接口失败: %v, 回源处理", err)
 	ProxyOrigin(c)
 	return true
 }
@@ -0,0 +1,101 @@
+package emby
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/config"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/https"
+)
+
+// sessionActiveWithin 查询最近多长时间内活跃的会话
+const sessionActiveWithin = 15 * time.Minute
+
+// Session 正在播放的 Emby 会话
+type Session struct {
+	Id           string    `json:"id"`
+	User         string    `json:"user"`
+	Client       string    `json:"client"`
+	Device       string    `json:"device"`
+	RemoteIP     string    `json:"remote_ip"`
+	ItemId       string    `json:"item_id"`
+	Item         string    `json:"item"`     // 播放项目名称, 剧集为 "剧名 S01E02 集名"
+	Position     int64     `json:"position"` // 播放进度 (秒)
+	Runtime      int64     `json:"runtime"`  // 总时长 (秒)
+	Paused       bool      `json:"paused"`
+	PlayMethod   string    `json:"play_method"` // DirectPlay/DirectStream/Transcode
+	LastActivity time.Time `json:"last_activity"`
+}
+
+// ActiveSessions 使用管理员 Key 查询 Emby 中正在播放的会话
+func ActiveSessions() ([]Session, error) {
+	if config.C().Emby.AdminApiKey == "" {
+		return nil, errors.New("未配置 emby.admin-api-key, 无法查询播放会话")
+	}
+
+	u := fmt.Sprintf("%s/emby/Sessions?ActiveWithinSeconds=%d", config.C().Emby.Host, int(sessionActiveWithin.Seconds()))
+	header := http.Header{"X-Emby-Token": []string{config.C().Emby.AdminApiKey}}
+	resp, err := https.Get(u).Header(header).Do()
+	if err != nil {
+		return nil, fmt.Errorf("请求 Emby 失败: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("查询播放会话失败, status: %s", resp.Status)
+	}
+
+	var holder []struct {
+		Id               string
+		UserName         string
+		Client           string
+		DeviceName       string
+		RemoteEndPoint   string
+		LastActivityDate time.Time
+		NowPlayingItem   *struct {
+			Id                string
+			Name              string
+			SeriesName        string
+			ParentIndexNumber int
+			IndexNumber       int
+			RunTimeTicks      int64
+		}
+		PlayState struct {
+			PositionTicks int64
+			IsPaused      bool
+			PlayMethod    string
+		}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&holder); err != nil {
+		return nil, fmt.Errorf("解析播放会话失败: %v", err)
+	}
+
+	res := make([]Session, 0)
+	for _, s := range holder {
+		item := s.NowPlayingItem
+		if item == nil {
+			continue
+		}
+		name := item.Name
+		if item.SeriesName != "" {
+			name = fmt.Sprintf("%s S%02dE%02d %s", item.SeriesName, item.ParentIndexNumber, item.IndexNumber, item.Name)
+		}
+		res = append(res, Session{
+			Id:           s.Id,
+			User:         s.UserName,
+			Client:       s.Client,
+			Device:       s.DeviceName,
+			RemoteIP:     s.RemoteEndPoint,
+			ItemId:       item.Id,
+			Item:         name,
+			Position:     s.PlayState.PositionTicks / 10_000_000,
+			Runtime:      item.RunTimeTicks / 10_000_000,
+			Paused:       s.PlayState.IsPaused,
+			PlayMethod:   s.PlayState.PlayMethod,
+			LastActivity: s.LastActivityDate,
+		})
+	}
+	return res, nil
+}
@@ -0,0 +1,45 @@
+package emby
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/config"
+)
+
+func TestActiveSessions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/emby/Sessions" || r.Header.Get("X-Emby-Token") != "admin-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`[
+			{"Id": "s1", "UserName": "alice", "Client": "Emby Web", "DeviceName": "Chrome", "RemoteEndPoint": "10.0.0.2",
+			 "NowPlayingItem": {"Id": "100", "Name": "第二集", "SeriesName": "某剧", "ParentIndexNumber": 1, "IndexNumber": 2, "RunTimeTicks": 36000000000},
+			 "PlayState": {"PositionTicks": 6000000000, "IsPaused": true, "PlayMethod": "DirectStream"}},
+			{"Id": "s2", "UserName": "bob", "Client": "Infuse"}
+		]`))
+	}))
+	defer server.Close()
+
+	oldC := config.C()
+	defer config.Set(oldC)
+	config.Set(&config.Config{Emby: &config.Emby{Host: server.URL}})
+	if _, err := ActiveSessions(); err == nil {
+		t.Error("未配置 admin-api-key 时应报错")
+	}
+
+	config.C().Emby.AdminApiKey = "admin-key"
+	sessions, err := ActiveSessions()
+	if err != nil {
+		t.Fatalf("查询播放会话失败: %v", err)
+	}
+	if len(sessions) != 1 {
+		t.Fatalf("只应返回正在播放的会话: %+v", sessions)
+	}
+	s := sessions[0]
+	if s.User != "alice" || s.Item != "某剧 S01E02 第二集" || s.Position != 600 || s.Runtime != 3600 || !s.Paused || s.PlayMethod != "DirectStream" {
+		t.Errorf("播放会话不正确: %+v", s)
+	}
+}
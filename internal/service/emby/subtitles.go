@@ -3,14 +3,12 @@ package emby
 import (
 	"net/http"
 	"net/url"
-	"time"
 
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/strs"
-	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/web/cache"
 	"github.com/gin-gonic/gin"
 )
 
-// ProxySubtitles 字幕代理, 过期时间设置为 30 天
+// ProxySubtitles 字幕代理, 缓存时间由 cache.rules 决定
 func ProxySubtitles(c *gin.Context) {
 	if c == nil {
 		return
@@ -28,6 +26,5 @@ func ProxySubtitles(c *gin.Context) {
 		return
 	}
 
-	c.Header(cache.HeaderKeyExpired, cache.Duration(time.Hour*24*30))
 	ProxyOrigin(c)
 }
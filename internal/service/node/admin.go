@@ -0,0 +1,281 @@
+package node
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/config"
+)
+
+// adminBodyLimit 管理接口请求体大小上限
+const adminBodyLimit = 1 << 20
+
+// States 获取所有节点的实时健康状态及节点列表的版本号, 按配置顺序
+//
+// 禁用的节点及刚添加尚未检查的节点视为不健康
+func (nm *Manager) States() ([]NodeState, string) {
+	nodes, version := nm.Snapshot()
+
+	statuses := make(map[string]*NodeStatus)
+	for _, s := range nm.healthChecker.GetAllNodes() {
+		statuses[s.GetName()] = s
+	}
+
+	res := make([]NodeState, 0, len(nodes))
+	for _, n := range nodes {
+		state := NodeState{Name: n.Name, Host: n.Host, Weight: n.Weight, Enabled: n.Enabled}
+		if s, ok := statuses[n.Name]; ok && n.Enabled {
+			st := s.State()
+			if st.Host == n.Host {
+				state.Healthy, state.Draining, state.LastCheck = st.Healthy, st.Draining, st.LastCheck
+				state.ConsecutiveFails, state.History = st.ConsecutiveFails, st.History
+			}
+		}
+		res = append(res, state)
+	}
+	return res, version
+}
+
+// addRequest 添加节点请求
+type addRequest struct {
+	Name    string `json:"name"`    // 节点名称, 为空时自动生成
+	Host    string `json:"host"`    // 节点地址
+	Weight  *int   `json:"weight"`  // 权重, 默认 100
+	Enabled *bool  `json:"enabled"` // 是否启用, 默认启用
+}
+
+// AdminHandler 节点管理接口, 挂载在 prefix 路径下, 与 Telegram Bot 的节点命令语义相同
+//
+//	GET     {prefix}                 所有节点及实时健康状态
+//	GET     {prefix}/{name}          单个节点及实时健康状态
+//	POST    {prefix}                 添加节点, 请求体: {"host": "http://1.2.3.4:80", "name": "可选", "weight": 100, "enabled": true}
+//	DELETE  {prefix}/{name}          删除节点
+//	POST    {prefix}/{name}/enable   启用节点
+//	POST    {prefix}/{name}/disable  禁用节点
+//	POST    {prefix}/{name}/drain    排空节点, 不再分配新请求, 不写入配置文件
+//	POST    {prefix}/{name}/undrain  恢复排空的节点
+//	POST    {prefix}/batch-add       批量添加节点, 请求体: {"hosts": ["http://1.2.3.4:80", "http://5.6.7.8:80:50"]}
+//	POST    {prefix}/batch-delete    批量删除节点, 请求体: {"names": ["node-1", "node-2"]}
+//
+// 响应头 ETag 为节点列表的版本号, 修改请求通过 If-Match 请求头传递读取时的版本号,
+// 节点列表已被修改 (如通过 Telegram Bot) 时返回 412, 不传递时不校验;
+// actor 根据请求生成记录到配置历史中的修改人
+func (nm *Manager) AdminHandler(prefix string, actor func(r *http.Request) string) http.Handler {
+	prefix = strings.TrimSuffix(prefix, "/")
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET "+prefix, func(w http.ResponseWriter, r *http.Request) {
+		states, version := nm.States()
+		if notModified(w, r, version) {
+			return
+		}
+		healthy := 0
+		for _, s := range states {
+			if s.Healthy {
+				healthy++
+			}
+		}
+		writeJson(w, http.StatusOK, map[string]any{"version": version, "healthy": healthy, "nodes": states})
+	})
+
+	mux.HandleFunc("GET "+prefix+"/{name}", func(w http.ResponseWriter, r *http.Request) {
+		states, version := nm.States()
+		for _, s := range states {
+			if s.Name != r.PathValue("name") {
+				continue
+			}
+			if notModified(w, r, version) {
+				return
+			}
+			writeJson(w, http.StatusOK, s)
+			return
+		}
+		writeError(w, http.StatusNotFound, fmt.Errorf("%w: %s", ErrNodeNotFound, r.PathValue("name")))
+	})
+
+	mux.HandleFunc("POST "+prefix, func(w http.ResponseWriter, r *http.Request) {
+		var req addRequest
+		if err := readJson(w, r, &req); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		n := config.Node{Name: req.Name, Host: req.Host, Weight: 100, Enabled: true}
+		if req.Weight != nil {
+			n.Weight = *req.Weight
+		}
+		if req.Enabled != nil {
+			n.Enabled = *req.Enabled
+		}
+		version := ifMatch(r)
+		added, err := nm.AddNode(r.Context(), actor(r), &version, n)
+		if err != nil {
+			writeError(w, errorStatus(err), err)
+			return
+		}
+		state := NodeState{Name: added.Name, Host: added.Host, Weight: added.Weight, Enabled: added.Enabled}
+		writeChanged(w, http.StatusCreated, version, map[string]any{"node": state})
+	})
+
+	mux.HandleFunc("DELETE "+prefix+"/{name}", func(w http.ResponseWriter, r *http.Request) {
+		version := ifMatch(r)
+		if err := nm.DeleteNode(r.Context(), actor(r), &version, r.PathValue("name")); err != nil {
+			writeError(w, errorStatus(err), err)
+			return
+		}
+		writeChanged(w, http.StatusOK, version, map[string]any{})
+	})
+
+	enable := func(enable bool) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			version := ifMatch(r)
+			if err := nm.EnableNode(r.Context(), actor(r), &version, r.PathValue("name"), enable); err != nil {
+				writeError(w, errorStatus(err), err)
+				return
+			}
+			writeChanged(w, http.StatusOK, version, map[string]any{})
+		}
+	}
+	mux.HandleFunc("POST "+prefix+"/{name}/enable", enable(true))
+	mux.HandleFunc("POST "+prefix+"/{name}/disable", enable(false))
+
+	drain := func(drain bool) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if err := nm.DrainNode(r.Context(), actor(r), r.PathValue("name"), drain); err != nil {
+				writeError(w, errorStatus(err), err)
+				return
+			}
+			writeJson(w, http.StatusOK, map[string]bool{"draining": drain})
+		}
+	}
+	mux.HandleFunc("POST "+prefix+"/{name}/drain", drain(true))
+	mux.HandleFunc("POST "+prefix+"/{name}/undrain", drain(false))
+
+	mux.HandleFunc("POST "+prefix+"/batch-add", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Hosts []string `json:"hosts"`
+		}
+		if err := readJson(w, r, &req); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if len(req.Hosts) == 0 {
+			writeError(w, http.StatusBadRequest, errors.New("hosts 不能为空"))
+			return
+		}
+		version := ifMatch(r)
+		added, failed, err := nm.BatchAddNodes(r.Context(), actor(r), &version, req.Hosts)
+		if err != nil {
+			writeJson(w, errorStatus(err), map[string]any{"error": err.Error(), "failed": nonNil(failed)})
+			return
+		}
+		writeChanged(w, http.StatusOK, version, map[string]any{"added": added, "failed": nonNil(failed)})
+	})
+
+	mux.HandleFunc("POST "+prefix+"/batch-delete", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Names []string `json:"names"`
+		}
+		if err := readJson(w, r, &req); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if len(req.Names) == 0 {
+			writeError(w, http.StatusBadRequest, errors.New("names 不能为空"))
+			return
+		}
+		version := ifMatch(r)
+		deleted, failed, err := nm.BatchDeleteNodes(r.Context(), actor(r), &version, req.Names)
+		if err != nil {
+			writeJson(w, errorStatus(err), map[string]any{"error": err.Error(), "failed": nonNil(failed)})
+			return
+		}
+		writeChanged(w, http.StatusOK, version, map[string]any{"deleted": deleted, "failed": nonNil(failed)})
+	})
+
+	return mux
+}
+
+// writeChanged 响应修改结果, 附带修改后节点列表的版本号
+func writeChanged(w http.ResponseWriter, code int, version string, res map[string]any) {
+	w.Header().Set("ETag", etag(version))
+	res["version"] = version
+	writeJson(w, code, res)
+}
+
+// notModified 设置 ETag 响应头, 客户端缓存的版本号未变化时响应 304
+func notModified(w http.ResponseWriter, r *http.Request, version string) bool {
+	w.Header().Set("ETag", etag(version))
+	if inm := r.Header.Get("If-None-Match"); inm != "" && parseETag(inm) == version {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+// ifMatch 获取请求中期望的节点列表版本号, 未传递或为 * 时返回空
+func ifMatch(r *http.Request) string {
+	v := parseETag(r.Header.Get("If-Match"))
+	if v == "*" {
+		return ""
+	}
+	return v
+}
+
+// etag 将版本号转换为 ETag 格式
+func etag(version string) string {
+	return `"` + version + `"`
+}
+
+// parseETag 从 ETag 中取出版本号, 兼容弱校验格式及不带引号的版本号
+func parseETag(s string) string {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "W/")
+	return strings.Trim(s, `"`)
+}
+
+// errorStatus 根据节点管理错误确定响应码
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrVersionConflict):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, ErrNodeNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrNodeExists):
+		return http.StatusConflict
+	case errors.Is(err, ErrSaveConfig):
+		return http.StatusInternalServerError
+	}
+	return http.StatusBadRequest
+}
+
+// readJson 解析 json 请求体
+func readJson(w http.ResponseWriter, r *http.Request, v any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, adminBodyLimit))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("请求体格式错误: %v", err)
+	}
+	return nil
+}
+
+// writeJson 响应 json 数据
+func writeJson(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError 响应错误信息
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJson(w, code, map[string]string{"error": err.Error()})
+}
+
+// nonNil 将 nil 切片转换为空切片, 使 JSON 输出 [] 而不是 null
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
@@ -0,0 +1,274 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/config"
+)
+
+const testAdminConfig = `
+emby:
+  host: http://127.0.0.1:8096
+nodes:
+  list:
+    - name: node-1
+      host: http://127.0.0.2:1
+      weight: 100
+      enabled: true
+`
+
+// newTestAdmin 基于临时配置文件初始化节点管理接口
+func newTestAdmin(t *testing.T) (*Manager, http.Handler) {
+	t.Helper()
+	oldC := config.C()
+	t.Cleanup(func() { config.Set(oldC) })
+
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(path, []byte(testAdminConfig), 0644); err != nil {
+		t.Fatalf("写入配置文件失败: %v", err)
+	}
+	if err := config.ReadFromFile(path); err != nil {
+		t.Fatalf("读取配置失败: %v", err)
+	}
+
+	nm := NewManager(NewHealthChecker(config.C().Nodes))
+	return nm, nm.AdminHandler("/api/nodes", func(r *http.Request) string { return "api:test" })
+}
+
+// doAdmin 发送管理接口请求, ifMatch 不为空时携带 If-Match 请求头
+func doAdmin(h http.Handler, method, path, body, ifMatch string) (*httptest.ResponseRecorder, map[string]any) {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	res := map[string]any{}
+	json.Unmarshal(rec.Body.Bytes(), &res)
+	return rec, res
+}
+
+func TestAdminHandler_Nodes(t *testing.T) {
+	nm, h := newTestAdmin(t)
+
+	rec, res := doAdmin(h, http.MethodGet, "/api/nodes", "", "")
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") != etag(nm.Version()) {
+		t.Fatalf("获取节点列表失败: %d %s", rec.Code, rec.Body)
+	}
+	if nodes, _ := res["nodes"].([]any); len(nodes) != 1 {
+		t.Errorf("节点数量不正确: %v", res)
+	}
+	tag := rec.Header().Get("ETag")
+
+	// 版本未变化时响应 304
+	req := httptest.NewRequest(http.MethodGet, "/api/nodes", nil)
+	req.Header.Set("If-None-Match", tag)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("版本未变化时应响应 304, 实际: %d", rec.Code)
+	}
+
+	// 添加节点, 使用默认权重
+	rec, res = doAdmin(h, http.MethodPost, "/api/nodes", `{"name": "node-2", "host": "http://127.0.0.3:1"}`, tag)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("添加节点失败: %d %s", rec.Code, rec.Body)
+	}
+	added, _ := res["node"].(map[string]any)
+	if added["name"] != "node-2" || added["weight"] != float64(100) || added["enabled"] != true {
+		t.Errorf("添加的节点不正确: %v", res)
+	}
+	if rec.Header().Get("ETag") == tag || res["version"] != nm.Version() {
+		t.Errorf("修改后应返回新的版本号: %v", res)
+	}
+
+	// 使用旧版本号修改时拒绝
+	if rec, _ = doAdmin(h, http.MethodPost, "/api/nodes/node-1/disable", "", tag); rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("版本号过期时应响应 412, 实际: %d", rec.Code)
+	}
+	if !config.C().Nodes.List[0].Enabled {
+		t.Error("版本号过期时不应修改节点")
+	}
+
+	// 不携带版本号时不校验
+	if rec, _ = doAdmin(h, http.MethodPost, "/api/nodes/node-1/disable", "", ""); rec.Code != http.StatusOK {
+		t.Errorf("禁用节点失败: %d %s", rec.Code, rec.Body)
+	}
+	rec, res = doAdmin(h, http.MethodGet, "/api/nodes/node-1", "", "")
+	if rec.Code != http.StatusOK || res["enabled"] != false || res["healthy"] != false {
+		t.Errorf("禁用的节点状态不正确: %v", res)
+	}
+
+	if rec, _ = doAdmin(h, http.MethodPost, "/api/nodes", `{"name": "node-1", "host": "http://127.0.0.4:1"}`, ""); rec.Code != http.StatusConflict {
+		t.Errorf("节点重名时应响应 409, 实际: %d", rec.Code)
+	}
+	if rec, _ = doAdmin(h, http.MethodPost, "/api/nodes", `{"host": "http://127.0.0.4:1", "weight": 0}`, ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("权重不合法时应响应 400, 实际: %d", rec.Code)
+	}
+	if rec, _ = doAdmin(h, http.MethodDelete, "/api/nodes/missing", "", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("删除不存在的节点应响应 404, 实际: %d", rec.Code)
+	}
+}
+
+func TestAdminHandler_Batch(t *testing.T) {
+	nm, h := newTestAdmin(t)
+
+	rec, res := doAdmin(h, http.MethodPost, "/api/nodes/batch-add", `{"hosts": ["http://127.0.0.3:1", "http://127.0.0.4:1:50", "http://127.0.0.2:1"]}`, nm.Version())
+	if rec.Code != http.StatusOK || res["added"] != float64(2) {
+		t.Fatalf("批量添加节点失败: %d %s", rec.Code, rec.Body)
+	}
+	if failed, _ := res["failed"].([]any); len(failed) != 1 {
+		t.Errorf("应有 1 个节点添加失败: %v", res)
+	}
+	if len(config.C().Nodes.List) != 3 || config.C().Nodes.List[2].Weight != 50 {
+		t.Errorf("批量添加后的节点列表不正确: %+v", config.C().Nodes.List)
+	}
+
+	rec, res = doAdmin(h, http.MethodPost, "/api/nodes/batch-delete", `{"names": ["node-1", "missing"]}`, "")
+	if rec.Code != http.StatusOK || res["deleted"] != float64(1) {
+		t.Fatalf("批量删除节点失败: %d %s", rec.Code, rec.Body)
+	}
+	if len(config.C().Nodes.List) != 2 {
+		t.Errorf("批量删除后的节点列表不正确: %+v", config.C().Nodes.List)
+	}
+
+	if rec, _ = doAdmin(h, http.MethodPost, "/api/nodes/batch-delete", `{"names": [], "extra": 1}`, ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("请求体格式错误时应响应 400, 实际: %d", rec.Code)
+	}
+}
+
+func TestAdminHandler_Drain(t *testing.T) {
+	nm, h := newTestAdmin(t)
+	version := nm.Version()
+	n := nm.healthChecker.GetAllNodes()[0]
+	n.record(true, 20*time.Millisecond)
+	n.record(false, time.Second)
+
+	if rec, _ := doAdmin(h, http.MethodPost, "/api/nodes/node-1/drain", "", ""); rec.Code != http.StatusOK {
+		t.Fatalf("排空节点失败: %d %s", rec.Code, rec.Body)
+	}
+	if NewSelector(nm.healthChecker).SelectNode() != nil {
+		t.Error("排空的节点不应再被选择")
+	}
+	if nm.Version() != version {
+		t.Error("排空节点不应修改节点列表的版本号")
+	}
+
+	var state NodeState
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/nodes/node-1", nil))
+	json.Unmarshal(rec.Body.Bytes(), &state)
+	if !state.Draining || len(state.History) != 2 || state.History[0].Latency != 20 || state.History[1].Ok {
+		t.Errorf("节点状态不正确: %s", rec.Body)
+	}
+
+	// 重新加载配置后保留排空状态
+	nm.healthChecker.ReloadNodes(context.Background())
+	if !n.IsDraining() || nm.healthChecker.IsSchedulable("node-1") {
+		t.Error("重新加载配置后应保留排空状态")
+	}
+
+	if rec, _ := doAdmin(h, http.MethodPost, "/api/nodes/node-1/undrain", "", ""); rec.Code != http.StatusOK || n.IsDraining() {
+		t.Errorf("恢复节点失败: %d %s", rec.Code, rec.Body)
+	}
+	if rec, _ := doAdmin(h, http.MethodPost, "/api/nodes/missing/drain", "", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("排空不存在的节点应响应 404, 实际: %d", rec.Code)
+	}
+}
+
+func TestNodeStatus_History(t *testing.T) {
+	n := &NodeStatus{}
+	for i := range historySize + 10 {
+		n.record(true, time.Duration(i)*time.Millisecond)
+	}
+	history := n.State().History
+	if len(history) != historySize || history[0].Latency != 10 || history[historySize-1].Latency != historySize+9 {
+		t.Errorf("超出上限时应丢弃最早的结果: %d %+v", len(history), history[0])
+	}
+}
+
+func TestManager_CopyOnWrite(t *testing.T) {
+	nm, _ := newTestAdmin(t)
+	before := config.C()
+
+	// 并发读取节点列表, 配合 -race 检查修改节点时没有原地修改
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			for _, n := range config.C().Nodes.List {
+				_ = n.Enabled
+			}
+		}
+	}()
+	for i := 0; i < 10; i++ {
+		if err := nm.EnableNode(context.Background(), "test", nil, "node-1", i%2 == 0); err != nil {
+			t.Fatalf("修改节点失败: %v", err)
+		}
+	}
+	<-done
+
+	if !before.Nodes.List[0].Enabled {
+		t.Error("修改节点不应影响修改前的配置对象")
+	}
+	if config.C() == before || config.C().Nodes.List[0].Enabled {
+		t.Error("修改节点后应替换为新的配置对象")
+	}
+
+	// 保存失败时当前配置保持不变
+	current := config.C()
+	if err := config.SetConfigPath(filepath.Join(t.TempDir(), "missing.yml")); err != nil {
+		t.Fatalf("设置配置文件路径失败: %v", err)
+	}
+	if _, err := nm.AddNode(context.Background(), "test", nil, config.Node{Name: "node-2", Host: "http://127.0.0.3:1", Weight: 1}); !errors.Is(err, ErrSaveConfig) {
+		t.Errorf("保存失败时应返回 ErrSaveConfig, 实际: %v", err)
+	}
+	if config.C() != current || len(current.Nodes.List) != 1 {
+		t.Errorf("保存失败时不应修改当前配置: %+v", config.C().Nodes.List)
+	}
+}
+
+func TestAdminHandler_ConcurrentWrites(t *testing.T) {
+	nm, h := newTestAdmin(t)
+	version := nm.Version()
+
+	// 携带相同版本号的并发修改只有一个成功, 其余因版本号冲突被拒绝
+	codes := make(chan int, 5)
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			body := fmt.Sprintf(`{"name": "node-c%d", "host": "http://127.0.1.%d:1"}`, i, i)
+			rec, _ := doAdmin(h, http.MethodPost, "/api/nodes", body, etag(version))
+			codes <- rec.Code
+		}(i)
+	}
+	wg.Wait()
+	close(codes)
+
+	created := 0
+	for code := range codes {
+		switch code {
+		case http.StatusCreated:
+			created++
+		case http.StatusPreconditionFailed:
+		default:
+			t.Errorf("并发修改的响应码不正确: %d", code)
+		}
+	}
+	if created != 1 || len(config.C().Nodes.List) != 2 {
+		t.Errorf("期望只有 1 个修改成功, 实际: %d, 节点列表: %+v", created, config.C().Nodes.List)
+	}
+}
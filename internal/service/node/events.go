@@ -0,0 +1,58 @@
+package node
+
+import (
+	"context"
+
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/config"
+)
+
+// 节点下线原因
+const (
+	DownUnhealthy = "unhealthy" // 健康检查连续失败
+	DownDisabled  = "disabled"  // 节点被禁用
+	DownRemoved   = "removed"   // 节点被删除或地址变更
+	DownDraining  = "draining"  // 节点被排空
+)
+
+// DownListener 节点下线回调, ctx 为触发下线的请求的 context, 健康检查触发时不携带请求信息
+type DownListener func(ctx context.Context, name, host, reason string)
+
+// OnNodeDown 注册节点下线回调
+//
+// 节点被标记为不健康、被禁用、被删除或被排空时触发, 回调在单独的 goroutine 中执行
+func (hc *HealthChecker) OnNodeDown(fn DownListener) {
+	hc.listenerMu.Lock()
+	defer hc.listenerMu.Unlock()
+	hc.listeners = append(hc.listeners, fn)
+}
+
+// notifyDown 异步通知所有节点下线回调
+func (hc *HealthChecker) notifyDown(ctx context.Context, name, host, reason string) {
+	hc.listenerMu.Lock()
+	listeners := append([]DownListener(nil), hc.listeners...)
+	hc.listenerMu.Unlock()
+	if len(listeners) == 0 {
+		return
+	}
+	go func() {
+		for _, fn := range listeners {
+			fn(ctx, name, host, reason)
+		}
+	}()
+}
+
+// downReason 判断重新加载配置后不再参与调度的节点的下线原因
+func downReason(old *NodeStatus, list []config.Node) string {
+	for _, n := range list {
+		if n.Name != old.Name {
+			continue
+		}
+		if !n.Enabled {
+			return DownDisabled
+		}
+		if n.Host == old.Host {
+			return ""
+		}
+	}
+	return DownRemoved
+}
@@ -0,0 +1,85 @@
+package node
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/config"
+)
+
+// downEvent 节点下线事件
+type downEvent struct {
+	name, reason string
+}
+
+func TestHealthChecker_OnNodeDown(t *testing.T) {
+	// 健康检查始终失败的节点
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	cfg := &config.Nodes{
+		HealthCheck: config.HealthCheck{Interval: 1, Timeout: 2, FailThreshold: 1, SuccessThreshold: 1},
+		List: []config.Node{
+			{Name: "bad", Host: server.URL, Weight: 100, Enabled: true},
+			{Name: "keep", Host: "http://127.0.0.2:1", Weight: 100, Enabled: true},
+			{Name: "off", Host: "http://127.0.0.3:1", Weight: 100, Enabled: true},
+		},
+	}
+	checker := NewHealthChecker(cfg)
+
+	events := make(chan downEvent, 10)
+	checker.OnNodeDown(func(_ context.Context, name, host, reason string) {
+		events <- downEvent{name, reason}
+	})
+
+	checker.checkNode(checker.nodes["bad"])
+	if e := waitEvent(t, events); e != (downEvent{"bad", DownUnhealthy}) {
+		t.Errorf("健康检查失败时的下线事件不正确: %+v", e)
+	}
+	if checker.IsHealthy("bad") {
+		t.Error("节点应被标记为不健康")
+	}
+
+	// 重新加载配置: 禁用 off, 删除 bad
+	oldC := config.C()
+	defer config.Set(oldC)
+	config.Set(&config.Config{Nodes: &config.Nodes{List: []config.Node{
+		{Name: "keep", Host: "http://127.0.0.2:1", Weight: 100, Enabled: true},
+		{Name: "off", Host: "http://127.0.0.3:1", Weight: 100, Enabled: false},
+	}}})
+	checker.ReloadNodes(context.Background())
+
+	// 重新加载后会立即执行一次健康检查, 这里只关注配置变更产生的事件
+	got := map[string]string{}
+	timeout := time.After(time.Millisecond * 500)
+	for collecting := true; collecting; {
+		select {
+		case e := <-events:
+			if e.reason != DownUnhealthy {
+				got[e.name] = e.reason
+			}
+		case <-timeout:
+			collecting = false
+		}
+	}
+	if len(got) != 2 || got["bad"] != DownRemoved || got["off"] != DownDisabled {
+		t.Errorf("重新加载配置后的下线事件不正确: %v", got)
+	}
+}
+
+// waitEvent 等待下线事件
+func waitEvent(t *testing.T, events chan downEvent) downEvent {
+	t.Helper()
+	select {
+	case e := <-events:
+		return e
+	case <-time.After(time.Second * 2):
+		t.Fatal("等待节点下线事件超时")
+	}
+	return downEvent{}
+}
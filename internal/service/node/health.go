@@ -9,8 +9,35 @@ import (
 
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/config"
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/logs"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/metrics"
 )
 
+var (
+	// healthCheckTotal 健康检查结果次数
+	healthCheckTotal = metrics.NewCounterVec(
+		"ge2o_node_health_check_total",
+		"节点健康检查结果次数",
+		"node", "result",
+	)
+
+	// healthCheckDuration 健康检查耗时
+	healthCheckDuration = metrics.NewHistogramVec(
+		"ge2o_node_health_check_duration_seconds",
+		"节点健康检查耗时 (秒)",
+		nil, "node",
+	)
+
+	// nodeHealthy 节点当前健康状态, 1 健康 0 不健康
+	nodeHealthy = metrics.NewGaugeVec(
+		"ge2o_node_healthy",
+		"节点当前健康状态, 1 健康 0 不健康",
+		"node",
+	)
+)
+
+// historySize 每个节点保留的健康检查结果个数
+const historySize = 60
+
 // HealthChecker 健康检查器
 type HealthChecker struct {
 	nodes    map[string]*NodeStatus
@@ -21,6 +48,9 @@ type HealthChecker struct {
 	succTh   int // 成功阈值
 	mu       sync.RWMutex
 	stopCh   chan struct{}
+
+	listeners  []DownListener // 节点下线回调
+	listenerMu sync.Mutex
 }
 
 // NewHealthChecker 创建健康检查器
@@ -109,35 +139,42 @@ func (hc *HealthChecker) checkNode(node *NodeStatus) {
 	ctx, cancel := context.WithTimeout(context.Background(), hc.timeout)
 	defer cancel()
 
-	// 构建健康检查 URL
-	// 健康检查固定使用 80 端口（从 node.Host 提取 IP/域名）
-	healthCheckURL := buildHealthCheckURL(node.Host)
-
-	// 构建健康检查请求
-	// curl -v -H "Host: gtm-health" http://<IP>:80/gtm-health
-	req, err := http.NewRequestWithContext(ctx, "GET", healthCheckURL, nil)
-	if err != nil {
-		hc.markUnhealthy(node)
-		return
-	}
-	req.Host = "gtm-health"
-
-	resp, err := hc.client.Do(req)
+	start := time.Now()
+	code, err := probe(ctx, hc.client, node.Host)
+	healthCheckDuration.With(node.Name).ObserveSince(start)
+	node.record(err == nil && code == http.StatusOK, time.Since(start))
 	if err != nil {
 		logs.Warn("节点 %s 健康检查失败: %v", node.Name, err)
 		hc.markUnhealthy(node)
 		return
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusOK {
+	if code == http.StatusOK {
 		hc.markHealthy(node)
 	} else {
-		logs.Warn("节点 %s 健康检查返回非200: %d", node.Name, resp.StatusCode)
+		logs.Warn("节点 %s 健康检查返回非200: %d", node.Name, code)
 		hc.markUnhealthy(node)
 	}
 }
 
+// probe 请求节点的健康检查接口, 返回响应状态码
+func probe(ctx context.Context, client *http.Client, host string) (int, error) {
+	// 健康检查固定使用 80 端口（从 node.Host 提取 IP/域名）
+	// curl -v -H "Host: gtm-health" http://<IP>:80/gtm-health
+	req, err := http.NewRequestWithContext(ctx, "GET", buildHealthCheckURL(host), nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Host = "gtm-health"
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
 // markHealthy 标记节点健康
 func (hc *HealthChecker) markHealthy(node *NodeStatus) {
 	node.mu.Lock()
@@ -146,6 +183,8 @@ func (hc *HealthChecker) markHealthy(node *NodeStatus) {
 	node.LastCheck = time.Now()
 	node.ConsecutiveFails = 0
 	node.ConsecutiveSucc++
+	healthCheckTotal.With(node.Name, "success").Inc()
+	defer func() { nodeHealthy.With(node.Name).Set(boolGauge(node.Healthy)) }()
 
 	if !node.Healthy && node.ConsecutiveSucc >= hc.succTh {
 		node.Healthy = true
@@ -161,10 +200,13 @@ func (hc *HealthChecker) markUnhealthy(node *NodeStatus) {
 	node.LastCheck = time.Now()
 	node.ConsecutiveSucc = 0
 	node.ConsecutiveFails++
+	healthCheckTotal.With(node.Name, "failure").Inc()
+	defer func() { nodeHealthy.With(node.Name).Set(boolGauge(node.Healthy)) }()
 
 	if node.Healthy && node.ConsecutiveFails >= hc.failTh {
 		node.Healthy = false
 		logs.Error("节点 %s 标记为不健康", node.Name)
+		hc.notifyDown(context.Background(), node.Name, node.Host, DownUnhealthy)
 	}
 }
 
@@ -211,6 +253,70 @@ func (hc *HealthChecker) GetHealthyNodes() []*NodeStatus {
 	return healthy
 }
 
+// GetSchedulableNodes 获取所有可以分配新请求的节点, 即健康且未被排空的节点
+func (hc *HealthChecker) GetSchedulableNodes() []*NodeStatus {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+
+	nodes := make([]*NodeStatus, 0)
+	for _, node := range hc.nodes {
+		node.mu.RLock()
+		if node.Healthy && !node.Draining {
+			nodes = append(nodes, node)
+		}
+		node.mu.RUnlock()
+	}
+	return nodes
+}
+
+// IsSchedulable 判断节点是否存在、健康且未被排空
+func (hc *HealthChecker) IsSchedulable(name string) bool {
+	hc.mu.RLock()
+	node, ok := hc.nodes[name]
+	hc.mu.RUnlock()
+	if !ok {
+		return false
+	}
+	node.mu.RLock()
+	defer node.mu.RUnlock()
+	return node.Healthy && !node.Draining
+}
+
+// SetDraining 排空或恢复节点, 排空的节点仍会进行健康检查, 但不再分配新请求
+//
+// 排空状态只保存在内存中, 重新加载配置时保留, 重启后恢复
+func (hc *HealthChecker) SetDraining(ctx context.Context, name string, draining bool) bool {
+	hc.mu.RLock()
+	node, ok := hc.nodes[name]
+	hc.mu.RUnlock()
+	if !ok {
+		return false
+	}
+
+	node.mu.Lock()
+	changed := node.Draining != draining
+	node.Draining = draining
+	node.mu.Unlock()
+
+	if changed && draining {
+		hc.notifyDown(ctx, node.GetName(), node.GetHost(), DownDraining)
+	}
+	return true
+}
+
+// IsHealthy 判断节点是否存在且健康
+func (hc *HealthChecker) IsHealthy(name string) bool {
+	hc.mu.RLock()
+	node, ok := hc.nodes[name]
+	hc.mu.RUnlock()
+	if !ok {
+		return false
+	}
+	node.mu.RLock()
+	defer node.mu.RUnlock()
+	return node.Healthy
+}
+
 // GetAllNodes 获取所有节点（包含健康状态）
 func (hc *HealthChecker) GetAllNodes() []*NodeStatus {
 	hc.mu.RLock()
@@ -224,18 +330,40 @@ func (hc *HealthChecker) GetAllNodes() []*NodeStatus {
 }
 
 // ReloadNodes 重新加载节点配置
-func (hc *HealthChecker) ReloadNodes() {
+//
+// 名称及地址都未变化的节点保留当前的健康状态, 其余节点初始假定健康并立即检查,
+// ctx 传递给节点下线回调, 用于在日志中关联触发修改的请求
+func (hc *HealthChecker) ReloadNodes(ctx context.Context) {
 	hc.mu.Lock()
 	defer hc.mu.Unlock()
 
-	// 清空现有节点
+	// 只读取一次节点列表, 避免配置在加载过程中被替换
+	list := config.C().Nodes.List
+
+	// 通知不再参与调度的节点下线
+	kept := make(map[string]*NodeStatus)
+	for name, old := range hc.nodes {
+		if reason := downReason(old, list); reason != "" {
+			nodeHealthy.Delete(name)
+			hc.notifyDown(ctx, old.Name, old.Host, reason)
+			continue
+		}
+		kept[name] = old
+	}
 	hc.nodes = make(map[string]*NodeStatus)
 
 	// 从配置中重新加载
-	for _, node := range config.C.Nodes.List {
+	for _, node := range list {
 		if !node.Enabled {
 			continue
 		}
+		if old, ok := kept[node.Name]; ok {
+			old.mu.Lock()
+			old.Weight = node.Weight
+			old.mu.Unlock()
+			hc.nodes[node.Name] = old
+			continue
+		}
 		hc.nodes[node.Name] = &NodeStatus{
 			Name:    node.Name,
 			Host:    node.Host,
@@ -250,3 +378,11 @@ func (hc *HealthChecker) ReloadNodes() {
 	// 立即执行一次健康检查
 	go hc.checkAll()
 }
+
+// boolGauge 将布尔值转换为指标值
+func boolGauge(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,494 @@
+package node
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/config"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/logs"
+)
+
+var (
+	// ErrNodeNotFound 节点不存在
+	ErrNodeNotFound = errors.New("节点不存在")
+
+	// ErrNodeExists 节点已存在
+	ErrNodeExists = errors.New("节点已存在")
+
+	// ErrVersionConflict 节点列表在读取之后已被修改
+	ErrVersionConflict = errors.New("节点列表已被修改, 请重新获取后再试")
+
+	// ErrSaveConfig 节点修改写入配置文件失败, 修改未生效
+	ErrSaveConfig = errors.New("保存配置失败")
+)
+
+// Manager 节点管理器, Telegram Bot 及管理接口共用, 保证节点修改串行执行
+//
+// 修改节点时可以传递读取节点列表时得到的版本号, 版本号与当前不一致时拒绝修改,
+// 避免覆盖其他调用方的修改; 修改成功后版本号更新为修改后的版本号, 为 nil 或空时不校验.
+// 修改在配置副本上进行, 保存成功后整体替换当前配置, 不会原地修改正在被读取的节点列表
+type Manager struct {
+	healthChecker *HealthChecker
+	mu            sync.RWMutex
+}
+
+// NewManager 创建节点管理器
+func NewManager(healthChecker *HealthChecker) *Manager {
+	return &Manager{
+		healthChecker: healthChecker,
+	}
+}
+
+// ListNodes 列出所有节点
+func (nm *Manager) ListNodes() []config.Node {
+	nodes, _ := nm.Snapshot()
+	return nodes
+}
+
+// Snapshot 获取所有节点及节点列表的版本号
+func (nm *Manager) Snapshot() ([]config.Node, string) {
+	nm.mu.RLock()
+	defer nm.mu.RUnlock()
+
+	nodes := slices.Clone(config.C().Nodes.List)
+	return nodes, listVersion(nodes)
+}
+
+// Version 获取节点列表的版本号
+func (nm *Manager) Version() string {
+	_, version := nm.Snapshot()
+	return version
+}
+
+// listVersion 根据节点列表内容计算版本号, 配置重载或手动修改配置文件后版本号同样会变化
+func listVersion(nodes []config.Node) string {
+	h := sha256.New()
+	for _, n := range nodes {
+		fmt.Fprintf(h, "%s\x00%s\x00%d\x00%t\n", n.Name, n.Host, n.Weight, n.Enabled)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// update 校验版本号后在配置副本上修改节点, 保存到配置文件成功后替换当前配置并重新加载节点, 调用方需持有写锁
+//
+// fn 返回本次修改的记录, fn 返回的错误原样返回; 保存失败时返回 ErrSaveConfig, 当前配置保持不变
+func (nm *Manager) update(ctx context.Context, version *string, fn func(nodes *config.Nodes) (config.Change, error)) error {
+	var fnErr error
+	c, err := config.Update(func(c *config.Config) (config.Change, error) {
+		if version != nil && *version != "" && *version != listVersion(c.Nodes.List) {
+			fnErr = ErrVersionConflict
+			return config.Change{}, fnErr
+		}
+		var ch config.Change
+		ch, fnErr = fn(c.Nodes)
+		return ch, fnErr
+	})
+	if fnErr != nil {
+		return fnErr
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSaveConfig, err)
+	}
+
+	// 通知健康检查器重新加载节点
+	nm.healthChecker.ReloadNodes(ctx)
+	if version != nil {
+		*version = listVersion(c.Nodes.List)
+	}
+	return nil
+}
+
+// AddNode 添加节点（支持自动命名）, 返回添加的节点
+//
+// actor 为记录到配置历史中的修改人, version 为期望的节点列表版本号
+func (nm *Manager) AddNode(ctx context.Context, actor string, version *string, newNode config.Node) (config.Node, error) {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+
+	if strings.TrimSpace(newNode.Host) == "" {
+		return newNode, errors.New("节点地址不能为空")
+	}
+	if newNode.Weight < 1 || newNode.Weight > 100 {
+		return newNode, errors.New("权重必须在 1-100 之间")
+	}
+
+	// 如果没有提供名称，自动生成
+	if newNode.Name == "" {
+		newNode.Name = nm.generateNodeName(newNode.Host)
+	}
+
+	err := nm.update(ctx, version, func(nodes *config.Nodes) (config.Change, error) {
+		// 检查节点是否已存在
+		for _, node := range nodes.List {
+			if node.Name == newNode.Name {
+				return config.Change{}, fmt.Errorf("%w: %s", ErrNodeExists, newNode.Name)
+			}
+		}
+
+		// 添加到配置
+		nodes.List = append(nodes.List, newNode)
+		return config.Change{Actor: actor, Action: "添加节点 " + newNode.Name}, nil
+	})
+	if err != nil {
+		return newNode, err
+	}
+
+	logs.Ctx(ctx).Info("[节点管理] %s 添加节点: %s (%s)", actor, newNode.Name, newNode.Host)
+	return newNode, nil
+}
+
+// generateNodeName 自动生成节点名称
+// 直接使用IP地址作为节点名称
+// 例如: http://8.138.199.183:46621 -> 8.138.199.183
+func (nm *Manager) generateNodeName(host string) string {
+	// 直接使用IP地址作为节点名称
+	// 例如: http://8.138.199.183:46621 -> 8.138.199.183
+	u, err := url.Parse(host)
+	if err != nil {
+		// 解析失败，尝试直接提取IP（例如: 8.138.199.183:46621 -> 8.138.199.183）
+		if strings.Contains(host, ":") {
+			parts := strings.Split(host, ":")
+			return parts[0]
+		}
+		// 如果都失败，使用host本身
+		return host
+	}
+
+	// 提取hostname（IP地址）
+	hostname := u.Hostname()
+	if hostname == "" {
+		// 如果解析失败，尝试从Host字段获取
+		if u.Host != "" {
+			if strings.Contains(u.Host, ":") {
+				parts := strings.Split(u.Host, ":")
+				return parts[0]
+			}
+			return u.Host
+		}
+		// 最后使用MD5作为fallback
+		hash := md5.Sum([]byte(host))
+		return hex.EncodeToString(hash[:])[:8]
+	}
+
+	return hostname
+}
+
+// extractHostID 从 host URL 中提取简短标识
+func (nm *Manager) extractHostID(host string) string {
+	u, err := url.Parse(host)
+	if err != nil {
+		// 解析失败，使用MD5哈希的前6位
+		hash := md5.Sum([]byte(host))
+		return hex.EncodeToString(hash[:])[:6]
+	}
+
+	hostname := u.Hostname()
+	if hostname == "" {
+		hash := md5.Sum([]byte(host))
+		return hex.EncodeToString(hash[:])[:6]
+	}
+
+	// 如果是IP地址，去掉点号
+	// 例如: 192.168.1.1 -> 192168
+	if len(hostname) > 0 && (hostname[0] >= '0' && hostname[0] <= '9') {
+		// 可能是IP，取前两段
+		parts := []byte(hostname)
+		result := make([]byte, 0, 10)
+		dotCount := 0
+		for _, b := range parts {
+			if b == '.' {
+				dotCount++
+				if dotCount >= 2 {
+					break
+				}
+				continue
+			}
+			result = append(result, b)
+		}
+		if len(result) > 0 {
+			return string(result)
+		}
+	}
+
+	// 如果是域名，取主域名部分
+	// 例如: cdn.example.com -> example
+	parts := []byte(hostname)
+	lastDot := -1
+	secondLastDot := -1
+	for i := len(parts) - 1; i >= 0; i-- {
+		if parts[i] == '.' {
+			if lastDot == -1 {
+				lastDot = i
+			} else if secondLastDot == -1 {
+				secondLastDot = i
+				break
+			}
+		}
+	}
+
+	if secondLastDot != -1 && lastDot != -1 {
+		return string(parts[secondLastDot+1 : lastDot])
+	} else if lastDot != -1 {
+		return string(parts[:lastDot])
+	}
+
+	// 降级：使用哈希
+	hash := md5.Sum([]byte(hostname))
+	return hex.EncodeToString(hash[:])[:6]
+}
+
+// DeleteNode 删除节点
+func (nm *Manager) DeleteNode(ctx context.Context, actor string, version *string, name string) error {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+
+	err := nm.update(ctx, version, func(nodes *config.Nodes) (config.Change, error) {
+		// 查找并删除节点
+		found := false
+		newList := make([]config.Node, 0, len(nodes.List))
+
+		for _, node := range nodes.List {
+			if node.Name == name {
+				found = true
+				continue
+			}
+			newList = append(newList, node)
+		}
+
+		if !found {
+			return config.Change{}, fmt.Errorf("%w: %s", ErrNodeNotFound, name)
+		}
+
+		nodes.List = newList
+		return config.Change{Actor: actor, Action: "删除节点 " + name}, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	logs.Ctx(ctx).Info("[节点管理] %s 删除节点: %s", actor, name)
+	return nil
+}
+
+// EnableNode 启用/禁用节点
+func (nm *Manager) EnableNode(ctx context.Context, actor string, version *string, name string, enable bool) error {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+
+	status := "禁用"
+	if enable {
+		status = "启用"
+	}
+
+	err := nm.update(ctx, version, func(nodes *config.Nodes) (config.Change, error) {
+		// 查找节点
+		idx := slices.IndexFunc(nodes.List, func(n config.Node) bool { return n.Name == name })
+		if idx < 0 {
+			return config.Change{}, fmt.Errorf("%w: %s", ErrNodeNotFound, name)
+		}
+		nodes.List[idx].Enabled = enable
+		return config.Change{Actor: actor, Action: status + "节点 " + name}, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	logs.Ctx(ctx).Info("[节点管理] %s %s节点: %s", actor, status, name)
+
+	return nil
+}
+
+// DrainNode 排空/恢复节点, 排空的节点不再分配新请求, 已有的播放不受影响
+//
+// 排空状态不写入配置文件, 不影响节点列表的版本号, 只能排空已启用的节点
+func (nm *Manager) DrainNode(ctx context.Context, actor string, name string, drain bool) error {
+	nm.mu.RLock()
+	defer nm.mu.RUnlock()
+
+	if !nm.healthChecker.SetDraining(ctx, name, drain) {
+		for _, n := range config.C().Nodes.List {
+			if n.Name == name {
+				return fmt.Errorf("节点 %s 已禁用, 无需排空", name)
+			}
+		}
+		return fmt.Errorf("%w: %s", ErrNodeNotFound, name)
+	}
+
+	status := "恢复"
+	if drain {
+		status = "排空"
+	}
+	logs.Ctx(ctx).Info("[节点管理] %s %s节点: %s", actor, status, name)
+	return nil
+}
+
+// BatchAddNodes 批量添加节点
+// hosts: 节点主机列表（可选包含权重，格式：host 或 host:weight）
+// 返回：成功数量、失败的节点列表（主机名）、错误
+func (nm *Manager) BatchAddNodes(ctx context.Context, actor string, version *string, hosts []string) (int, []string, error) {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+
+	successCount := 0
+	failedHosts := make([]string, 0)
+
+	// 收集所有新节点
+	nodesToAdd := make([]config.Node, 0, len(hosts))
+
+	err := nm.update(ctx, version, func(nodes *config.Nodes) (config.Change, error) {
+		for _, hostStr := range hosts {
+			// 解析主机和权重
+			host, weight := nm.parseHostWeight(hostStr)
+
+			// 生成节点名称
+			name := nm.generateNodeName(host)
+
+			// 检查节点是否已存在
+			exists := false
+			for _, node := range nodes.List {
+				if node.Name == name || node.Host == host {
+					exists = true
+					break
+				}
+			}
+
+			if exists {
+				logs.Ctx(ctx).Warn("[节点管理] 节点 %s 已存在，跳过", host)
+				failedHosts = append(failedHosts, host)
+				continue
+			}
+
+			nodesToAdd = append(nodesToAdd, config.Node{
+				Name:    name,
+				Host:    host,
+				Weight:  weight,
+				Enabled: true,
+			})
+		}
+
+		if len(nodesToAdd) == 0 {
+			return config.Change{}, fmt.Errorf("没有可添加的新节点")
+		}
+
+		// 批量添加到配置
+		nodes.List = append(nodes.List, nodesToAdd...)
+
+		addedNames := make([]string, len(nodesToAdd))
+		for i, node := range nodesToAdd {
+			addedNames[i] = node.Name
+		}
+		return config.Change{Actor: actor, Action: "批量添加节点 " + strings.Join(addedNames, ", ")}, nil
+	})
+	if err != nil {
+		if errors.Is(err, ErrVersionConflict) {
+			return 0, nil, err
+		}
+		return 0, failedHosts, err
+	}
+
+	successCount = len(nodesToAdd)
+	logs.Ctx(ctx).Info("[节点管理] %s 批量添加 %d 个节点成功", actor, successCount)
+
+	return successCount, failedHosts, nil
+}
+
+// BatchDeleteNodes 批量删除节点
+// names: 节点名称列表
+// 返回：成功数量、失败的节点列表、错误
+func (nm *Manager) BatchDeleteNodes(ctx context.Context, actor string, version *string, names []string) (int, []string, error) {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+
+	deletedCount := 0
+	failedNames := make([]string, 0)
+
+	err := nm.update(ctx, version, func(nodes *config.Nodes) (config.Change, error) {
+		newList := make([]config.Node, 0, len(nodes.List))
+
+		// 创建删除集合
+		toDelete := make(map[string]bool)
+		for _, name := range names {
+			toDelete[name] = true
+		}
+
+		// 过滤要删除的节点
+		deletedNames := make([]string, 0, len(names))
+		for _, node := range nodes.List {
+			if toDelete[node.Name] {
+				deletedCount++
+				deletedNames = append(deletedNames, node.Name)
+				delete(toDelete, node.Name)
+			} else {
+				newList = append(newList, node)
+			}
+		}
+
+		// 记录不存在的节点
+		for name := range toDelete {
+			failedNames = append(failedNames, name)
+		}
+
+		if deletedCount == 0 {
+			return config.Change{}, fmt.Errorf("没有删除任何节点")
+		}
+
+		nodes.List = newList
+		return config.Change{Actor: actor, Action: "批量删除节点 " + strings.Join(deletedNames, ", ")}, nil
+	})
+	if err != nil {
+		if errors.Is(err, ErrVersionConflict) {
+			return 0, nil, err
+		}
+		return 0, failedNames, err
+	}
+
+	logs.Ctx(ctx).Info("[节点管理] %s 批量删除 %d 个节点成功", actor, deletedCount)
+
+	return deletedCount, failedNames, nil
+}
+
+// parseHostWeight 解析主机和权重
+// 格式：host 或 host:weight
+// 例如：http://1.2.3.4:80 或 http://1.2.3.4:80:100
+func (nm *Manager) parseHostWeight(hostStr string) (string, int) {
+	// 默认权重
+	weight := 100
+
+	// 尝试从最后一个冒号分割权重
+	// 但要注意 http://host:port 的情况
+	lastColon := -1
+	colonCount := 0
+	for i := len(hostStr) - 1; i >= 0; i-- {
+		if hostStr[i] == ':' {
+			colonCount++
+			if colonCount == 1 {
+				lastColon = i
+			}
+			// http://host:port 格式最多2个冒号
+			// http://host:port:weight 格式最多3个冒号
+			if colonCount >= 3 {
+				break
+			}
+		}
+	}
+
+	// 如果有3个冒号，最后一个可能是权重
+	if colonCount >= 3 && lastColon > 0 {
+		weightStr := hostStr[lastColon+1:]
+		if w, err := strconv.Atoi(weightStr); err == nil && w >= 1 && w <= 100 {
+			weight = w
+			hostStr = hostStr[:lastColon]
+		}
+	}
+
+	return hostStr, weight
+}
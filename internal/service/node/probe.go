@@ -0,0 +1,72 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/config"
+)
+
+// defaultProbeTimeout 未配置超时时间时单次探测的超时时间
+const defaultProbeTimeout = 5 * time.Second
+
+// ProbeResult 单次探测结果
+type ProbeResult struct {
+	Name    string
+	Host    string
+	URL     string        // 健康检查地址
+	Enabled bool          // 节点是否启用
+	Status  int           // 响应状态码, 请求失败时为 0
+	Latency time.Duration // 请求耗时
+	Err     error         // 请求失败原因
+}
+
+// Healthy 节点是否健康
+func (r ProbeResult) Healthy() bool {
+	return r.Err == nil && r.Status == http.StatusOK
+}
+
+// ProbeAll 并发探测配置中的所有节点 (包括已禁用的节点) 一次,
+// 不影响健康检查器中的节点状态, 结果按配置顺序返回
+func ProbeAll(cfg *config.Nodes) []ProbeResult {
+	timeout := time.Duration(cfg.HealthCheck.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = defaultProbeTimeout
+	}
+	client := &http.Client{
+		Timeout: timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	results := make([]ProbeResult, len(cfg.List))
+	var wg sync.WaitGroup
+	for i, n := range cfg.List {
+		wg.Add(1)
+		go func(i int, n config.Node) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			res := ProbeResult{
+				Name:    n.Name,
+				Host:    n.Host,
+				URL:     buildHealthCheckURL(n.Host),
+				Enabled: n.Enabled,
+			}
+			start := time.Now()
+			res.Status, res.Err = probe(ctx, client, n.Host)
+			res.Latency = time.Since(start)
+			if res.Err == nil && res.Status != http.StatusOK {
+				res.Err = fmt.Errorf("返回非200: %d", res.Status)
+			}
+			results[i] = res
+		}(i, n)
+	}
+	wg.Wait()
+	return results
+}
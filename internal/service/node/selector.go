@@ -24,7 +24,7 @@ func NewSelector(checker *HealthChecker) *Selector {
 // SelectNode 选择最优节点
 // 策略: 加权随机
 func (s *Selector) SelectNode() *NodeStatus {
-	nodes := s.checker.GetHealthyNodes()
+	nodes := s.checker.GetSchedulableNodes()
 	if len(nodes) == 0 {
 		return nil
 	}
@@ -54,7 +54,7 @@ func (s *Selector) SelectNode() *NodeStatus {
 
 // SelectNodeRoundRobin 轮询选择节点
 func (s *Selector) SelectNodeRoundRobin() *NodeStatus {
-	nodes := s.checker.GetHealthyNodes()
+	nodes := s.checker.GetSchedulableNodes()
 	if len(nodes) == 0 {
 		return nil
 	}
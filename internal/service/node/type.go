@@ -12,12 +12,21 @@ type NodeStatus struct {
 	Weight           int
 	Enabled          bool // 是否启用
 	Healthy          bool
+	Draining         bool // 是否正在排空, 排空的节点不再分配新请求, 已有的播放不受影响
 	LastCheck        time.Time
 	ConsecutiveFails int
 	ConsecutiveSucc  int
+	history          []CheckResult // 最近的健康检查结果, 按时间顺序, 最多保留 historySize 条
 	mu               sync.RWMutex
 }
 
+// CheckResult 单次健康检查结果
+type CheckResult struct {
+	Time    time.Time `json:"time"`
+	Ok      bool      `json:"ok"`
+	Latency int64     `json:"latency_ms"` // 耗时 (毫秒)
+}
+
 // IsHealthy 线程安全地获取节点健康状态
 func (ns *NodeStatus) IsHealthy() bool {
 	ns.mu.RLock()
@@ -52,3 +61,50 @@ func (ns *NodeStatus) IsEnabled() bool {
 	defer ns.mu.RUnlock()
 	return ns.Enabled
 }
+
+// IsDraining 线程安全地获取节点排空状态
+func (ns *NodeStatus) IsDraining() bool {
+	ns.mu.RLock()
+	defer ns.mu.RUnlock()
+	return ns.Draining
+}
+
+// record 记录一次健康检查结果, 超出 historySize 时丢弃最早的结果
+func (ns *NodeStatus) record(ok bool, latency time.Duration) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	if len(ns.history) >= historySize {
+		ns.history = append(ns.history[:0], ns.history[len(ns.history)-historySize+1:]...)
+	}
+	ns.history = append(ns.history, CheckResult{Time: time.Now(), Ok: ok, Latency: latency.Milliseconds()})
+}
+
+// NodeState 节点及其实时健康状态, 用于管理接口展示
+type NodeState struct {
+	Name             string        `json:"name"`
+	Host             string        `json:"host"`
+	Weight           int           `json:"weight"`
+	Enabled          bool          `json:"enabled"`
+	Healthy          bool          `json:"healthy"`
+	Draining         bool          `json:"draining"`
+	LastCheck        time.Time     `json:"last_check,omitzero"` // 最近一次健康检查时间, 尚未检查时为空
+	ConsecutiveFails int           `json:"consecutive_fails"`
+	History          []CheckResult `json:"history,omitempty"` // 最近的健康检查结果, 按时间顺序
+}
+
+// State 线程安全地获取节点状态快照
+func (ns *NodeStatus) State() NodeState {
+	ns.mu.RLock()
+	defer ns.mu.RUnlock()
+	return NodeState{
+		Name:             ns.Name,
+		Host:             ns.Host,
+		Weight:           ns.Weight,
+		Enabled:          ns.Enabled,
+		Healthy:          ns.Healthy,
+		Draining:         ns.Draining,
+		LastCheck:        ns.LastCheck,
+		ConsecutiveFails: ns.ConsecutiveFails,
+		History:          append([]CheckResult(nil), ns.history...),
+	}
+}
@@ -1,6 +1,7 @@
 package telegram
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"time"
@@ -16,16 +17,18 @@ import (
 type Bot struct {
 	api           *tgbotapi.BotAPI
 	healthChecker *node.HealthChecker
-	nodeManager   *NodeManager
+	nodeManager   *node.Manager
+	stopCh        chan struct{}
+	doneCh        chan struct{}
 }
 
-// NewBot 创建 Telegram Bot
-func NewBot(healthChecker *node.HealthChecker) (*Bot, error) {
-	if !config.C.Telegram.Enable {
+// NewBot 创建 Telegram Bot, 节点管理器与管理接口共用
+func NewBot(healthChecker *node.HealthChecker, nodeManager *node.Manager) (*Bot, error) {
+	if !config.C().Telegram.Enable {
 		return nil, fmt.Errorf("Telegram Bot 未启用")
 	}
 
-	api, err := tgbotapi.NewBotAPI(config.C.Telegram.BotToken)
+	api, err := tgbotapi.NewBotAPI(config.C().Telegram.BotToken)
 	if err != nil {
 		return nil, fmt.Errorf("创建 Telegram Bot 失败: %v", err)
 	}
@@ -35,14 +38,18 @@ func NewBot(healthChecker *node.HealthChecker) (*Bot, error) {
 	bot := &Bot{
 		api:           api,
 		healthChecker: healthChecker,
-		nodeManager:   NewNodeManager(healthChecker),
+		nodeManager:   nodeManager,
+		stopCh:        make(chan struct{}),
+		doneCh:        make(chan struct{}),
 	}
 
 	return bot, nil
 }
 
-// Start 启动机器人
+// Start 启动机器人, 调用 Stop 后返回
 func (b *Bot) Start() {
+	defer close(b.doneCh)
+
 	u := tgbotapi.NewUpdate(0)
 	u.Timeout = 60
 
@@ -50,7 +57,13 @@ func (b *Bot) Start() {
 
 	logs.Info("[Telegram] 开始监听消息...")
 
-	for update := range updates {
+	for {
+		var update tgbotapi.Update
+		select {
+		case update = <-updates:
+		case <-b.stopCh:
+			return
+		}
 		if update.Message == nil {
 			continue
 		}
@@ -66,9 +79,19 @@ func (b *Bot) Start() {
 	}
 }
 
+// Stop 停止监听消息, 等待正在处理的命令完成
+//
+// 正在进行的长轮询请求在后台结束, 不等待其返回
+func (b *Bot) Stop() {
+	b.api.StopReceivingUpdates()
+	close(b.stopCh)
+	<-b.doneCh
+	logs.Info("[Telegram] Bot 已停止")
+}
+
 // isAdmin 检查用户是否是管理员
 func (b *Bot) isAdmin(userID int64) bool {
-	for _, adminID := range config.C.Telegram.AdminUserID {
+	for _, adminID := range config.C().Telegram.AdminUserID {
 		if adminID == userID {
 			return true
 		}
@@ -80,6 +103,8 @@ func (b *Bot) isAdmin(userID int64) bool {
 func (b *Bot) handleCommand(message *tgbotapi.Message) {
 	command := message.Command()
 	args := strings.Fields(message.CommandArguments())
+	// 修改人记录到配置历史中
+	actor := fmt.Sprintf("telegram:%d", message.From.ID)
 
 	switch command {
 	case "start", "help":
@@ -87,19 +112,29 @@ func (b *Bot) handleCommand(message *tgbotapi.Message) {
 	case "list":
 		b.handleList(message.Chat.ID)
 	case "add":
-		b.handleAdd(message.Chat.ID, args)
+		b.handleAdd(message.Chat.ID, actor, args)
 	case "del", "delete":
-		b.handleDelete(message.Chat.ID, args)
+		b.handleDelete(message.Chat.ID, actor, args)
 	case "batchadd":
-		b.handleBatchAdd(message.Chat.ID, args)
+		b.handleBatchAdd(message.Chat.ID, actor, args)
 	case "batchdel", "batchdelete":
-		b.handleBatchDelete(message.Chat.ID, args)
+		b.handleBatchDelete(message.Chat.ID, actor, args)
 	case "enable":
-		b.handleEnable(message.Chat.ID, args)
+		b.handleEnable(message.Chat.ID, actor, args)
 	case "disable":
-		b.handleDisable(message.Chat.ID, args)
+		b.handleDisable(message.Chat.ID, actor, args)
 	case "status":
 		b.handleStatus(message.Chat.ID)
+	case "cache":
+		b.handleCache(message.Chat.ID, args)
+	case "purge":
+		b.handlePurge(message.Chat.ID, args)
+	case "reload":
+		b.handleReload(message.Chat.ID)
+	case "history":
+		b.handleHistory(message.Chat.ID, args)
+	case "rollback":
+		b.handleRollback(message.Chat.ID, actor, args)
 	default:
 		b.reply(message.Chat.ID, "❓ 未知命令，请使用 /help 查看帮助")
 	}
@@ -129,6 +164,22 @@ func (b *Bot) handleHelp(chatID int64) {
 • /batchdel <name1> <name2> ... - 批量删除节点
   例如: /batchdel node1 node2
 
+*缓存管理：*
+• /cache - 查看缓存统计及缓存空间
+• /cache <key> - 查看单个缓存详情
+• /purge item <itemId> - 清除项目的所有缓存
+• /purge space <space> [spaceKey] - 按缓存空间清除
+• /purge node <name> - 清除指向节点的重定向缓存
+• /purge key <key> - 清除单个缓存
+• /purge uri <正则> - 按请求地址清除
+• /purge all - 清除全部缓存
+
+*配置管理：*
+• /reload - 重新读取配置文件并应用
+• /history - 查看最近的配置修改记录
+• /history <n> - 查看第 n 次修改的详细差异
+• /rollback <n> - 撤销第 n 次及之后的修改
+
 💡 *提示：*
 - 节点会自动命名（格式：node-{IP简写}-{序号}）
 - 节点必须支持健康检查接口 (GET /gtm-health)
@@ -167,7 +218,7 @@ func (b *Bot) handleList(chatID int64) {
 }
 
 // handleAdd 添加节点（支持自动命名）
-func (b *Bot) handleAdd(chatID int64, args []string) {
+func (b *Bot) handleAdd(chatID int64, actor string, args []string) {
 	if len(args) < 1 {
 		b.reply(chatID, "❌ 参数错误\n用法: /add <host> [weight]\n例如: /add http://1.2.3.4:80\n或: /add http://1.2.3.4:80 100")
 		return
@@ -195,26 +246,17 @@ func (b *Bot) handleAdd(chatID int64, args []string) {
 		Enabled: true,
 	}
 
-	if err := b.nodeManager.AddNode(newNode); err != nil {
+	added, err := b.nodeManager.AddNode(context.Background(), actor, nil, newNode)
+	if err != nil {
 		b.reply(chatID, fmt.Sprintf("❌ 添加节点失败: %v", err))
 		return
 	}
 
-	// 获取生成的节点名称
-	nodes := b.nodeManager.ListNodes()
-	var addedName string
-	for _, node := range nodes {
-		if node.Host == host {
-			addedName = node.Name
-			break
-		}
-	}
-
-	b.reply(chatID, fmt.Sprintf("✅ 节点添加成功\n• 名称: %s\n• 主机: %s\n• 权重: %d\n正在进行健康检查...", addedName, host, weight))
+	b.reply(chatID, fmt.Sprintf("✅ 节点添加成功\n• 名称: %s\n• 主机: %s\n• 权重: %d\n正在进行健康检查...", added.Name, host, weight))
 }
 
 // handleDelete 删除节点
-func (b *Bot) handleDelete(chatID int64, args []string) {
+func (b *Bot) handleDelete(chatID int64, actor string, args []string) {
 	if len(args) < 1 {
 		b.reply(chatID, "❌ 参数错误\n用法: /del <name>")
 		return
@@ -222,7 +264,7 @@ func (b *Bot) handleDelete(chatID int64, args []string) {
 
 	name := args[0]
 
-	if err := b.nodeManager.DeleteNode(name); err != nil {
+	if err := b.nodeManager.DeleteNode(context.Background(), actor, nil, name); err != nil {
 		b.reply(chatID, fmt.Sprintf("❌ 删除节点失败: %v", err))
 		return
 	}
@@ -231,7 +273,7 @@ func (b *Bot) handleDelete(chatID int64, args []string) {
 }
 
 // handleEnable 启用节点
-func (b *Bot) handleEnable(chatID int64, args []string) {
+func (b *Bot) handleEnable(chatID int64, actor string, args []string) {
 	if len(args) < 1 {
 		b.reply(chatID, "❌ 参数错误\n用法: /enable <name>")
 		return
@@ -239,7 +281,7 @@ func (b *Bot) handleEnable(chatID int64, args []string) {
 
 	name := args[0]
 
-	if err := b.nodeManager.EnableNode(name, true); err != nil {
+	if err := b.nodeManager.EnableNode(context.Background(), actor, nil, name, true); err != nil {
 		b.reply(chatID, fmt.Sprintf("❌ 启用节点失败: %v", err))
 		return
 	}
@@ -248,7 +290,7 @@ func (b *Bot) handleEnable(chatID int64, args []string) {
 }
 
 // handleDisable 禁用节点
-func (b *Bot) handleDisable(chatID int64, args []string) {
+func (b *Bot) handleDisable(chatID int64, actor string, args []string) {
 	if len(args) < 1 {
 		b.reply(chatID, "❌ 参数错误\n用法: /disable <name>")
 		return
@@ -256,7 +298,7 @@ func (b *Bot) handleDisable(chatID int64, args []string) {
 
 	name := args[0]
 
-	if err := b.nodeManager.EnableNode(name, false); err != nil {
+	if err := b.nodeManager.EnableNode(context.Background(), actor, nil, name, false); err != nil {
 		b.reply(chatID, fmt.Sprintf("❌ 禁用节点失败: %v", err))
 		return
 	}
@@ -307,13 +349,13 @@ func (b *Bot) handleStatus(chatID int64) {
 }
 
 // handleBatchAdd 批量添加节点
-func (b *Bot) handleBatchAdd(chatID int64, args []string) {
+func (b *Bot) handleBatchAdd(chatID int64, actor string, args []string) {
 	if len(args) < 1 {
 		b.reply(chatID, "❌ 参数错误\n用法: /batchadd <host1> <host2> ...\n例如: /batchadd http://1.2.3.4:80 http://5.6.7.8:80:50")
 		return
 	}
 
-	successCount, failedHosts, err := b.nodeManager.BatchAddNodes(args)
+	successCount, failedHosts, err := b.nodeManager.BatchAddNodes(context.Background(), actor, nil, args)
 
 	var sb strings.Builder
 	if successCount > 0 {
@@ -337,13 +379,13 @@ func (b *Bot) handleBatchAdd(chatID int64, args []string) {
 }
 
 // handleBatchDelete 批量删除节点
-func (b *Bot) handleBatchDelete(chatID int64, args []string) {
+func (b *Bot) handleBatchDelete(chatID int64, actor string, args []string) {
 	if len(args) < 1 {
 		b.reply(chatID, "❌ 参数错误\n用法: /batchdel <name1> <name2> ...\n例如: /batchdel 8.138.199.183 47.92.114.104")
 		return
 	}
 
-	deletedCount, failedNames, err := b.nodeManager.BatchDeleteNodes(args)
+	deletedCount, failedNames, err := b.nodeManager.BatchDeleteNodes(context.Background(), actor, nil, args)
 
 	var sb strings.Builder
 	if deletedCount > 0 {
@@ -0,0 +1,117 @@
+package telegram
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/web/cache"
+)
+
+// purgeUsage 清除缓存命令用法
+const purgeUsage = `❌ 参数错误
+用法:
+/purge item <itemId> - 清除某个项目的 PlaybackInfo、串流、字幕缓存
+/purge space <space> [spaceKey] - 按缓存空间清除
+/purge node <节点名称> - 清除指向某个节点的重定向缓存
+/purge key <cacheKey> - 清除单个缓存
+/purge uri <正则> - 按请求地址正则清除
+/purge all - 清除全部缓存`
+
+// handleCache 查看缓存统计, 指定 cacheKey 时查看单个缓存
+func (b *Bot) handleCache(chatID int64, args []string) {
+	if len(args) > 0 {
+		e, ok := cache.GetEntry(args[0])
+		if !ok {
+			b.reply(chatID, "❌ 缓存不存在")
+			return
+		}
+		b.reply(chatID, fmt.Sprintf(
+			"🗂 缓存详情\n• Key: %s\n• 地址: %s\n• 响应码: %d\n• 空间: %s / %s\n• 大小: %d B\n• 缓存时间: %s\n• 过期时间: %s\n• 内存: %v, 磁盘: %v%s",
+			e.Key, e.URI, e.Code, e.Space, e.SpaceKey, e.Size,
+			e.Created.Format("2006-01-02 15:04:05"), e.Expired.Format("2006-01-02 15:04:05"),
+			e.InMemory, e.OnDisk, locationLine(e.Location),
+		))
+		return
+	}
+
+	st := cache.GetStats()
+	var sb strings.Builder
+	sb.WriteString("🗂 缓存统计\n\n")
+	sb.WriteString(fmt.Sprintf("• 缓存数: %d / %d\n", st.Entries, st.MaxEntries))
+	sb.WriteString(fmt.Sprintf("• 大小: %.2f / %.2f MB\n", float64(st.Bytes)/1024/1024, float64(st.MaxBytes)/1024/1024))
+	sb.WriteString(fmt.Sprintf("• 命中率: %.2f%% (命中 %d, 未命中 %d)\n", st.HitRatio*100, st.Hits, st.Misses))
+	if st.Disk != nil {
+		sb.WriteString(fmt.Sprintf("• 磁盘缓存: %d 个, %.2f MB\n", st.Disk.Entries, float64(st.Disk.Bytes)/1024/1024))
+	}
+
+	sb.WriteString("\n📂 缓存空间\n")
+	for _, s := range cache.ListSpaces() {
+		sb.WriteString(fmt.Sprintf("• %s: 内存 %d, 磁盘 %d, 命中 %d\n", s.Name, s.Entries, s.DiskEntries, s.Hits))
+	}
+	b.reply(chatID, sb.String())
+}
+
+// locationLine 重定向地址展示
+func locationLine(location string) string {
+	if location == "" {
+		return ""
+	}
+	return "\n• 重定向: " + location
+}
+
+// handlePurge 清除缓存
+func (b *Bot) handlePurge(chatID int64, args []string) {
+	if len(args) < 1 {
+		b.reply(chatID, purgeUsage)
+		return
+	}
+
+	q := url.Values{}
+	switch strings.ToLower(args[0]) {
+	case "all":
+	case "item":
+		if len(args) < 2 {
+			b.reply(chatID, purgeUsage)
+			return
+		}
+		q.Set("item_id", args[1])
+	case "space":
+		if len(args) < 2 {
+			b.reply(chatID, purgeUsage)
+			return
+		}
+		q.Set("space", args[1])
+		if len(args) >= 3 {
+			q.Set("space_key", args[2])
+		}
+	case "node":
+		if len(args) < 2 {
+			b.reply(chatID, purgeUsage)
+			return
+		}
+		q.Set("node", args[1])
+	case "key":
+		if len(args) < 2 {
+			b.reply(chatID, purgeUsage)
+			return
+		}
+		q.Set("key", args[1])
+	case "uri":
+		if len(args) < 2 {
+			b.reply(chatID, purgeUsage)
+			return
+		}
+		q.Set("uri", strings.Join(args[1:], " "))
+	default:
+		b.reply(chatID, purgeUsage)
+		return
+	}
+
+	f, err := cache.ParseFilter(q)
+	if err != nil {
+		b.reply(chatID, fmt.Sprintf("❌ %v", err))
+		return
+	}
+	b.reply(chatID, fmt.Sprintf("✅ 已清除 %d 个缓存", cache.Purge(f)))
+}
@@ -0,0 +1,113 @@
+package telegram
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/config"
+)
+
+// handleReload 重新读取配置文件并应用, 配置有误时保持当前配置
+func (b *Bot) handleReload(chatID int64) {
+	e, err := config.Reload()
+	if err != nil {
+		b.reply(chatID, fmt.Sprintf("❌ 配置重载失败, 继续使用当前配置\n%v", err))
+		return
+	}
+	b.reply(chatID, "✅ 配置已重载\n"+reloadSummary(e))
+}
+
+// historyListSize /history 最多展示的记录数
+const historyListSize = 10
+
+// diffMaxLen 单条消息中展示的差异最大长度, Telegram 消息上限为 4096 字符
+const diffMaxLen = 3000
+
+// handleHistory 查看配置修改历史, 指定编号时查看该次修改的差异
+func (b *Bot) handleHistory(chatID int64, args []string) {
+	if len(args) > 0 {
+		id, err := strconv.Atoi(strings.TrimPrefix(args[0], "#"))
+		if err != nil {
+			b.reply(chatID, "❌ 参数错误\n用法: /history <编号>")
+			return
+		}
+		e, _, err := config.GetHistory(id)
+		if err != nil {
+			b.reply(chatID, fmt.Sprintf("❌ %v", err))
+			return
+		}
+		diff := e.Diff
+		if len([]rune(diff)) > diffMaxLen {
+			diff = string([]rune(diff)[:diffMaxLen]) + "\n..."
+		}
+		file := ""
+		if e.File != "" {
+			file = "\n• 文件: " + e.File
+		}
+		b.reply(chatID, fmt.Sprintf("📝 #%d %s\n• 修改人: %s\n• 操作: %s%s\n\n%s\n\n使用 /rollback %d 撤销该次及之后的修改",
+			e.Id, e.Time.Format("2006-01-02 15:04:05"), e.Actor, e.Action, file, diff, e.Id))
+		return
+	}
+
+	list, err := config.ListHistory()
+	if err != nil {
+		b.reply(chatID, fmt.Sprintf("❌ %v", err))
+		return
+	}
+	if len(list) == 0 {
+		b.reply(chatID, "📭 暂无配置修改记录")
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString("📝 最近的配置修改\n\n")
+	for _, e := range list[:min(len(list), historyListSize)] {
+		action := e.Action
+		if e.File != "" {
+			action += " (" + e.File + ")"
+		}
+		sb.WriteString(fmt.Sprintf("#%d %s %s\n   %s\n", e.Id, e.Time.Format("01-02 15:04:05"), e.Actor, action))
+	}
+	sb.WriteString("\n使用 /history <编号> 查看差异, /rollback <编号> 撤销该次及之后的修改")
+	b.reply(chatID, sb.String())
+}
+
+// handleRollback 回滚到指定修改之前的配置并立即生效
+func (b *Bot) handleRollback(chatID int64, actor string, args []string) {
+	if len(args) < 1 {
+		b.reply(chatID, "❌ 参数错误\n用法: /rollback <编号>\n编号可通过 /history 查看")
+		return
+	}
+	id, err := strconv.Atoi(strings.TrimPrefix(args[0], "#"))
+	if err != nil {
+		b.reply(chatID, "❌ 编号格式错误")
+		return
+	}
+
+	e, err := config.Rollback(id, config.Change{Actor: actor})
+	if errors.Is(err, config.ErrHistoryNotFound) {
+		b.reply(chatID, fmt.Sprintf("❌ 配置修改记录 #%d 不存在", id))
+		return
+	}
+	if err != nil {
+		b.reply(chatID, fmt.Sprintf("❌ 回滚失败, 配置未修改\n%v", err))
+		return
+	}
+	b.reply(chatID, fmt.Sprintf("✅ 已撤销 #%d 及之后的修改\n%s", id, reloadSummary(e)))
+}
+
+// reloadSummary 配置重载结果摘要
+func reloadSummary(e *config.ReloadEvent) string {
+	var sb strings.Builder
+	if len(e.Changed) == 0 {
+		sb.WriteString("• 没有发生变更的配置项")
+	} else {
+		sb.WriteString("• 变更项: " + strings.Join(e.Changed, ", "))
+	}
+	if len(e.RestartRequired) > 0 {
+		sb.WriteString("\n⚠️ 以下配置需要重启程序才能生效: " + strings.Join(e.RestartRequired, ", "))
+	}
+	return sb.String()
+}
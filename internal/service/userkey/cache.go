@@ -55,6 +55,13 @@ func (c *Cache) Set(userId, apiKey string) {
 	}
 }
 
+// SetTTL 修改缓存时间, 已缓存的 Key 按原过期时间失效
+func (c *Cache) SetTTL(ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ttl = ttl
+}
+
 // Delete 删除缓存项
 func (c *Cache) Delete(userId string) {
 	c.mu.Lock()
@@ -62,6 +69,21 @@ func (c *Cache) Delete(userId string) {
 	delete(c.data, userId)
 }
 
+// Len 获取未过期的缓存项个数
+func (c *Cache) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	now := time.Now()
+	cnt := 0
+	for _, v := range c.data {
+		if now.Before(v.ExpiredAt) {
+			cnt++
+		}
+	}
+	return cnt
+}
+
 // GetOrFetch 获取或使用原始 Key
 func (c *Cache) GetOrFetch(userId, originalKey string) string {
 	// 尝试从缓存获取
@@ -0,0 +1,333 @@
+package userkey
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/config"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/https"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/logs"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UserGinKey 解析成功的用户身份存放在 gin 上下文中的 key
+const UserGinKey = "embyUser"
+
+// ErrInvalidToken 令牌被 Emby 拒绝
+var ErrInvalidToken = errors.New("令牌无效或已过期")
+
+// User Emby 用户身份及权限策略
+type User struct {
+	Id               string   `json:"id"`
+	Name             string   `json:"name"`
+	IsAdministrator  bool     `json:"is_administrator"`
+	IsDisabled       bool     `json:"is_disabled"`
+	IsApiKey         bool     `json:"is_api_key"`         // 令牌是 Emby 后台签发的 API Key, 不归属具体用户
+	EnableAllFolders bool     `json:"enable_all_folders"` // 是否允许访问所有媒体库
+	EnabledFolders   []string `json:"enabled_folders"`    // 允许访问的媒体库 id 列表
+}
+
+// CanAccessFolder 判断用户是否可以访问指定的媒体库
+func (u *User) CanAccessFolder(folderId string) bool {
+	if u == nil || u.IsDisabled {
+		return false
+	}
+	if u.IsAdministrator || u.EnableAllFolders {
+		return true
+	}
+	for _, id := range u.EnabledFolders {
+		if id == folderId {
+			return true
+		}
+	}
+	return false
+}
+
+// cachedUser 缓存的用户身份
+type cachedUser struct {
+	user      *User
+	expiredAt time.Time
+}
+
+// resolveCall 正在向 Emby 校验的令牌
+type resolveCall struct {
+	done chan struct{}
+	user *User
+	err  error
+}
+
+// Resolver 用户身份解析器
+//
+// 将客户端令牌解析为 Emby 用户, 结果按 TTL 缓存,
+// 过期后重新向 Emby 校验, Emby 不可达时在宽限期内继续使用旧结果;
+// 同一令牌的并发校验会合并为一次请求
+type Resolver struct {
+	embyHost    string
+	adminApiKey string
+	ttl         time.Duration
+	grace       time.Duration
+	data        map[string]*cachedUser // key: 客户端令牌
+	mu          sync.RWMutex
+
+	flights   map[string]*resolveCall // 正在校验的令牌
+	flightsMu sync.Mutex
+}
+
+// NewResolver 创建用户身份解析器
+func NewResolver(cfg *config.Emby, ttl time.Duration) *Resolver {
+	r := &Resolver{
+		embyHost:    cfg.Host,
+		adminApiKey: cfg.AdminApiKey,
+		ttl:         ttl,
+		grace:       ttl * 6,
+		data:        make(map[string]*cachedUser),
+		flights:     make(map[string]*resolveCall),
+	}
+
+	// 启动定期清理过期缓存
+	go r.cleanupLoop()
+
+	return r
+}
+
+// Resolve 解析令牌对应的用户身份
+func (r *Resolver) Resolve(ctx context.Context, token string) (*User, error) {
+	if token == "" {
+		return nil, ErrInvalidToken
+	}
+
+	r.mu.RLock()
+	cached, ok := r.data[token]
+	r.mu.RUnlock()
+
+	if ok && time.Now().Before(cached.expiredAt) {
+		return cached.user, nil
+	}
+
+	// 缓存未命中或已过期, 重新校验
+	user, err := r.fetchOnce(ctx, token)
+	if errors.Is(err, ErrInvalidToken) {
+		r.Invalidate(token)
+		return nil, err
+	}
+	if err != nil {
+		r.mu.RLock()
+		grace := r.grace
+		r.mu.RUnlock()
+		if ok && time.Now().Before(cached.expiredAt.Add(grace)) {
+			logs.Ctx(ctx).Warn("重新校验用户身份失败, 继续使用缓存结果: %v", err)
+			return cached.user, nil
+		}
+		return nil, err
+	}
+
+	r.mu.Lock()
+	r.data[token] = &cachedUser{user: user, expiredAt: time.Now().Add(r.ttl)}
+	r.mu.Unlock()
+	return user, nil
+}
+
+// SetTTL 修改身份缓存时间, 已缓存的身份按原过期时间失效
+func (r *Resolver) SetTTL(ttl time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ttl, r.grace = ttl, ttl*6
+}
+
+// Cached 仅从缓存中获取未过期的用户身份, 不发起请求
+func (r *Resolver) Cached(token string) (*User, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	cached, ok := r.data[token]
+	if !ok || time.Now().After(cached.expiredAt) {
+		return nil, false
+	}
+	return cached.user, true
+}
+
+// Invalidate 删除令牌的缓存身份
+func (r *Resolver) Invalidate(token string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.data, token)
+}
+
+// InvalidateUser 删除某个用户所有令牌的缓存身份, 用于权限变更后立即生效
+func (r *Resolver) InvalidateUser(userId string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for token, cached := range r.data {
+		if cached.user.Id == userId {
+			delete(r.data, token)
+		}
+	}
+}
+
+// Middleware 解析请求令牌对应的用户并写入 gin 上下文
+//
+// 解析失败不会阻断请求, 是否放行由各自的鉴权逻辑决定
+func (r *Resolver) Middleware(extract func(c *gin.Context) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := extract(c)
+		if token == "" {
+			return
+		}
+		user, err := r.Resolve(c, token)
+		if err != nil {
+			if !errors.Is(err, ErrInvalidToken) {
+				logs.Ctx(c).Warn("解析用户身份失败: %v", err)
+			}
+			return
+		}
+		c.Set(UserGinKey, user)
+	}
+}
+
+// GetUser 从 gin 上下文中获取已解析的用户身份
+func GetUser(c *gin.Context) (*User, bool) {
+	if c == nil {
+		return nil, false
+	}
+	v, ok := c.Get(UserGinKey)
+	if !ok {
+		return nil, false
+	}
+	user, ok := v.(*User)
+	return user, ok && user != nil
+}
+
+// fetchOnce 合并同一令牌的并发校验, 只有首个请求向 Emby 发起查询, 其余请求等待并共用结果
+func (r *Resolver) fetchOnce(ctx context.Context, token string) (*User, error) {
+	r.flightsMu.Lock()
+	if call, ok := r.flights[token]; ok {
+		r.flightsMu.Unlock()
+		select {
+		case <-call.done:
+			return call.user, call.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	call := &resolveCall{done: make(chan struct{})}
+	r.flights[token] = call
+	r.flightsMu.Unlock()
+
+	call.user, call.err = r.fetch(token)
+
+	r.flightsMu.Lock()
+	delete(r.flights, token)
+	r.flightsMu.Unlock()
+	close(call.done)
+	return call.user, call.err
+}
+
+// fetch 向 Emby 查询令牌对应的用户
+//
+// 优先使用 /Users/Me, 令牌不归属具体用户时 (后台签发的 API Key),
+// 使用管理员 Key 查询 /Auth/Keys 进行匹配
+func (r *Resolver) fetch(token string) (*User, error) {
+	header := http.Header{"X-Emby-Token": []string{token}}
+	resp, err := https.Get(r.embyHost + "/emby/Users/Me").Header(header).Do()
+	if err != nil {
+		return nil, fmt.Errorf("请求 Emby 失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, ErrInvalidToken
+	}
+	if resp.StatusCode == http.StatusOK {
+		return decodeUser(resp.Body)
+	}
+
+	return r.fetchApiKey(token)
+}
+
+// fetchApiKey 使用管理员 Key 判断令牌是否是后台签发的 API Key
+func (r *Resolver) fetchApiKey(token string) (*User, error) {
+	if r.adminApiKey == "" {
+		return nil, errors.New("未配置 emby.admin-api-key, 无法识别 API Key 身份")
+	}
+	if token == r.adminApiKey {
+		return &User{Id: "apikey:admin", Name: "admin-api-key", IsAdministrator: true, IsApiKey: true}, nil
+	}
+
+	header := http.Header{"X-Emby-Token": []string{r.adminApiKey}}
+	resp, err := https.Get(r.embyHost + "/emby/Auth/Keys").Header(header).Do()
+	if err != nil {
+		return nil, fmt.Errorf("请求 Emby 失败: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("查询 API Key 列表失败, status: %s", resp.Status)
+	}
+
+	var holder struct {
+		Items []struct {
+			AccessToken string
+			AppName     string
+		}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&holder); err != nil {
+		return nil, fmt.Errorf("解析 API Key 列表失败: %v", err)
+	}
+	for _, item := range holder.Items {
+		if item.AccessToken == token {
+			// 后台签发的 API Key 拥有管理员权限
+			return &User{Id: "apikey:" + item.AppName, Name: item.AppName, IsAdministrator: true, IsApiKey: true}, nil
+		}
+	}
+	return nil, ErrInvalidToken
+}
+
+// decodeUser 解析 Emby 用户信息响应
+func decodeUser(body io.Reader) (*User, error) {
+	var holder struct {
+		Id     string
+		Name   string
+		Policy struct {
+			IsAdministrator  bool
+			IsDisabled       bool
+			EnableAllFolders bool
+			EnabledFolders   []string
+		}
+	}
+	if err := json.NewDecoder(body).Decode(&holder); err != nil {
+		return nil, fmt.Errorf("解析用户信息失败: %v", err)
+	}
+	if holder.Id == "" {
+		return nil, errors.New("用户信息缺少 Id")
+	}
+	return &User{
+		Id:               holder.Id,
+		Name:             holder.Name,
+		IsAdministrator:  holder.Policy.IsAdministrator,
+		IsDisabled:       holder.Policy.IsDisabled,
+		EnableAllFolders: holder.Policy.EnableAllFolders,
+		EnabledFolders:   holder.Policy.EnabledFolders,
+	}, nil
+}
+
+// cleanupLoop 定期清理超过宽限期的缓存
+func (r *Resolver) cleanupLoop() {
+	ticker := time.NewTicker(time.Minute * 5)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		r.mu.Lock()
+		now := time.Now()
+		for k, v := range r.data {
+			if now.After(v.expiredAt.Add(r.grace)) {
+				delete(r.data, k)
+			}
+		}
+		r.mu.Unlock()
+	}
+}
@@ -0,0 +1,115 @@
+package userkey
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/config"
+)
+
+func TestResolver_Resolve(t *testing.T) {
+	var calls int32
+	emby := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		switch r.Header.Get("X-Emby-Token") {
+		case "user-token":
+			w.Write([]byte(`{"Id":"u1","Name":"alice","Policy":{"IsAdministrator":false,"EnableAllFolders":false,"EnabledFolders":["lib1"]}}`))
+		case "admin-key":
+			if r.URL.Path == "/emby/Auth/Keys" {
+				w.Write([]byte(`{"Items":[{"AccessToken":"server-key","AppName":"scripts"}]}`))
+				return
+			}
+			w.WriteHeader(http.StatusBadRequest)
+		case "server-key":
+			w.WriteHeader(http.StatusBadRequest)
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer emby.Close()
+
+	r := NewResolver(&config.Emby{Host: emby.URL, AdminApiKey: "admin-key"}, time.Minute)
+
+	user, err := r.Resolve(context.Background(), "user-token")
+	if err != nil {
+		t.Fatalf("解析用户失败: %v", err)
+	}
+	if user.Id != "u1" || !user.CanAccessFolder("lib1") || user.CanAccessFolder("lib2") {
+		t.Errorf("用户信息解析错误: %+v", user)
+	}
+
+	// 第二次命中缓存
+	before := atomic.LoadInt32(&calls)
+	if _, err := r.Resolve(context.Background(), "user-token"); err != nil {
+		t.Fatalf("解析用户失败: %v", err)
+	}
+	if atomic.LoadInt32(&calls) != before {
+		t.Errorf("期望命中缓存, 实际发起了请求")
+	}
+
+	// 后台签发的 API Key
+	user, err = r.Resolve(context.Background(), "server-key")
+	if err != nil {
+		t.Fatalf("解析 API Key 失败: %v", err)
+	}
+	if !user.IsApiKey || !user.IsAdministrator {
+		t.Errorf("API Key 身份解析错误: %+v", user)
+	}
+
+	// 无效令牌
+	if _, err := r.Resolve(context.Background(), "bad-token"); err != ErrInvalidToken {
+		t.Errorf("期望 ErrInvalidToken, 实际 %v", err)
+	}
+}
+
+func TestResolver_StaleOnUpstreamError(t *testing.T) {
+	emby := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"Id":"u1","Name":"alice","Policy":{}}`))
+	}))
+
+	r := NewResolver(&config.Emby{Host: emby.URL}, time.Millisecond*10)
+	if _, err := r.Resolve(context.Background(), "user-token"); err != nil {
+		t.Fatalf("解析用户失败: %v", err)
+	}
+
+	// Emby 不可达时, 宽限期内继续使用旧结果
+	emby.Close()
+	time.Sleep(time.Millisecond * 20)
+	user, err := r.Resolve(context.Background(), "user-token")
+	if err != nil || user.Id != "u1" {
+		t.Errorf("期望返回缓存身份, 实际 user: %v, err: %v", user, err)
+	}
+}
+
+func TestResolver_CoalesceConcurrentMisses(t *testing.T) {
+	var calls int32
+	emby := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(time.Millisecond * 50)
+		w.Write([]byte(`{"Id":"u1","Name":"alice"}`))
+	}))
+	defer emby.Close()
+
+	r := NewResolver(&config.Emby{Host: emby.URL}, time.Minute)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if user, err := r.Resolve(context.Background(), "user-token"); err != nil || user.Id != "u1" {
+				t.Errorf("解析用户失败: %+v, %v", user, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("同一令牌的并发校验应合并为 1 次请求, 实际: %d", n)
+	}
+}
@@ -1,6 +1,7 @@
 package videoauth
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
@@ -16,9 +17,18 @@ import (
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/service/userkey"
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/https"
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/logs"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/metrics"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/reqids"
 	"github.com/gin-gonic/gin"
 )
 
+// verifyTokenTotal 令牌校验结果次数
+var verifyTokenTotal = metrics.NewCounterVec(
+	"ge2o_verify_token_total",
+	"verify-token 接口的校验结果次数",
+	"result",
+)
+
 // VideoAuthService 视频鉴权服务
 type VideoAuthService struct {
 	cache           *userkey.Cache
@@ -33,18 +43,23 @@ type VideoAuthService struct {
 }
 
 // NewVideoAuthService 创建视频鉴权服务
-func NewVideoAuthService(cache *userkey.Cache, cfg *config.Emby, healthChecker *node.HealthChecker, nodeSelector *node.Selector) *VideoAuthService {
-	return &VideoAuthService{
+func NewVideoAuthService(cache *userkey.Cache, cfg *config.Emby, secretKey string, healthChecker *node.HealthChecker, nodeSelector *node.Selector) *VideoAuthService {
+	s := &VideoAuthService{
 		cache:           cache,
 		embyHost:        cfg.Host,
 		adminApiKey:     cfg.AdminApiKey,
-		secretKey:       "go-emby2openlist-secret-2024", // TODO: 从配置读取
+		secretKey:       secretKey,
 		tokenTTL:        5 * time.Minute,                // 临时 URL 有效期 5 分钟
 		uidCache:        userkey.NewCache(10 * time.Minute), // UID 缓存有效期 10 分钟
 		playingSessions: userkey.NewCache(30 * time.Minute), // 播放会话缓存 30 分钟
 		healthChecker:   healthChecker,                      // 节点健康检查器
 		nodeSelector:    nodeSelector,                       // 节点选择器
 	}
+
+	metrics.NewGaugeFunc("ge2o_active_sessions", "当前活跃的播放会话数", func() float64 {
+		return float64(s.playingSessions.Len())
+	})
+	return s
 }
 
 // HandleVideoAuth 处理视频鉴权请求（返回 302 重定向）
@@ -57,7 +72,7 @@ func (s *VideoAuthService) HandleVideoAuth(c *gin.Context) {
 		apiKey = c.GetHeader("X-Emby-Token")
 	}
 	if apiKey == "" {
-		logs.Warn("[VideoAuth] 缺少 api_key，路径: %s, IP: %s", c.Request.URL.Path, c.ClientIP())
+		logs.Ctx(c).Warn("[VideoAuth] 缺少 api_key，路径: %s, IP: %s", c.Request.URL.Path, c.ClientIP())
 		c.JSON(http.StatusForbidden, gin.H{"error": "Missing api_key"})
 		return
 	}
@@ -65,13 +80,13 @@ func (s *VideoAuthService) HandleVideoAuth(c *gin.Context) {
 	// 2. 验证 api_key（使用缓存）
 	valid, err := s.validateApiKey(apiKey)
 	if err != nil {
-		logs.Error("[VideoAuth] 验证 API Key 失败: %v", err)
+		logs.Ctx(c).Error("[VideoAuth] 验证 API Key 失败: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Validation error"})
 		return
 	}
 
 	if !valid {
-		logs.Warn("[VideoAuth] 无效的 api_key，路径: %s, IP: %s", c.Request.URL.Path, c.ClientIP())
+		logs.Ctx(c).Warn("[VideoAuth] 无效的 api_key，路径: %s, IP: %s", c.Request.URL.Path, c.ClientIP())
 		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid api_key"})
 		return
 	}
@@ -94,7 +109,7 @@ func (s *VideoAuthService) HandleVideoAuth(c *gin.Context) {
 	}
 
 	// 5. 记录访问日志
-	logs.Info("[VideoAuth] 鉴权通过，生成临时 URL，用户: %s, 文件: %s, 节点: %s, IP: %s, 耗时: %v",
+	logs.Ctx(c).Info("[VideoAuth] 鉴权通过，生成临时 URL，用户: %s, 文件: %s, 节点: %s, IP: %s, 耗时: %v",
 		maskApiKey(apiKey), videoPath, nodeHost, c.ClientIP(), time.Since(startTime))
 
 	// 6. 构建重定向 URL（包含节点标识，用于故障转移时准确匹配）
@@ -114,7 +129,8 @@ func (s *VideoAuthService) HandleVerifyToken(c *gin.Context) {
 	path := c.Query("path") // Nginx 传递的原始路径
 
 	if token == "" || expiresStr == "" || uid == "" || path == "" {
-		logs.Warn("[TokenVerify] 缺少必需参数，IP: %s", c.ClientIP())
+		logs.Ctx(c).Warn("[TokenVerify] 缺少必需参数，IP: %s", c.ClientIP())
+		verifyTokenTotal.With("missing_params").Inc()
 		c.Status(http.StatusForbidden)
 		return
 	}
@@ -126,7 +142,8 @@ func (s *VideoAuthService) HandleVerifyToken(c *gin.Context) {
 	}
 	const maxRetries = 3
 	if retryCount >= maxRetries {
-		logs.Error("[TokenVerify] 故障转移重试次数超限 (%d 次)，拒绝访问，路径: %s", retryCount, path)
+		logs.Ctx(c).Error("[TokenVerify] 故障转移重试次数超限 (%d 次)，拒绝访问，路径: %s", retryCount, path)
+		verifyTokenTotal.With("retry_exceeded").Inc()
 		c.Status(http.StatusServiceUnavailable)
 		return
 	}
@@ -140,7 +157,8 @@ func (s *VideoAuthService) HandleVerifyToken(c *gin.Context) {
 
 	// 3. 检查是否过期
 	if currentUnix > expiresAt {
-		logs.Warn("[TokenVerify] Token 已过期，路径: %s, IP: %s", path, c.ClientIP())
+		logs.Ctx(c).Warn("[TokenVerify] Token 已过期，路径: %s, IP: %s", path, c.ClientIP())
+		verifyTokenTotal.With("expired").Inc()
 		c.Status(http.StatusForbidden)
 		return
 	}
@@ -148,7 +166,8 @@ func (s *VideoAuthService) HandleVerifyToken(c *gin.Context) {
 	// 4. 解密 uid 获取 api_key
 	apiKey := s.decryptUID(uid)
 	if apiKey == "" {
-		logs.Warn("[TokenVerify] 无效的 UID，路径: %s, IP: %s", path, c.ClientIP())
+		logs.Ctx(c).Warn("[TokenVerify] 无效的 UID，路径: %s, IP: %s", path, c.ClientIP())
+		verifyTokenTotal.With("invalid_uid").Inc()
 		c.Status(http.StatusForbidden)
 		return
 	}
@@ -156,7 +175,8 @@ func (s *VideoAuthService) HandleVerifyToken(c *gin.Context) {
 	// 5. 验证签名
 	expectedToken := s.generateToken(path, apiKey, expiresAt)
 	if token != expectedToken {
-		logs.Warn("[TokenVerify] Token 签名无效，路径: %s, IP: %s", path, c.ClientIP())
+		logs.Ctx(c).Warn("[TokenVerify] Token 签名无效，路径: %s, IP: %s", path, c.ClientIP())
+		verifyTokenTotal.With("bad_signature").Inc()
 		c.Status(http.StatusForbidden)
 		return
 	}
@@ -178,7 +198,7 @@ func (s *VideoAuthService) HandleVerifyToken(c *gin.Context) {
 			requestHost = c.Request.Host
 		}
 
-		logs.Info("[TokenVerify] 检测到的节点主机: %s (来源: %s)", requestHost,
+		logs.Ctx(c).Debug("[TokenVerify] 检测到的节点主机: %s (来源: %s)", requestHost,
 			func() string {
 				if c.Query("_node_host") != "" {
 					return "URL参数"
@@ -189,10 +209,10 @@ func (s *VideoAuthService) HandleVerifyToken(c *gin.Context) {
 				}
 			}())
 
-		isHealthy := s.isNodeHealthy(requestHost)
+		isHealthy := s.isNodeHealthy(c, requestHost)
 		if !isHealthy {
 			// 节点不健康 → 执行故障转移
-			logs.Warn("[TokenVerify] 节点不健康，执行故障转移: %s, 路径: %s, IP: %s",
+			logs.Ctx(c).Warn("[TokenVerify] 节点不健康，执行故障转移: %s, 路径: %s, IP: %s",
 				requestHost, path, c.ClientIP())
 
 			// 检测是否来自 Nginx auth_request（通过检查 X-Original-URI 头）
@@ -202,42 +222,46 @@ func (s *VideoAuthService) HandleVerifyToken(c *gin.Context) {
 			if isAuthRequest {
 				// 来自 auth_request：不能返回 307（auth_request 不支持重定向）
 				// 返回 403 Forbidden，让 Nginx 拦截并使用 error_page 处理
-				logs.Info("[TokenVerify] 检测到 auth_request 调用，返回 403 触发 Nginx error_page")
+				logs.Ctx(c).Info("[TokenVerify] 检测到 auth_request 调用，返回 403 触发 Nginx error_page")
 
 				// 选择新的健康节点并在响应头中返回
 				newNode := s.nodeSelector.SelectNode()
 				if newNode == nil {
-					logs.Error("[TokenVerify] 没有可用的健康节点，拒绝访问")
+					logs.Ctx(c).Error("[TokenVerify] 没有可用的健康节点，拒绝访问")
 					s.playingSessions.Delete(sessionKey)
+					verifyTokenTotal.With("no_node").Inc()
 					c.Status(http.StatusServiceUnavailable)
 					return
 				}
 
 				// 在响应头中返回新节点的 URL（供 Nginx error_page 使用）
-				newRedirectURL := s.buildFailoverURL(newNode.Host, path, apiKey, retryCount+1)
+				newRedirectURL := s.buildFailoverURL(c, newNode.Host, path, apiKey, retryCount+1)
 				c.Header("X-Failover-URL", newRedirectURL)
 				c.Header("X-Failover-Node", newNode.Name)
-				logs.Info("[TokenVerify] auth_request 故障转移: 新节点 %s (%s), URL: %s",
+				logs.Ctx(c).Info("[TokenVerify] auth_request 故障转移: 新节点 %s (%s), URL: %s",
 					newNode.Name, newNode.Host, newRedirectURL)
 
+				verifyTokenTotal.With("failover").Inc()
 				c.Status(http.StatusForbidden)
 				return
 			} else {
 				// 直接访问：可以返回 307 重定向
 				newNode := s.nodeSelector.SelectNode()
 				if newNode == nil {
-					logs.Error("[TokenVerify] 没有可用的健康节点，拒绝访问")
+					logs.Ctx(c).Error("[TokenVerify] 没有可用的健康节点，拒绝访问")
 					s.playingSessions.Delete(sessionKey)
+					verifyTokenTotal.With("no_node").Inc()
 					c.Status(http.StatusServiceUnavailable)
 					return
 				}
 
 				// 重新生成签名 URL（指向新节点）
-				newRedirectURL := s.buildFailoverURL(newNode.Host, path, apiKey, retryCount+1)
-				logs.Info("[TokenVerify] 故障转移到新节点: %s (%s), 重试次数: %d, 新 URL: %s",
+				newRedirectURL := s.buildFailoverURL(c, newNode.Host, path, apiKey, retryCount+1)
+				logs.Ctx(c).Info("[TokenVerify] 故障转移到新节点: %s (%s), 重试次数: %d, 新 URL: %s",
 					newNode.Name, newNode.Host, retryCount+1, newRedirectURL)
 
 				// 返回 307 临时重定向（保留 POST/Range 等方法）
+				verifyTokenTotal.With("failover").Inc()
 				c.Redirect(http.StatusTemporaryRedirect, newRedirectURL)
 				return
 			}
@@ -256,18 +280,20 @@ func (s *VideoAuthService) HandleVerifyToken(c *gin.Context) {
 			newSessionExpires := currentUnix + int64(s.tokenTTL.Seconds())
 			s.playingSessions.Set(sessionKey, fmt.Sprintf("%d", newSessionExpires))
 
-			logs.Info("[TokenVerify] 播放会话续期，用户: %s, 文件: %s, IP: %s, 新过期时间: %s",
+			logs.Ctx(c).Debug("[TokenVerify] 播放会话续期，用户: %s, 文件: %s, IP: %s, 新过期时间: %s",
 				maskApiKey(apiKey), path, c.ClientIP(),
 				time.Unix(newSessionExpires, 0).Format("2006-01-02 15:04:05"))
 
 			// 验证通过
+			verifyTokenTotal.With("renewed").Inc()
 			c.Status(http.StatusOK)
 			return
 		}
 
 		// 会话已过期，删除会话
 		s.playingSessions.Delete(sessionKey)
-		logs.Warn("[TokenVerify] 播放会话已过期（闲置超过5分钟），路径: %s, IP: %s", path, c.ClientIP())
+		logs.Ctx(c).Warn("[TokenVerify] 播放会话已过期（闲置超过5分钟），路径: %s, IP: %s", path, c.ClientIP())
+		verifyTokenTotal.With("session_expired").Inc()
 		c.Status(http.StatusForbidden)
 		return
 	}
@@ -277,11 +303,12 @@ func (s *VideoAuthService) HandleVerifyToken(c *gin.Context) {
 	sessionExpires := currentUnix + int64(s.tokenTTL.Seconds())
 	s.playingSessions.Set(sessionKey, fmt.Sprintf("%d", sessionExpires))
 
-	logs.Info("[TokenVerify] 创建播放会话，用户: %s, 文件: %s, IP: %s, 会话过期时间: %s",
+	logs.Ctx(c).Info("[TokenVerify] 创建播放会话，用户: %s, 文件: %s, IP: %s, 会话过期时间: %s",
 		maskApiKey(apiKey), path, c.ClientIP(),
 		time.Unix(sessionExpires, 0).Format("2006-01-02 15:04:05"))
 
 	// 8. 返回 200 表示验证通过
+	verifyTokenTotal.With("created").Inc()
 	c.Status(http.StatusOK)
 }
 
@@ -317,7 +344,7 @@ func (s *VideoAuthService) validateApiKey(apiKey string) (bool, error) {
 }
 
 // isNodeHealthy 检查节点是否健康（基于请求的 Host）
-func (s *VideoAuthService) isNodeHealthy(requestHost string) bool {
+func (s *VideoAuthService) isNodeHealthy(ctx context.Context, requestHost string) bool {
 	if s.healthChecker == nil {
 		return true // 健康检查器未初始化，默认认为健康
 	}
@@ -328,7 +355,7 @@ func (s *VideoAuthService) isNodeHealthy(requestHost string) bool {
 		requestIP = host
 	}
 
-	logs.Info("[VideoAuth] 检查节点健康状态: requestHost=%s, requestIP=%s", requestHost, requestIP)
+	logs.Ctx(ctx).Debug("[VideoAuth] 检查节点健康状态: requestHost=%s, requestIP=%s", requestHost, requestIP)
 
 	// 1. 先检查健康检查器中的节点（已启用的节点）
 	allNodes := s.healthChecker.GetAllNodes()
@@ -336,7 +363,7 @@ func (s *VideoAuthService) isNodeHealthy(requestHost string) bool {
 		// 解析节点的 Host
 		nodeURL, err := url.Parse(nodeStatus.GetHost())
 		if err != nil {
-			logs.Warn("[VideoAuth] 解析节点 URL 失败: %s, error: %v", nodeStatus.GetHost(), err)
+			logs.Ctx(ctx).Warn("[VideoAuth] 解析节点 URL 失败: %s, error: %v", nodeStatus.GetHost(), err)
 			continue
 		}
 
@@ -344,17 +371,17 @@ func (s *VideoAuthService) isNodeHealthy(requestHost string) bool {
 		nodeIP := nodeURL.Hostname()
 
 		// 调试日志
-		logs.Info("[VideoAuth] 比较已启用节点: requestIP=%s, nodeIP=%s, nodeName=%s, nodeHost=%s",
+		logs.Ctx(ctx).Debug("[VideoAuth] 比较已启用节点: requestIP=%s, nodeIP=%s, nodeName=%s, nodeHost=%s",
 			requestIP, nodeIP, nodeStatus.GetName(), nodeStatus.GetHost())
 
 		// 比较 IP 地址（忽略端口号）
 		if nodeIP == requestIP || nodeURL.Host == requestHost || nodeStatus.GetHost() == requestHost {
 			// 找到匹配的已启用节点，返回健康状态
 			isHealthy := nodeStatus.IsHealthy()
-			logs.Info("[VideoAuth] 匹配到已启用节点: %s (%s), 健康状态: %v",
+			logs.Ctx(ctx).Debug("[VideoAuth] 匹配到已启用节点: %s (%s), 健康状态: %v",
 				nodeStatus.GetName(), nodeStatus.GetHost(), isHealthy)
 			if !isHealthy {
-				logs.Warn("[VideoAuth] 节点不健康: %s (%s)", nodeStatus.GetName(), nodeStatus.GetHost())
+				logs.Ctx(ctx).Warn("[VideoAuth] 节点不健康: %s (%s)", nodeStatus.GetName(), nodeStatus.GetHost())
 			}
 			return isHealthy
 		}
@@ -362,7 +389,7 @@ func (s *VideoAuthService) isNodeHealthy(requestHost string) bool {
 
 	// 2. 检查配置中所有节点（包括被禁用的节点）
 	// 如果请求来自被禁用的节点，应该触发故障转移
-	for _, nodeCfg := range config.C.Nodes.List {
+	for _, nodeCfg := range config.C().Nodes.List {
 		// 解析配置中的节点 Host
 		nodeURL, err := url.Parse(nodeCfg.Host)
 		if err != nil {
@@ -372,14 +399,14 @@ func (s *VideoAuthService) isNodeHealthy(requestHost string) bool {
 		// 提取节点的 IP 地址
 		nodeIP := nodeURL.Hostname()
 
-		logs.Info("[VideoAuth] 比较配置节点: requestIP=%s, nodeIP=%s, nodeName=%s, enabled=%v",
+		logs.Ctx(ctx).Debug("[VideoAuth] 比较配置节点: requestIP=%s, nodeIP=%s, nodeName=%s, enabled=%v",
 			requestIP, nodeIP, nodeCfg.Name, nodeCfg.Enabled)
 
 		// 比较 IP 地址
 		if nodeIP == requestIP || nodeURL.Host == requestHost || nodeCfg.Host == requestHost {
 			if !nodeCfg.Enabled {
 				// 找到匹配的被禁用节点，返回不健康（触发故障转移）
-				logs.Warn("[VideoAuth] 匹配到被禁用的节点: %s (%s), 返回不健康以触发故障转移",
+				logs.Ctx(ctx).Warn("[VideoAuth] 匹配到被禁用的节点: %s (%s), 返回不健康以触发故障转移",
 					nodeCfg.Name, nodeCfg.Host)
 				return false
 			}
@@ -387,7 +414,7 @@ func (s *VideoAuthService) isNodeHealthy(requestHost string) bool {
 	}
 
 	// 3. 未找到任何匹配的节点，可能是直接访问 Emby，认为健康
-	logs.Info("[VideoAuth] 未找到匹配的节点: %s, 认为是直接访问 Emby，返回健康", requestHost)
+	logs.Ctx(ctx).Debug("[VideoAuth] 未找到匹配的节点: %s, 认为是直接访问 Emby，返回健康", requestHost)
 	return true
 }
 
@@ -432,9 +459,16 @@ func maskApiKey(apiKey string) string {
 	return apiKey[:4] + "****" + apiKey[len(apiKey)-4:]
 }
 
+// SignURL 为指定节点上的路径生成带签名的访问地址
+//
+// 签名方式与 verify-token 接口校验方式一致
+func (s *VideoAuthService) SignURL(ctx context.Context, nodeHost, path, apiKey string) string {
+	return s.buildFailoverURL(ctx, nodeHost, path, apiKey, 0)
+}
+
 // buildFailoverURL 构建故障转移 URL
 // 生成指向新节点的 /internal/data URL（带新token）
-func (s *VideoAuthService) buildFailoverURL(nodeHost, internalPath, apiKey string, retryCount int) string {
+func (s *VideoAuthService) buildFailoverURL(ctx context.Context, nodeHost, internalPath, apiKey string, retryCount int) string {
 	// 1. 生成新的临时签名 token
 	expiresAt := time.Now().Add(s.tokenTTL).Unix()
 	token := s.generateToken(internalPath, apiKey, expiresAt)
@@ -443,7 +477,7 @@ func (s *VideoAuthService) buildFailoverURL(nodeHost, internalPath, apiKey strin
 	// 2. 解析节点地址
 	u, err := url.Parse(nodeHost)
 	if err != nil {
-		logs.Error("[Failover] 解析节点地址失败: %v", err)
+		logs.Ctx(ctx).Error("[Failover] 解析节点地址失败: %v", err)
 		return ""
 	}
 
@@ -460,9 +494,13 @@ func (s *VideoAuthService) buildFailoverURL(nodeHost, internalPath, apiKey strin
 	if retryCount > 0 {
 		q.Set("_retry", fmt.Sprintf("%d", retryCount))
 	}
+	// 沿用当前请求 id, 故障转移后的请求仍可关联到同一次播放
+	if id := logs.RequestId(ctx); id != "" {
+		q.Set(reqids.QueryKey, reqids.Short(id))
+	}
 	u.RawQuery = q.Encode()
 
 	finalURL := u.String()
-	logs.Info("[Failover] 故障转移URL: %s (新节点 %s)", finalURL, nodeHost)
+	logs.Ctx(ctx).Debug("[Failover] 故障转移URL: %s (新节点 %s)", finalURL, nodeHost)
 	return finalURL
 }
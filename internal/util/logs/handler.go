@@ -0,0 +1,34 @@
+package logs
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// LevelHandler 运行时查看及修改日志级别的接口
+//
+//	GET           返回当前的日志级别配置, key 为空字符串表示默认级别
+//	PUT/POST      ?module=node&level=debug 修改模块日志级别, module 为空时修改默认级别
+func LevelHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			level, err := ParseLevel(r.FormValue("level"))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			module := r.FormValue("module")
+			SetLevel(module, level)
+			Warn("日志级别已修改: module=%q, level=%s", module, level)
+		default:
+			w.Header().Set("Allow", "GET, PUT, POST")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(Levels())
+	})
+}
@@ -1,49 +1,45 @@
 package logs
 
 import (
-	"fmt"
-	"time"
-
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/logs/colors"
 )
 
+// Debug 输出灰色 Debug 日志
+func Debug(format string, v ...any) {
+	output("", LevelDebug, "[DEBUG] ", colors.ToGray, format, v...)
+}
+
 // Info 输出蓝色 Info 日志
 func Info(format string, v ...any) {
-	s := fmt.Sprintf("[INFO] "+format, v...)
-	fmt.Println(now() + colors.ToBlue(s))
+	output("", LevelInfo, "[INFO] ", colors.ToBlue, format, v...)
 }
 
 // Success 输出绿色 Success 日志
 func Success(format string, v ...any) {
-	s := fmt.Sprintf("[SUCCESS] "+format, v...)
-	fmt.Println(now() + colors.ToGreen(s))
+	output("", LevelInfo, "[SUCCESS] ", colors.ToGreen, format, v...)
 }
 
 // Warn 输出黄色 Warn 日志
 func Warn(format string, v ...any) {
-	s := fmt.Sprintf("[WARN] "+format, v...)
-	fmt.Println(now() + colors.ToYellow(s))
+	output("", LevelWarn, "[WARN] ", colors.ToYellow, format, v...)
 }
 
 // Error 输出红色 Error 日志
 func Error(format string, v ...any) {
-	s := fmt.Sprintf("[ERROR] "+format, v...)
-	fmt.Println(now() + colors.ToRed(s))
+	output("", LevelError, "[ERROR] ", colors.ToRed, format, v...)
 }
 
 // Tip 输出灰色 Tip 日志
 func Tip(format string, v ...any) {
-	s := fmt.Sprintf(format, v...)
-	fmt.Println(now() + colors.ToGray(s))
+	output("", LevelInfo, "", colors.ToGray, format, v...)
 }
 
 // Progress 输出紫色 Progress 日志
 func Progress(format string, v ...any) {
-	s := fmt.Sprintf(format, v...)
-	fmt.Println(now() + colors.ToPurple(s))
+	output("", LevelInfo, "", colors.ToPurple, format, v...)
 }
 
-// now 返回当前时间戳
-func now() string {
-	return time.Now().Format("2006-01-02 15:04:05") + " "
+// Plain 输出不带级别标签的 Info 日志, 颜色由调用方自行控制
+func Plain(format string, v ...any) {
+	output("", LevelInfo, "", func(s string) string { return s }, format, v...)
 }
@@ -0,0 +1,231 @@
+package logs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Level 日志级别
+type Level int32
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// levelNames 日志级别名称
+var levelNames = map[Level]string{
+	LevelDebug: "debug",
+	LevelInfo:  "info",
+	LevelWarn:  "warn",
+	LevelError: "error",
+}
+
+// String 日志级别名称
+func (l Level) String() string {
+	if name, ok := levelNames[l]; ok {
+		return name
+	}
+	return fmt.Sprintf("level(%d)", l)
+}
+
+// ParseLevel 解析日志级别名称
+func ParseLevel(name string) (Level, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "warning" {
+		name = "warn"
+	}
+	for l, n := range levelNames {
+		if n == name {
+			return l, nil
+		}
+	}
+	return LevelInfo, fmt.Errorf("不支持的日志级别: %s, 可选值: debug, info, warn, error", name)
+}
+
+// 日志输出格式
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+var (
+	// defaultLevel 未单独配置级别的模块使用的日志级别
+	defaultLevel atomic.Int32
+
+	// moduleLevels 模块 -> 日志级别
+	moduleLevels sync.Map
+
+	// jsonFormat 是否以 JSON 格式输出
+	jsonFormat atomic.Bool
+
+	// stdout 控制台输出
+	stdout io.Writer = os.Stdout
+
+	// fileOut 额外的文件输出, 为空时只输出到控制台
+	fileOut atomic.Pointer[io.Writer]
+
+	// callerModules 调用位置 -> 模块名称缓存
+	callerModules sync.Map
+
+	// ansiReg 匹配颜色控制字符, 写入文件时去除
+	ansiReg = regexp.MustCompile("\x1b\\[[0-9;]*m")
+)
+
+func init() {
+	defaultLevel.Store(int32(LevelInfo))
+}
+
+// SetLevel 设置日志级别, module 为空时设置默认级别
+func SetLevel(module string, level Level) {
+	if module == "" {
+		defaultLevel.Store(int32(level))
+		return
+	}
+	moduleLevels.Store(module, level)
+}
+
+// ResetModuleLevels 清除所有模块单独配置的日志级别
+func ResetModuleLevels() {
+	moduleLevels.Range(func(key, _ any) bool {
+		moduleLevels.Delete(key)
+		return true
+	})
+}
+
+// Levels 当前的日志级别配置, key 为空字符串表示默认级别
+func Levels() map[string]string {
+	res := map[string]string{"": Level(defaultLevel.Load()).String()}
+	moduleLevels.Range(func(key, value any) bool {
+		res[key.(string)] = value.(Level).String()
+		return true
+	})
+	return res
+}
+
+// SetFormat 设置日志输出格式: text/json
+func SetFormat(format string) error {
+	switch format {
+	case "", FormatText:
+		jsonFormat.Store(false)
+	case FormatJSON:
+		jsonFormat.Store(true)
+	default:
+		return fmt.Errorf("不支持的日志格式: %s, 可选值: text, json", format)
+	}
+	return nil
+}
+
+// SetFileOutput 设置额外的日志文件输出, 传递 nil 表示关闭文件输出
+//
+// 返回之前设置的文件输出, 由调用方负责关闭
+func SetFileOutput(w io.Writer) io.Writer {
+	var old *io.Writer
+	if w == nil {
+		old = fileOut.Swap(nil)
+	} else {
+		old = fileOut.Swap(&w)
+	}
+	if old == nil {
+		return nil
+	}
+	return *old
+}
+
+// CloseFileOutput 关闭日志文件输出, 之后的日志只输出到控制台
+func CloseFileOutput() error {
+	if c, ok := SetFileOutput(nil).(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// levelOf 获取模块的日志级别
+func levelOf(module string) Level {
+	if l, ok := moduleLevels.Load(module); ok {
+		return l.(Level)
+	}
+	return Level(defaultLevel.Load())
+}
+
+// callerModule 根据调用方所在的包名判断模块, 如 node、emby、videoauth
+func callerModule(skip int) string {
+	pc, _, _, ok := runtime.Caller(skip + 1)
+	if !ok {
+		return ""
+	}
+	if m, ok := callerModules.Load(pc); ok {
+		return m.(string)
+	}
+
+	module := ""
+	if fn := runtime.FuncForPC(pc); fn != nil {
+		// 函数名格式: github.com/xxx/internal/service/node.(*HealthChecker).Start
+		name := fn.Name()
+		name = name[strings.LastIndex(name, "/")+1:]
+		if idx := strings.Index(name, "."); idx != -1 {
+			name = name[:idx]
+		}
+		module = name
+	}
+	callerModules.Store(pc, module)
+	return module
+}
+
+// jsonLine JSON 格式的日志
+type jsonLine struct {
+	Time      string `json:"time"`
+	Level     string `json:"level"`
+	Module    string `json:"module"`
+	RequestId string `json:"request_id,omitempty"`
+	Msg       string `json:"msg"`
+}
+
+// output 按级别过滤并输出日志, rid 不为空时附加请求 id
+//
+// 文本格式保持原有的控制台输出样式, 写入文件时去除颜色字符
+func output(rid string, level Level, tag string, color func(string) string, format string, v ...any) {
+	module := callerModule(2)
+	if level < levelOf(module) {
+		return
+	}
+
+	now := time.Now()
+	msg := fmt.Sprintf(format, v...)
+	file := fileOut.Load()
+
+	if jsonFormat.Load() {
+		data, _ := json.Marshal(jsonLine{
+			Time:      now.Format(time.RFC3339Nano),
+			Level:     level.String(),
+			Module:    module,
+			RequestId: rid,
+			Msg:       ansiReg.ReplaceAllString(msg, ""),
+		})
+		data = append(data, '\n')
+		stdout.Write(data)
+		if file != nil {
+			(*file).Write(data)
+		}
+		return
+	}
+
+	prefix := now.Format("2006-01-02 15:04:05") + " "
+	if rid != "" {
+		prefix += "[" + rid + "] "
+	}
+	fmt.Fprintln(stdout, prefix+color(tag+msg))
+	if file != nil {
+		(*file).Write([]byte(prefix + ansiReg.ReplaceAllString(tag+msg, "") + "\n"))
+	}
+}
@@ -0,0 +1,91 @@
+package logs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// captureOutput 替换控制台输出, 返回输出缓冲区及还原函数
+func captureOutput() (*bytes.Buffer, func()) {
+	buf := new(bytes.Buffer)
+	old := stdout
+	stdout = buf
+	return buf, func() {
+		stdout = old
+		SetLevel("", LevelInfo)
+		ResetModuleLevels()
+		SetFormat(FormatText)
+	}
+}
+
+func TestModuleLevel(t *testing.T) {
+	buf, restore := captureOutput()
+	defer restore()
+
+	Debug("默认级别下不输出")
+	if buf.Len() != 0 {
+		t.Errorf("默认 info 级别不应输出 debug 日志: %s", buf.String())
+	}
+
+	SetLevel("logs", LevelDebug)
+	Debug("模块级别生效")
+	if !strings.Contains(buf.String(), "[DEBUG] 模块级别生效") {
+		t.Errorf("模块设置为 debug 后应输出 debug 日志: %s", buf.String())
+	}
+
+	buf.Reset()
+	SetLevel("logs", LevelError)
+	Warn("被过滤")
+	Error("错误日志")
+	if strings.Contains(buf.String(), "被过滤") || !strings.Contains(buf.String(), "错误日志") {
+		t.Errorf("模块级别过滤结果不正确: %s", buf.String())
+	}
+
+	if got := Levels()["logs"]; got != "error" {
+		t.Errorf("Levels 返回的模块级别不正确: %s", got)
+	}
+}
+
+func TestJSONFormat(t *testing.T) {
+	buf, restore := captureOutput()
+	defer restore()
+
+	if err := SetFormat(FormatJSON); err != nil {
+		t.Fatal(err)
+	}
+	Ctx(WithRequestId(context.Background(), "abc123")).Warn("节点 %s 不健康", "node-1")
+
+	var line jsonLine
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("JSON 日志解析失败: %v, 原始内容: %s", err, buf.String())
+	}
+	if line.Level != "warn" || line.Module != "logs" || line.RequestId != "abc123" || line.Msg != "节点 node-1 不健康" {
+		t.Errorf("JSON 日志字段不正确: %+v", line)
+	}
+}
+
+func TestFileOutputStripColor(t *testing.T) {
+	_, restore := captureOutput()
+	defer restore()
+
+	file := new(bytes.Buffer)
+	SetFileOutput(file)
+	defer SetFileOutput(nil)
+
+	Plain("\x1b[31m红色\x1b[0m")
+	if strings.Contains(file.String(), "\x1b[") || !strings.Contains(file.String(), "红色") {
+		t.Errorf("写入文件的日志应去除颜色字符: %q", file.String())
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	if l, err := ParseLevel("WARNING"); err != nil || l != LevelWarn {
+		t.Errorf("解析 WARNING 失败: %v, %v", l, err)
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Error("不支持的日志级别应返回错误")
+	}
+}
@@ -0,0 +1,70 @@
+package logs
+
+import (
+	"context"
+
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/logs/colors"
+)
+
+// requestIdKey 请求 id 存放在 context 中的 key
+type requestIdKey struct{}
+
+// WithRequestId 返回携带请求 id 的 context, 使用 Ctx 输出的日志会带上该 id
+func WithRequestId(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, requestIdKey{}, id)
+}
+
+// RequestId 获取 context 中携带的请求 id
+func RequestId(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIdKey{}).(string)
+	return id
+}
+
+// Logger 带上请求 id 输出日志
+type Logger struct {
+	rid string
+}
+
+// Ctx 获取带上 ctx 中请求 id 的日志输出器
+//
+// 处理请求时传入 *gin.Context 或 http.Request.Context(),
+// 在新的协程中输出日志时需传入请求的 context, 而不是 *gin.Context (请求结束后会被复用)
+func Ctx(ctx context.Context) Logger {
+	return Logger{rid: RequestId(ctx)}
+}
+
+// Debug 输出灰色 Debug 日志
+func (l Logger) Debug(format string, v ...any) {
+	output(l.rid, LevelDebug, "[DEBUG] ", colors.ToGray, format, v...)
+}
+
+// Info 输出蓝色 Info 日志
+func (l Logger) Info(format string, v ...any) {
+	output(l.rid, LevelInfo, "[INFO] ", colors.ToBlue, format, v...)
+}
+
+// Success 输出绿色 Success 日志
+func (l Logger) Success(format string, v ...any) {
+	output(l.rid, LevelInfo, "[SUCCESS] ", colors.ToGreen, format, v...)
+}
+
+// Warn 输出黄色 Warn 日志
+func (l Logger) Warn(format string, v ...any) {
+	output(l.rid, LevelWarn, "[WARN] ", colors.ToYellow, format, v...)
+}
+
+// Error 输出红色 Error 日志
+func (l Logger) Error(format string, v ...any) {
+	output(l.rid, LevelError, "[ERROR] ", colors.ToRed, format, v...)
+}
+
+// Tip 输出灰色 Tip 日志
+func (l Logger) Tip(format string, v ...any) {
+	output(l.rid, LevelInfo, "", colors.ToGray, format, v...)
+}
@@ -0,0 +1,452 @@
+// Prometheus 文本格式的指标采集, 只实现了程序需要用到的
+// counter, gauge, histogram 三种类型
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefBuckets 默认的 histogram 分桶 (秒)
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// collector 可输出指标的采集器
+type collector interface {
+	// write 以 Prometheus 文本格式输出指标
+	write(w io.Writer)
+
+	// name 指标名称
+	name() string
+}
+
+var (
+	// registry 已注册的采集器
+	registry = make(map[string]collector)
+
+	// registryMu 注册表读写锁
+	registryMu sync.RWMutex
+)
+
+// register 注册采集器, 同名指标重复注册时直接返回已注册的采集器
+func register(c collector) collector {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if exist, ok := registry[c.name()]; ok {
+		return exist
+	}
+	registry[c.name()] = c
+	return c
+}
+
+// desc 指标描述信息
+type desc struct {
+	fqName string
+	help   string
+	labels []string
+}
+
+func (d *desc) name() string {
+	return d.fqName
+}
+
+// writeHeader 输出 HELP 和 TYPE 行
+func (d *desc) writeHeader(w io.Writer, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", d.fqName, escapeHelp(d.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", d.fqName, typ)
+}
+
+// labelKey 将标签值拼接成 map key
+func labelKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+// formatLabels 格式化标签, extra 为额外追加的标签对 (如 histogram 的 le)
+func (d *desc) formatLabels(values []string, extra ...string) string {
+	if len(d.labels) == 0 && len(extra) == 0 {
+		return ""
+	}
+	sb := strings.Builder{}
+	sb.WriteString("{")
+	first := true
+	writePair := func(k, v string) {
+		if !first {
+			sb.WriteString(",")
+		}
+		first = false
+		sb.WriteString(k)
+		sb.WriteString(`="`)
+		sb.WriteString(escapeLabel(v))
+		sb.WriteString(`"`)
+	}
+	for i, l := range d.labels {
+		writePair(l, values[i])
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		writePair(extra[i], extra[i+1])
+	}
+	sb.WriteString("}")
+	return sb.String()
+}
+
+// checkValues 校验标签值个数
+func (d *desc) checkValues(values []string) []string {
+	if len(values) == len(d.labels) {
+		return values
+	}
+	// 个数不匹配时补齐或截断, 避免因埋点错误导致程序崩溃
+	fixed := make([]string, len(d.labels))
+	copy(fixed, values)
+	return fixed
+}
+
+// Counter 只增不减的计数器
+type Counter struct {
+	mu  sync.Mutex
+	val float64
+}
+
+// Inc 计数加一
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Add 计数增加指定值, 负数会被忽略
+func (c *Counter) Add(v float64) {
+	if v < 0 {
+		return
+	}
+	c.mu.Lock()
+	c.val += v
+	c.mu.Unlock()
+}
+
+// Value 获取当前计数
+func (c *Counter) Value() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.val
+}
+
+// CounterVec 带标签的计数器
+type CounterVec struct {
+	desc
+	mu       sync.RWMutex
+	counters map[string]*Counter
+	values   map[string][]string
+}
+
+// NewCounterVec 创建并注册带标签的计数器
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	cv := &CounterVec{
+		desc:     desc{fqName: name, help: help, labels: labels},
+		counters: make(map[string]*Counter),
+		values:   make(map[string][]string),
+	}
+	return register(cv).(*CounterVec)
+}
+
+// With 获取指定标签值的计数器
+func (cv *CounterVec) With(values ...string) *Counter {
+	values = cv.checkValues(values)
+	key := labelKey(values)
+
+	cv.mu.RLock()
+	c, ok := cv.counters[key]
+	cv.mu.RUnlock()
+	if ok {
+		return c
+	}
+
+	cv.mu.Lock()
+	defer cv.mu.Unlock()
+	if c, ok = cv.counters[key]; ok {
+		return c
+	}
+	c = new(Counter)
+	cv.counters[key] = c
+	cv.values[key] = append([]string(nil), values...)
+	return c
+}
+
+func (cv *CounterVec) write(w io.Writer) {
+	cv.writeHeader(w, "counter")
+	cv.mu.RLock()
+	defer cv.mu.RUnlock()
+	for _, key := range sortedKeys(cv.counters) {
+		fmt.Fprintf(w, "%s%s %s\n", cv.fqName, cv.formatLabels(cv.values[key]), formatFloat(cv.counters[key].Value()))
+	}
+}
+
+// Gauge 可增可减的仪表盘
+type Gauge struct {
+	mu  sync.Mutex
+	val float64
+}
+
+// Set 设置当前值
+func (g *Gauge) Set(v float64) {
+	g.mu.Lock()
+	g.val = v
+	g.mu.Unlock()
+}
+
+// Add 增加指定值
+func (g *Gauge) Add(v float64) {
+	g.mu.Lock()
+	g.val += v
+	g.mu.Unlock()
+}
+
+// Inc 加一
+func (g *Gauge) Inc() {
+	g.Add(1)
+}
+
+// Dec 减一
+func (g *Gauge) Dec() {
+	g.Add(-1)
+}
+
+// Value 获取当前值
+func (g *Gauge) Value() float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.val
+}
+
+// GaugeVec 带标签的仪表盘
+type GaugeVec struct {
+	desc
+	mu     sync.RWMutex
+	gauges map[string]*Gauge
+	values map[string][]string
+}
+
+// NewGaugeVec 创建并注册带标签的仪表盘
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	gv := &GaugeVec{
+		desc:   desc{fqName: name, help: help, labels: labels},
+		gauges: make(map[string]*Gauge),
+		values: make(map[string][]string),
+	}
+	return register(gv).(*GaugeVec)
+}
+
+// With 获取指定标签值的仪表盘
+func (gv *GaugeVec) With(values ...string) *Gauge {
+	values = gv.checkValues(values)
+	key := labelKey(values)
+
+	gv.mu.RLock()
+	g, ok := gv.gauges[key]
+	gv.mu.RUnlock()
+	if ok {
+		return g
+	}
+
+	gv.mu.Lock()
+	defer gv.mu.Unlock()
+	if g, ok = gv.gauges[key]; ok {
+		return g
+	}
+	g = new(Gauge)
+	gv.gauges[key] = g
+	gv.values[key] = append([]string(nil), values...)
+	return g
+}
+
+// Delete 删除指定标签值的仪表盘, 用于节点被移除等场景
+func (gv *GaugeVec) Delete(values ...string) {
+	key := labelKey(gv.checkValues(values))
+	gv.mu.Lock()
+	defer gv.mu.Unlock()
+	delete(gv.gauges, key)
+	delete(gv.values, key)
+}
+
+func (gv *GaugeVec) write(w io.Writer) {
+	gv.writeHeader(w, "gauge")
+	gv.mu.RLock()
+	defer gv.mu.RUnlock()
+	for _, key := range sortedKeys(gv.gauges) {
+		fmt.Fprintf(w, "%s%s %s\n", gv.fqName, gv.formatLabels(gv.values[key]), formatFloat(gv.gauges[key].Value()))
+	}
+}
+
+// GaugeFunc 采集时调用函数获取值的仪表盘
+type GaugeFunc struct {
+	desc
+	fn func() float64
+}
+
+// NewGaugeFunc 创建并注册函数型仪表盘
+func NewGaugeFunc(name, help string, fn func() float64) *GaugeFunc {
+	gf := &GaugeFunc{desc: desc{fqName: name, help: help}, fn: fn}
+	return register(gf).(*GaugeFunc)
+}
+
+func (gf *GaugeFunc) write(w io.Writer) {
+	gf.writeHeader(w, "gauge")
+	fmt.Fprintf(w, "%s %s\n", gf.fqName, formatFloat(gf.fn()))
+}
+
+// Histogram 直方图
+type Histogram struct {
+	mu      sync.Mutex
+	buckets []float64
+	counts  []uint64
+	count   uint64
+	sum     float64
+}
+
+// Observe 记录一个观测值
+func (h *Histogram) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, b := range h.buckets {
+		if v <= b {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+// ObserveSince 记录从 start 到现在经过的秒数
+func (h *Histogram) ObserveSince(start time.Time) {
+	h.Observe(time.Since(start).Seconds())
+}
+
+// HistogramVec 带标签的直方图
+type HistogramVec struct {
+	desc
+	buckets    []float64
+	mu         sync.RWMutex
+	histograms map[string]*Histogram
+	values     map[string][]string
+}
+
+// NewHistogramVec 创建并注册带标签的直方图, buckets 为空时使用 DefBuckets
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if len(buckets) == 0 {
+		buckets = DefBuckets
+	}
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+	hv := &HistogramVec{
+		desc:       desc{fqName: name, help: help, labels: labels},
+		buckets:    sorted,
+		histograms: make(map[string]*Histogram),
+		values:     make(map[string][]string),
+	}
+	return register(hv).(*HistogramVec)
+}
+
+// With 获取指定标签值的直方图
+func (hv *HistogramVec) With(values ...string) *Histogram {
+	values = hv.checkValues(values)
+	key := labelKey(values)
+
+	hv.mu.RLock()
+	h, ok := hv.histograms[key]
+	hv.mu.RUnlock()
+	if ok {
+		return h
+	}
+
+	hv.mu.Lock()
+	defer hv.mu.Unlock()
+	if h, ok = hv.histograms[key]; ok {
+		return h
+	}
+	h = &Histogram{buckets: hv.buckets, counts: make([]uint64, len(hv.buckets))}
+	hv.histograms[key] = h
+	hv.values[key] = append([]string(nil), values...)
+	return h
+}
+
+func (hv *HistogramVec) write(w io.Writer) {
+	hv.writeHeader(w, "histogram")
+	hv.mu.RLock()
+	defer hv.mu.RUnlock()
+	for _, key := range sortedKeys(hv.histograms) {
+		h := hv.histograms[key]
+		values := hv.values[key]
+
+		h.mu.Lock()
+		for i, b := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", hv.fqName, hv.formatLabels(values, "le", formatFloat(b)), h.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", hv.fqName, hv.formatLabels(values, "le", "+Inf"), h.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", hv.fqName, hv.formatLabels(values), formatFloat(h.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", hv.fqName, hv.formatLabels(values), h.count)
+		h.mu.Unlock()
+	}
+}
+
+// WriteText 以 Prometheus 文本格式输出所有已注册的指标
+func WriteText(w io.Writer) error {
+	registryMu.RLock()
+	names := sortedKeys(registry)
+	collectors := make([]collector, 0, len(names))
+	for _, name := range names {
+		collectors = append(collectors, registry[name])
+	}
+	registryMu.RUnlock()
+
+	bw := bufio.NewWriter(w)
+	for _, c := range collectors {
+		c.write(bw)
+	}
+	return bw.Flush()
+}
+
+// Handler 返回输出指标的 http 处理器
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		WriteText(w)
+	})
+}
+
+// sortedKeys 返回排序后的 map key, 保证输出顺序稳定
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// formatFloat 格式化指标数值
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// escapeHelp 转义 HELP 文本
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+// escapeLabel 转义标签值
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(s)
+}
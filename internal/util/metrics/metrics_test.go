@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteText(t *testing.T) {
+	cv := NewCounterVec("test_requests_total", "测试请求数", "route")
+	cv.With("stream").Inc()
+	cv.With("stream").Add(2)
+	cv.With(`a"b`).Inc()
+
+	hv := NewHistogramVec("test_duration_seconds", "测试耗时", []float64{0.1, 1}, "endpoint")
+	hv.With("/items").Observe(0.05)
+	hv.With("/items").Observe(0.5)
+
+	NewGaugeFunc("test_sessions", "测试会话数", func() float64 { return 3 })
+
+	// 重复注册返回同一个采集器
+	if NewCounterVec("test_requests_total", "测试请求数", "route") != cv {
+		t.Errorf("重复注册应返回已存在的采集器")
+	}
+
+	buf := new(bytes.Buffer)
+	if err := WriteText(buf); err != nil {
+		t.Fatalf("输出指标失败: %v", err)
+	}
+	out := buf.String()
+
+	wants := []string{
+		"# TYPE test_requests_total counter",
+		`test_requests_total{route="stream"} 3`,
+		`test_requests_total{route="a\"b"} 1`,
+		`test_duration_seconds_bucket{endpoint="/items",le="0.1"} 1`,
+		`test_duration_seconds_bucket{endpoint="/items",le="1"} 2`,
+		`test_duration_seconds_bucket{endpoint="/items",le="+Inf"} 2`,
+		`test_duration_seconds_count{endpoint="/items"} 2`,
+		"test_sessions 3",
+	}
+	for _, want := range wants {
+		if !strings.Contains(out, want) {
+			t.Errorf("输出中缺少: %s\n实际输出:\n%s", want, out)
+		}
+	}
+}
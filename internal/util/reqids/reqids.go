@@ -0,0 +1,96 @@
+package reqids
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/url"
+	"regexp"
+
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/logs"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// Header 请求 id 请求头及响应头
+	Header = "X-Request-Id"
+
+	// GinKey 请求 id 存放在 gin 上下文中的 key
+	GinKey = "requestId"
+
+	// QueryKey 节点重定向地址中携带请求 id 的参数名
+	QueryKey = "_rid"
+
+	// ShortLen 重定向地址中携带的短 id 长度
+	ShortLen = 8
+)
+
+// validIdReg 允许沿用的外部请求 id, 防止日志注入
+var validIdReg = regexp.MustCompile(`^[0-9A-Za-z._-]{1,64}$`)
+
+// New 生成新的请求 id
+func New() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// Short 请求 id 的短格式, 用于拼接到节点重定向地址中
+func Short(id string) string {
+	if len(id) <= ShortLen {
+		return id
+	}
+	return id[:ShortLen]
+}
+
+// Get 获取当前请求的 id
+func Get(c *gin.Context) string {
+	if c == nil {
+		return ""
+	}
+	return c.GetString(GinKey)
+}
+
+// Middleware 为每个请求分配 id
+//
+// 优先沿用请求 context 中已有的 id (如程序内部发起的缓存后台刷新请求),
+// 其次是请求头 X-Request-Id, 最后是节点重定向地址中携带的 _rid 参数
+// (包括 Nginx auth_request 通过 X-Original-URI 传递的原始地址),
+// 请求 id 会写入 gin 上下文、请求的 context 及响应头, 使用 logs.Ctx 输出的日志会带上该 id
+//
+// 引擎需开启 ContextWithFallback, 才能直接将 *gin.Context 传给 logs.Ctx
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if id := logs.RequestId(c.Request.Context()); id != "" {
+			c.Set(GinKey, id)
+			c.Header(Header, id)
+			c.Next()
+			return
+		}
+
+		id := fromRequest(c)
+		if id == "" {
+			id = New()
+		}
+		c.Set(GinKey, id)
+		c.Header(Header, id)
+		c.Request = c.Request.WithContext(logs.WithRequestId(c.Request.Context(), id))
+		c.Next()
+	}
+}
+
+// fromRequest 从请求中提取已有的请求 id
+func fromRequest(c *gin.Context) string {
+	candidates := []string{c.GetHeader(Header), c.Query(QueryKey)}
+	if origin := c.GetHeader("X-Original-URI"); origin != "" {
+		if u, err := url.Parse(origin); err == nil {
+			candidates = append(candidates, u.Query().Get(QueryKey))
+		}
+	}
+	for _, id := range candidates {
+		if validIdReg.MatchString(id) {
+			return id
+		}
+	}
+	return ""
+}
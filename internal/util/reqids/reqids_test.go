@@ -0,0 +1,59 @@
+package reqids
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/logs"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.ContextWithFallback = true
+	r.Use(Middleware())
+	r.GET("/", func(c *gin.Context) {
+		if logs.RequestId(c) != Get(c) || logs.RequestId(c.Request.Context()) != Get(c) {
+			t.Errorf("context 中的请求 id 与 gin 上下文不一致: %s, %s", logs.RequestId(c), Get(c))
+		}
+		c.String(http.StatusOK, Get(c))
+	})
+
+	cases := []struct {
+		name   string
+		uri    string
+		header map[string]string
+		want   string
+	}{
+		{name: "沿用请求头", uri: "/", header: map[string]string{Header: "abc-123"}, want: "abc-123"},
+		{name: "重定向参数", uri: "/?_rid=1a2b3c4d", want: "1a2b3c4d"},
+		{name: "auth_request 原始地址", uri: "/", header: map[string]string{"X-Original-URI": "/video/a.mp4?token=x&_rid=deadbeef"}, want: "deadbeef"},
+		{name: "非法 id 重新生成", uri: "/", header: map[string]string{Header: "bad id\n"}},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, tc.uri, nil)
+		for k, v := range tc.header {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		got := w.Header().Get(Header)
+		if got != w.Body.String() {
+			t.Errorf("%s: 响应头与上下文中的请求 id 不一致: %s, %s", tc.name, got, w.Body.String())
+		}
+		if tc.want != "" && got != tc.want {
+			t.Errorf("%s: 期望: %s, 实际: %s", tc.name, tc.want, got)
+		}
+		if tc.want == "" && len(got) != 32 {
+			t.Errorf("%s: 期望生成新的请求 id, 实际: %s", tc.name, got)
+		}
+	}
+
+	if Short("0123456789abcdef") != "01234567" {
+		t.Errorf("短格式不正确")
+	}
+}
@@ -0,0 +1,394 @@
+package rotates
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/logs"
+)
+
+// BackupTimeFormat 轮转文件名中的时间格式
+const BackupTimeFormat = "20060102-150405"
+
+// Options 轮转参数
+type Options struct {
+	MaxSize    int64         // 单个文件的最大字节数, <= 0 时不按大小轮转
+	Interval   time.Duration // 按时间轮转的周期, <= 0 时不按时间轮转
+	MaxBackups int           // 最多保留的轮转文件数, <= 0 时不限制
+	MaxAge     time.Duration // 轮转文件的最长保留时间, <= 0 时不限制
+	Compress   bool          // 是否使用 gzip 压缩轮转文件
+
+	// OnRotate 轮转结束后的回调, backup 为轮转后的文件路径, err 不为空表示轮转过程中出错
+	//
+	// 回调在释放写锁后执行, 为空时不输出轮转结果;
+	// 写入器作为 logs 的文件输出时, 回调中不能再通过 logs 输出, 否则日志会写回当前写入器并再次触发轮转
+	OnRotate func(backup string, err error)
+}
+
+// Writer 支持按大小和时间轮转的文件写入器
+//
+// 轮转过程持有写锁, 不会丢失并发写入的数据;
+// 压缩和清理旧文件在后台完成, 不阻塞写入;
+// 持有写锁期间不通过 logs 输出, 以便作为 logs 的文件输出使用
+type Writer struct {
+	path     string
+	opts     Options
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	openedAt time.Time
+	closed   bool
+
+	millCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewWriter 创建轮转写入器, 以追加模式打开文件
+func NewWriter(path string, opts Options) (*Writer, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("创建日志目录失败: %v", err)
+	}
+
+	w := &Writer{
+		path:   path,
+		opts:   opts,
+		millCh: make(chan struct{}, 1),
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+
+	w.wg.Add(1)
+	go w.millLoop()
+	w.mill()
+	return w, nil
+}
+
+// Path 当前写入的文件路径
+func (w *Writer) Path() string {
+	return w.path
+}
+
+// Write 写入数据, 写入前检查是否需要轮转
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return 0, os.ErrClosed
+	}
+
+	rotated := w.shouldRotate(int64(len(p)))
+	var backup string
+	var rotateErr error
+	if rotated {
+		backup, rotateErr = w.rotate()
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	w.mu.Unlock()
+
+	if rotated {
+		w.report(backup, rotateErr)
+	}
+	return n, err
+}
+
+// Rotate 立即轮转
+func (w *Writer) Rotate() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return os.ErrClosed
+	}
+	backup, err := w.rotate()
+	w.mu.Unlock()
+
+	w.report(backup, err)
+	return err
+}
+
+// Reopen 重新打开文件
+//
+// 用于配合外部 logrotate 等工具: 文件被移走后, 重新打开原路径继续写入
+func (w *Writer) Reopen() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return os.ErrClosed
+	}
+	closeErr := w.file.Close()
+	if err := w.open(); err != nil {
+		return err
+	}
+	if closeErr != nil {
+		return fmt.Errorf("关闭日志文件失败: %v", closeErr)
+	}
+	return nil
+}
+
+// Close 关闭文件并等待后台任务结束
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	err := w.file.Close()
+	close(w.millCh)
+	w.mu.Unlock()
+
+	w.wg.Wait()
+	if err != nil {
+		return fmt.Errorf("关闭日志文件失败: %v", err)
+	}
+	return nil
+}
+
+// Backups 列出已轮转的文件, 按时间从新到旧排序
+func (w *Writer) Backups() ([]string, error) {
+	return ListBackups(w.path)
+}
+
+// ListBackups 列出指定日志文件已轮转的文件, 按时间从新到旧排序
+func ListBackups(path string) ([]string, error) {
+	dir, base := filepath.Dir(path), filepath.Base(path)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("读取日志目录失败: %v", err)
+	}
+
+	type backup struct {
+		path string
+		t    time.Time
+	}
+	backups := make([]backup, 0)
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		t, ok := backupTime(base, e.Name())
+		if !ok {
+			continue
+		}
+		backups = append(backups, backup{path: filepath.Join(dir, e.Name()), t: t})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		if !backups[i].t.Equal(backups[j].t) {
+			return backups[i].t.After(backups[j].t)
+		}
+		return backups[i].path > backups[j].path
+	})
+
+	res := make([]string, len(backups))
+	for i, b := range backups {
+		res[i] = b.path
+	}
+	return res, nil
+}
+
+// backupTime 解析轮转文件名中的时间
+//
+// 文件名格式: {base}.{时间}[-序号][.gz]
+func backupTime(base, name string) (time.Time, bool) {
+	if !strings.HasPrefix(name, base+".") {
+		return time.Time{}, false
+	}
+	rest := strings.TrimSuffix(strings.TrimPrefix(name, base+"."), ".gz")
+	if len(rest) < len(BackupTimeFormat) {
+		return time.Time{}, false
+	}
+	t, err := time.ParseInLocation(BackupTimeFormat, rest[:len(BackupTimeFormat)], time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if suffix := rest[len(BackupTimeFormat):]; suffix != "" && !strings.HasPrefix(suffix, "-") {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// open 以追加模式打开文件 (需持有锁)
+func (w *Writer) open() error {
+	file, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("打开日志文件失败: %v", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("获取日志文件状态失败: %v", err)
+	}
+	w.file = file
+	w.size = info.Size()
+	w.openedAt = time.Now()
+	if info.Size() > 0 {
+		// 沿用已有文件时, 按文件修改时间计算下一次按时间轮转的时机
+		w.openedAt = info.ModTime()
+	}
+	return nil
+}
+
+// shouldRotate 判断写入前是否需要轮转 (需持有锁)
+func (w *Writer) shouldRotate(n int64) bool {
+	if w.size == 0 {
+		return false
+	}
+	if w.opts.MaxSize > 0 && w.size+n > w.opts.MaxSize {
+		return true
+	}
+	if w.opts.Interval > 0 {
+		// 按周期对齐, 如 24h 的周期在每天零点 (UTC) 轮转
+		next := w.openedAt.Truncate(w.opts.Interval).Add(w.opts.Interval)
+		return !time.Now().Before(next)
+	}
+	return false
+}
+
+// rotate 将当前文件重命名为带时间后缀的文件并重新打开 (需持有锁)
+//
+// 返回轮转后的文件路径, 结果由调用方在释放锁后通过 report 输出
+func (w *Writer) rotate() (string, error) {
+	closeErr := w.file.Close()
+
+	newPath := w.backupName(time.Now())
+	if err := os.Rename(w.path, newPath); err != nil && !os.IsNotExist(err) {
+		// 重命名失败时继续写入原文件, 避免丢失日志
+		if openErr := w.open(); openErr != nil {
+			return "", openErr
+		}
+		return "", fmt.Errorf("重命名日志文件失败: %v", err)
+	}
+
+	if err := w.open(); err != nil {
+		return "", err
+	}
+	w.mill()
+	if closeErr != nil {
+		return newPath, fmt.Errorf("关闭日志文件失败: %v", closeErr)
+	}
+	return newPath, nil
+}
+
+// report 输出轮转结果 (不能持有锁)
+func (w *Writer) report(backup string, err error) {
+	if w.opts.OnRotate != nil {
+		w.opts.OnRotate(backup, err)
+	}
+}
+
+// LogRotated 通过 logs 输出轮转结果, 可作为 Options.OnRotate 使用
+//
+// 不能用于 logs 自身的文件输出
+func LogRotated(backup string, err error) {
+	if err != nil {
+		logs.Error("日志轮转失败: %v", err)
+		return
+	}
+	logs.Success("日志文件已轮转: %s", backup)
+}
+
+// backupName 生成不与已有文件冲突的轮转文件名
+func (w *Writer) backupName(t time.Time) string {
+	name := fmt.Sprintf("%s.%s", w.path, t.Format(BackupTimeFormat))
+	candidate := name
+	for i := 1; ; i++ {
+		_, err1 := os.Stat(candidate)
+		_, err2 := os.Stat(candidate + ".gz")
+		if os.IsNotExist(err1) && os.IsNotExist(err2) {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s-%d", name, i)
+	}
+}
+
+// mill 通知后台协程压缩和清理轮转文件
+func (w *Writer) mill() {
+	select {
+	case w.millCh <- struct{}{}:
+	default:
+	}
+}
+
+// millLoop 后台压缩和清理轮转文件
+func (w *Writer) millLoop() {
+	defer w.wg.Done()
+	for range w.millCh {
+		if err := w.millOnce(); err != nil {
+			logs.Error("清理轮转日志失败: %v", err)
+		}
+	}
+}
+
+// millOnce 压缩未压缩的轮转文件, 并按数量和时间清理过期文件
+func (w *Writer) millOnce() error {
+	backups, err := w.Backups()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for i, b := range backups {
+		expired := w.opts.MaxBackups > 0 && i >= w.opts.MaxBackups
+		if !expired && w.opts.MaxAge > 0 {
+			if t, ok := backupTime(filepath.Base(w.path), filepath.Base(b)); ok && now.Sub(t) > w.opts.MaxAge {
+				expired = true
+			}
+		}
+		if expired {
+			if err := os.Remove(b); err != nil && !os.IsNotExist(err) {
+				logs.Warn("删除过期日志失败: %v", err)
+			}
+			continue
+		}
+		if w.opts.Compress && !strings.HasSuffix(b, ".gz") {
+			if err := compressFile(b); err != nil {
+				logs.Warn("压缩日志失败: %v", err)
+			}
+		}
+	}
+	return nil
+}
+
+// compressFile 使用 gzip 压缩文件, 成功后删除原文件
+func compressFile(src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	dst := src + ".gz"
+	tmp := dst + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(out)
+	if _, err = io.Copy(gz, in); err == nil {
+		err = gz.Close()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	in.Close()
+	return os.Remove(src)
+}
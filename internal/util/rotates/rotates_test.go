@@ -0,0 +1,143 @@
+package rotates
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/logs"
+)
+
+func TestWriter_RotateBySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	w, err := NewWriter(path, Options{MaxSize: 10, MaxBackups: 2, Compress: true})
+	if err != nil {
+		t.Fatalf("创建写入器失败: %v", err)
+	}
+
+	for _, line := range []string{"line-0001\n", "line-0002\n", "line-0003\n", "line-0004\n"} {
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatalf("写入失败: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("关闭失败: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "line-0004\n" {
+		t.Errorf("当前文件内容不正确: %q, err: %v", data, err)
+	}
+
+	backups, err := ListBackups(path)
+	if err != nil {
+		t.Fatalf("列出轮转文件失败: %v", err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("期望保留 2 个轮转文件, 实际: %v", backups)
+	}
+	for _, b := range backups {
+		if !strings.HasSuffix(b, ".gz") {
+			t.Errorf("轮转文件未压缩: %s", b)
+			continue
+		}
+		f, err := os.Open(b)
+		if err != nil {
+			t.Fatalf("打开轮转文件失败: %v", err)
+		}
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatalf("解压轮转文件失败: %v", err)
+		}
+		content, _ := io.ReadAll(gz)
+		f.Close()
+		if !strings.HasPrefix(string(content), "line-000") {
+			t.Errorf("轮转文件内容不正确: %q", content)
+		}
+	}
+}
+
+func TestWriter_AsLogsOutput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	var rotated atomic.Int32
+	w, err := NewWriter(path, Options{MaxSize: 64, OnRotate: func(backup string, err error) {
+		if err != nil {
+			t.Errorf("轮转失败: %v", err)
+		}
+		rotated.Add(1)
+	}})
+	if err != nil {
+		t.Fatalf("创建写入器失败: %v", err)
+	}
+	defer w.Close()
+
+	logs.SetFileOutput(w)
+	defer logs.SetFileOutput(nil)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			logs.Info("写入日志并触发轮转: %d", i)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("作为 logs 的文件输出时轮转卡死")
+	}
+
+	if rotated.Load() == 0 {
+		t.Error("期望触发轮转回调")
+	}
+	backups, err := ListBackups(path)
+	if err != nil || len(backups) == 0 {
+		t.Errorf("期望生成轮转文件, 实际: %v, err: %v", backups, err)
+	}
+}
+
+func TestWriter_Reopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	w, err := NewWriter(path, Options{})
+	if err != nil {
+		t.Fatalf("创建写入器失败: %v", err)
+	}
+	defer w.Close()
+
+	w.Write([]byte("before\n"))
+	// 模拟外部 logrotate 移走文件
+	moved := path + "." + time.Now().Format(BackupTimeFormat)
+	if err := os.Rename(path, moved); err != nil {
+		t.Fatalf("移动文件失败: %v", err)
+	}
+	if err := w.Reopen(); err != nil {
+		t.Fatalf("重新打开失败: %v", err)
+	}
+	w.Write([]byte("after\n"))
+
+	data, _ := os.ReadFile(path)
+	if string(data) != "after\n" {
+		t.Errorf("重新打开后内容不正确: %q", data)
+	}
+}
+
+func TestBackupTime(t *testing.T) {
+	cases := map[string]bool{
+		"access.log.20240102-030405":        true,
+		"access.log.20240102-030405-1":      true,
+		"access.log.20240102-030405.gz":     true,
+		"access.log.20240102-030405.gz.tmp": false,
+		"access.log":                        false,
+		"other.log.20240102-030405":         false,
+	}
+	for name, want := range cases {
+		if _, ok := backupTime("access.log", name); ok != want {
+			t.Errorf("%s 期望: %v, 实际: %v", name, want, ok)
+		}
+	}
+}
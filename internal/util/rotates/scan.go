@@ -0,0 +1,89 @@
+package rotates
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// ErrStopScan 在回调中返回该错误可提前结束扫描
+var ErrStopScan = errors.New("stop scan")
+
+// ScanLines 按时间顺序逐行扫描日志文件及其轮转文件
+//
+// 先扫描轮转文件 (从旧到新, 自动解压 .gz), 最后扫描当前文件;
+// 修改时间早于 since 的轮转文件不包含所需记录, 直接跳过
+func ScanLines(path string, since time.Time, fn func(line []byte) error) error {
+	backups, err := ListBackups(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	files := make([]string, 0, len(backups)+1)
+	for i := len(backups) - 1; i >= 0; i-- {
+		if !since.IsZero() {
+			if info, err := os.Stat(backups[i]); err == nil && info.ModTime().Before(since) {
+				continue
+			}
+		}
+		files = append(files, backups[i])
+	}
+	files = append(files, path)
+
+	for _, f := range files {
+		err := scanFile(f, fn)
+		if errors.Is(err, ErrStopScan) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanFile 逐行扫描单个文件
+func scanFile(path string, fn func(line []byte) error) error {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		// 扫描期间轮转文件可能刚被后台压缩
+		if !strings.HasSuffix(path, ".gz") {
+			return scanFile(path+".gz", fn)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("打开日志文件失败: %v", err)
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("解压日志文件失败 [%s]: %v", path, err)
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		if err := fn(scanner.Bytes()); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("读取日志文件失败 [%s]: %v", path, err)
+	}
+	return nil
+}
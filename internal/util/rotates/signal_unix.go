@@ -0,0 +1,20 @@
+//go:build !windows
+
+package rotates
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// NotifyReopen 收到 SIGUSR1 信号时执行 fn, 用于配合外部 logrotate 重新打开日志文件
+func NotifyReopen(fn func()) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGUSR1)
+	go func() {
+		for range ch {
+			fn()
+		}
+	}()
+}
@@ -0,0 +1,6 @@
+//go:build windows
+
+package rotates
+
+// NotifyReopen Windows 不支持 SIGUSR1 信号, 忽略
+func NotifyReopen(fn func()) {}
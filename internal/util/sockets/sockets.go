@@ -0,0 +1,86 @@
+package sockets
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/logs"
+)
+
+var (
+	// inherited 从旧进程继承的监听套接字, 监听地址 -> 文件
+	inherited = make(map[string]*os.File)
+
+	// active 当前进程创建的监听套接字, 平滑升级时传递给新进程
+	active []activeListener
+
+	mu sync.Mutex
+)
+
+// activeListener 监听地址及对应的监听套接字
+type activeListener struct {
+	addr string
+	ln   *net.TCPListener
+}
+
+// Listen 在指定地址上监听 tcp 连接
+//
+// 由平滑升级启动时优先使用旧进程传递的同一地址的监听套接字, 端口始终可以接受连接
+func Listen(addr string) (net.Listener, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	var ln net.Listener
+	if f, ok := inherited[addr]; ok {
+		delete(inherited, addr)
+		var err error
+		ln, err = net.FileListener(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("使用继承的监听套接字 %s 失败: %v", addr, err)
+		}
+		logs.Info("使用旧进程的监听套接字: %s", addr)
+	} else {
+		var err error
+		if ln, err = net.Listen("tcp", addr); err != nil {
+			return nil, err
+		}
+	}
+
+	if tl, ok := ln.(*net.TCPListener); ok {
+		active = append(active, activeListener{addr: addr, ln: tl})
+	}
+	return ln, nil
+}
+
+// closeInherited 关闭未被使用的继承套接字, 如新版本不再监听某个端口
+func closeInherited() {
+	mu.Lock()
+	defer mu.Unlock()
+	for addr, f := range inherited {
+		logs.Warn("未使用旧进程的监听套接字 %s, 已关闭", addr)
+		f.Close()
+		delete(inherited, addr)
+	}
+}
+
+// activeFiles 复制当前所有监听套接字的文件描述符, 由调用方负责关闭
+func activeFiles() ([]string, []*os.File, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	addrs, files := make([]string, 0, len(active)), make([]*os.File, 0, len(active))
+	for _, a := range active {
+		f, err := a.ln.File()
+		if err != nil {
+			for _, f := range files {
+				f.Close()
+			}
+			return nil, nil, fmt.Errorf("获取监听套接字 %s 失败: %v", a.addr, err)
+		}
+		addrs, files = append(addrs, a.addr), append(files, f)
+	}
+	return addrs, files, nil
+}
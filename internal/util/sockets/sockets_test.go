@@ -0,0 +1,82 @@
+package sockets
+
+import (
+	"net"
+	"os"
+	"testing"
+)
+
+// resetState 清空继承及当前的监听套接字
+func resetState(t *testing.T) {
+	t.Cleanup(func() {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, a := range active {
+			a.ln.Close()
+		}
+		active, inherited = nil, make(map[string]*os.File)
+	})
+}
+
+func TestListen_Inherited(t *testing.T) {
+	resetState(t)
+
+	ln, err := Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	addr := ln.Addr().String()
+
+	addrs, files, err := activeFiles()
+	if err != nil || len(files) != 1 || addrs[0] != "127.0.0.1:0" {
+		t.Fatalf("获取监听套接字失败: %v, %v", addrs, err)
+	}
+
+	// 模拟新进程: 继承的套接字按地址复用, 旧监听关闭后仍可接受连接
+	inherited[addr] = files[0]
+	ln2, err := Listen(addr)
+	if err != nil {
+		t.Fatalf("使用继承的监听套接字失败: %v", err)
+	}
+	ln.Close()
+	if ln2.Addr().String() != addr {
+		t.Errorf("继承的监听地址不一致: %s != %s", ln2.Addr(), addr)
+	}
+	if _, ok := inherited[addr]; ok {
+		t.Error("使用后应从继承列表中移除")
+	}
+
+	go func() {
+		if c, err := ln2.Accept(); err == nil {
+			c.Close()
+		}
+	}()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("旧监听关闭后连接失败: %v", err)
+	}
+	conn.Close()
+}
+
+func TestCloseInherited(t *testing.T) {
+	resetState(t)
+
+	ln, err := Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	defer ln.Close()
+	_, files, err := activeFiles()
+	if err != nil {
+		t.Fatalf("获取监听套接字失败: %v", err)
+	}
+
+	inherited["127.0.0.1:1"] = files[0]
+	closeInherited()
+	if len(inherited) != 0 {
+		t.Errorf("未使用的继承套接字应被关闭: %v", inherited)
+	}
+	if err := files[0].Close(); err == nil {
+		t.Error("继承套接字应已关闭")
+	}
+}
@@ -0,0 +1,123 @@
+//go:build !windows
+
+package sockets
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/logs"
+)
+
+const (
+	// envFds 传递给新进程的监听地址, 逗号分隔, 顺序与 ExtraFiles 一致 (文件描述符从 3 开始)
+	envFds = "GO_EMBY2OPENLIST_FDS"
+
+	// envParent 旧进程的 pid, 新进程就绪后通知其退出
+	envParent = "GO_EMBY2OPENLIST_PARENT"
+)
+
+var (
+	// executable 启动时的程序路径, 替换程序文件后仍指向新版本
+	executable, _ = os.Executable()
+
+	// upgrading 是否已启动新进程, 等待其就绪
+	upgrading atomic.Bool
+)
+
+func init() {
+	addrs := os.Getenv(envFds)
+	if addrs == "" {
+		return
+	}
+	os.Unsetenv(envFds)
+	for i, addr := range strings.Split(addrs, ",") {
+		inherited[addr] = os.NewFile(uintptr(3+i), addr)
+	}
+}
+
+// NotifyUpgrade 收到 SIGUSR2 信号时启动新进程进行平滑升级
+func NotifyUpgrade() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGUSR2)
+	go func() {
+		for range ch {
+			if err := Upgrade(); err != nil {
+				logs.Error("平滑升级失败: %v", err)
+			}
+		}
+	}()
+}
+
+// Upgrade 使用当前程序文件启动新进程, 并将所有监听套接字传递给新进程
+//
+// 新进程就绪后向当前进程发送 SIGTERM, 当前进程随后按正常流程优雅退出;
+// 新进程启动失败时当前进程继续提供服务
+func Upgrade() error {
+	if executable == "" {
+		return errors.New("无法获取程序路径")
+	}
+	if !upgrading.CompareAndSwap(false, true) {
+		return errors.New("已有新进程正在启动")
+	}
+
+	addrs, files, err := activeFiles()
+	if err != nil {
+		upgrading.Store(false)
+		return err
+	}
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.ExtraFiles = files
+	cmd.Env = append(os.Environ(),
+		envFds+"="+strings.Join(addrs, ","),
+		envParent+"="+strconv.Itoa(os.Getpid()),
+	)
+	if err := cmd.Start(); err != nil {
+		upgrading.Store(false)
+		return fmt.Errorf("启动新进程失败: %v", err)
+	}
+	logs.Info("已启动新进程 %d, 传递监听套接字: %s, 等待新进程就绪", cmd.Process.Pid, strings.Join(addrs, ", "))
+
+	go func() {
+		err := cmd.Wait()
+		upgrading.Store(false)
+		logs.Error("新进程 %d 已退出 (%v), 继续由当前进程提供服务", cmd.Process.Pid, err)
+	}()
+	return nil
+}
+
+// Ready 所有服务开始监听后调用
+//
+// 由平滑升级启动时关闭未使用的继承套接字, 并通知旧进程退出
+func Ready() {
+	closeInherited()
+
+	ppid := os.Getenv(envParent)
+	if ppid == "" {
+		return
+	}
+	os.Unsetenv(envParent)
+	pid, err := strconv.Atoi(ppid)
+	if err != nil || pid != os.Getppid() {
+		return
+	}
+	if err := syscall.Kill(pid, syscall.SIGTERM); err != nil {
+		logs.Error("通知旧进程 %d 退出失败: %v", pid, err)
+		return
+	}
+	logs.Success("新进程已就绪, 已通知旧进程 %d 退出", pid)
+}
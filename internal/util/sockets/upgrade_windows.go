@@ -0,0 +1,16 @@
+//go:build windows
+
+package sockets
+
+import "errors"
+
+// NotifyUpgrade Windows 不支持 SIGUSR2 信号及传递监听套接字, 忽略
+func NotifyUpgrade() {}
+
+// Upgrade Windows 不支持平滑升级
+func Upgrade() error {
+	return errors.New("Windows 不支持平滑升级")
+}
+
+// Ready 所有服务开始监听后调用, Windows 下不会继承监听套接字, 无需处理
+func Ready() {}
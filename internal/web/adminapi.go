@@ -0,0 +1,215 @@
+package web
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/config"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/service/audit"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/service/emby"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/service/node"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/logs"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/metrics"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/web/cache"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/web/dashboard"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// AdminApiPrefix 主服务上管理接口的路径前缀
+	AdminApiPrefix = "/ge2o/api"
+
+	// AdminDashboardPrefix 主服务上管理面板的路径前缀
+	AdminDashboardPrefix = "/ge2o/admin"
+)
+
+// adminApiHandler 管理接口及管理面板处理器, 由 InitAdminApi 初始化
+var adminApiHandler http.Handler
+
+// InitAdminApi 初始化主服务上的管理接口及管理面板, 需在 Listen 之前调用
+//
+// 是否启用及访问令牌每次请求时从配置中读取, 修改后热重载即生效
+func InitAdminApi(nodeManager *node.Manager) {
+	api := http.NewServeMux()
+	actor := func(r *http.Request) string {
+		actor, _, _ := adminActor(r)
+		return actor
+	}
+	nodes := nodeManager.AdminHandler(AdminApiPrefix+"/nodes", actor)
+	api.Handle(AdminApiPrefix+"/nodes", nodes)
+	api.Handle(AdminApiPrefix+"/nodes/", nodes)
+	api.Handle(AdminApiPrefix+"/config/", configAdminHandler(AdminApiPrefix+"/config", actor))
+	api.Handle(AdminApiPrefix+"/cache/", cache.AdminHandler(AdminApiPrefix+"/cache"))
+	api.HandleFunc("GET "+AdminApiPrefix+"/sessions", adminSessionsList)
+	api.HandleFunc("GET "+AdminApiPrefix+"/audit", adminAuditRecent)
+	api.HandleFunc("GET "+AdminApiPrefix+"/map", adminMapPath)
+	api.Handle(AdminApiPrefix+"/log-level", logs.LevelHandler())
+	api.Handle("GET "+AdminApiPrefix+"/metrics", metrics.Handler())
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+AdminApiPrefix+"/login", adminLogin)
+	mux.HandleFunc("POST "+AdminApiPrefix+"/logout", adminLogout)
+	mux.Handle(AdminDashboardPrefix+"/", dashboard.Handler(AdminDashboardPrefix))
+	mux.Handle("/", adminAuth(api))
+	adminApiHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !config.C().AdminApi.Enable {
+			http.NotFound(w, r)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+
+	if a := config.C().AdminApi; a.Enable && a.ClientCa != "" && !config.C().Ssl.Enable {
+		logs.Warn("未启用 ssl, 管理接口的客户端证书鉴权不会生效")
+	}
+}
+
+// isAdminPath 判断请求路径是否属于管理接口或管理面板
+func isAdminPath(path string) bool {
+	for _, prefix := range []string{AdminApiPrefix, AdminDashboardPrefix} {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// adminApiInterceptor 拦截管理接口请求, 不经过 Emby 相关的中间件, 避免访问令牌被当作 Emby 令牌处理
+func adminApiInterceptor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if adminApiHandler == nil || !isAdminPath(c.Request.URL.Path) {
+			return
+		}
+		adminApiHandler.ServeHTTP(c.Writer, c.Request)
+		c.Abort()
+	}
+}
+
+// adminAuth 管理接口鉴权
+//
+// 通过管理面板登录的请求在修改数据时需携带 X-Requested-With 请求头, 防止跨站请求伪造
+func adminAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, viaSession, ok := adminActor(r)
+		if !ok {
+			logs.Warn("管理接口鉴权失败: %s %s %s", r.RemoteAddr, r.Method, r.URL.Path)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="go-emby2openlist"`)
+			writeAdminJson(w, http.StatusUnauthorized, map[string]string{"error": "鉴权失败"})
+			return
+		}
+		if viaSession && r.Method != http.MethodGet && r.Method != http.MethodHead && r.Header.Get("X-Requested-With") == "" {
+			writeAdminJson(w, http.StatusForbidden, map[string]string{"error": "缺少 X-Requested-With 请求头"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// adminActor 校验管理接口请求的客户端证书、访问令牌或管理面板登录会话, 返回记录到配置历史中的修改人
+//
+// 使用客户端证书时修改人为证书的 CN, 使用令牌时为脱敏后的令牌; viaSession 表示通过管理面板登录会话鉴权
+func adminActor(r *http.Request) (actor string, viaSession bool, ok bool) {
+	a := config.C().AdminApi
+	if a.ClientCa != "" && r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+		return "api:cert:" + r.TLS.VerifiedChains[0][0].Subject.CommonName, false, true
+	}
+	if token := requestToken(r); token != "" {
+		if !validAdminToken(token) {
+			return "", false, false
+		}
+		return requestActor(r), false, true
+	}
+	if actor, ok := sessionActor(r); ok {
+		return actor, true, true
+	}
+	return "", false, false
+}
+
+// validAdminToken 校验管理接口访问令牌, 未配置令牌时始终校验失败
+func validAdminToken(token string) bool {
+	want := config.C().AdminApi.Token
+	return want != "" && subtle.ConstantTimeCompare([]byte(token), []byte(want)) == 1
+}
+
+// adminTokenRequired 要求请求携带管理接口访问令牌, 用于主服务以外的端口上的管理类接口
+func adminTokenRequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if validAdminToken(requestToken(c.Request)) {
+			return
+		}
+		logs.Ctx(c).Warn("管理令牌鉴权失败: %s %s %s", c.ClientIP(), c.Request.Method, c.Request.URL.Path)
+		c.Header("WWW-Authenticate", `Bearer realm="go-emby2openlist"`)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "鉴权失败, 需携带 admin-api.token"})
+	}
+}
+
+// adminSessionsList 正在播放的 Emby 会话
+func adminSessionsList(w http.ResponseWriter, r *http.Request) {
+	sessions, err := emby.ActiveSessions()
+	if err != nil {
+		writeAdminJson(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+		return
+	}
+	writeAdminJson(w, http.StatusOK, sessions)
+}
+
+// adminAuditRecent 最近的重定向审计事件, 参数: limit (默认 100)
+func adminAuditRecent(w http.ResponseWriter, r *http.Request) {
+	limit := 100
+	if l := r.URL.Query().Get("limit"); l != "" {
+		var err error
+		if limit, err = strconv.Atoi(l); err != nil {
+			writeAdminJson(w, http.StatusBadRequest, map[string]string{"error": "limit 参数错误: " + l})
+			return
+		}
+	}
+	writeAdminJson(w, http.StatusOK, map[string]any{"enabled": audit.Enabled(), "events": audit.Recent(limit)})
+}
+
+// adminMapPath 查看 emby 路径命中的 emby2nginx 映射及各节点上的地址, 参数: path
+func adminMapPath(w http.ResponseWriter, r *http.Request) {
+	embyPath := strings.TrimSpace(r.URL.Query().Get("path"))
+	if embyPath == "" {
+		writeAdminJson(w, http.StatusBadRequest, map[string]string{"error": "path 参数不能为空"})
+		return
+	}
+
+	idx, nginxPath, ok := config.C().Path.MatchEmby2Nginx(embyPath)
+	if !ok {
+		writeAdminJson(w, http.StatusOK, map[string]any{"matched": false})
+		return
+	}
+	key := fmt.Sprintf("path.emby2nginx[%d]", idx)
+	file, line := config.LocateKey(key)
+
+	type nodeUrl struct {
+		Name    string `json:"name"`
+		Url     string `json:"url"`
+		Enabled bool   `json:"enabled"`
+	}
+	urls := make([]nodeUrl, 0, len(config.C().Nodes.List))
+	for _, n := range config.C().Nodes.List {
+		urls = append(urls, nodeUrl{Name: n.Name, Url: strings.TrimSuffix(n.Host, "/") + nginxPath, Enabled: n.Enabled})
+	}
+	writeAdminJson(w, http.StatusOK, map[string]any{
+		"matched":    true,
+		"key":        key,
+		"rule":       config.C().Path.Emby2Nginx[idx],
+		"file":       file,
+		"line":       line,
+		"nginx_path": nginxPath,
+		"nodes":      urls,
+	})
+}
+
+// writeAdminJson 响应 json 数据
+func writeAdminJson(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,203 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/config"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/service/node"
+
+	"github.com/gin-gonic/gin"
+)
+
+const testAdminToken = "0123456789abcdef"
+
+// initTestAdminApi 使用内存中的配置初始化管理接口
+func initTestAdminApi(t *testing.T) {
+	t.Helper()
+	oldC := config.C()
+	t.Cleanup(func() { config.Set(oldC) })
+
+	path := &config.Path{Emby2Nginx: []string{"/media/data:/video/data"}}
+	if err := path.Init(); err != nil {
+		t.Fatal(err)
+	}
+	config.Set(&config.Config{
+		Nodes: &config.Nodes{List: []config.Node{
+			{Name: "node-1", Host: "http://127.0.0.2:1", Weight: 100, Enabled: true},
+			{Name: "node-2", Host: "http://127.0.0.3:1", Weight: 50, Enabled: false},
+		}},
+		Path:     path,
+		Ssl:      &config.Ssl{},
+		AdminApi: &config.AdminApi{Enable: true, Token: testAdminToken},
+	})
+	InitAdminApi(node.NewManager(node.NewHealthChecker(config.C().Nodes)))
+}
+
+// serveAdmin 发送管理接口请求
+func serveAdmin(method, path, body string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	adminApiHandler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAdminApiAuth(t *testing.T) {
+	initTestAdminApi(t)
+
+	if rec := serveAdmin(http.MethodGet, "/ge2o/api/nodes", "", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("未携带令牌时应响应 401, 实际: %d", rec.Code)
+	}
+	if rec := serveAdmin(http.MethodGet, "/ge2o/api/nodes", "", map[string]string{"Authorization": "Bearer wrong-token-wrong"}); rec.Code != http.StatusUnauthorized {
+		t.Errorf("令牌错误时应响应 401, 实际: %d", rec.Code)
+	}
+	rec := serveAdmin(http.MethodGet, "/ge2o/api/nodes", "", map[string]string{"X-Admin-Token": testAdminToken})
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") == "" {
+		t.Errorf("令牌正确时应响应节点列表: %d %s", rec.Code, rec.Body)
+	}
+
+	// 配置管理及日志级别接口同样需要鉴权
+	for _, path := range []string{"/ge2o/api/config/effective", "/ge2o/api/config/history", "/ge2o/api/log-level", "/ge2o/api/metrics"} {
+		if rec := serveAdmin(http.MethodGet, path, "", nil); rec.Code != http.StatusUnauthorized {
+			t.Errorf("未携带令牌时 %s 应响应 401, 实际: %d", path, rec.Code)
+		}
+	}
+	if rec := serveAdmin(http.MethodPost, "/ge2o/api/config/rollback/1", "", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("未携带令牌时回滚应响应 401, 实际: %d", rec.Code)
+	}
+	rec = serveAdmin(http.MethodGet, "/ge2o/api/log-level", "", map[string]string{"X-Admin-Token": testAdminToken})
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"":`) {
+		t.Errorf("令牌正确时应响应日志级别: %d %s", rec.Code, rec.Body)
+	}
+
+	// 管理面板页面不需要鉴权
+	rec = serveAdmin(http.MethodGet, "/ge2o/admin/", "", nil)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "app.js") || rec.Header().Get("Content-Security-Policy") == "" {
+		t.Errorf("管理面板页面响应不正确: %d", rec.Code)
+	}
+
+	config.C().AdminApi.Enable = false
+	for _, path := range []string{"/ge2o/api/nodes", "/ge2o/admin/"} {
+		if rec := serveAdmin(http.MethodGet, path, "", map[string]string{"X-Admin-Token": testAdminToken}); rec.Code != http.StatusNotFound {
+			t.Errorf("未启用时 %s 应响应 404, 实际: %d", path, rec.Code)
+		}
+	}
+}
+
+func TestAdminApiLogin(t *testing.T) {
+	initTestAdminApi(t)
+
+	if rec := serveAdmin(http.MethodPost, "/ge2o/api/login", `{"token": "wrong"}`, nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("令牌错误时应登录失败, 实际: %d", rec.Code)
+	}
+	rec := serveAdmin(http.MethodPost, "/ge2o/api/login", `{"token": "`+testAdminToken+`"}`, nil)
+	cookies := rec.Result().Cookies()
+	if rec.Code != http.StatusOK || len(cookies) != 1 || !cookies[0].HttpOnly || cookies[0].SameSite != http.SameSiteStrictMode {
+		t.Fatalf("登录失败: %d %s", rec.Code, rec.Body)
+	}
+	cookie := map[string]string{"Cookie": cookies[0].Name + "=" + cookies[0].Value}
+
+	if rec := serveAdmin(http.MethodGet, "/ge2o/api/map?path=/media/data/a.mkv", "", cookie); rec.Code != http.StatusOK {
+		t.Fatalf("登录后应可以访问管理接口: %d %s", rec.Code, rec.Body)
+	} else {
+		var res struct {
+			Matched   bool   `json:"matched"`
+			NginxPath string `json:"nginx_path"`
+			Nodes     []struct {
+				Url string `json:"url"`
+			} `json:"nodes"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &res)
+		if !res.Matched || res.NginxPath != "/video/data/a.mkv" || len(res.Nodes) != 2 || res.Nodes[0].Url != "http://127.0.0.2:1/video/data/a.mkv" {
+			t.Errorf("路径映射结果不正确: %s", rec.Body)
+		}
+	}
+
+	// 通过登录会话修改数据时需携带 X-Requested-With
+	if rec := serveAdmin(http.MethodPost, "/ge2o/api/nodes/node-1/drain", "", cookie); rec.Code != http.StatusForbidden {
+		t.Errorf("缺少 X-Requested-With 时应响应 403, 实际: %d", rec.Code)
+	}
+	cookie["X-Requested-With"] = "test"
+	if rec := serveAdmin(http.MethodPost, "/ge2o/api/nodes/node-1/drain", "", cookie); rec.Code != http.StatusOK {
+		t.Errorf("排空节点失败: %d %s", rec.Code, rec.Body)
+	}
+
+	// 令牌修改后登录会话失效
+	config.C().AdminApi.Token = "fedcba9876543210"
+	if rec := serveAdmin(http.MethodGet, "/ge2o/api/audit", "", cookie); rec.Code != http.StatusUnauthorized {
+		t.Errorf("令牌修改后登录会话应失效, 实际: %d", rec.Code)
+	}
+
+	rec = serveAdmin(http.MethodPost, "/ge2o/api/logout", "", cookie)
+	if cookies := rec.Result().Cookies(); rec.Code != http.StatusOK || len(cookies) != 1 || cookies[0].MaxAge >= 0 {
+		t.Errorf("退出登录应清除 cookie: %d", rec.Code)
+	}
+}
+
+func TestIsAdminPath(t *testing.T) {
+	for path, want := range map[string]bool{
+		"/ge2o/api":                  true,
+		"/ge2o/api/nodes":            true,
+		"/ge2o/admin/":               true,
+		"/ge2o/apix":                 false,
+		"/emby/Items/1/PlaybackInfo": false,
+		"/ge2o/administrator/app.js": false,
+	} {
+		if got := isAdminPath(path); got != want {
+			t.Errorf("isAdminPath(%q) = %v, 期望 %v", path, got, want)
+		}
+	}
+}
+
+func TestAdminTokenRequired(t *testing.T) {
+	initTestAdminApi(t)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/api/logs", adminTokenRequired(), func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+
+	serve := func(token string) int {
+		req := httptest.NewRequest(http.MethodGet, "/api/logs", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if code := serve(""); code != http.StatusUnauthorized {
+		t.Errorf("未携带令牌时应响应 401, 实际: %d", code)
+	}
+	if code := serve(testAdminToken); code != http.StatusOK {
+		t.Errorf("令牌正确时应放行, 实际: %d", code)
+	}
+	config.C().AdminApi.Token = ""
+	if code := serve(""); code != http.StatusUnauthorized {
+		t.Errorf("未配置令牌时应拒绝所有请求, 实际: %d", code)
+	}
+}
+
+func TestConfigAdminHandler(t *testing.T) {
+	initTestAdminApi(t)
+	token := map[string]string{"X-Admin-Token": testAdminToken}
+
+	for _, path := range []string{"/ge2o/api/config/history/abc", "/ge2o/api/config/rollback/abc"} {
+		method := http.MethodGet
+		if strings.Contains(path, "rollback") {
+			method = http.MethodPost
+		}
+		if rec := serveAdmin(method, path, "", token); rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "id 参数错误") {
+			t.Errorf("%s id 错误时应响应 400, 实际: %d %s", path, rec.Code, rec.Body)
+		}
+	}
+
+	got := reloadResult(&config.ReloadEvent{})
+	if data, _ := json.Marshal(got); string(data) != `{"changed":[],"restart_required":[]}` {
+		t.Errorf("没有变更时应输出空数组, 实际: %s", data)
+	}
+}
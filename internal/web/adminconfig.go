@@ -0,0 +1,127 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/config"
+)
+
+// configAdminHandler 配置管理接口, 包括重载、查看生效的配置、配置历史及回滚, 需挂载在鉴权之后
+//
+//	GET  {prefix}/effective       当前生效的配置, 敏感配置脱敏显示
+//	POST {prefix}/reload          重新读取配置文件并应用
+//	GET  {prefix}/history         配置修改历史, 差异中的敏感配置脱敏显示
+//	GET  {prefix}/history/{id}    单条历史及修改前的配置文件, 敏感配置脱敏显示
+//	POST {prefix}/rollback/{id}   回滚到指定历史修改前的配置并立即生效
+//
+// actor 根据请求生成记录到配置历史中的修改人
+func configAdminHandler(prefix string, actor func(r *http.Request) string) http.Handler {
+	prefix = strings.TrimSuffix(prefix, "/")
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET "+prefix+"/effective", func(w http.ResponseWriter, r *http.Request) {
+		data, err := config.EffectiveYaml()
+		if err != nil {
+			writeAdminJson(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		w.Header().Set("Content-Type", "application/yaml; charset=utf-8")
+		w.Write(data)
+	})
+
+	mux.HandleFunc("POST "+prefix+"/reload", func(w http.ResponseWriter, r *http.Request) {
+		e, err := config.Reload()
+		if err != nil {
+			writeAdminJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		writeAdminJson(w, http.StatusOK, reloadResult(e))
+	})
+
+	mux.HandleFunc("GET "+prefix+"/history", func(w http.ResponseWriter, r *http.Request) {
+		list, err := config.ListHistory()
+		if err != nil {
+			writeAdminJson(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeAdminJson(w, http.StatusOK, list)
+	})
+
+	mux.HandleFunc("GET "+prefix+"/history/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			writeAdminJson(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("id 参数错误: %s", r.PathValue("id"))})
+			return
+		}
+		e, snapshot, err := config.GetHistory(id)
+		if err != nil {
+			writeAdminJson(w, historyStatus(err), map[string]string{"error": err.Error()})
+			return
+		}
+		writeAdminJson(w, http.StatusOK, map[string]any{"entry": e, "snapshot": config.MaskSnapshot(snapshot)})
+	})
+
+	mux.HandleFunc("POST "+prefix+"/rollback/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			writeAdminJson(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("id 参数错误: %s", r.PathValue("id"))})
+			return
+		}
+		e, err := config.Rollback(id, config.Change{Actor: actor(r)})
+		if err != nil {
+			writeAdminJson(w, historyStatus(err), map[string]string{"error": err.Error()})
+			return
+		}
+		writeAdminJson(w, http.StatusOK, reloadResult(e))
+	})
+
+	return mux
+}
+
+// requestActor 根据管理接口请求生成修改人标识
+//
+// 携带令牌时记录脱敏后的令牌, 否则记录客户端地址
+func requestActor(r *http.Request) string {
+	if token := requestToken(r); token != "" {
+		return "api:" + config.MaskSecret(token)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "api:" + host
+}
+
+// requestToken 获取管理接口请求携带的令牌, 支持 Authorization: Bearer <token> 及 X-Admin-Token 请求头
+func requestToken(r *http.Request) string {
+	token := strings.TrimSpace(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+	if token == "" {
+		token = strings.TrimSpace(r.Header.Get("X-Admin-Token"))
+	}
+	return token
+}
+
+// reloadResult 重载结果
+func reloadResult(e *config.ReloadEvent) map[string][]string {
+	changed, restart := e.Changed, e.RestartRequired
+	if changed == nil {
+		changed = []string{}
+	}
+	if restart == nil {
+		restart = []string{}
+	}
+	return map[string][]string{"changed": changed, "restart_required": restart}
+}
+
+// historyStatus 根据配置历史相关错误确定响应码
+func historyStatus(err error) int {
+	if errors.Is(err, config.ErrHistoryNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
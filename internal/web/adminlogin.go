@@ -0,0 +1,126 @@
+package web
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/config"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/logs"
+)
+
+const (
+	// adminCookieName 管理面板登录会话的 cookie 名称
+	adminCookieName = "ge2o_admin"
+
+	// adminSessionTTL 管理面板登录会话的有效期
+	adminSessionTTL = 12 * time.Hour
+)
+
+// adminSession 管理面板登录会话
+type adminSession struct {
+	actor    string            // 记录到配置历史中的修改人
+	tokenSum [sha256.Size]byte // 登录时使用的令牌摘要, 令牌修改后会话失效
+	expires  time.Time
+}
+
+var (
+	// adminSessions 管理面板登录会话, 会话 id -> 会话, 只保存在内存中, 重启后需重新登录
+	adminSessions   = make(map[string]adminSession)
+	adminSessionsMu sync.Mutex
+)
+
+// adminLogin 使用访问令牌登录管理面板, 请求体: {"token": "..."}, 成功后设置会话 cookie
+func adminLogin(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil {
+		writeAdminJson(w, http.StatusBadRequest, map[string]string{"error": "请求体格式错误"})
+		return
+	}
+	token := config.C().AdminApi.Token
+	if token == "" {
+		writeAdminJson(w, http.StatusBadRequest, map[string]string{"error": "未配置 admin-api.token, 请使用客户端证书访问"})
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(req.Token), []byte(token)) != 1 {
+		logs.Warn("管理面板登录失败: %s", r.RemoteAddr)
+		writeAdminJson(w, http.StatusUnauthorized, map[string]string{"error": "令牌错误"})
+		return
+	}
+
+	id, err := newSessionId()
+	if err != nil {
+		writeAdminJson(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	actor := "web:" + config.MaskSecret(token)
+	now := time.Now()
+	adminSessionsMu.Lock()
+	for key, s := range adminSessions {
+		if now.After(s.expires) {
+			delete(adminSessions, key)
+		}
+	}
+	adminSessions[id] = adminSession{actor: actor, tokenSum: sha256.Sum256([]byte(token)), expires: now.Add(adminSessionTTL)}
+	adminSessionsMu.Unlock()
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     adminCookieName,
+		Value:    id,
+		Path:     "/ge2o/",
+		MaxAge:   int(adminSessionTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+	logs.Info("管理面板登录成功: %s %s", actor, r.RemoteAddr)
+	writeAdminJson(w, http.StatusOK, map[string]string{"actor": actor})
+}
+
+// adminLogout 退出管理面板登录
+func adminLogout(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(adminCookieName); err == nil {
+		adminSessionsMu.Lock()
+		delete(adminSessions, cookie.Value)
+		adminSessionsMu.Unlock()
+	}
+	http.SetCookie(w, &http.Cookie{Name: adminCookieName, Path: "/ge2o/", MaxAge: -1, HttpOnly: true, SameSite: http.SameSiteStrictMode})
+	writeAdminJson(w, http.StatusOK, map[string]string{})
+}
+
+// sessionActor 校验管理面板登录会话, 返回登录时记录的修改人
+func sessionActor(r *http.Request) (string, bool) {
+	cookie, err := r.Cookie(adminCookieName)
+	if err != nil || cookie.Value == "" {
+		return "", false
+	}
+	adminSessionsMu.Lock()
+	defer adminSessionsMu.Unlock()
+	s, ok := adminSessions[cookie.Value]
+	if !ok {
+		return "", false
+	}
+	token := config.C().AdminApi.Token
+	if time.Now().After(s.expires) || token == "" || sha256.Sum256([]byte(token)) != s.tokenSum {
+		delete(adminSessions, cookie.Value)
+		return "", false
+	}
+	return s.actor, true
+}
+
+// newSessionId 生成随机的会话 id
+func newSessionId() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("生成会话 id 失败: %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -1,25 +1,32 @@
 package web
 
 import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/config"
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/service/authserver"
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/service/node"
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/service/userkey"
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/service/videoauth"
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/logs"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/reqids"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/sockets"
 
 	"github.com/gin-gonic/gin"
 )
 
 var (
-	authServerInstance  *authserver.Server
-	videoAuthService    *videoauth.VideoAuthService
-	accessLogger        *authserver.AccessLogger
+	authServerInstance *authserver.Server
+	videoAuthService   *videoauth.VideoAuthService
+	accessLogger       *authserver.AccessLogger
 )
 
 // ListenAuthServer 启动鉴权服务器
-func ListenAuthServer(cache *userkey.Cache, healthChecker *node.HealthChecker, nodeSelector *node.Selector) error {
-	if !config.C.Auth.EnableAuthServer {
+func ListenAuthServer(cache *userkey.Cache, resolver *userkey.Resolver, healthChecker *node.HealthChecker, nodeSelector *node.Selector) error {
+	if !config.C().Auth.EnableAuthServer {
 		logs.Info("鉴权服务器未启用")
 		return nil
 	}
@@ -27,24 +34,27 @@ func ListenAuthServer(cache *userkey.Cache, healthChecker *node.HealthChecker, n
 	// 初始化访问日志记录器
 	var err error
 	accessLogger, err = authserver.NewAccessLogger(
-		config.C.Auth.AuthServerLogPath,
-		config.C.Auth.EnableAuthServerLog,
+		config.C().Auth,
+		filepath.Join(config.BasePath, "auth-stats.json"),
 	)
 	if err != nil {
 		logs.Error("初始化访问日志记录器失败: %v", err)
 		return err
 	}
 
-	// 初始化鉴权服务器
-	authServerInstance = authserver.NewServer(cache, config.C.Emby, accessLogger)
-
 	// 初始化视频鉴权服务（传入健康检查器和节点选择器，用于故障转移）
-	videoAuthService = videoauth.NewVideoAuthService(cache, config.C.Emby, healthChecker, nodeSelector)
+	videoAuthService = videoauth.NewVideoAuthService(cache, config.C().Emby, config.C().Auth.VideoAuthSecret, healthChecker, nodeSelector)
+
+	// 初始化鉴权服务器（重定向地址使用视频鉴权服务签名）
+	authServerInstance = authserver.NewServer(cache, config.C().Emby, accessLogger, healthChecker, videoAuthService)
 
 	// 创建 Gin 引擎
 	r := gin.New()
+	r.ContextWithFallback = true // 使 *gin.Context 可以直接作为请求的 context 使用
 	r.Use(gin.Recovery())
+	r.Use(reqids.Middleware())
 	r.Use(CustomLogger("8097")) // 鉴权服务端口
+	r.Use(resolver.Middleware(authserver.ExtractApiKey))
 
 	// 注册路由
 	api := r.Group("/api")
@@ -58,6 +68,9 @@ func ListenAuthServer(cache *userkey.Cache, healthChecker *node.HealthChecker, n
 		// 统计接口
 		api.GET("/stats", authServerInstance.HandleStats)
 
+		// 访问日志查询及导出接口, 需携带管理接口访问令牌
+		api.GET("/logs", adminTokenRequired(), authServerInstance.HandleLogs)
+
 		// 视频鉴权接口（方案1：应用层签名）
 		api.GET("/video-auth/*path", videoAuthService.HandleVideoAuth)
 		api.HEAD("/video-auth/*path", videoAuthService.HandleVideoAuth)
@@ -69,26 +82,36 @@ func ListenAuthServer(cache *userkey.Cache, healthChecker *node.HealthChecker, n
 		// 健康检查
 		api.GET("/health", func(c *gin.Context) {
 			c.JSON(200, gin.H{
-				"status": "ok",
+				"status":  "ok",
 				"service": "auth-server",
 			})
 		})
 	}
 
 	// 启动服务
-	port := config.C.Auth.AuthServerPort
+	port := config.C().Auth.AuthServerPort
+	ln, err := sockets.Listen("0.0.0.0:" + port)
+	if err != nil {
+		accessLogger.Close()
+		accessLogger = nil
+		return fmt.Errorf("监听鉴权服务端口失败: %v", err)
+	}
+	srv := &http.Server{Handler: r.Handler()}
+	trackServer(srv)
 	logs.Success("鉴权服务器启动在端口: %s", port)
 
-	errChan := make(chan error, 1)
 	go func() {
-		err := r.Run("0.0.0.0:" + port)
-		errChan <- err
+		if err := srv.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+			logs.Error("鉴权服务器异常: %v", err)
+		}
 	}()
 
 	return nil
 }
 
-// CloseAuthServer 关闭鉴权服务器
+// CloseAuthServer 关闭鉴权服务器的访问日志, 写入缓冲中的日志并保存统计
+//
+// 应在 Shutdown 等待请求完成后调用
 func CloseAuthServer() error {
 	if accessLogger != nil {
 		return accessLogger.Close()
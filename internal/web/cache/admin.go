@@ -0,0 +1,368 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// secretParams 展示缓存请求地址时需要去除的鉴权参数
+var secretParams = []string{"api_key", "X-Emby-Token", "X-Emby-Authorization", "token", "sign"}
+
+// stripSecretParams 去除地址中的鉴权参数
+func stripSecretParams(uri string) string {
+	idx := strings.Index(uri, "?")
+	if idx == -1 {
+		return uri
+	}
+	q, err := url.ParseQuery(uri[idx+1:])
+	if err != nil {
+		return uri[:idx]
+	}
+	for key := range q {
+		for _, secret := range secretParams {
+			if strings.EqualFold(key, secret) {
+				q.Del(key)
+			}
+		}
+	}
+	if len(q) == 0 {
+		return uri[:idx]
+	}
+	return uri[:idx+1] + q.Encode()
+}
+
+// Entry 缓存元数据
+type Entry struct {
+	Key      string    `json:"key"`                 // 缓存 key
+	URI      string    `json:"uri"`                 // 请求地址, 已去除鉴权参数
+	Code     int       `json:"code"`                // 响应码
+	Location string    `json:"location,omitempty"`  // 重定向地址, 已去除鉴权参数
+	Space    string    `json:"space,omitempty"`     // 缓存空间
+	SpaceKey string    `json:"space_key,omitempty"` // 缓存空间 key
+	Node     string    `json:"node,omitempty"`      // 响应指向的节点 (如串流重定向)
+	Size     int64     `json:"size"`                // 响应体大小
+	Created  time.Time `json:"created"`             // 缓存时间
+	Expired  time.Time `json:"expired"`             // 过期时间
+	InMemory bool      `json:"in_memory"`           // 是否在内存中
+	OnDisk   bool      `json:"on_disk"`             // 是否在磁盘中
+}
+
+// entryOfCache 根据内存缓存生成元数据
+func entryOfCache(rc *respCache) Entry {
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+	return Entry{
+		Key:      rc.cacheKey,
+		URI:      rc.uri,
+		Code:     rc.code,
+		Location: stripSecretParams(rc.header.header.Get("Location")),
+		Space:    rc.header.space,
+		SpaceKey: rc.header.spaceKey,
+		Node:     rc.header.node,
+		Size:     int64(len(rc.body)),
+		Created:  time.UnixMilli(rc.created),
+		Expired:  time.UnixMilli(rc.expired),
+		InMemory: true,
+	}
+}
+
+// entryOfMeta 根据磁盘缓存元数据生成元数据
+func entryOfMeta(meta *diskMeta) Entry {
+	return Entry{
+		Key:      meta.Key,
+		URI:      meta.URI,
+		Code:     meta.Code,
+		Location: stripSecretParams(meta.Header.Get("Location")),
+		Space:    meta.Space,
+		SpaceKey: meta.SpaceKey,
+		Node:     meta.Node,
+		Size:     meta.Size,
+		Created:  time.UnixMilli(meta.Created),
+		Expired:  time.UnixMilli(meta.Expired),
+		OnDisk:   true,
+	}
+}
+
+// Filter 缓存筛选条件, 多个条件同时生效
+type Filter struct {
+	Key      string         // 缓存 key
+	Space    string         // 缓存空间
+	SpaceKey string         // 缓存空间 key
+	ItemId   string         // Emby 项目 id, 匹配 PlaybackInfo、串流、字幕等请求
+	Node     string         // 响应指向的节点名称
+	URI      *regexp.Regexp // 请求地址正则
+}
+
+// ParseFilter 从请求参数中解析筛选条件
+//
+// 参数: key, space, space_key, item_id, node, uri
+func ParseFilter(q url.Values) (Filter, error) {
+	f := Filter{
+		Key:      strings.TrimSpace(q.Get("key")),
+		Space:    strings.TrimSpace(q.Get("space")),
+		SpaceKey: strings.TrimSpace(q.Get("space_key")),
+		ItemId:   strings.TrimSpace(q.Get("item_id")),
+		Node:     strings.TrimSpace(q.Get("node")),
+	}
+	if uri := q.Get("uri"); uri != "" {
+		reg, err := regexp.Compile(uri)
+		if err != nil {
+			return f, fmt.Errorf("uri 正则表达式错误: %v", err)
+		}
+		f.URI = reg
+	}
+	return f, nil
+}
+
+// Empty 是否没有设置任何条件
+func (f Filter) Empty() bool {
+	return f.Key == "" && f.Space == "" && f.SpaceKey == "" && f.ItemId == "" && f.Node == "" && f.URI == nil
+}
+
+// Match 判断缓存是否满足筛选条件
+func (f Filter) Match(e Entry) bool {
+	if f.Key != "" && f.Key != e.Key {
+		return false
+	}
+	if f.Space != "" && f.Space != e.Space {
+		return false
+	}
+	if f.SpaceKey != "" && f.SpaceKey != e.SpaceKey {
+		return false
+	}
+	if f.ItemId != "" && !matchItemId(e, f.ItemId) {
+		return false
+	}
+	if f.Node != "" && f.Node != e.Node {
+		return false
+	}
+	if f.URI != nil && !f.URI.MatchString(e.URI) {
+		return false
+	}
+	return true
+}
+
+// matchItemId 判断缓存是否属于指定的 Emby 项目
+//
+// 请求路径中包含项目 id 片段 (如 /Items/{id}/PlaybackInfo、/videos/{id}/stream、
+// /Videos/{id}/{msId}/Subtitles/...), 或缓存空间 key 以项目 id 开头
+func matchItemId(e Entry, itemId string) bool {
+	if e.SpaceKey == itemId || strings.HasPrefix(e.SpaceKey, itemId+"_") {
+		return true
+	}
+	path := e.URI
+	if idx := strings.Index(path, "?"); idx != -1 {
+		path = path[:idx]
+	}
+	for _, seg := range strings.Split(path, "/") {
+		if strings.EqualFold(seg, itemId) {
+			return true
+		}
+	}
+	return false
+}
+
+// ListEntries 列出满足条件的缓存, 内存中的缓存按最近使用排序, 之后为仅存在于磁盘的缓存
+//
+// limit 小于等于 0 时不限制个数
+func ListEntries(f Filter, limit int) []Entry {
+	res := make([]Entry, 0)
+	seen := make(map[string]int)
+	for _, rc := range store.list() {
+		e := entryOfCache(rc)
+		if !f.Match(e) {
+			continue
+		}
+		if disk != nil {
+			_, e.OnDisk = disk.meta(e.Key)
+		}
+		seen[e.Key] = len(res)
+		res = append(res, e)
+	}
+
+	if disk != nil {
+		metas := disk.metas()
+		sort.Slice(metas, func(i, j int) bool { return metas[i].Created > metas[j].Created })
+		for _, meta := range metas {
+			if _, ok := seen[meta.Key]; ok {
+				continue
+			}
+			if e := entryOfMeta(meta); f.Match(e) {
+				res = append(res, e)
+			}
+		}
+	}
+
+	if limit > 0 && len(res) > limit {
+		res = res[:limit]
+	}
+	return res
+}
+
+// GetEntry 获取单个缓存的元数据
+func GetEntry(cacheKey string) (Entry, bool) {
+	if rc, ok := store.peek(cacheKey); ok {
+		e := entryOfCache(rc)
+		if disk != nil {
+			_, e.OnDisk = disk.meta(cacheKey)
+		}
+		return e, true
+	}
+	if disk != nil {
+		if meta, ok := disk.meta(cacheKey); ok {
+			return entryOfMeta(meta), true
+		}
+	}
+	return Entry{}, false
+}
+
+// Purge 清除满足条件的缓存 (内存及磁盘), 返回清除的缓存个数
+//
+// 条件为空时清除所有缓存
+func Purge(f Filter) int {
+	removed := make(map[string]struct{})
+	store.removeIf(func(rc *respCache) bool {
+		if f.Match(entryOfCache(rc)) {
+			removed[rc.cacheKey] = struct{}{}
+			return true
+		}
+		return false
+	}, EvictPurge)
+
+	if disk != nil {
+		for _, meta := range disk.metas() {
+			if _, ok := removed[meta.Key]; ok {
+				continue
+			}
+			if f.Match(entryOfMeta(meta)) {
+				removed[meta.Key] = struct{}{}
+				disk.remove(meta.Key)
+			}
+		}
+	}
+	return len(removed)
+}
+
+// SpaceSummary 缓存空间概览
+type SpaceSummary struct {
+	Name        string `json:"name"`         // 缓存空间名称, 不属于任何空间的缓存为 _default
+	Entries     int64  `json:"entries"`      // 内存中的缓存个数
+	Bytes       int64  `json:"bytes"`        // 内存中的响应体大小
+	Hits        uint64 `json:"hits"`         // 命中次数
+	DiskEntries int64  `json:"disk_entries"` // 磁盘中的缓存个数
+}
+
+// ListSpaces 列出所有缓存空间及缓存个数
+func ListSpaces() []SpaceSummary {
+	spaces := make(map[string]*SpaceSummary)
+	get := func(name string) *SpaceSummary {
+		if name == "" {
+			name = DefaultSpaceName
+		}
+		if s, ok := spaces[name]; ok {
+			return s
+		}
+		s := &SpaceSummary{Name: name}
+		spaces[name] = s
+		return s
+	}
+
+	for name, st := range store.stats().Spaces {
+		s := get(name)
+		s.Entries, s.Bytes, s.Hits = st.Entries, st.Bytes, st.Hits
+	}
+	if disk != nil {
+		for name, cnt := range disk.spaceCounts() {
+			get(name).DiskEntries = cnt
+		}
+	}
+
+	res := make([]SpaceSummary, 0, len(spaces))
+	for _, s := range spaces {
+		res = append(res, *s)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res
+}
+
+// AdminHandler 缓存管理接口, 挂载在 prefix 路径下
+//
+//	GET          {prefix}/stats          缓存统计
+//	GET          {prefix}/spaces         缓存空间及缓存个数
+//	GET          {prefix}/entries        按条件列出缓存, 参数: key, space, space_key, item_id, node, uri, limit (默认 100)
+//	GET          {prefix}/entries/{key}  单个缓存的元数据
+//	POST/DELETE  {prefix}/purge          按条件清除缓存, 参数同 entries, 清除全部缓存需传递 all=true
+func AdminHandler(prefix string) http.Handler {
+	prefix = strings.TrimSuffix(prefix, "/")
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET "+prefix+"/stats", func(w http.ResponseWriter, r *http.Request) {
+		writeJson(w, http.StatusOK, GetStats())
+	})
+
+	mux.HandleFunc("GET "+prefix+"/spaces", func(w http.ResponseWriter, r *http.Request) {
+		writeJson(w, http.StatusOK, ListSpaces())
+	})
+
+	mux.HandleFunc("GET "+prefix+"/entries", func(w http.ResponseWriter, r *http.Request) {
+		f, err := ParseFilter(r.URL.Query())
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		limit := 100
+		if l := r.URL.Query().Get("limit"); l != "" {
+			if limit, err = strconv.Atoi(l); err != nil {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("limit 参数错误: %s", l))
+				return
+			}
+		}
+		writeJson(w, http.StatusOK, ListEntries(f, limit))
+	})
+
+	mux.HandleFunc("GET "+prefix+"/entries/{key}", func(w http.ResponseWriter, r *http.Request) {
+		e, ok := GetEntry(r.PathValue("key"))
+		if !ok {
+			writeError(w, http.StatusNotFound, errors.New("缓存不存在"))
+			return
+		}
+		writeJson(w, http.StatusOK, e)
+	})
+
+	purge := func(w http.ResponseWriter, r *http.Request) {
+		f, err := ParseFilter(r.URL.Query())
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if f.Empty() && r.URL.Query().Get("all") != "true" {
+			writeError(w, http.StatusBadRequest, errors.New("未指定清除条件, 清除全部缓存需传递 all=true"))
+			return
+		}
+		writeJson(w, http.StatusOK, map[string]int{"purged": Purge(f)})
+	}
+	mux.HandleFunc("POST "+prefix+"/purge", purge)
+	mux.HandleFunc("DELETE "+prefix+"/purge", purge)
+
+	return mux
+}
+
+// writeJson 响应 json 数据
+func writeJson(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError 响应错误信息
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJson(w, code, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestStripSecretParams(t *testing.T) {
+	got := stripSecretParams("/videos/1/stream?api_key=abc&Static=true&X-Emby-Token=def")
+	if got != "/videos/1/stream?Static=true" {
+		t.Errorf("鉴权参数未去除: %s", got)
+	}
+	if got := stripSecretParams("/Items/1/PlaybackInfo?api_key=abc"); got != "/Items/1/PlaybackInfo" {
+		t.Errorf("鉴权参数未去除: %s", got)
+	}
+}
+
+func TestPurgeByItemId(t *testing.T) {
+	defer Purge(Filter{})
+
+	entries := []*respCache{
+		newTestCache("p1", 4, "PlaybackInfo", "1001_key"),
+		newTestCache("s1", 4, "", ""),
+		newTestCache("sub1", 4, "", ""),
+		newTestCache("other", 4, "PlaybackInfo", "2002_key"),
+	}
+	entries[0].uri = "/Items/1001/PlaybackInfo"
+	entries[1].uri = "/videos/1001/stream.mkv?Static=true"
+	entries[2].uri = "/Videos/1001/abc/Subtitles/3/Stream.srt"
+	entries[3].uri = "/Items/2002/PlaybackInfo"
+	for _, rc := range entries {
+		promote(rc)
+	}
+
+	if got := ListEntries(Filter{ItemId: "1001"}, 0); len(got) != 3 {
+		t.Errorf("按项目 id 筛选结果不正确: %+v", got)
+	}
+	if n := Purge(Filter{ItemId: "1001"}); n != 3 {
+		t.Errorf("按项目 id 清除的个数不正确: %d", n)
+	}
+	if _, ok := GetSpaceCache("PlaybackInfo", "1001_key"); ok {
+		t.Error("清除后缓存空间中不应再存在该缓存")
+	}
+	if _, ok := GetEntry("other"); !ok {
+		t.Error("其他项目的缓存不应被清除")
+	}
+}
+
+func TestAdminHandler(t *testing.T) {
+	defer Purge(Filter{})
+
+	rc := newTestCache("k1", 4, "Items", "x")
+	rc.uri = "/Users/1/Items?Limit=10"
+	promote(rc)
+
+	h := AdminHandler("/debug/cache")
+
+	// 没有条件时拒绝清除
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/debug/cache/purge", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("未指定条件时应返回 400, 实际: %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/cache/entries/k1", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"space":"Items"`) {
+		t.Errorf("查询缓存详情失败: %d %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/debug/cache/purge?uri="+url.QueryEscape(`^/Users/\d+/Items`), nil))
+	if !strings.Contains(w.Body.String(), `"purged":1`) {
+		t.Errorf("按地址正则清除失败: %s", w.Body.String())
+	}
+}
@@ -4,70 +4,57 @@ import (
 	"bytes"
 	"fmt"
 	"io"
-	"regexp"
+	"net/http"
 	"strings"
 	"time"
 
-	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/constant"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/config"
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/encrypts"
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/https"
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/logs"
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/logs/colors"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/reqids"
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/strs"
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/urls"
 
 	"github.com/gin-gonic/gin"
 )
 
-// CacheKeyIgnoreParams 忽略的请求头或者参数
-//
-// 如果请求地址包含列表中的请求头或者参数, 则不参与 cacheKey 运算
-var CacheKeyIgnoreParams = map[string]struct{}{
-	// Fileball
-	"StartTimeTicks": {}, "X-Playback-Session-Id": {},
-
-	// Emby
-	"PlaySessionId": {},
-
-	// Common
-	"Range": {}, "Host": {}, "Referrer": {}, "Connection": {},
-	"Accept": {}, "Accept-Encoding": {}, "Accept-Language": {}, "Cache-Control": {},
-	"Upgrade-Insecure-Requests": {}, "Referer": {}, "Origin": {},
-
-	// StreamMusic
-	"X-Streammusic-Audioid": {}, "X-Streammusic-Savepath": {},
-
-	// IP
-	"X-Forwarded-For": {}, "X-Real-IP": {}, "X-Real-Ip": {}, "Forwarded": {}, "Client-IP": {},
-	"True-Client-IP": {}, "CF-Connecting-IP": {}, "X-Cluster-Client-IP": {},
-	"Fastly-Client-IP": {}, "X-Client-IP": {}, "X-ProxyUser-IP": {},
-	"Via": {}, "Forwarded-For": {}, "X-From-Cdn": {},
-}
+// HitGinKey 请求由缓存直接响应时, 在 gin 上下文中写入的标记
+const HitGinKey = "cacheHit"
+
+// RuleGinKey 请求匹配的缓存规则存放在 gin 上下文中的 key
+const RuleGinKey = "cacheRule"
 
 // CacheableRouteMarker 缓存白名单
-// 只有匹配上正则表达式的路由才会被缓存
+//
+// 只有匹配上 cache.rules 中已启用规则的请求才会被缓存, 匹配的规则写入 gin 上下文
 func CacheableRouteMarker() gin.HandlerFunc {
-	cacheablePatterns := []*regexp.Regexp{
-		regexp.MustCompile(constant.Reg_PlaybackInfo),
-		regexp.MustCompile(constant.Reg_VideoSubtitles),
-		regexp.MustCompile(constant.Reg_ResourceStream),
-		regexp.MustCompile(constant.Reg_ItemDownload),
-		regexp.MustCompile(constant.Reg_ItemSyncDownload),
-		regexp.MustCompile(constant.Reg_UserItemsRandomWithLimit),
+	return func(c *gin.Context) {
+		rule := config.C().Cache.MatchRule(c.Request.RequestURI)
+		if rule == nil {
+			c.Header(HeaderKeyExpired, "-1")
+			return
+		}
+		c.Set(RuleGinKey, rule)
 	}
+}
 
-	return func(c *gin.Context) {
-		for _, pattern := range cacheablePatterns {
-			if pattern.MatchString(c.Request.RequestURI) {
-				return
-			}
+// getRule 获取请求匹配的缓存规则, 未经过 CacheableRouteMarker 时返回 nil
+func getRule(c *gin.Context) *config.CacheRule {
+	if v, ok := c.Get(RuleGinKey); ok {
+		if rule, ok := v.(*config.CacheRule); ok {
+			return rule
 		}
-		c.Header(HeaderKeyExpired, "-1")
 	}
+	return nil
 }
 
 // RequestCacher 请求缓存中间件
-func RequestCacher() gin.HandlerFunc {
+//
+// 相同的请求 (cacheKey 相同) 同时只会有一个请求上游, 其余请求等待并复用其响应;
+// 缓存即将过期时继续响应旧缓存, 同时使用 engine 在后台重新请求以刷新缓存
+func RequestCacher(engine http.Handler) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 1 判断请求是否需要缓存
 		if c.Writer.Header().Get(HeaderKeyExpired) == "-1" {
@@ -75,53 +62,98 @@ func RequestCacher() gin.HandlerFunc {
 		}
 
 		// 2 计算 cache key
-		cacheKey, err := calcCacheKey(c)
+		rule := getRule(c)
+		cacheKey, err := calcCacheKey(c, rule)
 		if err != nil {
-			logs.Warn("cache key 计算异常: %v, 跳过缓存", err)
+			logs.Ctx(c).Warn("cache key 计算异常: %v, 跳过缓存", err)
 			// 如果没有调用 Abort, Gin 会自动继续调用处理器链
 			return
 		}
 
-		// 3 尝试获取缓存
-		if rc, ok := getCache(cacheKey); ok {
-			if https.IsRedirectCode(rc.code) {
-				// 适配重定向请求
-				c.Redirect(rc.code, rc.header.header.Get("Location"))
-			} else {
-				c.Status(rc.code)
-				https.CloneHeader(c.Writer, rc.header.header)
-				c.Writer.Write(rc.body)
+		// 3 尝试获取缓存, 后台刷新请求直接请求上游
+		var result *respCache
+		if !IsRefresh(c.Request.Context()) {
+			if rc, ok := getCache(cacheKey); ok && nodeDown(rc) {
+				// 缓存指向的节点已下线, 重新请求上游选择节点
+				store.remove(rc, EvictNodeDown)
+			} else if ok {
+				cacheRequestTotal.With("hit").Inc()
+				if needRefresh(rc) {
+					body, _ := io.ReadAll(c.Request.Body)
+					refreshInBackground(engine, cacheKey, c, body)
+				}
+				writeCache(c, rc)
+				return
 			}
-			c.Abort()
-			return
+			cacheRequestTotal.With("miss").Inc()
+
+			// 4 合并相同的请求
+			call, leader := joinFlight(cacheKey)
+			if leader {
+				defer func() { finishFlight(cacheKey, call, result) }()
+			} else if rc, ok := call.wait(c.Request.Context()); ok {
+				cacheRequestTotal.With("coalesced").Inc()
+				writeCache(c, rc)
+				return
+			}
+			// 首个请求的响应不可缓存或等待超时, 自行请求上游
 		}
 
-		// 4 使用自定义的响应器
+		// 5 使用自定义的响应器
 		customWriter := &respCacheWriter{body: &bytes.Buffer{}, ResponseWriter: c.Writer}
 		c.Writer = customWriter
 
-		// 5 执行请求处理器
+		// 6 执行请求处理器
 		c.Next()
 
-		// 6 不缓存错误请求
+		// 7 不缓存错误请求
 		if https.IsErrorStatus(c.Writer.Status()) {
 			return
 		}
 
-		// 7 刷新缓存
+		// 8 刷新缓存
 		header := c.Writer.Header()
 		respHeader := respHeader{
 			expired:  header.Get(HeaderKeyExpired),
 			space:    header.Get(HeaderKeySpace),
 			spaceKey: header.Get(HeaderKeySpaceKey),
+			node:     header.Get(HeaderKeyNode),
 			header:   header.Clone(),
 		}
-		defer header.Del(HeaderKeyExpired)
-		defer header.Del(HeaderKeySpace)
-		defer header.Del(HeaderKeySpaceKey)
+		for _, key := range []string{HeaderKeyExpired, HeaderKeySpace, HeaderKeySpaceKey, HeaderKeyNode} {
+			respHeader.header.Del(key)
+			defer header.Del(key)
+		}
+		// 请求 id 属于当前请求, 命中缓存时由中间件重新写入
+		respHeader.header.Del(reqids.Header)
+
+		rc := newRespCache(cacheKey, c.Request.URL.RequestURI(), c.Writer.Status(), append([]byte(nil), customWriter.body.Bytes()...), respHeader, rule)
+		if rc == nil {
+			return
+		}
+		result = rc
+		go putCache(c.Request.Context(), rc)
+	}
+}
 
-		go putCache(cacheKey, c, append([]byte(nil), customWriter.body.Bytes()...), respHeader)
+// writeCache 使用缓存响应请求
+func writeCache(c *gin.Context, rc *respCache) {
+	code, body, header := rc.Code(), rc.BodyBytes(), rc.Headers()
+	cacheHitBytesTotal.With().Add(float64(len(body)))
+	c.Set(HitGinKey, true)
+	if https.IsRedirectCode(code) {
+		// 适配重定向请求
+		c.Redirect(code, header.Get("Location"))
+	} else {
+		c.Status(code)
+		https.CloneHeader(c.Writer, header)
+		if id := reqids.Get(c); id != "" {
+			// 升级前写入磁盘的缓存可能带有旧请求的 id
+			c.Header(reqids.Header, id)
+		}
+		c.Writer.Write(body)
 	}
+	c.Abort()
 }
 
 // Duration 将一个标准的时间转换成适用于缓存时间的字符串
@@ -139,12 +171,17 @@ func WaitingForHandleChan() {
 //
 // 计算方式: 取出 请求方法, 请求路径, 请求体, 请求头 转换成字符串之后字典排序,
 // 再进行 Md5Hash
-func calcCacheKey(c *gin.Context) (string, error) {
+//
+// 参与计算的参数及请求头由缓存规则决定, rule 为空时使用默认忽略列表之外的全部参数及请求头
+func calcCacheKey(c *gin.Context, rule *config.CacheRule) (string, error) {
 	method := c.Request.Method
 
+	ignored := rule.IgnoredParams()
 	q := c.Request.URL.Query()
-	for key := range CacheKeyIgnoreParams {
-		q.Del(key)
+	for key := range q {
+		if containsFold(ignored, key) {
+			q.Del(key)
+		}
 	}
 	c.Request.URL.RawQuery = q.Encode()
 	uri := c.Request.URL.String()
@@ -158,9 +195,16 @@ func calcCacheKey(c *gin.Context) (string, error) {
 		body = string(bodyBytes)
 		c.Request.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
 	}
+
+	scope := resolveScope(c, rule)
+	keyQuery := filterParams(q, rule, scope).Encode()
+
 	header := strings.Builder{}
 	for key, values := range c.Request.Header {
-		if _, ok := CacheKeyIgnoreParams[key]; ok {
+		if containsFold(ignored, key) {
+			continue
+		}
+		if !keepHeader(key, rule, scope) {
 			continue
 		}
 		header.WriteString(key)
@@ -168,11 +212,14 @@ func calcCacheKey(c *gin.Context) (string, error) {
 		header.WriteString(strings.Join(values, "|"))
 		header.WriteString(";")
 	}
+	if scope.userId != "" {
+		header.WriteString(scopeUserKey + "=" + scope.userId + ";")
+	}
 
 	headerStr := header.String()
-	preEnc := strs.Sort(c.Request.URL.RawQuery + body + headerStr)
+	preEnc := strs.Sort(keyQuery + body + headerStr)
 	if headerStr != "" {
-		logs.Tip("headers to encode cacheKey: %s", colors.ToYellow(headerStr))
+		logs.Ctx(c).Tip("headers to encode cacheKey: %s", colors.ToYellow(headerStr))
 	}
 
 	// 为防止字典排序后, 不同的 uri 冲突, 这里在排序完的字符串前再加上原始的 uri
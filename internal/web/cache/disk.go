@@ -0,0 +1,469 @@
+package cache
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/config"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/logs"
+)
+
+const (
+	// diskFileExt 磁盘缓存文件后缀
+	diskFileExt = ".cache"
+
+	// diskJobChanSize 磁盘写入任务通道大小
+	diskJobChanSize = 1024
+)
+
+// disk 磁盘缓存, 未启用时为 nil
+var disk *diskStore
+
+// InitDisk 初始化磁盘缓存
+//
+// 已有的缓存文件在后台扫描, 扫描期间的请求视为未命中
+func InitDisk(cfg *config.CacheDisk) error {
+	if cfg == nil || !cfg.Enable {
+		return nil
+	}
+	d, err := openDiskStore(cfg.Path(), cfg.MaxBytes(), cfg.MinTTL)
+	if err != nil {
+		return err
+	}
+	disk = d
+	logs.Success("磁盘缓存已启用，缓存目录: %s", cfg.Path())
+	return nil
+}
+
+// FlushDisk 等待排队中的磁盘缓存写入完成, 用于退出前保证缓存文件完整
+func FlushDisk() {
+	if disk != nil {
+		disk.flush()
+	}
+}
+
+// diskMeta 磁盘缓存元数据, 以 json 形式写在缓存文件的第一行, 之后为响应体
+type diskMeta struct {
+	Key      string      `json:"key"`
+	URI      string      `json:"uri,omitempty"`
+	Code     int         `json:"code"`
+	Created  int64       `json:"created"`
+	Expired  int64       `json:"expired"`
+	Space    string      `json:"space,omitempty"`
+	SpaceKey string      `json:"space_key,omitempty"`
+	Node     string      `json:"node,omitempty"`
+	Header   http.Header `json:"header,omitempty"`
+	Size     int64       `json:"size"`
+}
+
+// diskJob 磁盘缓存任务, 由单独的 goroutine 顺序执行
+type diskJob struct {
+	meta   *diskMeta
+	body   []byte
+	remove string        // 不为空时表示删除该 key 的缓存
+	done   chan struct{} // 不为空时表示等待之前的任务执行完毕
+}
+
+// diskStore 磁盘缓存存储
+//
+// 索引只保存元数据, 响应体在命中时才从磁盘读取
+type diskStore struct {
+	dir      string
+	maxBytes int64
+	minTTL   time.Duration
+
+	mu     sync.RWMutex
+	index  map[string]*diskMeta         // cacheKey -> 元数据
+	spaces map[string]map[string]string // 缓存空间 -> 空间 key -> cacheKey
+	bytes  int64
+
+	hits  atomic.Uint64
+	ready atomic.Bool
+	jobs  chan diskJob
+}
+
+// openDiskStore 打开磁盘缓存目录, 并在后台扫描已有的缓存文件
+//
+// 缓存的重定向地址及响应中可能携带用户的 api_key, 目录及文件只允许当前用户访问
+func openDiskStore(dir string, maxBytes int64, minTTL time.Duration) (*diskStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("创建磁盘缓存目录失败: %v", err)
+	}
+	d := &diskStore{
+		dir:      dir,
+		maxBytes: maxBytes,
+		minTTL:   minTTL,
+		index:    make(map[string]*diskMeta),
+		spaces:   make(map[string]map[string]string),
+		jobs:     make(chan diskJob, diskJobChanSize),
+	}
+	go d.loop()
+	return d, nil
+}
+
+// loop 先扫描已有的缓存文件, 再顺序执行写入和删除任务
+func (d *diskStore) loop() {
+	start := time.Now()
+	d.scan()
+	d.ready.Store(true)
+	d.mu.RLock()
+	logs.Info("磁盘缓存加载完成，缓存数: %d，大小: %d KB，耗时: %v", len(d.index), d.bytes/1024, time.Since(start))
+	d.mu.RUnlock()
+
+	for job := range d.jobs {
+		switch {
+		case job.done != nil:
+			close(job.done)
+		case job.remove != "":
+			d.removeFile(job.remove)
+		default:
+			if err := d.writeFile(job.meta, job.body); err != nil {
+				logs.Warn("写入磁盘缓存失败: %v", err)
+				continue
+			}
+			d.shrink()
+		}
+	}
+}
+
+// scan 扫描缓存目录, 只读取元数据建立索引, 同时清理过期及损坏的文件
+func (d *diskStore) scan() {
+	nowMillis := time.Now().UnixMilli()
+	filepath.WalkDir(d.dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return nil
+		}
+		if !strings.HasSuffix(path, diskFileExt) {
+			// 上次异常退出残留的临时文件
+			if strings.HasSuffix(path, ".tmp") {
+				os.Remove(path)
+			}
+			return nil
+		}
+		meta, err := readDiskMeta(path)
+		if err != nil || nowMillis > meta.Expired || d.path(meta.Key) != path {
+			os.Remove(path)
+			return nil
+		}
+		d.mu.Lock()
+		d.indexLocked(meta)
+		d.mu.Unlock()
+		return nil
+	})
+	d.shrink()
+}
+
+// readDiskMeta 读取缓存文件的元数据
+func readDiskMeta(path string) (*diskMeta, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	line, err := bufio.NewReader(f).ReadBytes('\n')
+	if err != nil {
+		return nil, err
+	}
+	var meta diskMeta
+	if err := json.Unmarshal(line, &meta); err != nil {
+		return nil, err
+	}
+	if meta.Key == "" {
+		return nil, fmt.Errorf("缓存文件缺少 key: %s", path)
+	}
+	return &meta, nil
+}
+
+// path 缓存文件路径, 按 key 前两位分目录存放
+func (d *diskStore) path(key string) string {
+	sub := key
+	if len(sub) > 2 {
+		sub = sub[:2]
+	}
+	return filepath.Join(d.dir, sub, key+diskFileExt)
+}
+
+// save 将缓存异步写入磁盘, 剩余有效期不足 minTTL 的缓存不写入
+func (d *diskStore) save(rc *respCache) {
+	rc.mu.RLock()
+	meta := &diskMeta{
+		Key:      rc.cacheKey,
+		URI:      rc.uri,
+		Code:     rc.code,
+		Created:  rc.created,
+		Expired:  rc.expired,
+		Space:    rc.header.space,
+		SpaceKey: rc.header.spaceKey,
+		Node:     rc.header.node,
+		Header:   rc.header.header.Clone(),
+		Size:     int64(len(rc.body)),
+	}
+	body := rc.body
+	rc.mu.RUnlock()
+
+	if time.Until(time.UnixMilli(meta.Expired)) < d.minTTL {
+		return
+	}
+	select {
+	case d.jobs <- diskJob{meta: meta, body: body}:
+	default:
+		logs.Debug("磁盘缓存写入队列已满, 跳过: %s", meta.Key)
+	}
+}
+
+// remove 删除磁盘缓存
+func (d *diskStore) remove(cacheKey string) {
+	d.mu.RLock()
+	_, ok := d.index[cacheKey]
+	d.mu.RUnlock()
+	if !ok && d.ready.Load() {
+		return
+	}
+	job := diskJob{remove: cacheKey}
+	select {
+	case d.jobs <- job:
+	default:
+		// 删除任务不能丢弃, 队列已满时异步等待
+		go func() { d.jobs <- job }()
+	}
+}
+
+// flush 等待已提交的任务执行完毕
+func (d *diskStore) flush() {
+	done := make(chan struct{})
+	d.jobs <- diskJob{done: done}
+	<-done
+}
+
+// lookup 从磁盘中读取缓存
+func (d *diskStore) lookup(cacheKey string) (*respCache, bool) {
+	if !d.ready.Load() {
+		return nil, false
+	}
+	d.mu.RLock()
+	meta, ok := d.index[cacheKey]
+	d.mu.RUnlock()
+	if !ok {
+		return nil, false
+	}
+	if time.Now().UnixMilli() > meta.Expired {
+		d.remove(cacheKey)
+		return nil, false
+	}
+
+	body, err := d.readBody(meta)
+	if err != nil {
+		logs.Warn("读取磁盘缓存失败: %v", err)
+		d.remove(cacheKey)
+		return nil, false
+	}
+	d.hits.Add(1)
+	return &respCache{
+		code:     meta.Code,
+		body:     body,
+		cacheKey: meta.Key,
+		uri:      meta.URI,
+		created:  meta.Created,
+		expired:  meta.Expired,
+		header: respHeader{
+			space:    meta.Space,
+			spaceKey: meta.SpaceKey,
+			node:     meta.Node,
+			header:   meta.Header.Clone(),
+		},
+	}, true
+}
+
+// lookupSpace 根据缓存空间从磁盘中读取缓存
+func (d *diskStore) lookupSpace(space, spaceKey string) (*respCache, bool) {
+	if !d.ready.Load() {
+		return nil, false
+	}
+	d.mu.RLock()
+	cacheKey, ok := d.spaces[space][spaceKey]
+	d.mu.RUnlock()
+	if !ok {
+		return nil, false
+	}
+	return d.lookup(cacheKey)
+}
+
+// readBody 读取缓存文件中的响应体
+func (d *diskStore) readBody(meta *diskMeta) ([]byte, error) {
+	f, err := os.Open(d.path(meta.Key))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	if _, err := r.ReadBytes('\n'); err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) != meta.Size {
+		return nil, fmt.Errorf("缓存文件不完整: %s", meta.Key)
+	}
+	return body, nil
+}
+
+// writeFile 写入缓存文件, 先写临时文件再重命名, 避免读到不完整的数据
+func (d *diskStore) writeFile(meta *diskMeta, body []byte) error {
+	path := d.path(meta.Key)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	line, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	w.Write(line)
+	w.WriteByte('\n')
+	w.Write(body)
+	if err := w.Flush(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	d.mu.Lock()
+	d.unindexLocked(meta.Key)
+	d.indexLocked(meta)
+	d.mu.Unlock()
+	return nil
+}
+
+// removeFile 删除缓存文件及索引
+func (d *diskStore) removeFile(cacheKey string) {
+	d.mu.Lock()
+	d.unindexLocked(cacheKey)
+	d.mu.Unlock()
+	if err := os.Remove(d.path(cacheKey)); err != nil && !os.IsNotExist(err) {
+		logs.Warn("删除磁盘缓存失败: %v", err)
+	}
+}
+
+// shrink 超出大小上限时, 优先删除最早过期的缓存
+func (d *diskStore) shrink() {
+	d.mu.RLock()
+	over := d.bytes - d.maxBytes
+	var metas []*diskMeta
+	if over > 0 {
+		metas = make([]*diskMeta, 0, len(d.index))
+		for _, meta := range d.index {
+			metas = append(metas, meta)
+		}
+	}
+	d.mu.RUnlock()
+	if over <= 0 {
+		return
+	}
+
+	sort.Slice(metas, func(i, j int) bool { return metas[i].Expired < metas[j].Expired })
+	for _, meta := range metas {
+		if over <= 0 {
+			break
+		}
+		d.removeFile(meta.Key)
+		over -= meta.Size
+	}
+}
+
+// indexLocked 将元数据加入索引
+func (d *diskStore) indexLocked(meta *diskMeta) {
+	d.index[meta.Key] = meta
+	d.bytes += meta.Size
+	if meta.Space != "" && meta.SpaceKey != "" {
+		if d.spaces[meta.Space] == nil {
+			d.spaces[meta.Space] = make(map[string]string)
+		}
+		d.spaces[meta.Space][meta.SpaceKey] = meta.Key
+	}
+}
+
+// unindexLocked 将元数据移出索引
+func (d *diskStore) unindexLocked(cacheKey string) {
+	meta, ok := d.index[cacheKey]
+	if !ok {
+		return
+	}
+	delete(d.index, cacheKey)
+	d.bytes -= meta.Size
+	if s := d.spaces[meta.Space]; s != nil && s[meta.SpaceKey] == cacheKey {
+		delete(s, meta.SpaceKey)
+		if len(s) == 0 {
+			delete(d.spaces, meta.Space)
+		}
+	}
+}
+
+// metas 获取所有磁盘缓存元数据的快照
+func (d *diskStore) metas() []*diskMeta {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	res := make([]*diskMeta, 0, len(d.index))
+	for _, meta := range d.index {
+		res = append(res, meta)
+	}
+	return res
+}
+
+// meta 获取单个磁盘缓存的元数据
+func (d *diskStore) meta(cacheKey string) (*diskMeta, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	meta, ok := d.index[cacheKey]
+	return meta, ok
+}
+
+// spaceCounts 各缓存空间的磁盘缓存个数
+func (d *diskStore) spaceCounts() map[string]int64 {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	res := make(map[string]int64)
+	for _, meta := range d.index {
+		res[meta.Space]++
+	}
+	return res
+}
+
+// stats 磁盘缓存统计
+func (d *diskStore) stats() *DiskStats {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return &DiskStats{
+		Entries:  int64(len(d.index)),
+		Bytes:    d.bytes,
+		MaxBytes: d.maxBytes,
+		Hits:     d.hits.Load(),
+		Loaded:   d.ready.Load(),
+	}
+}
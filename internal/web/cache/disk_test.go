@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// waitReady 等待磁盘缓存扫描完成
+func waitReady(t *testing.T, d *diskStore) {
+	d.flush()
+	if !d.ready.Load() {
+		t.Fatal("磁盘缓存扫描未完成")
+	}
+}
+
+func TestDiskStore_PersistAndReload(t *testing.T) {
+	dir := t.TempDir()
+	d, err := openDiskStore(dir, 1<<20, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitReady(t, d)
+
+	rc := newTestCache("abcdef", 16, "PlaybackInfo", "item-1")
+	rc.header.header = http.Header{"Content-Type": []string{"application/json"}}
+	d.save(rc)
+
+	// 剩余有效期不足 minTTL 的缓存不写入磁盘
+	short := newTestCache("short", 4, "", "")
+	short.expired = time.Now().Add(time.Second * 10).UnixMilli()
+	d.save(short)
+	d.flush()
+
+	// 缓存中可能携带用户的 api_key, 只允许当前用户读写
+	file := d.path("abcdef")
+	for path, want := range map[string]os.FileMode{file: 0600, filepath.Dir(file): 0700} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("获取缓存文件信息失败: %v", err)
+		}
+		if info.Mode().Perm() != want {
+			t.Errorf("%s 权限不正确, 期望: %v, 实际: %v", path, want, info.Mode().Perm())
+		}
+	}
+
+	// 重新打开目录, 模拟程序重启
+	d2, err := openDiskStore(dir, 1<<20, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitReady(t, d2)
+
+	got, ok := d2.lookupSpace("PlaybackInfo", "item-1")
+	if !ok {
+		t.Fatal("重启后应能通过缓存空间读取到磁盘缓存")
+	}
+	if got.cacheKey != "abcdef" || string(got.body) != strings.Repeat("a", 16) || got.Header("Content-Type") != "application/json" {
+		t.Errorf("磁盘缓存内容不正确: key=%s, body=%s, header=%v", got.cacheKey, got.body, got.header.header)
+	}
+	if _, ok := d2.lookup("short"); ok {
+		t.Error("短有效期的缓存不应写入磁盘")
+	}
+
+	d2.remove("abcdef")
+	d2.flush()
+	if _, ok := d2.lookup("abcdef"); ok {
+		t.Error("删除后不应再读取到缓存")
+	}
+}
+
+func TestDiskStore_SizeBound(t *testing.T) {
+	d, err := openDiskStore(t.TempDir(), 20, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitReady(t, d)
+
+	for i, key := range []string{"k1", "k2", "k3"} {
+		rc := newTestCache(key, 8, "", "")
+		rc.expired = time.Now().Add(time.Hour * time.Duration(i+1)).UnixMilli()
+		d.save(rc)
+	}
+	d.flush()
+
+	st := d.stats()
+	if st.Entries != 2 || st.Bytes != 16 {
+		t.Errorf("超出大小上限后应删除最早过期的缓存: %+v", st)
+	}
+	if _, ok := d.lookup("k1"); ok {
+		t.Error("最早过期的缓存应被删除")
+	}
+}
@@ -0,0 +1,175 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/https"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/logs"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/reqids"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// CoalesceTimeout 相同请求等待首个请求响应的最长时间, 超时后自行请求上游
+	CoalesceTimeout = time.Second * 30
+
+	// RefreshAheadRatio 缓存剩余有效期低于总有效期的该比例时, 视为即将过期,
+	// 继续响应旧缓存, 同时在后台刷新
+	RefreshAheadRatio = 0.1
+
+	// refreshTimeout 后台刷新请求的超时时间
+	refreshTimeout = time.Minute
+)
+
+// flightCall 正在请求上游的缓存
+type flightCall struct {
+	done chan struct{}
+	rc   *respCache // 首个请求的响应, 不可缓存时为 nil
+}
+
+// nodeHealthy 判断节点是否健康, 命中指向不健康节点的缓存时视为未命中
+var nodeHealthy func(name string) bool
+
+// SetNodeChecker 设置节点健康判断函数
+//
+// 设置后, 标记了节点的缓存 (如串流重定向) 在命中时若节点已不健康,
+// 会被清除并重新请求上游, 由上游重新选择节点
+func SetNodeChecker(fn func(name string) bool) {
+	nodeHealthy = fn
+}
+
+// nodeDown 判断缓存指向的节点是否已下线
+func nodeDown(rc *respCache) bool {
+	return rc.header.node != "" && nodeHealthy != nil && !nodeHealthy(rc.header.node)
+}
+
+var (
+	// flights 正在请求上游的缓存 key
+	flights   = make(map[string]*flightCall)
+	flightsMu sync.Mutex
+
+	// refreshing 正在后台刷新的缓存 key
+	refreshing sync.Map
+)
+
+// joinFlight 加入请求合并
+//
+// 返回 leader 为 true 时, 当前请求负责请求上游, 完成后需调用 finishFlight
+func joinFlight(cacheKey string) (call *flightCall, leader bool) {
+	flightsMu.Lock()
+	defer flightsMu.Unlock()
+	if call, ok := flights[cacheKey]; ok {
+		return call, false
+	}
+	call = &flightCall{done: make(chan struct{})}
+	flights[cacheKey] = call
+	return call, true
+}
+
+// finishFlight 首个请求处理完毕, 唤醒等待中的请求
+func finishFlight(cacheKey string, call *flightCall, rc *respCache) {
+	flightsMu.Lock()
+	delete(flights, cacheKey)
+	flightsMu.Unlock()
+	call.rc = rc
+	close(call.done)
+}
+
+// wait 等待首个请求处理完毕, 返回可复用的响应
+func (call *flightCall) wait(ctx context.Context) (*respCache, bool) {
+	timer := time.NewTimer(CoalesceTimeout)
+	defer timer.Stop()
+	select {
+	case <-call.done:
+		return call.rc, call.rc != nil
+	case <-timer.C:
+		return nil, false
+	case <-ctx.Done():
+		return nil, false
+	}
+}
+
+// refreshCtxKey 标记后台刷新请求的上下文 key
+type refreshCtxKey struct{}
+
+// WithRefresh 返回标记为缓存后台刷新请求的 context
+func WithRefresh(ctx context.Context) context.Context {
+	return context.WithValue(ctx, refreshCtxKey{}, true)
+}
+
+// IsRefresh 判断请求是否是缓存后台刷新请求, ctx 为请求的 context
+//
+// 后台刷新请求由程序自身发起, 审计日志及重定向指标应跳过这类请求
+func IsRefresh(ctx context.Context) bool {
+	return ctx.Value(refreshCtxKey{}) != nil
+}
+
+// needRefresh 判断缓存是否即将过期
+func needRefresh(rc *respCache) bool {
+	if rc.created <= 0 {
+		return false
+	}
+	now := time.Now().UnixMilli()
+	total := rc.expired - rc.created
+	return total > 0 && float64(rc.expired-now) < float64(total)*RefreshAheadRatio
+}
+
+// refreshInBackground 使用相同的请求在后台重新请求, 由 RequestCacher 刷新缓存
+//
+// 同一个缓存同时只会有一个刷新请求
+func refreshInBackground(handler http.Handler, cacheKey string, c *gin.Context, body []byte) {
+	if handler == nil {
+		return
+	}
+	if _, loaded := refreshing.LoadOrStore(cacheKey, struct{}{}); loaded {
+		return
+	}
+
+	// 沿用触发刷新的请求 id, 后台刷新期间输出的日志可以关联到原始请求
+	ctx := logs.WithRequestId(WithRefresh(context.Background()), reqids.Get(c))
+	ctx, cancel := context.WithTimeout(ctx, refreshTimeout)
+	req := c.Request.Clone(ctx)
+	req.Body = http.NoBody
+	if len(body) > 0 {
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.ContentLength = int64(len(body))
+	}
+
+	go func() {
+		defer refreshing.Delete(cacheKey)
+		defer cancel()
+		w := &discardWriter{header: make(http.Header)}
+		handler.ServeHTTP(w, req)
+		result := "ok"
+		if https.IsErrorCode(w.status()) {
+			result = "error"
+		}
+		cacheRefreshTotal.With(result).Inc()
+		logs.Ctx(ctx).Debug("缓存后台刷新完成: %s, 响应码: %d", stripSecretParams(req.URL.RequestURI()), w.status())
+	}()
+}
+
+// discardWriter 后台刷新请求使用的响应器, 丢弃响应内容
+type discardWriter struct {
+	header http.Header
+	code   int
+}
+
+func (w *discardWriter) Header() http.Header         { return w.header }
+func (w *discardWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *discardWriter) WriteHeader(code int)        { w.code = code }
+func (w *discardWriter) Flush()                      {}
+
+// status 响应码
+func (w *discardWriter) status() int {
+	if w.code == 0 {
+		return http.StatusOK
+	}
+	return w.code
+}
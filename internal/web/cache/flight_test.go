@@ -0,0 +1,189 @@
+package cache
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/logs"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/reqids"
+
+	"github.com/gin-gonic/gin"
+)
+
+// upstreamRequestId 上游处理器最近一次收到的请求 id
+var upstreamRequestId atomic.Value
+
+// refreshSeen 缓存中间件之前的中间件观察到的后台刷新请求数
+var refreshSeen atomic.Int32
+
+// newTestEngine 构造使用 RequestCacher 的测试引擎, 返回上游被调用的次数
+func newTestEngine(t *testing.T, delay time.Duration) (*gin.Engine, *atomic.Int32) {
+	gin.SetMode(gin.TestMode)
+	old := DefaultExpired
+	DefaultExpired = func() time.Duration { return time.Hour }
+	t.Cleanup(func() {
+		DefaultExpired = old
+		Purge(Filter{})
+	})
+
+	calls := new(atomic.Int32)
+	r := gin.New()
+	r.ContextWithFallback = true
+	r.Use(reqids.Middleware())
+	r.Use(func(c *gin.Context) {
+		if IsRefresh(c.Request.Context()) {
+			refreshSeen.Add(1)
+		}
+	})
+	r.Use(RequestCacher(r))
+	r.GET("/Items/:id/PlaybackInfo", func(c *gin.Context) {
+		upstreamRequestId.Store(logs.RequestId(c))
+		n := calls.Add(1)
+		time.Sleep(delay)
+		c.String(http.StatusOK, "resp-%d", n)
+	})
+	return r, calls
+}
+
+func TestRequestCacher_Coalesce(t *testing.T) {
+	r, calls := newTestEngine(t, time.Millisecond*200)
+
+	var wg sync.WaitGroup
+	bodies := make([]string, 10)
+	for i := range bodies {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/Items/1/PlaybackInfo?api_key=x", nil))
+			bodies[i] = w.Body.String()
+		}(i)
+	}
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("相同请求应只请求上游一次, 实际: %d", n)
+	}
+	for i, body := range bodies {
+		if body != "resp-1" {
+			t.Errorf("第 %d 个请求的响应不正确: %s", i, body)
+		}
+	}
+}
+
+func TestRequestCacher_StaleWhileRevalidate(t *testing.T) {
+	r, calls := newTestEngine(t, 0)
+
+	var lastId string
+	req := func() string {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/Items/2/PlaybackInfo?api_key=x", nil))
+		lastId = w.Header().Get(reqids.Header)
+		return w.Body.String()
+	}
+
+	req()
+	var rc *respCache
+	waitFor(t, func() bool {
+		entries := ListEntries(Filter{ItemId: "2"}, 0)
+		if len(entries) == 0 {
+			return false
+		}
+		rc, _ = store.peek(entries[0].Key)
+		return rc != nil
+	})
+
+	// 将缓存调整为即将过期
+	now := time.Now().UnixMilli()
+	rc.created, rc.expired = now-time.Hour.Milliseconds(), now+time.Second.Milliseconds()
+
+	if body := req(); body != "resp-1" {
+		t.Errorf("即将过期时应继续响应旧缓存, 实际: %s", body)
+	}
+	triggerId := lastId
+
+	// 等待后台刷新完成
+	waitFor(t, func() bool {
+		cur, ok := store.peek(rc.cacheKey)
+		return ok && cur != rc
+	})
+
+	if body := req(); body != "resp-2" {
+		t.Errorf("后台刷新后应响应新缓存, 实际: %s", body)
+	}
+	if n := refreshSeen.Load(); n != 1 {
+		t.Errorf("前置中间件应能识别后台刷新请求, 实际识别次数: %d", n)
+	}
+	if rid := upstreamRequestId.Load(); rid != triggerId {
+		t.Errorf("后台刷新应沿用触发刷新的请求 id, 实际: %v", rid)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("上游调用次数不正确: %d", n)
+	}
+}
+
+// waitFor 等待条件满足, 超时后测试失败
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second * 3)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("等待超时")
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+}
+
+func TestRequestCacher_NodeDown(t *testing.T) {
+	r, calls := newTestEngine(t, 0)
+	r.GET("/videos/:id/stream", func(c *gin.Context) {
+		n := calls.Add(1)
+		node := fmt.Sprintf("node-%d", n)
+		c.Header(HeaderKeyNode, node)
+		c.Redirect(http.StatusTemporaryRedirect, "http://"+node+"/video.mkv")
+	})
+
+	healthy := map[string]bool{"node-1": true, "node-2": true}
+	var mu sync.Mutex
+	SetNodeChecker(func(name string) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return healthy[name]
+	})
+	defer SetNodeChecker(nil)
+
+	req := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/videos/9/stream?api_key=x", nil))
+		return w
+	}
+
+	w := req()
+	if w.Header().Get(HeaderKeyNode) != "" {
+		t.Error("节点标记响应头不应返回给客户端")
+	}
+	waitFor(t, func() bool { return len(ListEntries(Filter{Node: "node-1"}, 0)) == 1 })
+
+	if loc := req().Header().Get("Location"); loc != "http://node-1/video.mkv" {
+		t.Errorf("节点健康时应命中缓存, 实际: %s", loc)
+	}
+
+	// 节点下线后, 命中缓存时重新请求上游
+	mu.Lock()
+	healthy["node-1"] = false
+	mu.Unlock()
+	if loc := req().Header().Get("Location"); loc != "http://node-2/video.mkv" {
+		t.Errorf("节点下线后应重新选择节点, 实际: %s", loc)
+	}
+
+	// 按节点清除缓存
+	waitFor(t, func() bool { return len(ListEntries(Filter{Node: "node-2"}, 0)) == 1 })
+	if n := Purge(Filter{Node: "node-2"}); n != 1 {
+		t.Errorf("按节点清除的缓存个数不正确: %d", n)
+	}
+}
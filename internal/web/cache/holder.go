@@ -1,90 +1,85 @@
 package cache
 
 import (
+	"context"
 	"strconv"
 	"sync"
 	"time"
 
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/config"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/logs"
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/strs"
-
-	"github.com/gin-gonic/gin"
 )
 
 const (
 
-	// MaxCacheSize 缓存最大大小 (Byte)
-	//
-	// 这里的大小指的是响应体大小, 实际占用大小可能略大一些
-	MaxCacheSize int64 = 100 * 1024 * 1024
-
-	// MaxCacheNum 最多缓存多少个请求信息
-	MaxCacheNum = 8092
-
 	// HeaderKeyExpired 缓存过期响应头, 用于覆盖默认的缓存过期时间
 	HeaderKeyExpired = "Expired"
-)
 
-// currentCacheSize 当前内存中的缓存大小 (Byte)
-var currentCacheSize int64 = 0
+	// preCacheChanSize 预缓存通道大小
+	preCacheChanSize = 1024
+)
 
 // DefaultExpired 默认的请求过期时间
 //
 // 可通过设置 "Expired" 响应头进行覆盖
-var DefaultExpired = func() time.Duration { return config.C.Cache.ExpiredDuration() }
+var DefaultExpired = func() time.Duration { return config.C().Cache.ExpiredDuration() }
 
-// cacheMap 存放缓存数据的 map
-var cacheMap = sync.Map{}
+// store 存放缓存数据, 超出 cache.max-entries 或 cache.max-size 时按 LRU 淘汰
+//
+// 这里的大小指的是响应体大小, 实际占用大小可能略大一些
+var store = newLruStore(budget)
 
 // preCacheChan 预缓存通道
 //
 // 缓存数据先暂存在通道中, 再由专门的 goroutine 单线程处理
 //
 // preCacheChan 的淘汰规则是先入先淘汰, 不管缓存对象的过期时间
-var preCacheChan = make(chan *respCache, MaxCacheNum)
+var preCacheChan = make(chan *respCache, preCacheChanSize)
 
 // cacheHandleWaitGroup 允许等待预缓存通道处理完毕后再获取数据
 var cacheHandleWaitGroup = sync.WaitGroup{}
 
 func init() {
+	store.onEvict = func(rc *respCache, space, spaceKey, reason string) {
+		delSpaceCache(space, spaceKey, rc)
+		if reason != "" {
+			cacheEvictionTotal.With(reason).Inc()
+		}
+		// 内存不足淘汰的缓存仍保留在磁盘中
+		if disk != nil && (reason == EvictExpired || reason == EvictPurge || reason == EvictNodeDown) {
+			disk.remove(rc.cacheKey)
+		}
+	}
+	store.onUpdate = func(rc *respCache) {
+		if disk != nil {
+			disk.save(rc)
+		}
+	}
 	go loopMaintainCache()
 }
 
-// loopMaintainCache cacheMap 由单独的 goroutine 维护
-func loopMaintainCache() {
-
-	// cleanCache 清洗缓存数据
-	cleanCache := func() {
-		validCnt := 0
-		nowMillis := time.Now().UnixMilli()
-		toDelete := make([]*respCache, 0, 1<<3)
-
-		cacheMap.Range(func(key, value any) bool {
-			rc := value.(*respCache)
-			if nowMillis > rc.expired || validCnt == MaxCacheNum || currentCacheSize > MaxCacheSize {
-				toDelete = append(toDelete, rc)
-			} else {
-				validCnt++
-			}
-			return true
-		})
-
-		for _, rc := range toDelete {
-			cacheMap.Delete(rc.cacheKey)
-			currentCacheSize -= int64(len(rc.body))
-			delSpaceCache(rc.header.space, rc.header.spaceKey)
-		}
+// budget 从配置中读取缓存上限, 配置未初始化时使用默认值
+func budget() (int, int64) {
+	if config.C() == nil || config.C().Cache == nil || config.C().Cache.MaxEntries <= 0 {
+		return config.DefaultCacheMaxEntries, config.DefaultCacheMaxSize * 1024 * 1024
 	}
+	return config.C().Cache.MaxEntries, config.C().Cache.MaxBytes()
+}
+
+// ApplyConfig 按当前配置重新应用缓存上限, 配置重载后调用
+func ApplyConfig() {
+	store.shrink()
+}
 
-	// putrespCache 将缓存对象维护到 cacheMap 中
-	//
-	// 同时淘汰掉过期缓存
+// loopMaintainCache 缓存写入及过期清理由单独的 goroutine 维护
+func loopMaintainCache() {
+
+	// putrespCache 将缓存对象维护到存储中
 	putrespCache := func(rc *respCache) {
-		cacheMap.Store(rc.cacheKey, rc)
-		currentCacheSize += int64(len(rc.body))
-		space, spaceKey := rc.header.space, rc.header.spaceKey
-		if strs.AllNotEmpty(space, spaceKey) {
-			putSpaceCache(space, spaceKey, rc)
+		promote(rc)
+		if disk != nil {
+			disk.save(rc)
 		}
 	}
 
@@ -96,23 +91,44 @@ func loopMaintainCache() {
 			putrespCache(rc)
 			cacheHandleWaitGroup.Done()
 		case <-timer.C:
-			cleanCache()
+			store.removeExpired()
 		}
 	}
 }
 
 // getCache 根据 cacheKey 获取缓存
+//
+// 内存中不存在时, 尝试从磁盘缓存中加载
 func getCache(cacheKey string) (*respCache, bool) {
-	if c, ok := cacheMap.Load(cacheKey); ok {
-		return c.(*respCache), true
+	if rc, ok := store.get(cacheKey); ok {
+		return rc, true
+	}
+	if disk == nil {
+		return nil, false
 	}
-	return nil, false
+	rc, ok := disk.lookup(cacheKey)
+	if ok {
+		promote(rc)
+	}
+	return rc, ok
 }
 
-// putCache 设置缓存
-func putCache(cacheKey string, c *gin.Context, respBody []byte, respHeader respHeader) {
-	if cacheKey == "" || c == nil || respBody == nil {
-		return
+// promote 将缓存放入内存及缓存空间中
+func promote(rc *respCache) {
+	space, spaceKey := rc.header.space, rc.header.spaceKey
+	if strs.AllNotEmpty(space, spaceKey) {
+		putSpaceCache(space, spaceKey, rc)
+	}
+	store.put(rc)
+}
+
+// newRespCache 根据响应构造缓存对象
+//
+// 响应头指定不缓存时返回 nil;
+// 过期时间优先取缓存规则中的 ttl, 其次取响应头中指定的过期时间, 最后取默认过期时间
+func newRespCache(cacheKey, uri string, code int, respBody []byte, respHeader respHeader, rule *config.CacheRule) *respCache {
+	if cacheKey == "" || respBody == nil {
+		return nil
 	}
 
 	// 计算缓存过期时间
@@ -123,21 +139,33 @@ func putCache(cacheKey string, c *gin.Context, respBody []byte, respHeader respH
 
 		// 特定接口不使用缓存
 		if customMillis < 0 {
-			return
+			return nil
 		}
 
 		if customMillis > nowMillis {
 			expiredMillis = customMillis
 		}
 	}
+	if ttl := rule.TTLDuration(); ttl > 0 {
+		expiredMillis = nowMillis + ttl.Milliseconds()
+	}
 
-	rc := &respCache{
-		code:     c.Writer.Status(),
+	return &respCache{
+		code:     code,
 		body:     respBody,
 		cacheKey: cacheKey,
+		uri:      stripSecretParams(uri),
+		created:  nowMillis,
 		expired:  expiredMillis,
 		header:   respHeader,
 	}
+}
+
+// putCache 设置缓存, ctx 为产生缓存的请求的 context, 用于在日志中关联请求
+func putCache(ctx context.Context, rc *respCache) {
+	if rc == nil {
+		return
+	}
 
 	// 依据先进先淘汰原则, 将最新缓存放入预缓存通道中
 	cacheHandleWaitGroup.Add(1)
@@ -147,7 +175,10 @@ func putCache(cacheKey string, c *gin.Context, respBody []byte, respHeader respH
 		case preCacheChan <- rc:
 			return
 		default:
-			<-preCacheChan
+			dropped := <-preCacheChan
+			logs.Ctx(ctx).Debug("预缓存通道已满, 丢弃最早的缓存: %s", dropped.uri)
+			store.recordEviction(EvictQueueFull)
+			cacheEvictionTotal.With(EvictQueueFull).Inc()
 			doneOnce()
 		}
 	}
@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/config"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/service/userkey"
+
+	"github.com/gin-gonic/gin"
+)
+
+// scopeUserKey 按用户区分缓存时, 参与缓存 key 计算的用户标识
+const scopeUserKey = "scope-user"
+
+// credentialParams 客户端令牌参数, 按用户或共享缓存时不参与缓存 key 计算
+var credentialParams = []string{"api_key", "X-Emby-Token"}
+
+// credentialHeaders 客户端令牌请求头, 按用户或共享缓存时不参与缓存 key 计算
+var credentialHeaders = []string{"X-Emby-Token", "X-Emby-Authorization", "Authorization", "X-Mediabrowser-Token"}
+
+// keyScope 计算缓存 key 时实际生效的用户范围
+type keyScope struct {
+	dropCredential bool   // 是否去除令牌
+	userId         string // 按用户区分时的用户 id
+}
+
+// resolveScope 根据规则确定用户范围
+//
+// 规则要求按用户区分但无法识别用户身份时, 退化为按令牌区分, 避免不同用户共享缓存
+func resolveScope(c *gin.Context, rule *config.CacheRule) keyScope {
+	if rule == nil {
+		return keyScope{}
+	}
+	switch rule.Scope {
+	case config.CacheScopeShared:
+		return keyScope{dropCredential: true}
+	case config.CacheScopeUser:
+		if user, ok := userkey.GetUser(c); ok {
+			return keyScope{dropCredential: true, userId: user.Id}
+		}
+	}
+	return keyScope{}
+}
+
+// filterParams 按规则过滤参与缓存 key 计算的参数, 不修改原参数
+func filterParams(q url.Values, rule *config.CacheRule, scope keyScope) url.Values {
+	if rule == nil || (len(rule.IncludeParams) == 0 && len(rule.ExcludeParams) == 0 && !scope.dropCredential) {
+		return q
+	}
+	res := make(url.Values, len(q))
+	for key, values := range q {
+		if len(rule.IncludeParams) > 0 && !containsFold(rule.IncludeParams, key) {
+			continue
+		}
+		if containsFold(rule.ExcludeParams, key) {
+			continue
+		}
+		if scope.dropCredential && containsFold(credentialParams, key) {
+			continue
+		}
+		res[key] = values
+	}
+	return res
+}
+
+// keepHeader 判断请求头是否参与缓存 key 计算, key 为规范化后的请求头名称
+func keepHeader(key string, rule *config.CacheRule, scope keyScope) bool {
+	if rule == nil {
+		return true
+	}
+	if len(rule.IncludeHeaders) > 0 && !slices.Contains(rule.IncludeHeaders, key) {
+		return false
+	}
+	if slices.Contains(rule.ExcludeHeaders, key) {
+		return false
+	}
+	return !scope.dropCredential || !containsFold(credentialHeaders, key)
+}
+
+// containsFold 忽略大小写判断切片中是否包含指定字符串
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/config"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/service/userkey"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newKeyTestContext 构造计算缓存 key 用的请求上下文
+func newKeyTestContext(uri, token string, user *userkey.User) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, uri, nil)
+	c.Request.Header.Set("X-Emby-Token", token)
+	if user != nil {
+		c.Set(userkey.UserGinKey, user)
+	}
+	return c
+}
+
+func TestCalcCacheKeyScope(t *testing.T) {
+	newRule := func(scope string) *config.CacheRule {
+		rule := &config.CacheRule{Pattern: "/", Scope: scope, ExcludeParams: []string{"Fields"}}
+		if err := rule.Init(); err != nil {
+			t.Fatalf("初始化规则失败: %v", err)
+		}
+		return rule
+	}
+	key := func(uri, token string, user *userkey.User, rule *config.CacheRule) string {
+		k, err := calcCacheKey(newKeyTestContext(uri, token, user), rule)
+		if err != nil {
+			t.Fatalf("计算缓存 key 失败: %v", err)
+		}
+		return k
+	}
+	alice, bob := &userkey.User{Id: "alice"}, &userkey.User{Id: "bob"}
+
+	tokenRule := newRule(config.CacheScopeToken)
+	if key("/Shows/NextUp?a=1", "t1", alice, tokenRule) == key("/Shows/NextUp?a=1", "t2", alice, tokenRule) {
+		t.Errorf("token 范围下不同令牌的缓存 key 应不同")
+	}
+	if key("/Shows/NextUp?a=1&Fields=x", "t1", nil, tokenRule) != key("/Shows/NextUp?a=1&Fields=y", "t1", nil, tokenRule) {
+		t.Errorf("排除的参数不应参与缓存 key 计算")
+	}
+
+	userRule := newRule(config.CacheScopeUser)
+	if key("/Shows/NextUp?api_key=t1", "t1", alice, userRule) != key("/Shows/NextUp?api_key=t2", "t2", alice, userRule) {
+		t.Errorf("user 范围下同一用户的不同令牌应共享缓存 key")
+	}
+	if key("/Shows/NextUp", "t1", alice, userRule) == key("/Shows/NextUp", "t1", bob, userRule) {
+		t.Errorf("user 范围下不同用户的缓存 key 应不同")
+	}
+	if key("/Shows/NextUp", "t1", nil, userRule) == key("/Shows/NextUp", "t2", nil, userRule) {
+		t.Errorf("无法识别用户时应按令牌区分缓存 key")
+	}
+
+	sharedRule := newRule(config.CacheScopeShared)
+	if key("/Shows/NextUp", "t1", alice, sharedRule) != key("/Shows/NextUp", "t2", bob, sharedRule) {
+		t.Errorf("shared 范围下所有用户应共享缓存 key")
+	}
+
+	if key("/Shows/NextUp?a=1", "t1", nil, nil) != key("/Shows/NextUp?a=1", "t1", nil, tokenRule) {
+		t.Errorf("默认 token 范围且无过滤时, 缓存 key 应与未配置规则时一致")
+	}
+}
+
+func TestCalcCacheKeyIgnoreParams(t *testing.T) {
+	key := func(uri string, rule *config.CacheRule) string {
+		if rule != nil {
+			if err := rule.Init(); err != nil {
+				t.Fatalf("初始化规则失败: %v", err)
+			}
+		}
+		k, err := calcCacheKey(newKeyTestContext(uri, "t1", nil), rule)
+		if err != nil {
+			t.Fatalf("计算缓存 key 失败: %v", err)
+		}
+		return k
+	}
+
+	// 未配置时使用默认列表, 播放会话 id 不参与计算
+	if key("/Items/1/PlaybackInfo?PlaySessionId=a", nil) != key("/Items/1/PlaybackInfo?PlaySessionId=b", &config.CacheRule{Pattern: "/"}) {
+		t.Errorf("默认应忽略 PlaySessionId")
+	}
+
+	// 规则配置后覆盖默认列表
+	custom := func() *config.CacheRule {
+		return &config.CacheRule{Pattern: "/", IgnoreParams: []string{"t"}}
+	}
+	if key("/Items/1/PlaybackInfo?t=1", custom()) != key("/Items/1/PlaybackInfo?t=2", custom()) {
+		t.Errorf("规则配置的 ignore-params 应忽略参数 t")
+	}
+	if key("/Items/1/PlaybackInfo?PlaySessionId=a", custom()) == key("/Items/1/PlaybackInfo?PlaySessionId=b", custom()) {
+		t.Errorf("规则配置 ignore-params 后不应再使用默认列表")
+	}
+
+	// 配置为空列表时不忽略任何参数
+	none := func() *config.CacheRule { return &config.CacheRule{Pattern: "/", IgnoreParams: []string{}} }
+	if key("/Items/1/PlaybackInfo?PlaySessionId=a", none()) == key("/Items/1/PlaybackInfo?PlaySessionId=b", none()) {
+		t.Errorf("ignore-params 为空列表时所有参数都应参与计算")
+	}
+}
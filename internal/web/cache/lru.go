@@ -0,0 +1,335 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// 缓存淘汰原因
+const (
+	EvictExpired   = "expired"    // 缓存过期
+	EvictOverflow  = "overflow"   // 超出个数或大小上限
+	EvictQueueFull = "queue_full" // 预缓存通道已满
+	EvictPurge     = "purge"      // 手动清除
+	EvictNodeDown  = "node_down"  // 指向的节点已下线
+)
+
+// lruEntry 链表中的缓存节点
+type lruEntry struct {
+	rc       *respCache
+	size     int64 // 响应体大小, 由 store 维护, 避免淘汰时读取 rc 加锁
+	space    string
+	spaceKey string
+}
+
+// spaceStats 单个缓存空间的统计
+type spaceStats struct {
+	entries int64
+	bytes   int64
+	hits    uint64
+}
+
+// lruStore 按最近最少使用原则淘汰的缓存存储
+//
+// 所有操作都在同一把锁内完成, 保证个数、大小以及各缓存空间的统计准确
+type lruStore struct {
+	mu        sync.Mutex
+	ll        *list.List               // 链表头部为最近使用的缓存
+	items     map[string]*list.Element // cacheKey -> 链表节点
+	bytes     int64                    // 当前响应体总大小
+	spaces    map[string]*spaceStats   // 缓存空间名称 -> 统计, 不属于任何空间的缓存记录在空字符串下
+	hits      uint64
+	misses    uint64
+	evictions map[string]uint64
+
+	// budget 获取缓存上限, 每次写入时读取, 配置变更后立即生效
+	budget func() (maxEntries int, maxBytes int64)
+
+	// onEvict 缓存被移除时的回调, 在锁外执行
+	onEvict func(rc *respCache, space, spaceKey, reason string)
+
+	// onUpdate 缓存内容通过 Update 更新后的回调, 在锁外执行
+	onUpdate func(rc *respCache)
+}
+
+// newLruStore 初始化缓存存储
+func newLruStore(budget func() (int, int64)) *lruStore {
+	return &lruStore{
+		ll:        list.New(),
+		items:     make(map[string]*list.Element),
+		spaces:    make(map[string]*spaceStats),
+		evictions: make(map[string]uint64),
+		budget:    budget,
+	}
+}
+
+// evicted 记录被移除的缓存, 在锁外统一回调
+type evicted struct {
+	entry  *lruEntry
+	reason string
+}
+
+// get 获取缓存, 命中后移动到链表头部, 已过期的缓存会被直接移除
+func (s *lruStore) get(cacheKey string) (*respCache, bool) {
+	s.mu.Lock()
+	el, ok := s.items[cacheKey]
+	if !ok {
+		s.misses++
+		s.mu.Unlock()
+		return nil, false
+	}
+	e := el.Value.(*lruEntry)
+	if time.Now().UnixMilli() > e.rc.expired {
+		s.misses++
+		s.removeLocked(el)
+		s.mu.Unlock()
+		s.notify([]evicted{{e, EvictExpired}})
+		return nil, false
+	}
+	s.hits++
+	s.spaceOf(e.space).hits++
+	s.ll.MoveToFront(el)
+	s.mu.Unlock()
+	return e.rc, true
+}
+
+// put 写入缓存, 替换同 key 的旧缓存, 超出上限时从链表尾部开始淘汰
+func (s *lruStore) put(rc *respCache) {
+	rc.mu.Lock()
+	e := &lruEntry{
+		rc:       rc,
+		size:     int64(len(rc.body)),
+		space:    rc.header.space,
+		spaceKey: rc.header.spaceKey,
+	}
+	rc.onUpdate = func(delta int64) {
+		s.resize(rc, delta)
+		if s.onUpdate != nil {
+			s.onUpdate(rc)
+		}
+	}
+	rc.mu.Unlock()
+
+	s.mu.Lock()
+	var removed []evicted
+	if old, ok := s.items[rc.cacheKey]; ok {
+		// 被替换的缓存不计入淘汰统计
+		oe := old.Value.(*lruEntry)
+		s.removeLocked(old)
+		removed = append(removed, evicted{oe, ""})
+	}
+	s.items[rc.cacheKey] = s.ll.PushFront(e)
+	s.bytes += e.size
+	ss := s.spaceOf(e.space)
+	ss.entries++
+	ss.bytes += e.size
+	removed = append(removed, s.shrinkLocked()...)
+	s.mu.Unlock()
+
+	s.notify(removed)
+}
+
+// shrink 按当前上限淘汰超出的缓存, 用于上限调小后立即释放内存
+func (s *lruStore) shrink() {
+	s.mu.Lock()
+	removed := s.shrinkLocked()
+	s.mu.Unlock()
+	s.notify(removed)
+}
+
+// resize 缓存响应体被更新后, 修正大小统计
+func (s *lruStore) resize(rc *respCache, delta int64) {
+	if delta == 0 {
+		return
+	}
+	s.mu.Lock()
+	el, ok := s.items[rc.cacheKey]
+	if !ok || el.Value.(*lruEntry).rc != rc {
+		s.mu.Unlock()
+		return
+	}
+	e := el.Value.(*lruEntry)
+	e.size += delta
+	s.bytes += delta
+	s.spaceOf(e.space).bytes += delta
+	removed := s.shrinkLocked()
+	s.mu.Unlock()
+
+	s.notify(removed)
+}
+
+// removeExpired 移除所有过期缓存
+func (s *lruStore) removeExpired() {
+	nowMillis := time.Now().UnixMilli()
+	s.mu.Lock()
+	var removed []evicted
+	for el := s.ll.Back(); el != nil; {
+		prev := el.Prev()
+		if e := el.Value.(*lruEntry); nowMillis > e.rc.expired {
+			s.removeLocked(el)
+			removed = append(removed, evicted{e, EvictExpired})
+		}
+		el = prev
+	}
+	s.mu.Unlock()
+
+	s.notify(removed)
+}
+
+// remove 移除指定的缓存, 缓存已被替换时不做处理
+func (s *lruStore) remove(rc *respCache, reason string) {
+	s.mu.Lock()
+	el, ok := s.items[rc.cacheKey]
+	if !ok || el.Value.(*lruEntry).rc != rc {
+		s.mu.Unlock()
+		return
+	}
+	e := el.Value.(*lruEntry)
+	s.removeLocked(el)
+	s.mu.Unlock()
+
+	s.notify([]evicted{{e, reason}})
+}
+
+// removeIf 移除所有满足条件的缓存, 返回移除的个数
+func (s *lruStore) removeIf(match func(rc *respCache) bool, reason string) int {
+	s.mu.Lock()
+	var removed []evicted
+	for el := s.ll.Front(); el != nil; {
+		next := el.Next()
+		if e := el.Value.(*lruEntry); match(e.rc) {
+			s.removeLocked(el)
+			removed = append(removed, evicted{e, reason})
+		}
+		el = next
+	}
+	s.mu.Unlock()
+
+	s.notify(removed)
+	return len(removed)
+}
+
+// list 获取所有缓存的快照, 最近使用的排在前面
+func (s *lruStore) list() []*respCache {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := make([]*respCache, 0, s.ll.Len())
+	for el := s.ll.Front(); el != nil; el = el.Next() {
+		res = append(res, el.Value.(*lruEntry).rc)
+	}
+	return res
+}
+
+// peek 获取缓存, 不影响淘汰顺序及命中统计
+func (s *lruStore) peek(cacheKey string) (*respCache, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.items[cacheKey]; ok {
+		return el.Value.(*lruEntry).rc, true
+	}
+	return nil, false
+}
+
+// recordEviction 记录未进入存储就被丢弃的缓存
+func (s *lruStore) recordEviction(reason string) {
+	s.mu.Lock()
+	s.evictions[reason]++
+	s.mu.Unlock()
+}
+
+// shrinkLocked 超出上限时从链表尾部开始淘汰, 至少保留最新写入的一个缓存
+func (s *lruStore) shrinkLocked() []evicted {
+	maxEntries, maxBytes := s.budget()
+	var removed []evicted
+	for s.ll.Len() > 1 && (len(s.items) > maxEntries || s.bytes > maxBytes) {
+		el := s.ll.Back()
+		e := el.Value.(*lruEntry)
+		s.removeLocked(el)
+		removed = append(removed, evicted{e, EvictOverflow})
+	}
+	return removed
+}
+
+// removeLocked 移除链表节点并修正统计
+func (s *lruStore) removeLocked(el *list.Element) {
+	e := el.Value.(*lruEntry)
+	s.ll.Remove(el)
+	delete(s.items, e.rc.cacheKey)
+	s.bytes -= e.size
+	ss := s.spaceOf(e.space)
+	ss.entries--
+	ss.bytes -= e.size
+	if ss.entries == 0 && ss.hits == 0 {
+		delete(s.spaces, e.space)
+	}
+}
+
+// spaceOf 获取缓存空间的统计, 不存在时初始化
+func (s *lruStore) spaceOf(space string) *spaceStats {
+	ss, ok := s.spaces[space]
+	if !ok {
+		ss = new(spaceStats)
+		s.spaces[space] = ss
+	}
+	return ss
+}
+
+// notify 统计淘汰次数并执行回调
+func (s *lruStore) notify(removed []evicted) {
+	if len(removed) == 0 {
+		return
+	}
+	s.mu.Lock()
+	for _, r := range removed {
+		if r.reason != "" {
+			s.evictions[r.reason]++
+		}
+	}
+	s.mu.Unlock()
+
+	if s.onEvict == nil {
+		return
+	}
+	for _, r := range removed {
+		s.onEvict(r.entry.rc, r.entry.space, r.entry.spaceKey, r.reason)
+	}
+}
+
+// usage 当前的缓存个数及响应体总大小
+func (s *lruStore) usage() (int64, int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return int64(len(s.items)), s.bytes
+}
+
+// stats 获取缓存统计快照
+func (s *lruStore) stats() Stats {
+	maxEntries, maxBytes := s.budget()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := Stats{
+		Entries:    int64(len(s.items)),
+		Bytes:      s.bytes,
+		MaxEntries: maxEntries,
+		MaxBytes:   maxBytes,
+		Hits:       s.hits,
+		Misses:     s.misses,
+		Evictions:  make(map[string]uint64, len(s.evictions)),
+		Spaces:     make(map[string]SpaceStats, len(s.spaces)),
+	}
+	if total := s.hits + s.misses; total > 0 {
+		st.HitRatio = float64(s.hits) / float64(total)
+	}
+	for reason, cnt := range s.evictions {
+		st.Evictions[reason] = cnt
+	}
+	for name, ss := range s.spaces {
+		if name == "" {
+			name = DefaultSpaceName
+		}
+		st.Spaces[name] = SpaceStats{Entries: ss.entries, Bytes: ss.bytes, Hits: ss.hits}
+	}
+	return st
+}
@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestCache 构造测试用的缓存对象
+func newTestCache(key string, size int, space, spaceKey string) *respCache {
+	return &respCache{
+		code:     200,
+		body:     []byte(strings.Repeat("a", size)),
+		cacheKey: key,
+		expired:  time.Now().Add(time.Hour).UnixMilli(),
+		header:   respHeader{space: space, spaceKey: spaceKey},
+	}
+}
+
+func TestLruStore_EvictLeastRecentlyUsed(t *testing.T) {
+	s := newLruStore(func() (int, int64) { return 2, 1 << 20 })
+
+	s.put(newTestCache("a", 1, "", ""))
+	s.put(newTestCache("b", 1, "", ""))
+	if _, ok := s.get("a"); !ok {
+		t.Fatal("a 应该命中缓存")
+	}
+	s.put(newTestCache("c", 1, "", ""))
+
+	if _, ok := s.get("b"); ok {
+		t.Error("b 最久未使用, 应该被淘汰")
+	}
+	if _, ok := s.get("a"); !ok {
+		t.Error("a 最近被访问过, 不应该被淘汰")
+	}
+
+	st := s.stats()
+	if st.Entries != 2 || st.Bytes != 2 {
+		t.Errorf("缓存个数或大小统计不正确: %+v", st)
+	}
+	if st.Evictions[EvictOverflow] != 1 {
+		t.Errorf("淘汰次数统计不正确: %v", st.Evictions)
+	}
+	if st.Hits != 2 || st.Misses != 1 {
+		t.Errorf("命中统计不正确: hits=%d, misses=%d", st.Hits, st.Misses)
+	}
+}
+
+func TestLruStore_ByteBudget(t *testing.T) {
+	s := newLruStore(func() (int, int64) { return 100, 10 })
+
+	s.put(newTestCache("a", 4, "", ""))
+	s.put(newTestCache("b", 4, "", ""))
+	s.put(newTestCache("c", 4, "", ""))
+
+	st := s.stats()
+	if st.Entries != 2 || st.Bytes != 8 {
+		t.Errorf("超出大小上限后应淘汰最久未使用的缓存: %+v", st)
+	}
+
+	// 替换同 key 缓存时, 大小应按新值计算
+	s.put(newTestCache("c", 1, "", ""))
+	if st = s.stats(); st.Bytes != 5 {
+		t.Errorf("替换缓存后大小统计不正确: %d", st.Bytes)
+	}
+}
+
+func TestLruStore_SpaceAccountingAndResize(t *testing.T) {
+	s := newLruStore(func() (int, int64) { return 100, 1 << 20 })
+	var evictedKeys []string
+	s.onEvict = func(rc *respCache, space, spaceKey, reason string) {
+		evictedKeys = append(evictedKeys, space+"/"+spaceKey+":"+reason)
+	}
+
+	rc := newTestCache("a", 10, "PlaybackInfo", "1")
+	s.put(rc)
+	s.put(newTestCache("b", 5, "", ""))
+
+	rc.Update(0, []byte("12345678901234567890"), nil)
+	st := s.stats()
+	if st.Bytes != 25 || st.Spaces["PlaybackInfo"].Bytes != 20 {
+		t.Errorf("更新响应体后大小统计不正确: %+v", st)
+	}
+	if st.Spaces[DefaultSpaceName].Entries != 1 {
+		t.Errorf("默认空间统计不正确: %+v", st.Spaces)
+	}
+
+	rc.expired = time.Now().Add(-time.Second).UnixMilli()
+	s.removeExpired()
+	if st = s.stats(); st.Entries != 1 || st.Spaces["PlaybackInfo"].Entries != 0 {
+		t.Errorf("过期缓存未被移除: %+v", st)
+	}
+	if len(evictedKeys) != 1 || evictedKeys[0] != "PlaybackInfo/1:"+EvictExpired {
+		t.Errorf("淘汰回调不正确: %v", evictedKeys)
+	}
+}
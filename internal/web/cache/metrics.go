@@ -0,0 +1,45 @@
+package cache
+
+import (
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/metrics"
+)
+
+var (
+	// cacheRequestTotal 缓存命中情况
+	cacheRequestTotal = metrics.NewCounterVec(
+		"ge2o_cache_requests_total",
+		"请求缓存命中次数, result: hit/miss/coalesced",
+		"result",
+	)
+
+	// cacheEvictionTotal 缓存淘汰次数
+	cacheEvictionTotal = metrics.NewCounterVec(
+		"ge2o_cache_evictions_total",
+		"请求缓存淘汰次数, reason: expired/overflow/queue_full/purge/node_down",
+		"reason",
+	)
+
+	// cacheRefreshTotal 缓存后台刷新次数
+	cacheRefreshTotal = metrics.NewCounterVec(
+		"ge2o_cache_refresh_total",
+		"缓存即将过期时的后台刷新次数, result: ok/error",
+		"result",
+	)
+
+	// cacheHitBytesTotal 从缓存中响应的字节数
+	cacheHitBytesTotal = metrics.NewCounterVec(
+		"ge2o_cache_hit_bytes_total",
+		"从请求缓存中直接响应的字节数",
+	)
+)
+
+func init() {
+	metrics.NewGaugeFunc("ge2o_cache_bytes", "当前内存中的缓存大小 (字节)", func() float64 {
+		_, bytes := store.usage()
+		return float64(bytes)
+	})
+	metrics.NewGaugeFunc("ge2o_cache_entries", "当前内存中的缓存个数", func() float64 {
+		entries, _ := store.usage()
+		return float64(entries)
+	})
+}
@@ -15,6 +15,9 @@ const (
 
 	// HeaderKeySpaceKey 缓存空间内部 key
 	HeaderKeySpaceKey = "Space-Key"
+
+	// HeaderKeyNode 响应指向的节点名称, 节点下线时据此清除缓存
+	HeaderKeyNode = "Node"
 )
 
 // spaceMap 缓存空间
@@ -29,9 +32,18 @@ func GetSpaceCache(space, spaceKey string) (RespCache, bool) {
 	}
 	s := getSpace(space)
 	rc, ok := getSpaceCache(s, spaceKey)
-	if !ok {
+	if ok {
+		return rc, true
+	}
+
+	// 内存中不存在时, 尝试从磁盘缓存中加载
+	if disk == nil {
 		return nil, false
 	}
+	if rc, ok = disk.lookupSpace(space, spaceKey); !ok {
+		return nil, false
+	}
+	promote(rc)
 	return rc, true
 }
 
@@ -43,11 +55,14 @@ func putSpaceCache(space, spaceKey string, cache *respCache) {
 	getSpace(space).Store(spaceKey, cache)
 }
 
-func delSpaceCache(space, spaceKey string) {
+// delSpaceCache 从缓存空间中删除缓存
+//
+// 只有空间中存放的仍是 cache 时才删除, 避免误删同 key 的新缓存
+func delSpaceCache(space, spaceKey string, cache *respCache) {
 	if strs.AnyEmpty(space, spaceKey) {
 		return
 	}
-	getSpace(space).Delete(spaceKey)
+	getSpace(space).CompareAndDelete(spaceKey, cache)
 }
 
 // getSpace 获取缓存空间
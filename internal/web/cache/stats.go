@@ -0,0 +1,43 @@
+package cache
+
+// DefaultSpaceName 统计信息中, 不属于任何缓存空间的缓存归入的名称
+const DefaultSpaceName = "_default"
+
+// SpaceStats 单个缓存空间的统计
+type SpaceStats struct {
+	Entries int64  `json:"entries"` // 缓存个数
+	Bytes   int64  `json:"bytes"`   // 响应体总大小
+	Hits    uint64 `json:"hits"`    // 命中次数
+}
+
+// Stats 缓存统计
+type Stats struct {
+	Entries    int64                 `json:"entries"`        // 当前缓存个数
+	Bytes      int64                 `json:"bytes"`          // 当前响应体总大小
+	MaxEntries int                   `json:"max_entries"`    // 缓存个数上限
+	MaxBytes   int64                 `json:"max_bytes"`      // 响应体总大小上限
+	Hits       uint64                `json:"hits"`           // 命中次数
+	Misses     uint64                `json:"misses"`         // 未命中次数
+	HitRatio   float64               `json:"hit_ratio"`      // 命中率
+	Evictions  map[string]uint64     `json:"evictions"`      // 淘汰原因 -> 次数
+	Spaces     map[string]SpaceStats `json:"spaces"`         // 缓存空间名称 -> 统计
+	Disk       *DiskStats            `json:"disk,omitempty"` // 磁盘缓存统计, 未启用时为空
+}
+
+// DiskStats 磁盘缓存统计
+type DiskStats struct {
+	Entries  int64  `json:"entries"`   // 缓存个数
+	Bytes    int64  `json:"bytes"`     // 响应体总大小
+	MaxBytes int64  `json:"max_bytes"` // 响应体总大小上限
+	Hits     uint64 `json:"hits"`      // 内存未命中, 从磁盘加载的次数
+	Loaded   bool   `json:"loaded"`    // 启动时的缓存文件扫描是否已完成
+}
+
+// GetStats 获取缓存统计快照
+func GetStats() Stats {
+	st := store.stats()
+	if disk != nil {
+		st.Disk = disk.stats()
+	}
+	return st
+}
@@ -33,6 +33,12 @@ type respCache struct {
 	// cacheKey 缓存 key
 	cacheKey string
 
+	// uri 原始请求地址, 已去除参与鉴权的参数, 用于管理接口展示及匹配
+	uri string
+
+	// created 缓存创建时间戳 UnixMilli
+	created int64
+
 	// expired 缓存过期时间戳 UnixMilli
 	expired int64
 
@@ -41,6 +47,9 @@ type respCache struct {
 
 	// mu 读写互斥控制
 	mu sync.RWMutex
+
+	// onUpdate 缓存被更新后回调, delta 为响应体大小的变化量
+	onUpdate func(delta int64)
 }
 
 // respHeader 记录特定请求的缓存参数
@@ -48,6 +57,7 @@ type respHeader struct {
 	expired  string      // 过期时间
 	space    string      // 缓存空间名称
 	spaceKey string      // 缓存空间 key
+	node     string      // 响应指向的节点名称
 	header   http.Header // 原始请求的克隆请求头
 }
 
@@ -121,18 +131,25 @@ func (c *respCache) Update(code int, body []byte, header http.Header) {
 		return
 	}
 	c.mu.Lock()
-	defer c.mu.Unlock()
 
 	if code != 0 {
 		c.code = code
 	}
 
+	var delta int64
 	if body != nil {
 		// 新建一个底层数组来存放响应体数据
+		delta = int64(len(body) - len(c.body))
 		c.body = append(([]byte)(nil), body...)
 	}
 
 	if header != nil {
 		c.header.header = header.Clone()
 	}
+	onUpdate := c.onUpdate
+	c.mu.Unlock()
+
+	if onUpdate != nil {
+		onUpdate(delta)
+	}
 }
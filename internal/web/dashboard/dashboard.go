@@ -0,0 +1,32 @@
+package dashboard
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+	"strings"
+)
+
+// static 管理面板静态文件, 编译时嵌入到程序中
+//
+//go:embed static
+var static embed.FS
+
+// Handler 管理面板静态页面, 挂载在 prefix 路径下
+//
+// 页面本身不包含敏感信息, 数据通过管理接口获取, 由管理接口负责鉴权
+func Handler(prefix string) http.Handler {
+	sub, err := fs.Sub(static, "static")
+	if err != nil {
+		panic(err)
+	}
+	files := http.StripPrefix(strings.TrimSuffix(prefix, "/"), http.FileServerFS(sub))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Set("Cache-Control", "no-cache")
+		h.Set("X-Frame-Options", "DENY")
+		h.Set("X-Content-Type-Options", "nosniff")
+		h.Set("Content-Security-Policy", "default-src 'self'; img-src 'self' data:; frame-ancestors 'none'")
+		files.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,394 @@
+// go-emby2openlist 管理面板, 数据均通过 /ge2o/api 管理接口获取
+(function () {
+  'use strict';
+
+  const API = '/ge2o/api';
+  const REFRESH_INTERVAL = 10000;
+  const TABS = ['nodes', 'sessions', 'cache', 'audit', 'map'];
+
+  let refreshTimer = null;
+  let nodesVersion = '';
+  let auditEvents = [];
+
+  const $ = (id) => document.getElementById(id);
+
+  // el 创建 DOM 元素, 文本内容一律使用 textContent, 避免注入
+  function el(tag, attrs, ...children) {
+    const e = document.createElement(tag);
+    for (const [k, v] of Object.entries(attrs || {})) {
+      if (k === 'class') e.className = v;
+      else if (k.startsWith('on')) e.addEventListener(k.slice(2), v);
+      else e.setAttribute(k, v);
+    }
+    for (const c of children.flat()) {
+      if (c === null || c === undefined || c === false) continue;
+      e.append(c instanceof Node ? c : document.createTextNode(String(c)));
+    }
+    return e;
+  }
+
+  // api 请求管理接口, 未登录时跳转到登录页
+  async function api(method, path, body, headers) {
+    const opts = {
+      method,
+      credentials: 'same-origin',
+      headers: Object.assign({ 'X-Requested-With': 'ge2o-dashboard' }, headers || {}),
+    };
+    if (body !== undefined) {
+      opts.headers['Content-Type'] = 'application/json';
+      opts.body = JSON.stringify(body);
+    }
+    const resp = await fetch(API + path, opts);
+    if (resp.status === 401) {
+      showLogin();
+      throw new Error('登录已失效, 请重新登录');
+    }
+    const data = await resp.json().catch(() => ({}));
+    if (!resp.ok) {
+      const err = new Error(data.error || resp.statusText);
+      err.status = resp.status;
+      throw err;
+    }
+    return { data, resp };
+  }
+
+  function showMessage(text, isError) {
+    const m = $('message');
+    m.textContent = text;
+    m.className = isError ? 'message error' : 'message';
+    m.hidden = false;
+    clearTimeout(showMessage.timer);
+    showMessage.timer = setTimeout(() => { m.hidden = true; }, 5000);
+  }
+
+  function fail(err) {
+    if (err && err.message) showMessage(err.message, true);
+  }
+
+  function fillTable(tbody, rows, cols, emptyText) {
+    tbody.replaceChildren(...(rows.length ? rows : [el('tr', {}, el('td', { class: 'empty', colspan: cols }, emptyText))]));
+  }
+
+  function formatBytes(n) {
+    const units = ['B', 'KB', 'MB', 'GB', 'TB'];
+    let i = 0;
+    while (n >= 1024 && i < units.length - 1) { n /= 1024; i++; }
+    return (i === 0 ? n : n.toFixed(1)) + ' ' + units[i];
+  }
+
+  function formatSeconds(s) {
+    s = Math.max(0, Math.floor(s));
+    const h = Math.floor(s / 3600), m = Math.floor(s % 3600 / 60), sec = s % 60;
+    const pad = (v) => String(v).padStart(2, '0');
+    return (h ? h + ':' + pad(m) : m) + ':' + pad(sec);
+  }
+
+  function formatAgo(time) {
+    if (!time) return '尚未检查';
+    const s = Math.round((Date.now() - new Date(time).getTime()) / 1000);
+    if (s < 60) return Math.max(s, 0) + ' 秒前';
+    if (s < 3600) return Math.floor(s / 60) + ' 分钟前';
+    return new Date(time).toLocaleString();
+  }
+
+  function formatTime(time) {
+    const d = new Date(time);
+    return d.toDateString() === new Date().toDateString() ? d.toLocaleTimeString() : d.toLocaleString();
+  }
+
+  // ---------- 登录 ----------
+
+  function showLogin() {
+    stopRefresh();
+    $('app').hidden = true;
+    $('login').hidden = false;
+    $('login-token').focus();
+  }
+
+  function showApp() {
+    $('login').hidden = true;
+    $('app').hidden = false;
+    switchTab();
+  }
+
+  $('login-form').addEventListener('submit', async (e) => {
+    e.preventDefault();
+    $('login-error').textContent = '';
+    const resp = await fetch(API + '/login', {
+      method: 'POST',
+      credentials: 'same-origin',
+      headers: { 'Content-Type': 'application/json', 'X-Requested-With': 'ge2o-dashboard' },
+      body: JSON.stringify({ token: $('login-token').value }),
+    });
+    const data = await resp.json().catch(() => ({}));
+    if (!resp.ok) {
+      $('login-error').textContent = data.error || '登录失败';
+      return;
+    }
+    $('login-token').value = '';
+    showApp();
+  });
+
+  $('logout').addEventListener('click', async () => {
+    await api('POST', '/logout').catch(() => {});
+    showLogin();
+  });
+
+  // ---------- 标签页 ----------
+
+  const loaders = {
+    nodes: loadNodes,
+    sessions: loadSessions,
+    cache: loadCache,
+    audit: loadAudit,
+  };
+
+  function stopRefresh() {
+    clearInterval(refreshTimer);
+    refreshTimer = null;
+  }
+
+  function switchTab() {
+    const tab = TABS.includes(location.hash.slice(1)) ? location.hash.slice(1) : 'nodes';
+    for (const t of TABS) $('tab-' + t).hidden = t !== tab;
+    document.querySelectorAll('header nav a').forEach((a) => a.classList.toggle('active', a.dataset.tab === tab));
+
+    stopRefresh();
+    const load = loaders[tab];
+    if (!load) return;
+    load().catch(fail);
+    refreshTimer = setInterval(() => {
+      if (!document.hidden) load().catch(fail);
+    }, REFRESH_INTERVAL);
+  }
+
+  window.addEventListener('hashchange', () => {
+    if (!$('app').hidden) switchTab();
+  });
+
+  // ---------- 节点 ----------
+
+  // sparkline 使用最近的健康检查结果绘制耗时柱状图, 失败的检查为满高的红色柱
+  function sparkline(history) {
+    const ns = 'http://www.w3.org/2000/svg';
+    const width = 120, height = 20, slots = 60, bar = width / slots;
+    const svg = document.createElementNS(ns, 'svg');
+    svg.setAttribute('class', 'spark');
+    svg.setAttribute('width', width);
+    svg.setAttribute('height', height);
+    const list = (history || []).slice(-slots);
+    const max = Math.max(1, ...list.filter((h) => h.ok).map((h) => h.latency_ms));
+    list.forEach((h, i) => {
+      const hgt = h.ok ? Math.max(2, Math.round(h.latency_ms / max * height)) : height;
+      const rect = document.createElementNS(ns, 'rect');
+      rect.setAttribute('class', h.ok ? 'ok' : 'fail');
+      rect.setAttribute('x', (slots - list.length + i) * bar);
+      rect.setAttribute('y', height - hgt);
+      rect.setAttribute('width', Math.max(bar - 0.5, 1));
+      rect.setAttribute('height', hgt);
+      const title = document.createElementNS(ns, 'title');
+      title.textContent = formatTime(h.time) + (h.ok ? ' ' + h.latency_ms + 'ms' : ' 失败');
+      rect.append(title);
+      svg.append(rect);
+    });
+    return svg;
+  }
+
+  function nodeBadge(n) {
+    if (!n.enabled) return el('span', { class: 'badge' }, '已禁用');
+    if (n.draining) return el('span', { class: 'badge warn' }, '排空中');
+    if (n.healthy) return el('span', { class: 'badge ok' }, '健康');
+    return el('span', { class: 'badge fail' }, '不健康');
+  }
+
+  // nodeAction 修改节点, 携带读取时的版本号, 期间被其他人修改时提示并刷新
+  async function nodeAction(method, path, body, done) {
+    try {
+      await api(method, '/nodes' + path, body, nodesVersion ? { 'If-Match': '"' + nodesVersion + '"' } : {});
+      showMessage(done);
+    } catch (err) {
+      if (err.status === 412) showMessage('节点列表已被其他人修改, 已刷新, 请确认后重试', true);
+      else fail(err);
+    }
+    await loadNodes().catch(fail);
+  }
+
+  async function loadNodes() {
+    const { data } = await api('GET', '/nodes');
+    nodesVersion = data.version;
+    const enabled = data.nodes.filter((n) => n.enabled).length;
+    $('nodes-summary').textContent = `共 ${data.nodes.length} 个节点, 启用 ${enabled} 个, 健康 ${data.healthy} 个`;
+
+    const rows = data.nodes.map((n) => {
+      const name = encodeURIComponent(n.name);
+      const actions = [
+        n.enabled
+          ? el('button', { onclick: () => nodeAction('POST', `/${name}/disable`, undefined, `已禁用节点 ${n.name}`) }, '禁用')
+          : el('button', { onclick: () => nodeAction('POST', `/${name}/enable`, undefined, `已启用节点 ${n.name}`) }, '启用'),
+        n.enabled && (n.draining
+          ? el('button', { onclick: () => nodeAction('POST', `/${name}/undrain`, undefined, `已恢复节点 ${n.name}`) }, '恢复')
+          : el('button', { title: '不再分配新请求, 已有的播放不受影响', onclick: () => nodeAction('POST', `/${name}/drain`, undefined, `已排空节点 ${n.name}`) }, '排空')),
+        el('button', {
+          class: 'danger',
+          onclick: () => {
+            if (confirm(`确定删除节点 ${n.name}?`)) nodeAction('DELETE', `/${name}`, undefined, `已删除节点 ${n.name}`);
+          },
+        }, '删除'),
+      ];
+      return el('tr', { class: n.enabled ? '' : 'muted' },
+        el('td', {}, n.name),
+        el('td', {}, n.host),
+        el('td', {}, n.weight),
+        el('td', {}, nodeBadge(n), n.enabled ? sparkline(n.history) : null),
+        el('td', {}, n.enabled ? formatAgo(n.last_check) : '-'),
+        el('td', { class: 'actions' }, actions));
+    });
+    fillTable($('nodes-body'), rows, 6, '未配置任何节点');
+  }
+
+  $('node-add').addEventListener('submit', async (e) => {
+    e.preventDefault();
+    const f = e.target.elements;
+    const body = { host: f.host.value.trim(), weight: Number(f.weight.value) || 100 };
+    if (f['name'].value.trim()) body.name = f['name'].value.trim();
+    await nodeAction('POST', '', body, `已添加节点 ${body.host}`);
+    f.host.value = '';
+    f['name'].value = '';
+  });
+
+  // ---------- 播放会话 ----------
+
+  async function loadSessions() {
+    const { data } = await api('GET', '/sessions');
+    const rows = data.map((s) => {
+      const percent = s.runtime ? Math.min(100, s.position / s.runtime * 100) : 0;
+      const bar = el('span');
+      bar.style.width = percent.toFixed(1) + '%';
+      return el('tr', {},
+        el('td', {}, s.user),
+        el('td', {}, `${s.client} / ${s.device}`),
+        el('td', {}, s.remote_ip),
+        el('td', { class: 'wrap' }, s.item),
+        el('td', {}, el('span', { class: 'progress' }, bar), formatSeconds(s.position),
+          s.runtime ? ' / ' + formatSeconds(s.runtime) : '', s.paused ? el('span', { class: 'badge warn' }, '已暂停') : null),
+        el('td', {}, s.play_method));
+    });
+    fillTable($('sessions-body'), rows, 6, '当前没有正在播放的会话');
+  }
+
+  // ---------- 缓存 ----------
+
+  function card(title, value, note) {
+    return el('div', { class: 'card' }, el('small', {}, title), el('b', {}, value), note ? el('small', {}, note) : null);
+  }
+
+  async function purgeCache(query, desc) {
+    try {
+      const { data } = await api('POST', '/cache/purge?' + new URLSearchParams(query));
+      showMessage(`已清除${desc} ${data.purged} 个缓存`);
+    } catch (err) {
+      fail(err);
+    }
+    await loadCache().catch(fail);
+  }
+
+  async function loadCache() {
+    const [{ data: stats }, { data: spaces }] = await Promise.all([api('GET', '/cache/stats'), api('GET', '/cache/spaces')]);
+    const evictions = Object.entries(stats.evictions || {}).map(([k, v]) => `${k}: ${v}`).join(', ');
+    $('cache-stats').replaceChildren(
+      card('缓存个数', stats.entries, stats.max_entries ? '上限 ' + stats.max_entries : ''),
+      card('占用空间', formatBytes(stats.bytes), stats.max_bytes ? '上限 ' + formatBytes(stats.max_bytes) : ''),
+      card('命中率', (stats.hit_ratio * 100).toFixed(1) + '%', `命中 ${stats.hits} / 未命中 ${stats.misses}`),
+      card('淘汰', Object.values(stats.evictions || {}).reduce((a, b) => a + b, 0), evictions),
+      stats.disk ? card('磁盘缓存', stats.disk.entries, formatBytes(stats.disk.bytes) + (stats.disk.loaded ? '' : ' (扫描中)')) : null);
+
+    const rows = spaces.map((s) => el('tr', {},
+      el('td', {}, s.name),
+      el('td', {}, s.entries),
+      el('td', {}, formatBytes(s.bytes)),
+      el('td', {}, s.hits),
+      el('td', {}, s.disk_entries),
+      el('td', { class: 'actions' }, s.name === '_default' ? null : el('button', {
+        class: 'danger',
+        onclick: () => {
+          if (confirm(`确定清除缓存空间 ${s.name} 中的所有缓存?`)) purgeCache({ space: s.name }, `缓存空间 ${s.name} 中的`);
+        },
+      }, '清除'))));
+    fillTable($('cache-body'), rows, 6, '暂无缓存');
+  }
+
+  $('cache-purge-item').addEventListener('submit', (e) => {
+    e.preventDefault();
+    const id = e.target.item_id.value.trim();
+    purgeCache({ item_id: id }, `项目 ${id} 的`);
+    e.target.item_id.value = '';
+  });
+
+  $('cache-purge-all').addEventListener('click', () => {
+    if (confirm('确定清除全部缓存?')) purgeCache({ all: 'true' }, '全部');
+  });
+
+  // ---------- 审计日志 ----------
+
+  function renderAudit() {
+    const keyword = $('audit-filter').value.trim().toLowerCase();
+    const list = keyword
+      ? auditEvents.filter((e) => [e.user, e.item_id, e.node, e.decision, e.route, e.uri, e.remote_ip]
+        .some((v) => v && String(v).toLowerCase().includes(keyword)))
+      : auditEvents;
+    const rows = list.map((e) => el('tr', {},
+      el('td', {}, formatTime(e.timestamp)),
+      el('td', {}, e.user || e.remote_ip),
+      el('td', {}, e.route),
+      el('td', {}, el('span', { class: 'badge' + (e.decision === 'redirect' ? ' ok' : e.error ? ' fail' : '') }, e.decision),
+        e.cache_hit ? el('span', { class: 'badge' }, '缓存') : null),
+      el('td', {}, e.node || '-'),
+      el('td', {}, e.status),
+      el('td', {}, Math.round(e.latency / 1e6) + 'ms'),
+      el('td', { class: 'wrap', title: e.error || e.uri }, e.emby_path || e.uri)));
+    fillTable($('audit-body'), rows, 8, '暂无审计事件');
+  }
+
+  async function loadAudit() {
+    const { data } = await api('GET', '/audit?limit=200');
+    auditEvents = data.events;
+    $('audit-summary').textContent = data.enabled ? `最近 ${auditEvents.length} 条` : '审计日志未启用 (audit.enable)';
+    renderAudit();
+  }
+
+  $('audit-filter').addEventListener('input', renderAudit);
+
+  // ---------- 路径映射 ----------
+
+  $('map-form').addEventListener('submit', async (e) => {
+    e.preventDefault();
+    const result = $('map-result');
+    try {
+      const { data } = await api('GET', '/map?' + new URLSearchParams({ path: e.target.path.value.trim() }));
+      if (!data.matched) {
+        result.replaceChildren(el('p', { class: 'error' }, '未命中任何 path.emby2nginx 映射'));
+        return;
+      }
+      const location = data.line > 0 ? `${data.key} (${data.file} 第 ${data.line} 行)` : data.key;
+      const nodes = data.nodes.map((n) => el('tr', { class: n.enabled ? '' : 'muted' },
+        el('td', {}, n.name), el('td', { class: 'wrap' }, n.url), el('td', {}, n.enabled ? '' : '已禁用')));
+      const tbody = el('tbody');
+      fillTable(tbody, nodes, 3, '未配置任何节点');
+      result.replaceChildren(
+        el('dl', {},
+          el('dt', {}, '命中映射'), el('dd', {}, data.rule),
+          el('dt', {}, '配置位置'), el('dd', {}, location),
+          el('dt', {}, 'nginx 路径'), el('dd', {}, data.nginx_path)),
+        el('table', {}, el('thead', {}, el('tr', {}, el('th', {}, '节点'), el('th', {}, '地址'), el('th', {}, ''))), tbody));
+    } catch (err) {
+      fail(err);
+    }
+  });
+
+  // ---------- 启动 ----------
+
+  // 使用客户端证书访问或已登录时直接进入面板, 未登录时 api 已跳转到登录页
+  api('GET', '/nodes').then(showApp, (err) => {
+    if (err.status) showApp();
+  });
+})();
@@ -1,93 +1,178 @@
 package web
 
 import (
+	"context"
 	"crypto/tls"
-	"log"
+	"errors"
+	"fmt"
 	"net/http"
+	"slices"
+	"sync"
 
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/config"
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/service/emby"
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/logs"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/reqids"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/sockets"
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/web/cache"
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/web/webport"
 
 	"github.com/gin-gonic/gin"
 )
 
+var (
+	// servers 正在运行的服务, 退出时统一关闭
+	servers   []*http.Server
+	serversMu sync.Mutex
+)
+
 // Listen 监听指定端口
+//
+// 所有端口监听成功后通知旧进程退出 (平滑升级), 之后阻塞直到服务异常或调用 Shutdown
 func Listen() error {
 	initRulePatterns()
 
-	errChanHTTP, errChanHTTPS := make(chan error, 1), make(chan error, 1)
-	if !config.C.Ssl.Enable {
-		go listenHTTP(errChanHTTP)
-	} else if config.C.Ssl.SinglePort {
-		go listenHTTPS(errChanHTTPS)
-	} else {
-		go listenHTTP(errChanHTTP)
-		go listenHTTPS(errChanHTTPS)
+	var serves []func() error
+	if !config.C().Ssl.Enable || !config.C().Ssl.SinglePort {
+		serve, err := listenHTTP()
+		if err != nil {
+			return fmt.Errorf("http 服务异常: %v", err)
+		}
+		serves = append(serves, serve)
 	}
+	if config.C().Ssl.Enable {
+		serve, err := listenHTTPS()
+		if err != nil {
+			return fmt.Errorf("https 服务异常: %v", err)
+		}
+		serves = append(serves, serve)
+	}
+	sockets.Ready()
 
-	select {
-	case err := <-errChanHTTP:
-		log.Fatal("http 服务异常: ", err)
-	case err := <-errChanHTTPS:
-		log.Fatal("https 服务异常: ", err)
+	errChan := make(chan error, len(serves))
+	for _, serve := range serves {
+		go func() { errChan <- serve() }()
+	}
+	for range serves {
+		if err := <-errChan; !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
 	}
 	return nil
 }
 
+// Shutdown 停止所有服务 (包括鉴权服务器): 关闭监听端口, 等待处理中的请求完成
+//
+// ctx 结束时仍未完成的连接会被强制关闭, 并返回错误
+func Shutdown(ctx context.Context) error {
+	serversMu.Lock()
+	list := slices.Clone(servers)
+	serversMu.Unlock()
+
+	errs := make([]error, len(list))
+	var wg sync.WaitGroup
+	for i, srv := range list {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := srv.Shutdown(ctx); err != nil {
+				srv.Close()
+				errs[i] = err
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// trackServer 记录运行中的服务, 退出时由 Shutdown 关闭
+func trackServer(srv *http.Server) {
+	serversMu.Lock()
+	defer serversMu.Unlock()
+	servers = append(servers, srv)
+}
+
 // initRouter 初始化路由引擎
 func initRouter(r *gin.Engine) {
 	r.Use(referrerPolicySetter())
+	r.Use(adminApiInterceptor())
+	r.Use(emby.IdentityResolver())
 	r.Use(emby.ApiKeyChecker())
+	r.Use(emby.AuditRecorder())
 	r.Use(emby.DownloadStrategyChecker())
-	if config.C.Cache.Enable {
+	if config.C().Cache.Enable {
 		r.Use(cache.CacheableRouteMarker())
-		r.Use(cache.RequestCacher())
+		r.Use(cache.RequestCacher(r))
 	}
 	initRoutes(r)
 }
 
 // listenHTTP 在指定端口上监听 http 服务
 //
-// 出现错误时, 会写入 errChan 中
-func listenHTTP(errChan chan error) {
+// 监听成功后返回阻塞提供服务的函数
+func listenHTTP() (func() error, error) {
 	r := gin.New()
+	r.ContextWithFallback = true // 使 *gin.Context 可以直接作为请求的 context 使用
 	r.Use(gin.Recovery())
+	r.Use(reqids.Middleware())
 	r.Use(CustomLogger(webport.HTTP))
 	r.Use(func(c *gin.Context) {
 		c.Set(webport.GinKey, webport.HTTP)
 	})
 	initRouter(r)
+
+	ln, err := sockets.Listen("0.0.0.0:" + webport.HTTP)
+	if err != nil {
+		return nil, err
+	}
+	srv := &http.Server{Handler: r.Handler()}
+	trackServer(srv)
 	logs.Info("在端口【%s】上启动 HTTP 服务", webport.HTTP)
-	err := r.Run("0.0.0.0:" + webport.HTTP)
-	errChan <- err
-	close(errChan)
+	return func() error { return srv.Serve(ln) }, nil
 }
 
 // listenHTTPS 在指定端口上监听 https 服务
 //
-// 出现错误时, 会写入 errChan 中
-func listenHTTPS(errChan chan error) {
+// 监听成功后返回阻塞提供服务的函数
+func listenHTTPS() (func() error, error) {
 	r := gin.New()
+	r.ContextWithFallback = true // 使 *gin.Context 可以直接作为请求的 context 使用
 	r.Use(gin.Recovery())
+	r.Use(reqids.Middleware())
 	r.Use(CustomLogger(webport.HTTPS))
 	r.Use(func(c *gin.Context) {
 		c.Set(webport.GinKey, webport.HTTPS)
 	})
 	initRouter(r)
-	logs.Info("在端口【%s】上启动 HTTPS 服务", webport.HTTPS)
-	ssl := config.C.Ssl
+	ssl := config.C().Ssl
+
+	cert, err := tls.LoadX509KeyPair(ssl.CrtPath(), ssl.KeyPath())
+	if err != nil {
+		return nil, fmt.Errorf("加载证书失败: %v", err)
+	}
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{cert}}
+
+	// 管理接口使用客户端证书鉴权时校验客户端提供的证书, 不提供证书的普通客户端不受影响
+	if a := config.C().AdminApi; a.Enable && a.ClientCa != "" {
+		pool, err := a.ClientCaPool()
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.ClientAuth, tlsConfig.ClientCAs = tls.VerifyClientCertIfGiven, pool
+	}
 
 	srv := &http.Server{
-		Addr:    "0.0.0.0:" + webport.HTTPS,
-		Handler: r,
+		Handler:   r.Handler(),
+		TLSConfig: tlsConfig,
 	}
 	// 禁用 HTTP/2
 	srv.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
 
-	err := srv.ListenAndServeTLS(ssl.CrtPath(), ssl.KeyPath())
-	errChan <- err
-	close(errChan)
+	ln, err := sockets.Listen("0.0.0.0:" + webport.HTTPS)
+	if err != nil {
+		return nil, err
+	}
+	trackServer(srv)
+	logs.Info("在端口【%s】上启动 HTTPS 服务", webport.HTTPS)
+	return func() error { return srv.ServeTLS(ln, "", "") }, nil
 }
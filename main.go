@@ -1,35 +1,67 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/config"
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/constant"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/service/audit"
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/service/emby"
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/service/node"
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/service/telegram"
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/service/userkey"
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/logs"
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/logs/colors"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/metrics"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/util/sockets"
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/web"
+	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/web/cache"
 	"github.com/AmbitiousJun/go-emby2openlist/v2/internal/web/webport"
 	"github.com/gin-gonic/gin"
 )
 
 var ginMode = gin.DebugMode
 
-func main() {
-	go func() { http.ListenAndServe(":60360", nil) }()
+// shutdownTimeout 退出时等待处理中的请求完成的最长时间
+var shutdownTimeout time.Duration
+
+// debugAddr pprof 及 Prometheus 指标的监听地址, 为空时不监听
+var debugAddr string
 
+func main() {
 	dataRoot := parseFlag()
 
+	// 子命令执行完成后直接退出, 不启动服务
+	if flag.NArg() > 0 {
+		os.Exit(runCommand(dataRoot, flag.Args()))
+	}
+
+	// pprof 及 Prometheus 指标, 没有鉴权, 默认监听所有地址以兼容旧版本
+	http.Handle("/metrics", metrics.Handler())
+	debugSrv := &http.Server{}
+	if debugAddr != "" {
+		if ln, err := sockets.Listen(debugAddr); err != nil {
+			logs.Error("pprof 及指标接口监听 %s 失败: %v", debugAddr, err)
+		} else {
+			if host, _, _ := net.SplitHostPort(debugAddr); !isLoopback(host) {
+				logs.Warn("pprof 及指标接口没有鉴权, 当前监听 %s, 请确认该端口不会暴露到公网, 只需本机访问时可使用 -debug-addr 127.0.0.1:60360", debugAddr)
+			}
+			go debugSrv.Serve(ln)
+		}
+	}
+
 	if err := config.ReadFromFile(filepath.Join(dataRoot, "config.yml")); err != nil {
 		log.Fatal(err)
 	}
@@ -38,32 +70,77 @@ func main() {
 
 	// 初始化节点健康检查
 	logs.Info("正在初始化节点健康检查模块...")
-	healthChecker := node.NewHealthChecker(config.C.Nodes)
+	healthChecker := node.NewHealthChecker(config.C().Nodes)
+	healthChecker.OnNodeDown(emby.PurgeNodeRedirects)
+	cache.SetNodeChecker(healthChecker.IsSchedulable)
 	go healthChecker.Start()
 
 	// 初始化节点选择器
 	nodeSelector := node.NewSelector(healthChecker)
 
+	// 初始化节点管理, Telegram Bot 及管理接口共用
+	nodeManager := node.NewManager(healthChecker)
+	web.InitAdminApi(nodeManager)
+
 	// 初始化用户 Key 缓存
 	logs.Info("正在初始化用户 Key 缓存模块...")
-	keyCache := userkey.NewCache(config.C.Auth.UserKeyCacheTTL)
+	keyCache := userkey.NewCache(config.C().Auth.UserKeyCacheTTL)
+
+	// 初始化用户身份解析
+	identityResolver := userkey.NewResolver(config.C().Emby, config.C().Auth.UserIdentityTTL)
+	emby.InitIdentity(identityResolver)
 
 	// 初始化重定向模块
-	emby.InitRedirect(nodeSelector, keyCache)
+	emby.InitRedirect(nodeSelector)
+
+	// 初始化重定向审计日志
+	if err := audit.Init(config.C().Audit); err != nil {
+		logs.Error("审计日志初始化失败: %v", err)
+	}
+
+	// 初始化磁盘缓存
+	if config.C().Cache.Enable {
+		if err := cache.InitDisk(config.C().Cache.Disk); err != nil {
+			logs.Error("磁盘缓存初始化失败: %v", err)
+		}
+	}
+
+	// 配置热重载: 收到 SIGHUP 信号或配置文件变更时重新读取配置
+	// 路径映射、缓存规则、策略等配置每次使用时从 config.C() 读取, 替换后即生效
+	config.OnReload(func(e config.ReloadEvent) {
+		if e.Has("nodes") {
+			healthChecker.ReloadNodes(context.Background())
+		}
+		if e.Has("auth") {
+			keyCache.SetTTL(e.New.Auth.UserKeyCacheTTL)
+			identityResolver.SetTTL(e.New.Auth.UserIdentityTTL)
+		}
+		if e.Has("cache") {
+			cache.ApplyConfig()
+		}
+	})
+	config.NotifyReload()
+
+	// 收到 SIGINT/SIGTERM 信号时优雅退出, 收到 SIGUSR2 信号时启动新进程平滑升级
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	sockets.NotifyUpgrade()
+	go config.WatchFile(ctx, config.WatchInterval)
 
 	// 启动鉴权服务器（如果启用）
-	if config.C.Auth.EnableAuthServer {
+	if config.C().Auth.EnableAuthServer {
 		logs.Info("正在启动鉴权服务器...")
-		if err := web.ListenAuthServer(keyCache, healthChecker, nodeSelector); err != nil {
+		if err := web.ListenAuthServer(keyCache, identityResolver, healthChecker, nodeSelector); err != nil {
 			logs.Error("鉴权服务器启动失败: %v", err)
 		}
 	}
 
 	// 启动 Telegram Bot（如果启用）
-	if config.C.Telegram.Enable {
+	var bot *telegram.Bot
+	if config.C().Telegram.Enable {
 		logs.Info("正在启动 Telegram Bot...")
-		bot, err := telegram.NewBot(healthChecker)
-		if err != nil {
+		var err error
+		if bot, err = telegram.NewBot(healthChecker, nodeManager); err != nil {
 			logs.Error("Telegram Bot 启动失败: %v", err)
 		} else {
 			go bot.Start()
@@ -73,8 +150,51 @@ func main() {
 
 	logs.Info("正在启动主服务...")
 	gin.SetMode(ginMode)
-	if err := web.Listen(); err != nil {
-		log.Fatal(colors.ToRed(err.Error()))
+	listenErr := make(chan error, 1)
+	go func() { listenErr <- web.Listen() }()
+
+	exitCode := 0
+	select {
+	case <-ctx.Done():
+		logs.Info("收到退出信号, 正在停止服务, 再次发送信号可强制退出...")
+	case err := <-listenErr:
+		logs.Error("主服务异常: %v", err)
+		exitCode = 1
+	}
+	stop()
+
+	shutdown(bot, healthChecker, debugSrv)
+	os.Exit(exitCode)
+}
+
+// shutdown 优雅退出
+//
+// 先停止接收新请求并等待处理中的请求完成, 再停止后台任务,
+// 最后写入缓冲中的访问日志、审计日志、鉴权统计及磁盘缓存
+func shutdown(bot *telegram.Bot, healthChecker *node.HealthChecker, debugSrv *http.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := web.Shutdown(ctx); err != nil {
+		logs.Warn("等待处理中的请求超时, 已强制关闭剩余连接: %v", err)
+	}
+	debugSrv.Close()
+
+	if bot != nil {
+		bot.Stop()
+	}
+	healthChecker.Stop()
+
+	if err := web.CloseAuthServer(); err != nil {
+		logs.Error("关闭访问日志失败: %v", err)
+	}
+	if err := audit.Close(); err != nil {
+		logs.Error("关闭审计日志失败: %v", err)
+	}
+	cache.FlushDisk()
+
+	logs.Success("服务已停止")
+	if err := logs.CloseFileOutput(); err != nil {
+		fmt.Fprintf(os.Stderr, "关闭日志文件失败: %v\n", err)
 	}
 }
 
@@ -84,6 +204,9 @@ func parseFlag() (dataRoot string) {
 	phs := flag.Int("ps", 8094, "HTTPS 服务监听端口")
 	printVersion := flag.Bool("version", false, "查看程序版本")
 	dr := flag.String("dr", ".", "程序数据根目录")
+	flag.DurationVar(&shutdownTimeout, "shutdown-timeout", 30*time.Second, "退出时等待处理中的请求完成的最长时间")
+	flag.StringVar(&debugAddr, "debug-addr", ":60360", "pprof 及 Prometheus 指标的监听地址, 为空时不监听")
+	flag.Usage = usage
 	flag.Parse()
 
 	if *printVersion {
@@ -108,6 +231,15 @@ func parseFlag() (dataRoot string) {
 	return
 }
 
+// isLoopback 判断监听地址是否只允许本机访问
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
 func printBanner() {
 	fmt.Printf(colors.ToYellow(`
                                  _           ___                        _ _     _   